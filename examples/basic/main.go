@@ -3,7 +3,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -48,9 +47,7 @@ func main() {
 	}
 
 	// Output strict JSON to stdout
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(result); err != nil {
+	if err := result.WriteJSON(os.Stdout, true); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR encoding JSON: %v\n", err)
 		os.Exit(1)
 	}
@@ -1,3 +1,5 @@
 module github.com/sudhanshushekhar/ocr-go-prototype
 
 go 1.25.0
+
+require golang.org/x/image v0.25.0
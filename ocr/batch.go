@@ -0,0 +1,262 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// BatchResult is the outcome of one source of ExtractBatch.
+type BatchResult struct {
+	Source string
+	Result *models.OCRResult
+	Err    error
+}
+
+// Format is the record format ExtractBatchTo writes.
+type Format string
+
+// Batch output formats.
+const (
+	// FormatJSONL writes one JSON object per line: "index" and "source",
+	// then either "result", the OCRResult as written by WriteJSON, or
+	// "error".
+	FormatJSONL Format = "jsonl"
+
+	// FormatCSV writes a header and one summary row per source; see
+	// batchCSVHeader for the columns. Failed sources have only index,
+	// source and error filled in.
+	FormatCSV Format = "csv"
+)
+
+// batchCSVHeader is the header row of FormatCSV.
+var batchCSVHeader = []string{
+	"index", "source", "document_type", "language", "confidence_score",
+	"lines", "model", "prompt_tokens", "eval_tokens", "text", "error",
+}
+
+// ExtractBatch runs OCR on many local file paths or remote URLs. See
+// Client.ExtractBatch.
+func ExtractBatch(ctx context.Context, sources []string, opts ...Option) []BatchResult {
+	return NewClient(opts...).ExtractBatch(ctx, sources)
+}
+
+// ExtractBatch runs OCR on many local file paths or remote URLs and returns
+// one BatchResult per source, in the order of sources. A failed source does
+// not stop the others.
+//
+// Sources go through two stages: up to MaxConcurrentDownloads sources are
+// downloaded or read at once, and up to MaxConcurrency loaded sources are
+// processed by the model at once. Downloads therefore run ahead of the model,
+// but at most MaxConcurrentDownloads loaded sources wait for it. Timeout
+// applies to each download and, separately, to each model pass, starting
+// when the source reaches the model, so time spent waiting for a free worker
+// does not count.
+func (c *Client) ExtractBatch(ctx context.Context, sources []string, opts ...Option) []BatchResult {
+	results := make([]BatchResult, len(sources))
+	runBatch(ctx, c.config(opts...), sources, func(i int, r BatchResult) {
+		results[i] = r
+	})
+	return results
+}
+
+// runBatch runs the ExtractBatch pipeline over sources and calls emit with
+// the index and outcome of each source as soon as it is done. emit is called
+// exactly once per source, from the worker goroutines, so it may be called
+// concurrently for different sources.
+func runBatch(ctx context.Context, cfg *Config, sources []string, emit func(int, BatchResult)) {
+	type loaded struct {
+		index     int
+		requestID string
+		logger    *slog.Logger
+		in        input
+	}
+
+	jobs := make(chan int)
+	ready := make(chan loaded)
+
+	var downloads sync.WaitGroup
+	for range min(cfg.MaxConcurrentDownloads, len(sources)) {
+		downloads.Add(1)
+		go func() {
+			defer downloads.Done()
+			for i := range jobs {
+				start := time.Now()
+				requestID := generateRequestID(cfg)
+				logger := newLogger(requestID, cfg)
+
+				logger.Info("OCR extraction started",
+					slog.String("source", sources[i]),
+				)
+
+				if err := ctx.Err(); err != nil {
+					emit(i, BatchResult{Source: sources[i], Err: NewOCRError("ExtractBatch", requestID, fmt.Errorf("%w: %v", ErrContextCanceled, err))})
+					continue
+				}
+				loadCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+				in, err := load(loadCtx, sources[i], cfg, requestID, logger, start)
+				cancel()
+				if err != nil {
+					emit(i, BatchResult{Source: sources[i], Err: err})
+					continue
+				}
+				ready <- loaded{index: i, requestID: requestID, logger: logger, in: in}
+			}
+		}()
+	}
+
+	var workers sync.WaitGroup
+	for range min(cfg.MaxConcurrency, len(sources)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for l := range ready {
+				r := BatchResult{Source: sources[l.index]}
+				if err := ctx.Err(); err != nil {
+					r.Err = NewOCRError("ExtractBatch", l.requestID, fmt.Errorf("%w: %v", ErrContextCanceled, err))
+					emit(l.index, r)
+					continue
+				}
+				processCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+				r.Result, r.Err = process(processCtx, cfg, l.requestID, l.logger, l.in)
+				cancel()
+				emit(l.index, r)
+			}
+		}()
+	}
+
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+	downloads.Wait()
+	close(ready)
+	workers.Wait()
+}
+
+// ExtractBatchTo runs OCR on many local file paths or remote URLs and
+// streams the results to w. See Client.ExtractBatchTo.
+func ExtractBatchTo(ctx context.Context, sources []string, w io.Writer, format Format, opts ...Option) error {
+	return NewClient(opts...).ExtractBatchTo(ctx, sources, w, format)
+}
+
+// ExtractBatchTo processes sources like ExtractBatch, but instead of
+// holding every result in memory it writes one record per source to w in
+// format as soon as that source is done, so records appear in completion
+// order, not in the order of sources; the "index" of each record is the
+// position of its source. A failed source is written as an error record and
+// does not stop the others. Writes are serialized, so w need not be safe
+// for concurrent use.
+//
+// The error wraps ErrUnknownFormat for an unknown format, in which case
+// nothing is processed, or is the first write error, after which the
+// remaining sources are canceled and not written.
+func (c *Client) ExtractBatchTo(ctx context.Context, sources []string, w io.Writer, format Format, opts ...Option) error {
+	var write func(int, BatchResult) error
+	switch format {
+	case FormatJSONL:
+		write = func(i int, r BatchResult) error { return writeBatchJSONL(w, i, r) }
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := writeCSVRecord(cw, batchCSVHeader); err != nil {
+			return WrapError("ExtractBatchTo", err)
+		}
+		write = func(i int, r BatchResult) error { return writeCSVRecord(cw, batchCSVRow(i, r)) }
+	default:
+		return WrapError("ExtractBatchTo", fmt.Errorf("%w: %q", ErrUnknownFormat, format))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		writeErr error
+	)
+	runBatch(ctx, c.config(opts...), sources, func(i int, r BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if writeErr != nil {
+			return
+		}
+		if err := write(i, r); err != nil {
+			writeErr = err
+			cancel()
+		}
+	})
+	if writeErr != nil {
+		return WrapError("ExtractBatchTo", writeErr)
+	}
+	return nil
+}
+
+// batchRecord is one FormatJSONL line.
+type batchRecord struct {
+	Index  int             `json:"index"`
+	Source string          `json:"source"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// writeBatchJSONL writes the outcome r of source i as one line of JSON.
+func writeBatchJSONL(w io.Writer, i int, r BatchResult) error {
+	rec := batchRecord{Index: i, Source: r.Source}
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+	} else {
+		var buf bytes.Buffer
+		if err := r.Result.WriteJSON(&buf, false); err != nil {
+			return err
+		}
+		rec.Result = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+
+	var line bytes.Buffer
+	enc := json.NewEncoder(&line)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rec); err != nil {
+		return err
+	}
+	_, err := w.Write(line.Bytes())
+	return err
+}
+
+// batchCSVRow returns the FormatCSV row for the outcome r of source i.
+func batchCSVRow(i int, r BatchResult) []string {
+	row := make([]string, len(batchCSVHeader))
+	row[0] = strconv.Itoa(i)
+	row[1] = r.Source
+	if r.Err != nil {
+		row[10] = r.Err.Error()
+		return row
+	}
+	res := r.Result
+	row[2] = string(res.Metadata.DocumentType)
+	row[3] = res.LanguageCode()
+	row[4] = strconv.FormatFloat(res.Metadata.ConfidenceScore, 'f', -1, 64)
+	row[5] = strconv.Itoa(len(res.Text.Lines))
+	row[6] = res.Usage.Model
+	row[7] = strconv.Itoa(res.Usage.PromptTokens)
+	row[8] = strconv.Itoa(res.Usage.EvalTokens)
+	row[9] = res.Text.Raw
+	return row
+}
+
+// writeCSVRecord writes record and flushes it, so it reaches the underlying
+// writer as soon as its source is done.
+func writeCSVRecord(cw *csv.Writer, record []string) error {
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
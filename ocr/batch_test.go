@@ -0,0 +1,285 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+)
+
+// peak tracks the highest number of concurrent calls.
+type peak struct {
+	current, max atomic.Int32
+}
+
+func (p *peak) enter() {
+	n := p.current.Add(1)
+	for {
+		m := p.max.Load()
+		if n <= m || p.max.CompareAndSwap(m, n) {
+			return
+		}
+	}
+}
+
+func (p *peak) leave() { p.current.Add(-1) }
+
+func TestExtractBatch_ConcurrencyLimits(t *testing.T) {
+	var model, downloads peak
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		model.enter()
+		defer model.leave()
+		time.Sleep(20 * time.Millisecond)
+		return http.StatusOK, ollamatest.Response
+	})
+
+	imageData := testPNG(t)
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.enter()
+		defer downloads.leave()
+		time.Sleep(20 * time.Millisecond)
+		w.Write(imageData)
+	}))
+	defer images.Close()
+	proxyURL, _ := url.Parse(images.URL)
+
+	sources := make([]string, 10)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("http://images.example.com/%d.png", i)
+	}
+	sources[3] = ""
+
+	results := ExtractBatch(context.Background(), sources,
+		WithOllamaURL(srv.URL),
+		WithProxy(proxyURL),
+		WithMaxConcurrency(2),
+		WithMaxConcurrentDownloads(3),
+	)
+
+	if len(results) != len(sources) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(sources))
+	}
+	for i, r := range results {
+		if r.Source != sources[i] {
+			t.Errorf("results[%d].Source = %q, want %q", i, r.Source, sources[i])
+		}
+		if i == 3 {
+			if !errors.Is(r.Err, ErrEmptySource) {
+				t.Errorf("results[3].Err = %v, want ErrEmptySource", r.Err)
+			}
+			continue
+		}
+		if r.Err != nil || r.Result == nil {
+			t.Errorf("results[%d] = %v, %v; want a result", i, r.Result, r.Err)
+		}
+	}
+
+	if got := downloads.max.Load(); got > 3 {
+		t.Errorf("concurrent downloads = %d, want at most 3", got)
+	}
+	if got := model.max.Load(); got > 2 {
+		t.Errorf("concurrent model calls = %d, want at most 2", got)
+	}
+	if got := len(srv.Requests()); got != 9 {
+		t.Errorf("model calls = %d, want 9", got)
+	}
+}
+
+func TestExtractBatch_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := ExtractBatch(ctx, []string{"a.png", "b.png"})
+	for i, r := range results {
+		if !errors.Is(r.Err, ErrContextCanceled) {
+			t.Errorf("results[%d].Err = %v, want ErrContextCanceled", i, r.Err)
+		}
+	}
+}
+
+func TestExtractBatch_DownloadTimeout(t *testing.T) {
+	imageData := testPNG(t)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Send the headers and part of the body, then stall
+		w.Write(imageData[:8])
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	start := time.Now()
+	results := ExtractBatch(context.Background(), []string{"http://images.example.com/stalled.png"},
+		WithProxy(proxyURL), WithTimeout(50*time.Millisecond))
+	if results[0].Err == nil {
+		t.Fatal("Err = nil, want the stalled download to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ExtractBatch took %v, want the download cut off by the timeout", elapsed)
+	}
+}
+
+// batchSources returns n image URLs served through a proxy that answers
+// later sources sooner, so results complete out of order, and an empty
+// source at index 1.
+func batchSources(t *testing.T, n int) ([]string, Option) {
+	t.Helper()
+	imageData := testPNG(t)
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i, _ := strconv.Atoi(strings.TrimSuffix(path.Base(r.URL.Path), ".png"))
+		time.Sleep(time.Duration(n-i) * 10 * time.Millisecond)
+		w.Write(imageData)
+	}))
+	t.Cleanup(images.Close)
+	proxyURL, _ := url.Parse(images.URL)
+
+	sources := make([]string, n)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("http://images.example.com/%d.png", i)
+	}
+	sources[1] = ""
+	return sources, WithProxy(proxyURL)
+}
+
+func TestExtractBatchTo_JSONL(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	sources, proxy := batchSources(t, 5)
+
+	var buf bytes.Buffer
+	err := ExtractBatchTo(context.Background(), sources, &buf, FormatJSONL,
+		WithOllamaURL(srv.URL), proxy, WithMaxConcurrentDownloads(5), WithMaxConcurrency(5))
+	if err != nil {
+		t.Fatalf("ExtractBatchTo: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(sources) {
+		t.Fatalf("records = %d, want one per source:\n%s", len(lines), buf.String())
+	}
+	seen := make(map[int]bool)
+	var order []int
+	for _, line := range lines {
+		var rec struct {
+			Index  int               `json:"index"`
+			Source string            `json:"source"`
+			Result *models.OCRResult `json:"result"`
+			Error  string            `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("record %q: %v", line, err)
+		}
+		if seen[rec.Index] {
+			t.Errorf("index %d written twice", rec.Index)
+		}
+		seen[rec.Index] = true
+		order = append(order, rec.Index)
+
+		if rec.Source != sources[rec.Index] {
+			t.Errorf("record %d: Source = %q, want %q", rec.Index, rec.Source, sources[rec.Index])
+		}
+		if rec.Index == 1 {
+			if rec.Result != nil || !strings.Contains(rec.Error, ErrEmptySource.Error()) {
+				t.Errorf("record 1 = %q, want an error record", line)
+			}
+			continue
+		}
+		if rec.Error != "" || rec.Result == nil || rec.Result.Text.Raw == "" {
+			t.Errorf("record %d = %q, want a result", rec.Index, line)
+		}
+	}
+	if slices.IsSorted(order) {
+		t.Errorf("records written in order %v, want completion order", order)
+	}
+}
+
+func TestExtractBatchTo_CSV(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	sources, proxy := batchSources(t, 4)
+
+	var buf bytes.Buffer
+	err := ExtractBatchTo(context.Background(), sources, &buf, FormatCSV,
+		WithOllamaURL(srv.URL), proxy, WithMaxConcurrentDownloads(4), WithMaxConcurrency(4))
+	if err != nil {
+		t.Fatalf("ExtractBatchTo: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(records) != len(sources)+1 || !slices.Equal(records[0], batchCSVHeader) {
+		t.Fatalf("records = %q, want the header and one row per source", records)
+	}
+	var indexes []string
+	for _, row := range records[1:] {
+		indexes = append(indexes, row[0])
+		switch row[0] {
+		case "1":
+			if row[2] != "" || !strings.Contains(row[10], ErrEmptySource.Error()) {
+				t.Errorf("row 1 = %q, want only the error", row)
+			}
+		default:
+			if row[2] != "receipt" || row[3] != "en" || row[6] == "" || row[9] == "" || row[10] != "" {
+				t.Errorf("row %s = %q, want a summary of the result", row[0], row)
+			}
+		}
+	}
+	slices.Sort(indexes)
+	if want := []string{"0", "1", "2", "3"}; !slices.Equal(indexes, want) {
+		t.Errorf("indexes = %q, want %q", indexes, want)
+	}
+}
+
+// failingWriter fails every write after the first n.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestExtractBatchTo_Errors(t *testing.T) {
+	err := ExtractBatchTo(context.Background(), []string{"a.png"}, io.Discard, "xml")
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v, want ErrUnknownFormat", err)
+	}
+
+	// The CSV header fails to write
+	err = ExtractBatchTo(context.Background(), []string{"a.png"}, &failingWriter{}, FormatCSV)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("err = %v, want the write error", err)
+	}
+
+	// The first record is written, the second fails and the rest are
+	// abandoned
+	err = ExtractBatchTo(context.Background(), []string{"", "", ""}, &failingWriter{n: 1}, FormatJSONL,
+		WithMaxConcurrentDownloads(1))
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("err = %v, want the write error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops calls to an Ollama server that keeps failing to
+// connect. After threshold consecutive connection failures it opens and
+// rejects calls for cooldown; the first call after that is let through as a
+// probe, and closes the breaker again if it succeeds. A nil breaker allows
+// every call. It is safe for concurrent use.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// newCircuitBreaker returns a breaker, or nil if threshold is 0.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns an error if the breaker is open. Once cooldown has passed it
+// admits a single probe at a time; the caller must report its outcome with
+// record.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+	if wait := time.Until(b.openUntil); wait > 0 {
+		return fmt.Errorf("circuit open after %d consecutive connection failures, retrying in %s",
+			b.failures, wait.Round(time.Millisecond))
+	}
+	if b.probing {
+		return fmt.Errorf("circuit open after %d consecutive connection failures, probe in progress", b.failures)
+	}
+	b.probing = true
+	return nil
+}
+
+// record reports the outcome of an allowed call. A nil err closes the
+// breaker, a connection failure counts towards opening it, and other errors
+// (the server answered, or ctx ended) leave the count unchanged.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case err == nil:
+		b.failures = 0
+	case ctx.Err() == nil && isConnectionError(err):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	}
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached or dropped the connection, as opposed to answering with an error.
+func isConnectionError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,114 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+)
+
+// flakyOllama is a fake Ollama server that can be taken down: while down it
+// drops every connection without answering.
+type flakyOllama struct {
+	*ollamatest.Server
+	front    *httptest.Server
+	down     atomic.Bool
+	downHits atomic.Int32
+}
+
+func newFlakyOllama(t *testing.T, handler ollamatest.Handler) *flakyOllama {
+	f := &flakyOllama{Server: ollamatest.NewServer(t, handler)}
+	backend, _ := url.Parse(f.Server.URL)
+	proxy := httputil.NewSingleHostReverseProxy(backend)
+	f.front = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.down.Load() {
+			f.downHits.Add(1)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	// One request per connection, so a dropped connection is never retried
+	f.front.Config.SetKeepAlivesEnabled(false)
+	t.Cleanup(f.front.Close)
+	return f
+}
+
+func TestExtractBatch_CircuitBreaker(t *testing.T) {
+	var f *flakyOllama
+	var calls atomic.Int32
+	f = newFlakyOllama(t, func(client.GenerateRequest) (int, string) {
+		if calls.Add(1) == 3 {
+			f.down.Store(true) // the server dies after answering this request
+		}
+		return http.StatusOK, ollamatest.Response
+	})
+
+	dir := t.TempDir()
+	sources := make([]string, 10)
+	for i := range sources {
+		sources[i] = filepath.Join(dir, string(rune('a'+i))+".png")
+		if err := os.WriteFile(sources[i], testPNG(t), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := ExtractBatch(context.Background(), sources,
+		WithOllamaURL(f.front.URL),
+		WithMaxConcurrentDownloads(1), // process sources in order
+		WithCircuitBreaker(2, time.Hour),
+	)
+
+	for i, r := range results {
+		if i < 3 {
+			if r.Err != nil {
+				t.Errorf("results[%d].Err = %v, want success before the server went down", i, r.Err)
+			}
+			continue
+		}
+		if !errors.Is(r.Err, ErrOllamaUnavailable) {
+			t.Errorf("results[%d].Err = %v, want ErrOllamaUnavailable", i, r.Err)
+		}
+	}
+	if got := f.downHits.Load(); got != 2 {
+		t.Errorf("requests to the dead server = %d, want 2 before the breaker opened", got)
+	}
+}
+
+func TestClient_CircuitBreakerRecovers(t *testing.T) {
+	f := newFlakyOllama(t, nil)
+	f.down.Store(true)
+	c := NewClient(WithOllamaURL(f.front.URL), WithCircuitBreaker(1, 50*time.Millisecond))
+	data := testPNG(t)
+
+	for range 2 {
+		if _, err := c.ExtractBytes(context.Background(), data, ".png"); !errors.Is(err, ErrOllamaUnavailable) {
+			t.Fatalf("err = %v, want ErrOllamaUnavailable", err)
+		}
+	}
+	if got := f.downHits.Load(); got != 1 {
+		t.Errorf("requests to the dead server = %d, want 1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	f.down.Store(false)
+	if _, err := c.ExtractBytes(context.Background(), data, ".png"); err != nil {
+		t.Fatalf("ExtractBytes after cooldown: %v", err)
+	}
+	if got := len(f.Requests()); got != 1 {
+		t.Errorf("generate requests = %d, want 1 after the probe closed the breaker", got)
+	}
+}
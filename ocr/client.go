@@ -0,0 +1,63 @@
+package ocr
+
+import (
+	"context"
+	"io"
+	"io/fs"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// Client is a reusable OCR client. Options given to NewClient form its base
+// configuration; options given to a single Extract call are applied on top of
+// a copy of that base and never leak into later calls.
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	cfg *Config
+}
+
+// NewClient creates a Client with the given base options applied over the defaults.
+func NewClient(opts ...Option) *Client {
+	cfg := DefaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg.breaker = newCircuitBreaker(cfg.CircuitBreakerThreshold, cfg.CircuitBreakerCooldown)
+	cfg.formats = client.NewFormatCache(0)
+	return &Client{cfg: cfg}
+}
+
+// Extract runs OCR on a local file path or remote URL. Per-call options
+// override the client's base configuration for this call only.
+func (c *Client) Extract(ctx context.Context, source string, opts ...Option) (*models.OCRResult, error) {
+	return extract(ctx, source, c.config(opts...))
+}
+
+// ExtractBytes runs OCR on in-memory image data with the given extension.
+// Per-call options override the client's base configuration for this call only.
+func (c *Client) ExtractBytes(ctx context.Context, data []byte, ext string, opts ...Option) (*models.OCRResult, error) {
+	return extractBytes(ctx, data, ext, c.config(opts...))
+}
+
+// ExtractReader runs OCR on image data read from r with the given extension.
+// Per-call options override the client's base configuration for this call only.
+func (c *Client) ExtractReader(ctx context.Context, r io.Reader, ext string, opts ...Option) (*models.OCRResult, error) {
+	return extractReader(ctx, r, ext, c.config(opts...))
+}
+
+// ExtractFS runs OCR on the file name in fsys. Per-call options override the
+// client's base configuration for this call only.
+func (c *Client) ExtractFS(ctx context.Context, fsys fs.FS, name string, opts ...Option) (*models.OCRResult, error) {
+	return extractFS(ctx, fsys, name, c.config(opts...))
+}
+
+// config returns a copy of the base config with per-call options applied.
+func (c *Client) config(opts ...Option) *Config {
+	cfg := c.cfg.Clone()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
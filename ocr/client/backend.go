@@ -0,0 +1,36 @@
+package client
+
+import "context"
+
+// VisionBackend is a vision model server. Requests and responses use the
+// Ollama shapes; other backends translate them to their own API.
+type VisionBackend interface {
+	// Generate sends a prompt with images and returns the model's answer.
+	Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error)
+
+	// Ping checks that the server is reachable.
+	Ping(ctx context.Context) error
+}
+
+// StreamingBackend is implemented by backends that can stream the model's
+// answer as it is generated.
+type StreamingBackend interface {
+	// GenerateStream works like Generate but calls onChunk with each piece
+	// of the answer as it arrives.
+	GenerateStream(ctx context.Context, req GenerateRequest, onChunk func(string)) (*GenerateResponse, error)
+}
+
+// ModelLister is implemented by backends that can list the models they
+// serve.
+type ModelLister interface {
+	// ListModels returns the names of the available models.
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+var (
+	_ VisionBackend    = (*OllamaClient)(nil)
+	_ VisionBackend    = (*OpenAICompatClient)(nil)
+	_ ModelLister      = (*OllamaClient)(nil)
+	_ StreamingBackend = (*OllamaClient)(nil)
+	_ ModelLister      = (*OpenAICompatClient)(nil)
+)
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBodySize caps the buffers kept in bodyPool so one huge request
+// does not pin its buffer in memory.
+const maxPooledBodySize = 16 << 20
+
+// bodyPool holds buffers for JSON request bodies, which carry base64 images
+// and are the largest allocation per request.
+var bodyPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// jsonBody is a request body backed by a pooled buffer. The buffer returns
+// to the pool when the HTTP transport closes the body, which it may do after
+// the round trip has returned.
+type jsonBody struct {
+	*bytes.Reader
+	buf  *bytes.Buffer
+	once sync.Once
+}
+
+// newJSONBody encodes v as JSON into a pooled buffer.
+func newJSONBody(v any) (*jsonBody, error) {
+	buf := bodyPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		bodyPool.Put(buf)
+		return nil, err
+	}
+	return &jsonBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}, nil
+}
+
+// Len returns the encoded body size.
+func (b *jsonBody) Len() int64 {
+	return b.Reader.Size()
+}
+
+// Close returns the buffer to the pool. The body must not be read after.
+func (b *jsonBody) Close() error {
+	b.once.Do(func() {
+		if b.buf.Cap() <= maxPooledBodySize {
+			bodyPool.Put(b.buf)
+		}
+	})
+	return nil
+}
@@ -1,29 +1,150 @@
-// Package client provides an HTTP client for the Ollama API.
+// Package client provides HTTP clients for vision model APIs: Ollama and
+// OpenAI-compatible servers such as llama.cpp.
 package client
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrModelNotFound is returned by Generate when the server does not have
+// the requested model.
+var ErrModelNotFound = errors.New("model not found")
+
+// ErrFormatNotSupported is returned by the server when a model or Ollama
+// version cannot constrain its answer to GenerateRequest.Format. Generate
+// handles it by retrying without Format, so callers only see it if that
+// fails too.
+var ErrFormatNotSupported = errors.New("format not supported")
+
+// DefaultFormatCacheTTL is how long a FormatCache remembers that a model
+// rejected GenerateRequest.Format, so an upgraded model or server is asked
+// with it again.
+const DefaultFormatCacheTTL = time.Hour
+
+// FormatCache remembers the models, by server, that rejected
+// GenerateRequest.Format, so they are asked without it until the entry
+// expires. A FormatCache is safe for concurrent use and may be shared by
+// several clients.
+type FormatCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[formatKey]time.Time // expiry
+}
+
+// formatKey identifies a model on one server.
+type formatKey struct {
+	baseURL, model string
+}
+
+// NewFormatCache returns an empty FormatCache whose entries expire after
+// ttl. A ttl <= 0 uses DefaultFormatCacheTTL.
+func NewFormatCache(ttl time.Duration) *FormatCache {
+	if ttl <= 0 {
+		ttl = DefaultFormatCacheTTL
+	}
+	return &FormatCache{ttl: ttl, entries: make(map[formatKey]time.Time)}
+}
+
+// Reset forgets every model, so each is asked with Format again.
+func (fc *FormatCache) Reset() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	clear(fc.entries)
+}
+
+// unsupported reports whether key rejected Format and has not expired.
+func (fc *FormatCache) unsupported(key formatKey) bool {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	expiry, ok := fc.entries[key]
+	if ok && !time.Now().Before(expiry) {
+		delete(fc.entries, key)
+		return false
+	}
+	return ok
+}
+
+// markUnsupported records that key rejected Format.
+func (fc *FormatCache) markUnsupported(key formatKey) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.entries[key] = time.Now().Add(fc.ttl)
+}
+
 // OllamaClient is an HTTP client for the Ollama vision API.
 type OllamaClient struct {
 	baseURL    string
 	httpClient *http.Client
+	formats    *FormatCache
+}
+
+// defaultTransport is shared by all clients so idle connections to Ollama are
+// reused across Extract calls instead of being re-dialed each time.
+var defaultTransport = newDefaultTransport()
+
+// newDefaultTransport returns an http.Transport tuned for many requests to a
+// single local host.
+func newDefaultTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = 32
+	t.IdleConnTimeout = 90 * time.Second
+	t.ForceAttemptHTTP2 = true
+	return t
+}
+
+// ClientOption configures the HTTP client of a backend.
+type ClientOption func(*http.Client)
+
+// WithTransport overrides the HTTP transport used for model requests.
+// A nil transport keeps the default.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *http.Client) {
+		if rt != nil {
+			c.Transport = rt
+		}
+	}
+}
+
+// newHTTPClient returns an HTTP client with the given timeout on the shared
+// default transport, with opts applied.
+func newHTTPClient(timeout time.Duration, opts []ClientOption) *http.Client {
+	c := &http.Client{
+		Timeout:   timeout,
+		Transport: defaultTransport,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // NewOllamaClient creates a new OllamaClient with the given base URL and timeout.
-func NewOllamaClient(baseURL string, timeout time.Duration) *OllamaClient {
+// The timeout bounds each HTTP request, independent of the transport in use.
+// The client has its own FormatCache; see SetFormatCache to share one.
+func NewOllamaClient(baseURL string, timeout time.Duration, opts ...ClientOption) *OllamaClient {
 	return &OllamaClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		baseURL:    baseURL,
+		httpClient: newHTTPClient(timeout, opts),
+		formats:    NewFormatCache(0),
+	}
+}
+
+// SetFormatCache makes the client use fc, e.g. one shared by the clients of
+// a long-lived caller, so a model that rejected Format is not probed again
+// by each new client. A nil fc keeps the current cache.
+func (c *OllamaClient) SetFormatCache(fc *FormatCache) {
+	if fc != nil {
+		c.formats = fc
 	}
 }
 
@@ -35,12 +156,53 @@ type GenerateRequest struct {
 	Stream  bool          `json:"stream"`
 	Options *ModelOptions `json:"options,omitempty"`
 	Format  string        `json:"format,omitempty"`
+
+	// Logprobs asks for the log probability of each generated token.
+	// Servers that do not support it ignore it.
+	Logprobs bool `json:"logprobs,omitempty"`
+}
+
+// Redacted returns a copy of the request that is safe to log: each image is
+// replaced by a "[N bytes base64]" placeholder and the prompt is truncated to
+// maxPromptLen characters (0 means no truncation). The client sends no auth
+// headers, so the request body is the only thing that needs redaction.
+func (r GenerateRequest) Redacted(maxPromptLen int) GenerateRequest {
+	out := r
+	if len(r.Images) > 0 {
+		out.Images = make([]string, len(r.Images))
+		for i, img := range r.Images {
+			out.Images[i] = fmt.Sprintf("[%d bytes base64]", len(img))
+		}
+	}
+	if maxPromptLen > 0 {
+		// Cut at a rune boundary so the logged prompt stays valid UTF-8
+		n := 0
+		for i := range r.Prompt {
+			if n == maxPromptLen {
+				out.Prompt = r.Prompt[:i] + "..."
+				break
+			}
+			n++
+		}
+	}
+	return out
 }
 
 // ModelOptions holds model-level options for Ollama.
 type ModelOptions struct {
-	Temperature float64 `json:"temperature"`
-	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature float64  `json:"temperature"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// Seed, if set, makes sampling reproducible and lets calls with the
+	// same prompt differ only by seed.
+	Seed *int `json:"seed,omitempty"`
+
+	// NumGPU and NumThread are pointers so that an explicit 0 (e.g. CPU-only
+	// inference) is sent while an unset value leaves Ollama's default.
+	NumGPU    *int `json:"num_gpu,omitempty"`
+	NumThread *int `json:"num_thread,omitempty"`
 }
 
 // GenerateResponse is the response from the Ollama /api/generate endpoint (non-streaming).
@@ -55,43 +217,190 @@ type GenerateResponse struct {
 	PromptEvalDuration int64  `json:"prompt_eval_duration"`
 	EvalCount          int    `json:"eval_count"`
 	EvalDuration       int64  `json:"eval_duration"`
+
+	// Logprobs holds the generated tokens with their log probabilities, in
+	// order, if they were requested and the server supports them.
+	Logprobs []TokenLogprob `json:"logprobs,omitempty"`
+}
+
+// TokenLogprob is a generated token and its natural log probability.
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
 }
 
 // Generate sends a vision request to Ollama and returns the raw response.
+// An empty 200 body, which Ollama sends on some errors, yields a response
+// with an empty Response field rather than an error.
 func (c *OllamaClient) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
-	body, err := json.Marshal(req)
+	req.Stream = false
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var genResp GenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return &genResp, nil
+}
+
+// GenerateStream sends a vision request to Ollama with streaming enabled and
+// calls onChunk with each piece of the answer as it arrives. The returned
+// response holds the whole answer and the final token counts.
+func (c *OllamaClient) GenerateStream(ctx context.Context, req GenerateRequest, onChunk func(string)) (*GenerateResponse, error) {
+	req.Stream = true
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		answer   strings.Builder
+		logprobs []TokenLogprob
+		final    GenerateResponse
+	)
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk struct {
+			GenerateResponse
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unmarshal stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		if chunk.Response != "" {
+			answer.WriteString(chunk.Response)
+			onChunk(chunk.Response)
+		}
+		logprobs = append(logprobs, chunk.Logprobs...)
+		if chunk.Done {
+			final = chunk.GenerateResponse
+			break
+		}
+	}
+
+	final.Response = answer.String()
+	final.Logprobs = logprobs
+	return &final, nil
+}
+
+// post sends req to /api/generate and returns the response if its status is
+// 200 OK. The caller must close the body. If the model rejects req.Format,
+// the request is sent once more without it, relying on the prompt alone,
+// and the model is remembered in the client's FormatCache so later requests
+// leave Format out at once.
+func (c *OllamaClient) post(ctx context.Context, req GenerateRequest) (*http.Response, error) {
+	if req.Format == "" {
+		return c.send(ctx, req)
+	}
+	key := formatKey{baseURL: c.baseURL, model: req.Model}
+	if c.formats.unsupported(key) {
+		req.Format = ""
+		return c.send(ctx, req)
+	}
+
+	resp, err := c.send(ctx, req)
+	if !errors.Is(err, ErrFormatNotSupported) {
+		return resp, err
+	}
+	c.formats.markUnsupported(key)
+	req.Format = ""
+	return c.send(ctx, req)
+}
+
+// send posts req to /api/generate as is; see post.
+func (c *OllamaClient) send(ctx context.Context, req GenerateRequest) (*http.Response, error) {
+	body, err := newJSONBody(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/api/generate", c.baseURL)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
 	if err != nil {
+		body.Close()
 		return nil, fmt.Errorf("create request: %w", err)
 	}
+	httpReq.ContentLength = body.Len()
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+		if msg, ok := modelNotFound(resp.StatusCode, respBody); ok {
+			return nil, fmt.Errorf("%w: %s (run `ollama pull %s`)", ErrModelNotFound, msg, req.Model)
+		}
+		if msg, ok := formatNotSupported(resp.StatusCode, respBody); ok && req.Format != "" {
+			return nil, fmt.Errorf("%w: %s", ErrFormatNotSupported, msg)
+		}
 		return nil, fmt.Errorf("ollama API returned HTTP %d: %s", resp.StatusCode, string(respBody))
 	}
+	return resp, nil
+}
 
-	var genResp GenerateResponse
-	if err := json.Unmarshal(respBody, &genResp); err != nil {
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+// modelNotFound reports whether an error response means the model is not
+// pulled, returning Ollama's message. Ollama answers with a 404 and a body
+// like {"error":"model 'x' not found, try pulling it first"}.
+func modelNotFound(status int, body []byte) (string, bool) {
+	if status != http.StatusNotFound {
+		return "", false
+	}
+	var errResp struct {
+		Error string `json:"error"`
 	}
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == "" {
+		return "", false
+	}
+	msg := strings.ToLower(errResp.Error)
+	if !strings.Contains(msg, "model") || !strings.Contains(msg, "not found") {
+		return "", false
+	}
+	return errResp.Error, true
+}
 
-	return &genResp, nil
+// formatNotSupported reports whether an error response means the model or
+// server cannot use the requested format, returning Ollama's message. Older
+// servers fail to decode the field, e.g. {"error":"json: cannot unmarshal
+// string into Go struct field GenerateRequest.format ..."}; others answer
+// like {"error":"model does not support format"}.
+func formatNotSupported(status int, body []byte) (string, bool) {
+	if status != http.StatusBadRequest && status != http.StatusInternalServerError {
+		return "", false
+	}
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == "" {
+		return "", false
+	}
+	msg := strings.ToLower(errResp.Error)
+	if !strings.Contains(msg, "format") {
+		return "", false
+	}
+	for _, s := range []string{"not support", "unsupported", "cannot unmarshal", "invalid format"} {
+		if strings.Contains(msg, s) {
+			return errResp.Error, true
+		}
+	}
+	return "", false
 }
 
 // Ping checks if the Ollama server is available.
@@ -113,3 +422,38 @@ func (c *OllamaClient) Ping(ctx context.Context) error {
 	}
 	return nil
 }
+
+// ListModels returns the names of the models pulled on the Ollama server,
+// including their tag, e.g. "llama3.2-vision:latest".
+func (c *OllamaClient) ListModels(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/api/tags", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create list models request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned HTTP %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("unmarshal models: %w", err)
+	}
+
+	names := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
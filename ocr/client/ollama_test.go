@@ -3,10 +3,14 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestOllamaClient_Generate(t *testing.T) {
@@ -85,6 +89,251 @@ func TestOllamaClient_Generate_ServerError(t *testing.T) {
 	}
 }
 
+func TestOllamaClient_Generate_ModelNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model 'llava:13b' not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOllamaClient(server.URL, 10*time.Second).Generate(context.Background(), GenerateRequest{Model: "llava:13b"})
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("err = %v, want ErrModelNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "ollama pull llava:13b") {
+		t.Errorf("err = %v, want the pull command", err)
+	}
+}
+
+func TestOllamaClient_Generate_Other404(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	_, err := NewOllamaClient(server.URL, 10*time.Second).Generate(context.Background(), GenerateRequest{Model: "m"})
+	if err == nil || errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("err = %v, want a plain HTTP 404 error", err)
+	}
+}
+
+func TestOllamaClient_Generate_FormatNotSupported(t *testing.T) {
+	var formats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		formats = append(formats, req.Model+":"+req.Format)
+		if req.Format != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"json: cannot unmarshal string into Go struct field GenerateRequest.format of type bool"}`))
+			return
+		}
+		w.Write([]byte(`{"model":"old","response":"{}","done":true}`))
+	}))
+	defer server.Close()
+
+	req := GenerateRequest{Model: "old", Format: "json"}
+	c := NewOllamaClient(server.URL, 10*time.Second)
+	resp, err := c.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Response != "{}" {
+		t.Errorf("Response = %q, want the answer without format", resp.Response)
+	}
+
+	// Later requests for the model from the same client skip the probe;
+	// other models are still asked with the format
+	if _, err := c.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if _, err := c.GenerateStream(context.Background(), req, func(string) {}); err != nil {
+		t.Fatalf("GenerateStream: %v", err)
+	}
+	c.Generate(context.Background(), GenerateRequest{Model: "new", Format: "json"})
+
+	want := []string{"old:json", "old:", "old:", "old:", "new:json", "new:"}
+	if !slices.Equal(formats, want) {
+		t.Errorf("requests = %q, want %q", formats, want)
+	}
+
+	// A new client has its own cache and probes again
+	formats = nil
+	NewOllamaClient(server.URL, 10*time.Second).Generate(context.Background(), req)
+	if want := []string{"old:json", "old:"}; !slices.Equal(formats, want) {
+		t.Errorf("new client requests = %q, want %q", formats, want)
+	}
+}
+
+func TestFormatCache(t *testing.T) {
+	var formats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		formats = append(formats, req.Format)
+		if req.Format != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"model does not support format"}`))
+			return
+		}
+		w.Write([]byte(`{"model":"m","response":"{}","done":true}`))
+	}))
+	defer server.Close()
+
+	req := GenerateRequest{Model: "m", Format: "json"}
+	newClient := func(fc *FormatCache) *OllamaClient {
+		c := NewOllamaClient(server.URL, 10*time.Second)
+		c.SetFormatCache(fc)
+		return c
+	}
+	run := func(c *OllamaClient) []string {
+		formats = nil
+		if _, err := c.Generate(context.Background(), req); err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return formats
+	}
+	probed := []string{"json", ""}
+	skipped := []string{""}
+
+	t.Run("shared", func(t *testing.T) {
+		fc := NewFormatCache(time.Hour)
+		run(newClient(fc))
+		if got := run(newClient(fc)); !slices.Equal(got, skipped) {
+			t.Errorf("second client requests = %q, want %q", got, skipped)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		fc := NewFormatCache(time.Hour)
+		c := newClient(fc)
+		run(c)
+		fc.Reset()
+		if got := run(c); !slices.Equal(got, probed) {
+			t.Errorf("requests after Reset = %q, want %q", got, probed)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		fc := NewFormatCache(time.Millisecond)
+		c := newClient(fc)
+		run(c)
+		time.Sleep(5 * time.Millisecond)
+		if got := run(c); !slices.Equal(got, probed) {
+			t.Errorf("requests after expiry = %q, want %q", got, probed)
+		}
+	})
+}
+
+func TestOllamaClient_Generate_OtherBadRequest(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid image"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOllamaClient(server.URL, 10*time.Second).Generate(context.Background(), GenerateRequest{Model: "m", Format: "json"})
+	if err == nil || errors.Is(err, ErrFormatNotSupported) {
+		t.Fatalf("err = %v, want a plain HTTP 400 error", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want no retry for other errors", calls)
+	}
+}
+
+func TestOllamaClient_Generate_EmptyBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := NewOllamaClient(server.URL, 10*time.Second).Generate(context.Background(), GenerateRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Response != "" {
+		t.Errorf("Response = %q, want empty", resp.Response)
+	}
+}
+
+func TestOllamaClient_GenerateStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if !req.Stream {
+			t.Error("stream should be true")
+		}
+
+		enc := json.NewEncoder(w)
+		for _, chunk := range []string{`{"text":`, ` {"raw": "hi"}`, `}`} {
+			enc.Encode(GenerateResponse{Model: req.Model, Response: chunk})
+			w.(http.Flusher).Flush()
+		}
+		enc.Encode(GenerateResponse{Model: req.Model, Done: true, PromptEvalCount: 10, EvalCount: 3})
+	}))
+	defer server.Close()
+
+	var chunks []string
+	resp, err := NewOllamaClient(server.URL, 10*time.Second).GenerateStream(context.Background(),
+		GenerateRequest{Model: "m"}, func(s string) { chunks = append(chunks, s) })
+	if err != nil {
+		t.Fatalf("GenerateStream: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("chunks = %q, want 3", chunks)
+	}
+	if resp.Response != `{"text": {"raw": "hi"}}` {
+		t.Errorf("Response = %q, want the whole answer", resp.Response)
+	}
+	if resp.Model != "m" || resp.PromptEvalCount != 10 || resp.EvalCount != 3 {
+		t.Errorf("final response = %+v, want the counts of the last chunk", resp)
+	}
+}
+
+func TestOllamaClient_GenerateStream_Logprobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if !req.Logprobs {
+			t.Error("logprobs should be requested")
+		}
+		enc := json.NewEncoder(w)
+		for _, chunk := range []string{`{"a"`, `:1}`} {
+			enc.Encode(GenerateResponse{Response: chunk, Logprobs: []TokenLogprob{{Token: chunk, Logprob: -0.5}}})
+		}
+		enc.Encode(GenerateResponse{Done: true})
+	}))
+	defer server.Close()
+
+	resp, err := NewOllamaClient(server.URL, 10*time.Second).GenerateStream(context.Background(),
+		GenerateRequest{Model: "m", Logprobs: true}, func(string) {})
+	if err != nil {
+		t.Fatalf("GenerateStream: %v", err)
+	}
+	if len(resp.Logprobs) != 2 || resp.Logprobs[0].Token != `{"a"` || resp.Logprobs[1].Token != `:1}` || resp.Logprobs[1].Logprob != -0.5 {
+		t.Errorf("Logprobs = %+v, want the tokens of every chunk", resp.Logprobs)
+	}
+}
+
+func TestOllamaClient_GenerateStream_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"response":"{","done":false}` + "\n" + `{"error":"model runner crashed"}` + "\n"))
+	}))
+	defer server.Close()
+
+	_, err := NewOllamaClient(server.URL, 10*time.Second).GenerateStream(context.Background(),
+		GenerateRequest{Model: "m"}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "model runner crashed") {
+		t.Fatalf("err = %v, want the stream error", err)
+	}
+}
+
 func TestOllamaClient_Ping(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/tags" {
@@ -111,6 +360,24 @@ func TestOllamaClient_Ping_Unavailable(t *testing.T) {
 	}
 }
 
+func TestOllamaClient_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"models":[{"name":"llama3.2-vision:latest","size":1},{"name":"moondream:1.8b"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewOllamaClient(server.URL, 10*time.Second).ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 2 || models[0] != "llama3.2-vision:latest" || models[1] != "moondream:1.8b" {
+		t.Errorf("models = %q", models)
+	}
+}
+
 func TestOllamaClient_Generate_ContextCancel(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(5 * time.Second)
@@ -132,3 +399,162 @@ func TestOllamaClient_Generate_ContextCancel(t *testing.T) {
 		t.Fatal("expected error for canceled context")
 	}
 }
+
+func TestGenerateRequest_Redacted(t *testing.T) {
+	req := GenerateRequest{
+		Model:  "test-model",
+		Prompt: "0123456789abcdef",
+		Images: []string{"aGVsbG8=", "d29ybGQhIQ=="},
+	}
+
+	redacted := req.Redacted(10)
+
+	if redacted.Images[0] != "[8 bytes base64]" {
+		t.Errorf("Images[0] = %q, want %q", redacted.Images[0], "[8 bytes base64]")
+	}
+	if redacted.Images[1] != "[12 bytes base64]" {
+		t.Errorf("Images[1] = %q, want %q", redacted.Images[1], "[12 bytes base64]")
+	}
+	if redacted.Prompt != "0123456789..." {
+		t.Errorf("Prompt = %q, want %q", redacted.Prompt, "0123456789...")
+	}
+
+	// Original request must be untouched
+	if req.Images[0] != "aGVsbG8=" || req.Prompt != "0123456789abcdef" {
+		t.Error("Redacted modified the original request")
+	}
+}
+
+func TestGenerateRequest_RedactedMultiByte(t *testing.T) {
+	req := GenerateRequest{Prompt: "Größe: 10 €"}
+
+	redacted := req.Redacted(4)
+	if redacted.Prompt != "Größ..." {
+		t.Errorf("Prompt = %q, want %q", redacted.Prompt, "Größ...")
+	}
+	if !utf8.ValidString(redacted.Prompt) {
+		t.Errorf("Prompt %q is not valid UTF-8", redacted.Prompt)
+	}
+	if got := req.Redacted(11).Prompt; got != req.Prompt {
+		t.Errorf("Prompt = %q, want it unchanged at its length in characters", got)
+	}
+}
+
+func TestGenerateRequest_RedactedNoTruncation(t *testing.T) {
+	req := GenerateRequest{Prompt: "short"}
+
+	redacted := req.Redacted(0)
+	if redacted.Prompt != "short" {
+		t.Errorf("Prompt = %q, want %q", redacted.Prompt, "short")
+	}
+	if redacted.Images != nil {
+		t.Errorf("Images = %v, want nil", redacted.Images)
+	}
+}
+
+func TestModelOptions_ResourceFieldsOmittedWhenUnset(t *testing.T) {
+	zero, four := 0, 4
+
+	tests := []struct {
+		name string
+		opts ModelOptions
+		want string
+	}{
+		{"unset", ModelOptions{Temperature: 0.1}, `{"temperature":0.1}`},
+		{"cpu only", ModelOptions{Temperature: 0.1, NumGPU: &zero}, `{"temperature":0.1,"num_gpu":0}`},
+		{"threads", ModelOptions{Temperature: 0.1, NumThread: &four}, `{"temperature":0.1,"num_thread":4}`},
+		{"sampling", ModelOptions{Temperature: 0, TopP: 0.9, Stop: []string{"\n\n"}}, `{"temperature":0,"top_p":0.9,"stop":["\n\n"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.opts)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOllamaClient_DefaultTransport(t *testing.T) {
+	c := NewOllamaClient("http://localhost:11434", 5*time.Second)
+
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want %v", c.httpClient.Timeout, 5*time.Second)
+	}
+	tr, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport = %T, want *http.Transport", c.httpClient.Transport)
+	}
+	if tr.MaxIdleConnsPerHost < 2 || !tr.ForceAttemptHTTP2 {
+		t.Errorf("default transport not tuned: MaxIdleConnsPerHost=%d ForceAttemptHTTP2=%v",
+			tr.MaxIdleConnsPerHost, tr.ForceAttemptHTTP2)
+	}
+
+	// Clients share the transport so connections are reused between them
+	other := NewOllamaClient("http://localhost:11434", time.Second)
+	if other.httpClient.Transport != c.httpClient.Transport {
+		t.Error("clients should share the default transport")
+	}
+}
+
+type countingTransport struct {
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewOllamaClient_WithTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	rt := &countingTransport{}
+	c := NewOllamaClient(server.URL, 5*time.Second, WithTransport(rt))
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if rt.calls != 1 {
+		t.Errorf("custom transport calls = %d, want 1", rt.calls)
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want %v", c.httpClient.Timeout, 5*time.Second)
+	}
+}
+
+func BenchmarkOllamaClient_Generate(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"bench","response":"{}","done":true}`))
+	}))
+	defer server.Close()
+
+	transports := map[string]http.RoundTripper{
+		"tuned":  newDefaultTransport(),
+		"stdlib": http.DefaultTransport.(*http.Transport).Clone(),
+	}
+
+	for name, rt := range transports {
+		b.Run(name, func(b *testing.B) {
+			c := NewOllamaClient(server.URL, 10*time.Second, WithTransport(rt))
+			req := GenerateRequest{Model: "bench", Prompt: "bench"}
+
+			b.SetParallelism(8)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := c.Generate(context.Background(), req); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAICompatClient is an HTTP client for servers that implement the
+// OpenAI chat completions API, such as llama.cpp's server. It accepts the
+// same GenerateRequest as OllamaClient: images are sent as base64 data URLs
+// and the first choice's message content is returned as the response.
+type OpenAICompatClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOpenAICompatClient creates a client for the server at baseURL, e.g.
+// "http://localhost:8080" (without the /v1 suffix). The timeout bounds each
+// HTTP request.
+func NewOpenAICompatClient(baseURL string, timeout time.Duration, opts ...ClientOption) *OpenAICompatClient {
+	return &OpenAICompatClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: newHTTPClient(timeout, opts),
+	}
+}
+
+// chatRequest is the request body for /v1/chat/completions.
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	TopP           float64         `json:"top_p,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	Seed           *int            `json:"seed,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream"`
+	Logprobs       bool            `json:"logprobs,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string        `json:"role"`
+	Content []contentPart `json:"content"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+// chatResponse is the non-streaming response from /v1/chat/completions.
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Logprobs *struct {
+			Content []TokenLogprob `json:"content"`
+		} `json:"logprobs"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Generate sends req as a chat completion with a single user message.
+func (c *OpenAICompatClient) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	parts := []contentPart{{Type: "text", Text: req.Prompt}}
+	for _, img := range req.Images {
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: dataURL(img)}})
+	}
+
+	chatReq := chatRequest{
+		Model:    req.Model,
+		Messages: []chatMessage{{Role: "user", Content: parts}},
+		Logprobs: req.Logprobs,
+	}
+	if req.Options != nil {
+		chatReq.Temperature = req.Options.Temperature
+		chatReq.MaxTokens = req.Options.NumPredict
+		chatReq.TopP = req.Options.TopP
+		chatReq.Stop = req.Options.Stop
+		chatReq.Seed = req.Options.Seed
+	}
+	if req.Format == "json" {
+		chatReq.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+
+	body, err := newJSONBody(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/chat/completions", c.baseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.ContentLength = body.Len()
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completions API returned HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var chatResp chatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("chat completions response has no choices")
+	}
+
+	genResp := &GenerateResponse{
+		Model:           chatResp.Model,
+		Response:        chatResp.Choices[0].Message.Content,
+		Done:            true,
+		PromptEvalCount: chatResp.Usage.PromptTokens,
+		EvalCount:       chatResp.Usage.CompletionTokens,
+	}
+	if lp := chatResp.Choices[0].Logprobs; lp != nil {
+		genResp.Logprobs = lp.Content
+	}
+	return genResp, nil
+}
+
+// Ping checks if the server is available.
+func (c *OpenAICompatClient) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1/models", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create ping request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ping server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ListModels returns the IDs of the models the server serves.
+func (c *OpenAICompatClient) ListModels(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/v1/models", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create list models request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("unmarshal models: %w", err)
+	}
+
+	ids := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// dataURL wraps a base64-encoded image in a data URL, sniffing its MIME type.
+func dataURL(b64 string) string {
+	// 512 bytes of image data are enough to sniff; decode just those.
+	head := b64
+	if len(head) > 684 {
+		head = head[:684]
+	}
+	data, _ := base64.StdEncoding.DecodeString(head)
+	return "data:" + http.DetectContentType(data) + ";base64," + b64
+}
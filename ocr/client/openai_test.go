@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough of a PNG for MIME sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestOpenAICompatClient_Generate(t *testing.T) {
+	image := base64.StdEncoding.EncodeToString(pngHeader)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Model != "llava" || req.Temperature != 0.1 || req.MaxTokens != 4096 || req.TopP != 0.9 || len(req.Stop) != 1 {
+			t.Errorf("request = %+v, want model and sampling parameters from the generate request", req)
+		}
+		if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+			t.Errorf("ResponseFormat = %+v, want json_object", req.ResponseFormat)
+		}
+		if len(req.Messages) != 1 || len(req.Messages[0].Content) != 2 {
+			t.Fatalf("Messages = %+v, want one message with text and image parts", req.Messages)
+		}
+		parts := req.Messages[0].Content
+		if parts[0].Type != "text" || parts[0].Text != "Extract text" {
+			t.Errorf("text part = %+v", parts[0])
+		}
+		if parts[1].Type != "image_url" || parts[1].ImageURL.URL != "data:image/png;base64,"+image {
+			t.Errorf("image part = %+v, want a PNG data URL", parts[1])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"llava-v1.6","choices":[{"index":0,"message":{"role":"assistant","content":"{\"text\":{}}"}}],"usage":{"prompt_tokens":700,"completion_tokens":42}}`))
+	}))
+	defer server.Close()
+
+	c := NewOpenAICompatClient(server.URL+"/", 10*time.Second)
+	resp, err := c.Generate(context.Background(), GenerateRequest{
+		Model:   "llava",
+		Prompt:  "Extract text",
+		Images:  []string{image},
+		Format:  "json",
+		Options: &ModelOptions{Temperature: 0.1, NumPredict: 4096, TopP: 0.9, Stop: []string{"\n\n\n"}},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if resp.Model != "llava-v1.6" || resp.Response != `{"text":{}}` || !resp.Done {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.PromptEvalCount != 700 || resp.EvalCount != 42 {
+		t.Errorf("token counts = %d/%d, want 700/42", resp.PromptEvalCount, resp.EvalCount)
+	}
+}
+
+func TestOpenAICompatClient_Generate_Logprobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if !req.Logprobs {
+			t.Error("logprobs should be requested")
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"{}"},"logprobs":{"content":[{"token":"{","logprob":-0.1,"bytes":[123]},{"token":"}","logprob":0}]}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAICompatClient(server.URL, 10*time.Second).Generate(context.Background(),
+		GenerateRequest{Model: "m", Logprobs: true})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(resp.Logprobs) != 2 || resp.Logprobs[0].Token != "{" || resp.Logprobs[0].Logprob != -0.1 {
+		t.Errorf("Logprobs = %+v, want the tokens of the choice", resp.Logprobs)
+	}
+}
+
+func TestOpenAICompatClient_Generate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"server error", http.StatusInternalServerError, "boom", "HTTP 500"},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "no choices"},
+		{"invalid JSON", http.StatusOK, `not json`, "unmarshal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := NewOpenAICompatClient(server.URL, 10*time.Second)
+			_, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "p"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatClient_Ping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	if err := NewOpenAICompatClient(server.URL, 5*time.Second).Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if err := NewOpenAICompatClient("http://localhost:99999", time.Second).Ping(context.Background()); err == nil {
+		t.Error("expected error for unreachable server")
+	}
+}
+
+func TestOpenAICompatClient_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"llava","object":"model"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewOpenAICompatClient(server.URL, 5*time.Second).ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 1 || models[0] != "llava" {
+		t.Errorf("models = %q, want [llava]", models)
+	}
+}
@@ -0,0 +1,83 @@
+package ocr
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+)
+
+func TestClient_PerCallOverride(t *testing.T) {
+	c := NewClient(WithSummary(false), WithModel("minicpm-v"))
+
+	overridden := c.config(WithSummary(true))
+	if !overridden.WithSummary {
+		t.Error("per-call WithSummary(true) should apply")
+	}
+	if overridden.Model != "minicpm-v" {
+		t.Errorf("Model = %q, want base model %q", overridden.Model, "minicpm-v")
+	}
+
+	// Subsequent calls must not see the override
+	next := c.config()
+	if next.WithSummary {
+		t.Error("per-call override leaked into a subsequent call")
+	}
+	if c.cfg.WithSummary {
+		t.Error("per-call override mutated the client's base config")
+	}
+}
+
+func TestConfig_Clone(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FallbackModels = []string{"minicpm-v"}
+
+	clone := cfg.Clone()
+	clone.FallbackModels[0] = "moondream"
+	clone.Model = "other"
+
+	if cfg.FallbackModels[0] != "minicpm-v" {
+		t.Error("Clone shares FallbackModels with the original")
+	}
+	if cfg.Model != DefaultModel {
+		t.Error("Clone shares fields with the original")
+	}
+}
+
+func TestClient_SharesFormatCache(t *testing.T) {
+	srv := ollamatest.NewServer(t, func(req client.GenerateRequest) (int, string) {
+		if req.Format != "" {
+			return http.StatusBadRequest, `{"error":"model does not support format"}`
+		}
+		return http.StatusOK, ollamatest.Response
+	})
+	formats := func() []string {
+		var out []string
+		for _, req := range srv.Requests() {
+			out = append(out, req.Format)
+		}
+		return out
+	}
+
+	// The client's calls probe the format once between them
+	c := NewClient(WithOllamaURL(srv.URL))
+	for range 2 {
+		if _, err := c.ExtractBytes(context.Background(), testPNG(t), ".png"); err != nil {
+			t.Fatalf("ExtractBytes: %v", err)
+		}
+	}
+	if got, want := formats(), []string{"json", "", ""}; !slices.Equal(got, want) {
+		t.Errorf("client requests = %q, want %q", got, want)
+	}
+
+	// A package-level call does not see the client's cache
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if got, want := formats()[3:], []string{"json", ""}; !slices.Equal(got, want) {
+		t.Errorf("package-level requests = %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,20 @@
 package ocr
 
-import "time"
+import (
+	"maps"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/engine"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/prompt"
+)
+
+// SchemaVersion is the version of the OCRResult JSON schema produced by this
+// package. See models.SchemaVersion.
+const SchemaVersion = models.SchemaVersion
 
 const (
 	// DefaultOllamaURL is the default Ollama API endpoint.
@@ -15,51 +29,454 @@ const (
 	// DefaultTemperature is the deterministic temperature for OCR.
 	DefaultTemperature = 0.1
 
+	// DefaultNumPredict is the default cap on tokens generated per request.
+	DefaultNumPredict = engine.DefaultNumPredict
+
 	// DefaultMaxFileSize is the maximum allowed file size (50 MB).
 	DefaultMaxFileSize = 50 * 1024 * 1024
 
 	// DefaultMaxImageDimension is the maximum allowed image dimension (pixels) per side.
 	DefaultMaxImageDimension = 8192
 
+	// DefaultDebugPromptLength is how much of the prompt is logged when request
+	// debug logging is enabled.
+	DefaultDebugPromptLength = 500
+
+	// DefaultMaxConcurrency is how many ExtractBatch sources are processed
+	// by the model at once.
+	DefaultMaxConcurrency = 1
+
+	// DefaultMaxConcurrentDownloads is how many ExtractBatch sources are
+	// downloaded or read at once.
+	DefaultMaxConcurrentDownloads = 4
+
+	// DefaultJPEGQuality is the JPEG quality used with ImageEncodingJPEG.
+	DefaultJPEGQuality = 85
+
 	// MaxRetries is the number of retries if JSON parsing fails.
 	MaxRetries = 1
+
+	// AutoRotateConfidenceThreshold is the overall confidence below which
+	// WithAutoRotate retries OCR on a rotated image.
+	AutoRotateConfidenceThreshold = 0.5
+
+	// AutoStripAspectRatio is the height-to-width ratio above which
+	// WithAutoStrip splits an image into horizontal strips.
+	AutoStripAspectRatio = 3.0
+
+	// stripAspectRatio is the largest height-to-width ratio of one strip.
+	stripAspectRatio = 2.0
+
+	// CandidateTemperature is the lowest temperature used for every
+	// candidate of WithCandidates after the first, so they can differ.
+	CandidateTemperature = 0.7
 )
 
+// EngineType selects the OCR backend.
+type EngineType string
+
+const (
+	// EngineOllama uses an Ollama vision model (default).
+	EngineOllama EngineType = "ollama"
+
+	// EngineTesseract uses a local Tesseract binary. It extracts text and
+	// line boxes only; structured data and summaries are not supported.
+	EngineTesseract EngineType = "tesseract"
+
+	// EngineAuto uses Ollama, falling back to Tesseract when Ollama is
+	// unreachable and Tesseract is installed.
+	EngineAuto EngineType = "auto"
+)
+
+// SummaryLength is the requested verbosity of the document summary.
+type SummaryLength string
+
+const (
+	SummaryLengthShort  SummaryLength = "short"
+	SummaryLengthMedium SummaryLength = "medium"
+	SummaryLengthLong   SummaryLength = "long"
+)
+
+// SourceInfo describes a source before it is downloaded or processed. It is
+// passed to the SourceValidator.
+type SourceInfo struct {
+	// Type is how the source was supplied (URL, file or bytes).
+	Type models.SourceType
+
+	// Host is the URL host without port. Empty for files and bytes.
+	Host string
+
+	// Ext is the lower-case file extension including the dot, e.g. ".png".
+	Ext string
+
+	// Size is the source size in bytes, or -1 if it is not known before
+	// loading (URLs and readers).
+	Size int64
+}
+
+// LineEndings selects the line ending used in extracted text.
+type LineEndings string
+
+const (
+	LineEndingsLF       LineEndings = "lf"
+	LineEndingsCRLF     LineEndings = "crlf"
+	LineEndingsPreserve LineEndings = "preserve"
+)
+
+// ImageEncoding selects the format images are sent to the model in.
+type ImageEncoding string
+
+const (
+	ImageEncodingPNG  ImageEncoding = "png"
+	ImageEncodingJPEG ImageEncoding = "jpeg"
+)
+
+// TableMergePolicy selects how table cells merged with the cell above them
+// are normalized.
+type TableMergePolicy string
+
+const (
+	TableMergeFillDown TableMergePolicy = "fill-down"
+	TableMergeEmpty    TableMergePolicy = "empty"
+	TableMergeMark     TableMergePolicy = "mark"
+)
+
+// MergedCellMarker replaces merged table cells under TableMergeMark.
+const MergedCellMarker = "<merged>"
+
 // Config holds all configuration for an OCR extraction request.
 type Config struct {
 	// OllamaURL is the base URL for the Ollama API.
 	OllamaURL string
 
+	// Engine selects the OCR backend.
+	Engine EngineType
+
 	// Model is the Ollama model to use.
 	Model string
 
+	// FallbackModels are tried in order if Model fails.
+	FallbackModels []string
+
 	// Timeout is the request timeout.
 	Timeout time.Duration
 
+	// DeadlinePadding is the part of the request deadline reserved for
+	// parsing and merging after each model call.
+	DeadlinePadding time.Duration
+
+	// ResponseTimeout bounds each model call; 0 leaves only Timeout.
+	ResponseTimeout time.Duration
+
 	// Temperature controls randomness (0 = deterministic).
 	Temperature float64
 
+	// NumPredict caps the tokens the model generates per request.
+	NumPredict int
+
+	// TopP is the nucleus sampling cutoff. 0 leaves the server default.
+	TopP float64
+
+	// Stop lists sequences that end generation.
+	Stop []string
+
+	// ChecksumAlgorithm and ExpectedChecksum, if set, are the hash the
+	// source data must have before it is processed.
+	ChecksumAlgorithm string
+	ExpectedChecksum  string
+
 	// MaxFileSize is the maximum file size in bytes.
 	MaxFileSize int64
 
 	// MaxImageDimension is the max width/height in pixels.
 	MaxImageDimension int
 
+	// MinImageDimension is the min width/height in pixels. 0 disables the
+	// check. PDFs are not checked.
+	MinImageDimension int
+
+	// MaxImagePixels is the max width times height. 0 disables the check.
+	// PDFs are not checked.
+	MaxImagePixels int64
+
+	// NumGPU is the number of model layers Ollama offloads to the GPU.
+	// Nil leaves the server default; 0 forces CPU-only inference.
+	NumGPU *int
+
+	// NumThread is the number of CPU threads Ollama uses. Nil leaves the
+	// server default.
+	NumThread *int
+
+	// ThumbnailHint sends a downscaled overview of the image along with it.
+	ThumbnailHint bool
+
+	// CompactPrompt sends a terser prompt to save context on small models.
+	CompactPrompt bool
+
+	// FewShotExamples are shown to the model ahead of the prompt.
+	FewShotExamples []prompt.Example
+
+	// LogprobConfidence derives line confidences from token log
+	// probabilities when the server returns them.
+	LogprobConfidence bool
+
+	// SchemaValidation checks each model answer against the response JSON
+	// Schema and retries if it does not conform.
+	SchemaValidation bool
+
+	// RetryOnEmptyText retries once when the model returns no text at all.
+	RetryOnEmptyText bool
+
+	// RetryBackoff is the delay before the first retry of a model call,
+	// doubling for each further one up to RetryMaxBackoff. 0 retries at
+	// once; a RetryMaxBackoff of 0 leaves the delay uncapped.
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	// MaxTotalRetries caps parse-failure retries across all pages or regions
+	// of one extraction. Nil allows each model call to retry once.
+	MaxTotalRetries *int
+
+	// MaxTotalTokens caps the prompt and eval tokens of a PDF extraction.
+	// 0 means no limit.
+	MaxTotalTokens int
+
+	// MaxConcurrency and MaxConcurrentDownloads bound how many ExtractBatch
+	// sources are processed by the model and loaded at once.
+	MaxConcurrency         int
+	MaxConcurrentDownloads int
+
+	// CircuitBreakerThreshold is the number of consecutive Ollama connection
+	// failures after which calls fail fast with ErrOllamaUnavailable (or
+	// ErrBackendUnavailable for a custom Backend) for CircuitBreakerCooldown.
+	// 0 disables the breaker.
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+
+	// breaker is created by NewClient and shared by every call of the
+	// client, including clones of its config.
+	breaker *circuitBreaker
+
+	// formats is created by NewClient and remembers, for every call of the
+	// client, the models that rejected Ollama's JSON format. Without a
+	// Client each call starts with an empty cache.
+	formats *client.FormatCache
+
+	// Proxy routes image downloads through this proxy. Nil uses the
+	// HTTP_PROXY/HTTPS_PROXY environment variables.
+	Proxy *url.URL
+
+	// SourceValidator approves or rejects a source before it is loaded.
+	// Nil accepts every source.
+	SourceValidator func(source string, info SourceInfo) error
+
+	// URLRefresher returns a new URL for a source whose download was
+	// refused with 401 or 403. Nil disables the retry.
+	URLRefresher func(source string) (string, error)
+
+	// ProgressiveParse receives partial results while the model's answer
+	// streams in. Nil disables streaming.
+	ProgressiveParse func(*models.OCRResult)
+
+	// ResultProcessors run in order over the final result before Extract
+	// returns it.
+	ResultProcessors []func(*models.OCRResult) error
+
+	// ConfidenceCalibrator maps model confidences to calibrated values.
+	// Nil keeps the model's values.
+	ConfidenceCalibrator func(float64) float64
+
+	// Transport overrides the HTTP transport used to talk to Ollama.
+	// Nil uses a shared transport tuned for connection reuse.
+	Transport http.RoundTripper
+
+	// Backend sends model requests. Nil uses an Ollama client built from
+	// OllamaURL, Timeout and Transport.
+	Backend client.VisionBackend
+
 	// Feature flags
 	WithSummary              bool
 	WithLanguageDetection    bool
 	WithStructuredExtraction bool
 	WithBoundingBoxes        bool
 	WithConfidenceScores     bool
+
+	// KeyValueConfidence adds a confidence per key-value pair in
+	// StructuredData.KeyValueDetails. Requires WithConfidenceScores.
+	KeyValueConfidence bool
+
+	// MinTableConfidence drops tables the model is less confident about.
+	// 0 keeps every table.
+	MinTableConfidence float64
+
+	// MergeAdjacentLines merges line fragments that share a visual line.
+	// Requires WithBoundingBoxes.
+	MergeAdjacentLines bool
+
+	// SummaryLength guides how verbose the summary is. Empty leaves it to
+	// the model.
+	SummaryLength SummaryLength
+
+	// SummaryMaxWords asks the model for at most this many summary words and
+	// truncates longer summaries. 0 means no limit.
+	SummaryMaxWords int
+
+	// CropRegions, in pixels, restricts OCR to these areas of the image.
+	// Each region is processed separately. Ignored for PDFs.
+	CropRegions []models.BoundingBox
+
+	// AutoRotate retries low-confidence images rotated by 180 degrees.
+	// Requires WithConfidenceScores. PDFs, crop regions, strips and
+	// candidates are not rotated.
+	AutoRotate bool
+
+	// AutoStrip processes very tall images in horizontal strips.
+	AutoStrip bool
+
+	// Candidates is the number of extractions generated for review; 1 or
+	// less generates one.
+	Candidates int
+
+	// OriginalCoordinates maps bounding boxes back through the transforms
+	// in OCRResult.Transforms to the coordinates of the original image.
+	OriginalCoordinates bool
+
+	// ValidateImageBytes fully decodes images before the model call so
+	// corrupt data fails early. PDFs are not checked.
+	ValidateImageBytes bool
+
+	// SkipBlank skips the model call for images that look blank.
+	SkipBlank bool
+
+	// ImageEncoding is the format images are sent to the model in. PNG
+	// keeps the preprocessed image as is; JPEG re-encodes it at JPEGQuality.
+	ImageEncoding ImageEncoding
+	JPEGQuality   int
+
+	// MaxLines caps the number of text lines returned; 0 means no limit.
+	MaxLines int
+
+	// ReflowLines splits lines longer than this many characters; 0 leaves
+	// lines as the model returned them.
+	ReflowLines int
+
+	// KeepEmptyLines keeps lines whose text is empty or whitespace-only.
+	KeepEmptyLines bool
+
+	// NormalizeWhitespace collapses runs of spaces and trims lines and
+	// blank lines in the text.
+	NormalizeWhitespace bool
+
+	// DejoinHyphens rejoins words split by a hyphen at a line break in the
+	// raw text.
+	DejoinHyphens bool
+
+	// LineEndings normalizes line endings in raw and line text.
+	LineEndings LineEndings
+
+	// Schema is the output shape Client.Render projects results into.
+	Schema SchemaName
+
+	// TableMergePolicy normalizes merged table cells. Empty leaves tables
+	// as the model returned them.
+	TableMergePolicy TableMergePolicy
+
+	// TableSpans asks for the column and row spans of table cells.
+	TableSpans bool
+
+	// FlagEmptyStructuredData warns when structured extraction yields
+	// nothing where something was expected.
+	FlagEmptyStructuredData bool
+
+	// DetectTextDirection reports the reading direction of the text in
+	// Metadata.Direction.
+	DetectTextDirection bool
+
+	// DetectMultipleDocuments asks the model for the region of each document
+	// in the image and reports them in OCRResult.Documents.
+	DetectMultipleDocuments bool
+
+	// FilterWatermarks asks the model to list watermarks separately and
+	// leaves them out of the text.
+	FilterWatermarks bool
+
+	// AllowFreeformDocumentType accepts any non-empty document type from
+	// the model instead of only the DocumentType constants.
+	AllowFreeformDocumentType bool
+
+	// NumberLocale is the BCP 47 locale, e.g. "de-DE", whose decimal
+	// separator NormalizeAmount and LineItems use. Empty guesses it per
+	// value.
+	NumberLocale string
+
+	// PDFPassword is the user password for encrypted PDFs.
+	PDFPassword string
+
+	// ExtractFormFields reads filled-in PDF form fields into
+	// StructuredData.KeyValuePairs, taking precedence over OCR.
+	ExtractFormFields bool
+
+	// ExtractPDFMetadata reads the PDF document information dictionary into
+	// OCRResult.PDFInfo.
+	ExtractPDFMetadata bool
+
+	// RawJSON returns the model's JSON verbatim in OCRResult.RawData instead
+	// of mapping it onto Text and StructuredData.
+	RawJSON bool
+
+	// SanitizeText strips invalid UTF-8 and control characters from text
+	// and structured data returned by the model.
+	SanitizeText bool
+
+	// BoundingBoxUnits converts bounding boxes to this coordinate system.
+	// Empty keeps whatever the model emitted.
+	BoundingBoxUnits models.BoundingBoxUnits
+
+	// QualityReport adds line confidence statistics to the result.
+	// Requires WithConfidenceScores.
+	QualityReport bool
+
+	// Timings adds a per-stage timing breakdown to the result.
+	Timings bool
+
+	// RetainImage keeps the processed image bytes on the result.
+	RetainImage bool
+
+	// SourceMetadata is copied to Source.Metadata of every result.
+	SourceMetadata map[string]string
+
+	// ExpectedDocumentType, when set, tells the model which document type to
+	// expect. An empty value lets the model classify the document freely.
+	ExpectedDocumentType models.DocumentType
+
+	// AllowedLanguages, if set, lists the only detected languages accepted.
+	AllowedLanguages []string
+
+	// StrictMode turns soft checks, such as a document type mismatch, into
+	// errors instead of warnings.
+	StrictMode bool
+
+	// DebugRequestLog logs each Ollama request at debug level with images
+	// elided, and lowers the log level to debug.
+	DebugRequestLog bool
+
+	// DebugPromptLength caps the logged prompt length when DebugRequestLog is on.
+	DebugPromptLength int
+
+	// Clock returns the time request IDs are generated from. Nil uses
+	// time.Now.
+	Clock func() time.Time
 }
 
 // DefaultConfig returns a Config with all defaults applied.
 func DefaultConfig() *Config {
 	return &Config{
 		OllamaURL:                DefaultOllamaURL,
+		Engine:                   EngineOllama,
 		Model:                    DefaultModel,
 		Timeout:                  DefaultTimeout,
 		Temperature:              DefaultTemperature,
+		NumPredict:               DefaultNumPredict,
 		MaxFileSize:              DefaultMaxFileSize,
 		MaxImageDimension:        DefaultMaxImageDimension,
 		WithSummary:              false,
@@ -67,5 +484,57 @@ func DefaultConfig() *Config {
 		WithStructuredExtraction: true,
 		WithBoundingBoxes:        true,
 		WithConfidenceScores:     true,
+		SanitizeText:             true,
+		LineEndings:              LineEndingsLF,
+		Schema:                   SchemaStrict,
+		ImageEncoding:            ImageEncodingPNG,
+		JPEGQuality:              DefaultJPEGQuality,
+		DebugPromptLength:        DefaultDebugPromptLength,
+		MaxConcurrency:           DefaultMaxConcurrency,
+		MaxConcurrentDownloads:   DefaultMaxConcurrentDownloads,
+	}
+}
+
+// Clone returns a deep copy of the config so it can be modified without
+// affecting the original.
+func (c *Config) Clone() *Config {
+	clone := *c
+	if c.FallbackModels != nil {
+		clone.FallbackModels = append([]string(nil), c.FallbackModels...)
+	}
+	if c.Proxy != nil {
+		proxy := *c.Proxy
+		clone.Proxy = &proxy
+	}
+	if c.AllowedLanguages != nil {
+		clone.AllowedLanguages = append([]string(nil), c.AllowedLanguages...)
+	}
+	if c.SourceMetadata != nil {
+		clone.SourceMetadata = maps.Clone(c.SourceMetadata)
+	}
+	if c.FewShotExamples != nil {
+		clone.FewShotExamples = append([]prompt.Example(nil), c.FewShotExamples...)
+	}
+	if c.CropRegions != nil {
+		clone.CropRegions = append([]models.BoundingBox(nil), c.CropRegions...)
+	}
+	if c.ResultProcessors != nil {
+		clone.ResultProcessors = append([]func(*models.OCRResult) error(nil), c.ResultProcessors...)
+	}
+	if c.Stop != nil {
+		clone.Stop = append([]string(nil), c.Stop...)
+	}
+	if c.NumGPU != nil {
+		n := *c.NumGPU
+		clone.NumGPU = &n
+	}
+	if c.NumThread != nil {
+		n := *c.NumThread
+		clone.NumThread = &n
+	}
+	if c.MaxTotalRetries != nil {
+		n := *c.MaxTotalRetries
+		clone.MaxTotalRetries = &n
 	}
+	return &clone
 }
@@ -0,0 +1,297 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// Engine is an OCR backend that turns images or PDFs into a ProcessResult.
+type Engine interface {
+	// Process runs OCR on a single image.
+	Process(ctx context.Context, imageData []byte, cfg ProcessConfig) (*ProcessResult, error)
+
+	// ProcessPDF runs OCR on every page of a PDF and merges the results.
+	ProcessPDF(ctx context.Context, pdfPath string, cfg ProcessConfig) (*ProcessResult, error)
+}
+
+// processFunc processes a single page image.
+type processFunc func(ctx context.Context, imageData []byte, cfg ProcessConfig) (*ProcessResult, error)
+
+// processPDF converts a PDF to page images, runs process on each page and
+// merges the results. It is shared by all engines.
+func processPDF(ctx context.Context, logger *slog.Logger, pdfPath string, cfg ProcessConfig, process processFunc) (*ProcessResult, error) {
+	logger = cfg.logger(logger)
+	logger.Info("processing PDF",
+		slog.String("request_id", cfg.RequestID),
+		slog.String("path", pdfPath),
+	)
+
+	// Form fields carry the exact values, so read them before rendering
+	var (
+		formFields map[string]string
+		info       *models.PDFInfo
+		warnings   []string
+	)
+	if cfg.ExtractFormFields {
+		var err error
+		formFields, err = readFormFields(pdfPath)
+		if err != nil {
+			logger.Warn("reading PDF form fields failed",
+				slog.String("request_id", cfg.RequestID),
+				slog.String("error", err.Error()),
+			)
+			warnings = append(warnings, "PDF form fields were not extracted: "+err.Error())
+		}
+	}
+	if cfg.ExtractPDFMetadata {
+		var err error
+		info, err = readPDFMetadata(pdfPath)
+		if err != nil {
+			logger.Warn("reading PDF metadata failed",
+				slog.String("request_id", cfg.RequestID),
+				slog.String("error", err.Error()),
+			)
+			warnings = append(warnings, "PDF metadata was not extracted: "+err.Error())
+		}
+	}
+
+	renderStart := time.Now()
+	pages, err := utils.PDFToImagesWithPassword(pdfPath, cfg.PDFPassword)
+	if err != nil {
+		return nil, fmt.Errorf("convert PDF to images: %w", err)
+	}
+	renderLatency := time.Since(renderStart)
+
+	result, err := processPages(ctx, logger, pages, cfg, process)
+	if err != nil {
+		return nil, err
+	}
+	result.PreprocessLatency += renderLatency
+	result.Latency += renderLatency
+	result.SentImage = nil
+	result.FormFields = formFields
+	result.PDFInfo = info
+	result.Warnings = append(result.Warnings, warnings...)
+	return result, nil
+}
+
+// readFormFields returns the filled-in AcroForm fields of the PDF at path.
+func readFormFields(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pdf: %w", err)
+	}
+	return utils.PDFFormFields(data)
+}
+
+// readPDFMetadata returns the document information dictionary of the PDF at
+// path.
+func readPDFMetadata(path string) (*models.PDFInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pdf: %w", err)
+	}
+	return utils.PDFMetadata(data)
+}
+
+// processPages runs process on each page image and merges the results.
+// Each page is processed with a child of logger that adds a "page"
+// attribute, so every log line of the page can be attributed to it, and a
+// summary of the whole document is logged at the end.
+func processPages(ctx context.Context, logger *slog.Logger, pages [][]byte, cfg ProcessConfig, process processFunc) (*ProcessResult, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF produced no pages")
+	}
+
+	var (
+		allResults []*ProcessResult
+		tokens     int
+		exceeded   bool
+	)
+	for i, page := range pages {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		pageCfg := cfg
+		pageCfg.Logger = logger.With(slog.Int("page", i+1))
+		pageCfg.Logger.Info("processing PDF page",
+			slog.String("request_id", cfg.RequestID),
+			slog.Int("total_pages", len(pages)),
+		)
+
+		result, err := process(ctx, page, pageCfg)
+		if err != nil {
+			if len(pages) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("process page %d: %w", i+1, err)
+		}
+		setPageNumber(result, i+1)
+		allResults = append(allResults, result)
+
+		tokens += result.PromptTokens + result.EvalTokens
+		if cfg.MaxTotalTokens > 0 && tokens > cfg.MaxTotalTokens {
+			exceeded = true
+			break
+		}
+	}
+
+	result := MergeResults(allResults, "Page")
+	if exceeded {
+		done := len(allResults)
+		msg := fmt.Sprintf("token budget of %d exceeded after page %d of %d (%d tokens)", cfg.MaxTotalTokens, done, len(pages), tokens)
+		if done < len(pages) {
+			msg += fmt.Sprintf("; pages %d-%d were not processed", done+1, len(pages))
+		}
+		logger.Warn("token budget exceeded",
+			slog.String("request_id", cfg.RequestID),
+			slog.Int("max_total_tokens", cfg.MaxTotalTokens),
+			slog.Int("tokens", tokens),
+			slog.Int("processed_pages", done),
+			slog.Int("total_pages", len(pages)),
+		)
+		result.BudgetExceeded = true
+		result.Warnings = append(result.Warnings, msg)
+	}
+	logger.Info("PDF processing complete",
+		slog.String("request_id", cfg.RequestID),
+		slog.Int("total_pages", len(pages)),
+		slog.Int("prompt_tokens", result.PromptTokens),
+		slog.Int("eval_tokens", result.EvalTokens),
+		slog.Duration("latency", result.Latency),
+	)
+	return result, nil
+}
+
+// setPageNumber records the PDF page on every line of result.
+func setPageNumber(result *ProcessResult, page int) {
+	if result.VisionResponse == nil || result.VisionResponse.Text == nil {
+		return
+	}
+	for i := range result.VisionResponse.Text.Lines {
+		result.VisionResponse.Text.Lines[i].PageNumber = page
+	}
+}
+
+// MergeResults combines the results of several parts of one document (PDF
+// pages, image regions) into a single result. Each part's raw text is
+// prefixed with a "--- <section> N ---" header.
+func MergeResults(results []*ProcessResult, section string) *ProcessResult {
+	if len(results) == 0 {
+		return nil
+	}
+	if len(results) == 1 {
+		return results[0]
+	}
+
+	merged := &ProcessResult{
+		VisionResponse: &models.OllamaVisionResponse{
+			Metadata: results[0].VisionResponse.Metadata,
+			Text: &models.OllamaTextResult{
+				Raw:   "",
+				Lines: nil,
+			},
+			StructuredData: &models.OllamaStructuredData{
+				KeyValuePairs: make(map[string]string),
+				Tables:        nil,
+			},
+			Summary: nil,
+		},
+		Model: results[0].Model,
+	}
+
+	var rawParts []string
+	var rawData []any
+	hasStructuredData := false
+	var totalLatency time.Duration
+
+	for i, r := range results {
+		totalLatency += r.Latency
+		merged.PreprocessLatency += r.PreprocessLatency
+		merged.ModelLatency += r.ModelLatency
+		merged.ParseLatency += r.ParseLatency
+		merged.PromptTokens += r.PromptTokens
+		merged.EvalTokens += r.EvalTokens
+
+		if r.VisionResponse.Text != nil {
+			pagePrefix := fmt.Sprintf("--- %s %d ---\n", section, i+1)
+			rawParts = append(rawParts, pagePrefix+r.VisionResponse.Text.Raw)
+			merged.VisionResponse.Text.Lines = append(merged.VisionResponse.Text.Lines, r.VisionResponse.Text.Lines...)
+		}
+
+		if r.VisionResponse.StructuredData != nil {
+			hasStructuredData = true
+			sd := merged.VisionResponse.StructuredData
+			for k, v := range r.VisionResponse.StructuredData.KeyValuePairs {
+				sd.KeyValuePairs[k] = v
+				if conf, ok := r.VisionResponse.StructuredData.KeyValueConfidence[k]; ok {
+					if sd.KeyValueConfidence == nil {
+						sd.KeyValueConfidence = make(map[string]models.Confidence)
+					}
+					sd.KeyValueConfidence[k] = conf
+				} else {
+					delete(sd.KeyValueConfidence, k)
+				}
+				if box, ok := r.VisionResponse.StructuredData.KeyValueBoxes[k]; ok {
+					if sd.KeyValueBoxes == nil {
+						sd.KeyValueBoxes = make(map[string]*models.BoundingBox)
+					}
+					sd.KeyValueBoxes[k] = box
+				} else {
+					delete(sd.KeyValueBoxes, k)
+				}
+			}
+			for _, k := range r.VisionResponse.StructuredData.KeyOrder {
+				if !slices.Contains(sd.KeyOrder, k) {
+					sd.KeyOrder = append(sd.KeyOrder, k)
+				}
+			}
+			merged.VisionResponse.StructuredData.Tables = append(
+				merged.VisionResponse.StructuredData.Tables,
+				r.VisionResponse.StructuredData.Tables...,
+			)
+		}
+
+		if r.RawData != nil {
+			rawData = append(rawData, r.RawData)
+		}
+
+		// Use the summary from the last page if available
+		if r.VisionResponse.Summary != nil {
+			merged.VisionResponse.Summary = r.VisionResponse.Summary
+		}
+
+		for _, w := range r.Warnings {
+			if !slices.Contains(merged.Warnings, w) {
+				merged.Warnings = append(merged.Warnings, w)
+			}
+		}
+		for _, w := range r.VisionResponse.Watermarks {
+			if !merged.VisionResponse.Watermarks.Contains(w) {
+				merged.VisionResponse.Watermarks = append(merged.VisionResponse.Watermarks, w)
+			}
+		}
+	}
+
+	merged.VisionResponse.Text.Raw = strings.Join(rawParts, "\n")
+	if !hasStructuredData {
+		merged.VisionResponse.StructuredData = nil
+	}
+	if rawData != nil {
+		merged.RawData = map[string]any{strings.ToLower(section) + "s": rawData}
+	}
+	merged.Latency = totalLatency
+
+	return merged
+}
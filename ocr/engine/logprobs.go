@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// applyLogprobConfidence replaces the confidence of each line in resp with
+// the geometric mean probability of the tokens that spell its text. It
+// reports false, leaving resp unchanged, if there are lines but the tokens
+// are missing, do not add up to response or cannot be matched to the lines.
+func applyLogprobConfidence(resp *models.OllamaVisionResponse, response string, logprobs []client.TokenLogprob) bool {
+	if resp.Text == nil || len(resp.Text.Lines) == 0 {
+		return true
+	}
+	if len(logprobs) == 0 {
+		return false
+	}
+
+	// Token offsets into response
+	offsets := make([]int, len(logprobs)+1)
+	var sb strings.Builder
+	for i, lp := range logprobs {
+		sb.WriteString(lp.Token)
+		offsets[i+1] = offsets[i] + len(lp.Token)
+	}
+	if sb.String() != response {
+		return false
+	}
+
+	cleaned := utils.CleanJSONResponse(response)
+	base := strings.Index(response, cleaned)
+	spans := lineTextSpans(cleaned)
+	if base < 0 || len(spans) != len(resp.Text.Lines) {
+		return false
+	}
+
+	confidences := make([]float64, len(spans))
+	for i, span := range spans {
+		start, end := base+span[0], base+span[1]
+		var sum float64
+		n := 0
+		for t := range logprobs {
+			if offsets[t] < end && offsets[t+1] > start {
+				sum += logprobs[t].Logprob
+				n++
+			}
+		}
+		if n == 0 {
+			return false
+		}
+		confidences[i] = math.Exp(sum / float64(n))
+	}
+	for i := range resp.Text.Lines {
+		resp.Text.Lines[i].Confidence = models.Confidence(confidences[i])
+	}
+	return true
+}
+
+// lineTextSpans returns the byte ranges, quotes included, of the
+// text.lines[].text string values in the JSON object doc, in order. Lines
+// without a string text have no span.
+func lineTextSpans(doc string) [][2]int {
+	type frame struct {
+		array     bool
+		key       string
+		expectKey bool
+	}
+	var (
+		stack []frame
+		spans [][2]int
+	)
+	inLineText := func() bool {
+		return len(stack) == 4 && stack[0].key == "text" && stack[1].key == "lines" &&
+			stack[2].array && !stack[3].array && stack[3].key == "text"
+	}
+	// valueDone marks the value of the innermost object as consumed.
+	valueDone := func() {
+		if n := len(stack); n > 0 && !stack[n-1].array {
+			stack[n-1].expectKey = true
+		}
+	}
+
+	dec := json.NewDecoder(strings.NewReader(doc))
+	for {
+		before := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return spans
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			switch tok {
+			case '{':
+				stack = append(stack, frame{expectKey: true})
+			case '[':
+				stack = append(stack, frame{array: true})
+			default:
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+		case string:
+			if n := len(stack); n > 0 && !stack[n-1].array && stack[n-1].expectKey {
+				stack[n-1].key = tok
+				stack[n-1].expectKey = false
+				continue
+			}
+			if inLineText() {
+				start := before + strings.IndexByte(doc[before:], '"')
+				spans = append(spans, [2]int{start, int(dec.InputOffset())})
+			}
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
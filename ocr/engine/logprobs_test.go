@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+const logprobResponse = `{"text":{"raw":"TOTAL\n9.99","lines":[{"text":"TOTAL","confidence":0.99},{"text":"9.99","confidence":0.99}]}}`
+
+// byteTokens splits response into one token per byte, each with logprob 0
+// except those in the string value "low", which get log(0.25).
+func byteTokens(response, low string) []client.TokenLogprob {
+	start := strings.Index(response, `"text":"`+low+`"`) + len(`"text":`)
+	end := start + len(low) + 2
+	tokens := make([]client.TokenLogprob, len(response))
+	for i := range response {
+		tokens[i].Token = response[i : i+1]
+		if i >= start && i < end {
+			tokens[i].Logprob = math.Log(0.25)
+		}
+	}
+	return tokens
+}
+
+func TestLineTextSpans(t *testing.T) {
+	doc := `{"metadata":{"text":"not a line"},"text":{"raw":"a","lines":[{"confidence":1,"text":"a\"b"},{"text":"c","bounding_box":{"text":"x"}}]}}`
+	spans := lineTextSpans(doc)
+	var got []string
+	for _, s := range spans {
+		got = append(got, doc[s[0]:s[1]])
+	}
+	want := []string{`"a\"b"`, `"c"`}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("spans = %q, want %q", got, want)
+	}
+}
+
+func TestApplyLogprobConfidence(t *testing.T) {
+	resp, err := utils.ParseAndValidateJSON(logprobResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !applyLogprobConfidence(resp, logprobResponse, byteTokens(logprobResponse, "9.99")) {
+		t.Fatal("applyLogprobConfidence = false, want true")
+	}
+	if got := float64(resp.Text.Lines[0].Confidence); math.Abs(got-1) > 1e-9 {
+		t.Errorf("line 0 confidence = %v, want 1", got)
+	}
+	if got := float64(resp.Text.Lines[1].Confidence); math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("line 1 confidence = %v, want 0.25", got)
+	}
+
+	// Fenced answers are matched within the fence
+	fenced := "```json\n" + logprobResponse + "\n```"
+	resp, _ = utils.ParseAndValidateJSON(fenced)
+	if !applyLogprobConfidence(resp, fenced, byteTokens(fenced, "TOTAL")) {
+		t.Fatal("fenced answer: applyLogprobConfidence = false, want true")
+	}
+	if got := float64(resp.Text.Lines[0].Confidence); math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("fenced line 0 confidence = %v, want 0.25", got)
+	}
+}
+
+func TestApplyLogprobConfidence_Unusable(t *testing.T) {
+	tests := []struct {
+		name     string
+		logprobs []client.TokenLogprob
+	}{
+		{"none", nil},
+		{"do not add up", []client.TokenLogprob{{Token: `{"text":`, Logprob: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := utils.ParseAndValidateJSON(logprobResponse)
+			if applyLogprobConfidence(resp, logprobResponse, tt.logprobs) {
+				t.Error("applyLogprobConfidence = true, want false")
+			}
+			if resp.Text.Lines[0].Confidence != 0.99 {
+				t.Errorf("confidence = %v, want the reported 0.99 kept", resp.Text.Lines[0].Confidence)
+			}
+		})
+	}
+}
+
+// logprobBackend answers with logprobResponse, with per-byte logprobs if
+// withLogprobs is set, and records whether logprobs were requested.
+type logprobBackend struct {
+	withLogprobs bool
+	requested    bool
+}
+
+func (b *logprobBackend) Generate(ctx context.Context, req client.GenerateRequest) (*client.GenerateResponse, error) {
+	b.requested = req.Logprobs
+	resp := &client.GenerateResponse{Model: req.Model, Response: logprobResponse, Done: true}
+	if b.withLogprobs && req.Logprobs {
+		resp.Logprobs = byteTokens(logprobResponse, "9.99")
+	}
+	return resp, nil
+}
+
+func (b *logprobBackend) Ping(ctx context.Context) error { return nil }
+
+func TestProcess_LogprobConfidence(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	lineConfidence := func(t *testing.T, backend *logprobBackend, cfg ProcessConfig) models.Confidence {
+		t.Helper()
+		result, err := NewVisionEngine(backend, logger).Process(context.Background(), []byte("image"), cfg)
+		if err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		return result.VisionResponse.Text.Lines[1].Confidence
+	}
+
+	backend := &logprobBackend{withLogprobs: true}
+	got := lineConfidence(t, backend, ProcessConfig{Model: "m", WithConfidenceScores: true, LogprobConfidence: true})
+	if !backend.requested {
+		t.Error("logprobs should be requested")
+	}
+	if math.Abs(float64(got)-0.25) > 1e-9 {
+		t.Errorf("confidence = %v, want 0.25 from the logprobs", got)
+	}
+
+	// Without logprobs from the server the reported confidence is kept
+	backend = &logprobBackend{}
+	if got := lineConfidence(t, backend, ProcessConfig{Model: "m", WithConfidenceScores: true, LogprobConfidence: true}); got != 0.99 {
+		t.Errorf("confidence = %v, want the reported 0.99", got)
+	}
+
+	// Not requested without confidence scores
+	backend = &logprobBackend{withLogprobs: true}
+	lineConfidence(t, backend, ProcessConfig{Model: "m", LogprobConfidence: true})
+	if backend.requested {
+		t.Error("logprobs should not be requested without confidence scores")
+	}
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// partialResponse assembles a streamed model answer from its top-level
+// fields as they complete.
+type partialResponse struct {
+	scanner utils.JSONFieldScanner
+	fields  map[string]json.RawMessage
+}
+
+// feed adds a chunk of the answer. If the chunk completed a schema field, it
+// returns the response parsed from all fields so far; otherwise nil. Fields
+// that do not parse are left out rather than failing the snapshot.
+func (p *partialResponse) feed(chunk string) *models.OllamaVisionResponse {
+	added := false
+	for _, f := range p.scanner.Feed(chunk) {
+		one, err := json.Marshal(map[string]json.RawMessage{f.Key: f.Value})
+		if err != nil {
+			continue
+		}
+		var probe models.OllamaVisionResponse
+		if json.Unmarshal(one, &probe) != nil || reflect.ValueOf(probe).IsZero() {
+			continue
+		}
+		if p.fields == nil {
+			p.fields = make(map[string]json.RawMessage)
+		}
+		p.fields[f.Key] = f.Value
+		added = true
+	}
+	if !added {
+		return nil
+	}
+
+	data, err := json.Marshal(p.fields)
+	if err != nil {
+		return nil
+	}
+	var resp models.OllamaVisionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil
+	}
+	return &resp
+}
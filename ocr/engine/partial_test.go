@@ -0,0 +1,29 @@
+package engine
+
+import "testing"
+
+func TestPartialResponse_Feed(t *testing.T) {
+	var p partialResponse
+
+	resp := p.feed("```json\n{\"metadata\": {\"document_type\": \"receipt\", \"confidence_score\": 0.9}")
+	if resp == nil || resp.Metadata == nil || resp.Metadata.DocumentType != "receipt" || resp.Text != nil {
+		t.Fatalf("after metadata: %+v, want only the metadata", resp)
+	}
+
+	if resp := p.feed(`, "extra": {"ignored": true}, "text": {"raw": "TOTAL`); resp != nil {
+		t.Fatalf("after an unknown field and half the text: %+v, want nil", resp)
+	}
+
+	resp = p.feed(` 9.99", "lines": [{"text": "TOTAL 9.99"}]}, "structured_data": {"tables": "not a list"}`)
+	if resp == nil || resp.Metadata == nil || resp.Text == nil || resp.Text.Raw != "TOTAL 9.99" {
+		t.Fatalf("after text: %+v, want metadata and text", resp)
+	}
+	if resp.StructuredData != nil {
+		t.Errorf("StructuredData = %+v, want the malformed field left out", resp.StructuredData)
+	}
+
+	resp = p.feed(`, "summary": "A receipt."}`)
+	if resp == nil || resp.Summary == nil || *resp.Summary != "A receipt." || resp.Text == nil {
+		t.Fatalf("after summary: %+v, want every field so far", resp)
+	}
+}
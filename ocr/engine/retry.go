@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRetryDeadline is returned instead of retrying when the backoff before
+// the retry would end after the deadline of the request context.
+var ErrRetryDeadline = errors.New("retry backoff would pass the deadline")
+
+// RetryBudget caps the number of parse-failure retries across all model
+// calls of one extraction, e.g. every page of a PDF. A nil budget is
+// unlimited. It is safe for concurrent use.
+type RetryBudget struct {
+	remaining atomic.Int64
+}
+
+// NewRetryBudget returns a budget that allows n retries in total.
+func NewRetryBudget(n int) *RetryBudget {
+	b := &RetryBudget{}
+	b.remaining.Store(int64(n))
+	return b
+}
+
+// Take consumes one retry and reports whether one was available.
+func (b *RetryBudget) Take() bool {
+	if b == nil {
+		return true
+	}
+	for {
+		n := b.remaining.Load()
+		if n <= 0 {
+			return false
+		}
+		if b.remaining.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+// Remaining returns the number of retries left, or -1 for a nil budget.
+func (b *RetryBudget) Remaining() int {
+	if b == nil {
+		return -1
+	}
+	return int(b.remaining.Load())
+}
+
+// backoff returns the delay before retry n, counted from 1: base, doubled
+// for each earlier retry and capped at maxDelay if that is positive.
+func backoff(base, maxDelay time.Duration, n int) time.Duration {
+	if base <= 0 || n < 1 {
+		return 0
+	}
+	d := base
+	for i := 1; i < n && (maxDelay <= 0 || d < maxDelay); i++ {
+		d *= 2
+	}
+	if maxDelay > 0 {
+		d = min(d, maxDelay)
+	}
+	return d
+}
+
+// waitRetry sleeps for d before a retry. If ctx has a deadline that comes
+// before d has passed it returns ErrRetryDeadline at once rather than
+// sleeping for nothing, and if ctx is done during the sleep it returns
+// ctx.Err().
+func waitRetry(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return ErrRetryDeadline
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
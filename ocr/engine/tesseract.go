@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// TesseractModelName is reported as the model for results produced by the
+// Tesseract engine.
+const TesseractModelName = "tesseract"
+
+// TesseractEngine runs OCR with a locally installed Tesseract binary. It is a
+// lightweight alternative to the vision engine for machines without a GPU.
+// It extracts text and line geometry only: no structured data, summary,
+// language or document type.
+type TesseractEngine struct {
+	logger *slog.Logger
+}
+
+// NewTesseractEngine creates a new TesseractEngine.
+func NewTesseractEngine(logger *slog.Logger) *TesseractEngine {
+	return &TesseractEngine{logger: logger}
+}
+
+// TesseractAvailable reports whether the tesseract binary is on PATH.
+func TesseractAvailable() bool {
+	_, err := exec.LookPath("tesseract")
+	return err == nil
+}
+
+// Process runs Tesseract on a single image.
+func (e *TesseractEngine) Process(ctx context.Context, imageData []byte, cfg ProcessConfig) (*ProcessResult, error) {
+	startTime := time.Now()
+	logger := cfg.logger(e.logger)
+
+	logger.Info("starting tesseract OCR processing",
+		slog.String("request_id", cfg.RequestID),
+		slog.Int("image_bytes", len(imageData)),
+	)
+
+	bin, err := exec.LookPath("tesseract")
+	if err != nil {
+		return nil, fmt.Errorf("tesseract not found: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "stdin", "stdout", "tsv")
+	cmd.Stdin = bytes.NewReader(imageData)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tesseract failed: %s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+	modelLatency := time.Since(startTime)
+
+	parseStart := time.Now()
+	visionResp, err := parseTesseractTSV(stdout.String())
+	if err != nil {
+		return nil, fmt.Errorf("parse tesseract output: %w", err)
+	}
+	parseLatency := time.Since(parseStart)
+
+	latency := time.Since(startTime)
+	logger.Info("tesseract OCR processing complete",
+		slog.String("request_id", cfg.RequestID),
+		slog.Duration("latency", latency),
+	)
+
+	var warnings []string
+	if cfg.WithStructuredExtraction {
+		warnings = append(warnings, "structured extraction is not supported by the tesseract engine")
+	}
+	if cfg.WithSummary {
+		warnings = append(warnings, "summary is not supported by the tesseract engine")
+	}
+	if cfg.WithTextDirection {
+		warnings = append(warnings, "text direction detection is not supported by the tesseract engine")
+	}
+	if cfg.WithDocumentRegions {
+		warnings = append(warnings, "document detection is not supported by the tesseract engine")
+	}
+	if cfg.RawJSON {
+		warnings = append(warnings, "raw JSON is not supported by the tesseract engine")
+	}
+
+	return &ProcessResult{
+		VisionResponse: visionResp,
+		Model:          TesseractModelName,
+		Latency:        latency,
+		ModelLatency:   modelLatency,
+		ParseLatency:   parseLatency,
+		Warnings:       warnings,
+	}, nil
+}
+
+// ProcessPDF renders each PDF page and runs Tesseract on it.
+func (e *TesseractEngine) ProcessPDF(ctx context.Context, pdfPath string, cfg ProcessConfig) (*ProcessResult, error) {
+	return processPDF(ctx, e.logger, pdfPath, cfg, e.Process)
+}
+
+// tsvLineKey identifies a text line in Tesseract's TSV output.
+type tsvLineKey struct {
+	page, block, par, line int
+}
+
+// tsvLine accumulates the words and geometry of one text line.
+type tsvLine struct {
+	key     tsvLineKey
+	box     *models.BoundingBox
+	words   []string
+	confSum float64
+	confN   int
+}
+
+// parseTesseractTSV converts Tesseract's TSV output into the same
+// intermediate structure the vision engine produces. Line boxes come from
+// level-4 rows; line text and confidence are built from level-5 word rows.
+func parseTesseractTSV(tsv string) (*models.OllamaVisionResponse, error) {
+	rows := strings.Split(strings.TrimRight(tsv, "\n"), "\n")
+	if len(rows) == 0 || !strings.HasPrefix(rows[0], "level") {
+		return nil, fmt.Errorf("missing TSV header")
+	}
+
+	var (
+		order []tsvLineKey
+		lines = make(map[tsvLineKey]*tsvLine)
+	)
+
+	lineFor := func(key tsvLineKey) *tsvLine {
+		l, ok := lines[key]
+		if !ok {
+			l = &tsvLine{key: key}
+			lines[key] = l
+			order = append(order, key)
+		}
+		return l
+	}
+
+	for i, row := range rows[1:] {
+		cols := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(cols) < 11 {
+			continue
+		}
+
+		nums := make([]int, 10)
+		for j := range nums {
+			n, err := strconv.Atoi(cols[j])
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %d: %w", i+2, j+1, err)
+			}
+			nums[j] = n
+		}
+		level := nums[0]
+		key := tsvLineKey{page: nums[1], block: nums[2], par: nums[3], line: nums[4]}
+
+		switch level {
+		case 4:
+			lineFor(key).box = &models.BoundingBox{
+				X:      float64(nums[6]),
+				Y:      float64(nums[7]),
+				Width:  float64(nums[8]),
+				Height: float64(nums[9]),
+			}
+		case 5:
+			text := ""
+			if len(cols) > 11 {
+				text = strings.TrimSpace(cols[11])
+			}
+			if text == "" {
+				continue
+			}
+			l := lineFor(key)
+			l.words = append(l.words, text)
+			if conf, err := strconv.ParseFloat(cols[10], 64); err == nil && conf >= 0 {
+				l.confSum += conf / 100
+				l.confN++
+			}
+		}
+	}
+
+	resp := &models.OllamaVisionResponse{
+		Metadata: &models.OllamaMetadata{DocumentType: string(models.DocumentTypeUnknown)},
+		Text:     &models.OllamaTextResult{},
+	}
+
+	var (
+		raw          strings.Builder
+		prev         *tsvLineKey
+		docConfSum   float64
+		docConfLines int
+	)
+	for _, key := range order {
+		l := lines[key]
+		if len(l.words) == 0 {
+			continue
+		}
+
+		text := strings.Join(l.words, " ")
+		conf := 0.0
+		if l.confN > 0 {
+			conf = l.confSum / float64(l.confN)
+			docConfSum += conf
+			docConfLines++
+		}
+
+		if prev != nil {
+			raw.WriteString("\n")
+			if prev.page != key.page || prev.block != key.block || prev.par != key.par {
+				raw.WriteString("\n")
+			}
+		}
+		raw.WriteString(text)
+		k := key
+		prev = &k
+
+		resp.Text.Lines = append(resp.Text.Lines, models.OllamaTextLine{
+			Text:        text,
+			BoundingBox: l.box,
+			Confidence:  models.Confidence(conf),
+		})
+	}
+
+	resp.Text.Raw = raw.String()
+	if docConfLines > 0 {
+		resp.Metadata.ConfidenceScore = models.Confidence(docConfSum / float64(docConfLines))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+)
+
+const tesseractTSVFixture = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n" +
+	"1\t1\t0\t0\t0\t0\t0\t0\t600\t400\t-1\t\n" +
+	"2\t1\t1\t0\t0\t0\t10\t10\t300\t60\t-1\t\n" +
+	"3\t1\t1\t1\t0\t0\t10\t10\t300\t60\t-1\t\n" +
+	"4\t1\t1\t1\t1\t0\t10\t10\t200\t20\t-1\t\n" +
+	"5\t1\t1\t1\t1\t1\t10\t10\t90\t20\t96.5\tACME\n" +
+	"5\t1\t1\t1\t1\t2\t110\t10\t100\t20\t89.5\tStore\n" +
+	"4\t1\t1\t1\t2\t0\t10\t40\t150\t20\t-1\t\n" +
+	"5\t1\t1\t1\t2\t1\t10\t40\t150\t20\t80\tReceipt\n" +
+	"2\t1\t2\t0\t0\t0\t10\t100\t300\t20\t-1\t\n" +
+	"3\t1\t2\t1\t0\t0\t10\t100\t300\t20\t-1\t\n" +
+	"4\t1\t2\t1\t1\t0\t10\t100\t120\t20\t-1\t\n" +
+	"5\t1\t2\t1\t1\t1\t10\t100\t120\t20\t70\tTOTAL\n" +
+	"4\t1\t2\t1\t2\t0\t10\t130\t120\t20\t-1\t\n" +
+	"5\t1\t2\t1\t2\t1\t10\t130\t120\t20\t-1\t \n"
+
+func TestParseTesseractTSV(t *testing.T) {
+	resp, err := parseTesseractTSV(tesseractTSVFixture)
+	if err != nil {
+		t.Fatalf("parseTesseractTSV: %v", err)
+	}
+
+	wantRaw := "ACME Store\nReceipt\n\nTOTAL"
+	if resp.Text.Raw != wantRaw {
+		t.Errorf("Raw = %q, want %q", resp.Text.Raw, wantRaw)
+	}
+
+	if len(resp.Text.Lines) != 3 {
+		t.Fatalf("len(Lines) = %d, want 3 (empty lines skipped)", len(resp.Text.Lines))
+	}
+
+	first := resp.Text.Lines[0]
+	if first.Text != "ACME Store" {
+		t.Errorf("Lines[0].Text = %q, want %q", first.Text, "ACME Store")
+	}
+	if math.Abs(float64(first.Confidence)-0.93) > 1e-9 {
+		t.Errorf("Lines[0].Confidence = %v, want 0.93", first.Confidence)
+	}
+	if first.BoundingBox == nil || first.BoundingBox.X != 10 || first.BoundingBox.Width != 200 {
+		t.Errorf("Lines[0].BoundingBox = %+v, want x=10 width=200", first.BoundingBox)
+	}
+
+	if resp.Metadata.DocumentType != "unknown" {
+		t.Errorf("DocumentType = %q, want %q", resp.Metadata.DocumentType, "unknown")
+	}
+	wantDocConf := (0.93 + 0.80 + 0.70) / 3
+	if math.Abs(float64(resp.Metadata.ConfidenceScore)-wantDocConf) > 1e-9 {
+		t.Errorf("ConfidenceScore = %v, want %v", resp.Metadata.ConfidenceScore, wantDocConf)
+	}
+	if resp.StructuredData != nil || resp.Summary != nil {
+		t.Error("tesseract output should not include structured data or summary")
+	}
+}
+
+func TestParseTesseractTSV_MissingHeader(t *testing.T) {
+	if _, err := parseTesseractTSV("garbage output"); err == nil {
+		t.Fatal("expected error for output without TSV header")
+	}
+}
+
+func TestTesseractEngine_NotInstalled(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	if TesseractAvailable() {
+		t.Fatal("TesseractAvailable should be false with an empty PATH")
+	}
+
+	eng := NewTesseractEngine(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if _, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{}); err == nil {
+		t.Fatal("expected error when tesseract is not installed")
+	}
+}
@@ -2,7 +2,9 @@
 package engine
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"path/filepath"
@@ -15,32 +17,147 @@ import (
 	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
 )
 
+// DefaultNumPredict caps the generated tokens when ProcessConfig.NumPredict
+// is not set. ocr.DefaultNumPredict refers to it.
+const DefaultNumPredict = 4096
+
+// thumbnailMaxSide is the longest side of the thumbnail sent with
+// ProcessConfig.ThumbnailHint, in pixels.
+const thumbnailMaxSide = 512
+
+// ErrEmptyResponse is returned when the model answers with an empty
+// response on every attempt.
+var ErrEmptyResponse = errors.New("model returned empty response")
+
 // VisionEngine orchestrates the OCR pipeline:
-// load image → build prompt → call Ollama → parse/validate → return result
+// load image → build prompt → call the model → parse/validate → return result
 type VisionEngine struct {
-	client *client.OllamaClient
+	client client.VisionBackend
 	logger *slog.Logger
 }
 
-// NewVisionEngine creates a new VisionEngine.
-func NewVisionEngine(ollamaClient *client.OllamaClient, logger *slog.Logger) *VisionEngine {
+// NewVisionEngine creates a new VisionEngine that sends requests to backend.
+func NewVisionEngine(backend client.VisionBackend, logger *slog.Logger) *VisionEngine {
 	return &VisionEngine{
-		client: ollamaClient,
+		client: backend,
 		logger: logger,
 	}
 }
 
 // ProcessConfig holds per-request processing parameters.
 type ProcessConfig struct {
-	Model       string
-	Temperature float64
-	RequestID   string
+	Model          string
+	FallbackModels []string
+	Temperature    float64
+	NumPredict     int // 0 uses DefaultNumPredict
+	TopP           float64
+	Stop           []string
+	Seed           *int
+	NumGPU         *int
+	NumThread      *int
+	RequestID      string
+
+	// Logger, if set, replaces the engine's logger for this call, e.g. a
+	// logger scoped to one PDF page.
+	Logger *slog.Logger
 
 	WithSummary              bool
 	WithLanguageDetection    bool
 	WithStructuredExtraction bool
 	WithBoundingBoxes        bool
 	WithConfidenceScores     bool
+	WithKeyValueConfidence   bool
+	WithTableConfidence      bool
+	WithTableSpans           bool
+	WithTextDirection        bool
+	WithDocumentRegions      bool
+	WithWatermarks           bool
+	FreeformDocumentType     bool
+
+	ExpectedDocumentType string
+
+	SummaryLength   string
+	SummaryMaxWords int
+
+	DebugRequestLog   bool
+	DebugPromptLength int
+
+	// RetryBudget, if set, is shared by every page of the request; a parse
+	// failure is only retried while it has retries left.
+	RetryBudget *RetryBudget
+
+	// RetryBackoff is the delay before the first retry of a model call,
+	// doubled for each further one and capped at RetryMaxBackoff if that is
+	// positive. A retry whose delay would end past the deadline of ctx is
+	// not attempted. 0 retries at once.
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	// MaxTotalTokens, if positive, caps the prompt and eval tokens of a PDF.
+	// Once the pages processed so far exceed it, the remaining pages are
+	// skipped and ProcessResult.BudgetExceeded is set.
+	MaxTotalTokens int
+
+	// DeadlinePadding shortens the deadline of each model call by this much
+	// so a late response still leaves time to parse and merge before ctx
+	// expires. It has no effect if ctx has no deadline.
+	DeadlinePadding time.Duration
+
+	// ResponseTimeout, if positive, bounds each model call. It never extends
+	// the deadline of ctx, so a call gets the shorter of the two.
+	ResponseTimeout time.Duration
+
+	// RawJSON keeps the model's JSON as a generic map in
+	// ProcessResult.RawData instead of requiring it to match the schema.
+	RawJSON bool
+
+	// SchemaValidation checks the model's answer against
+	// utils.ResponseSchema before accepting it, and retries, like a parse
+	// failure, if it does not conform. Ignored with RawJSON.
+	SchemaValidation bool
+
+	// PDFPassword opens encrypted PDFs.
+	PDFPassword string
+
+	// JPEGQuality, if set (1-100), re-encodes every image as JPEG at this
+	// quality before it is sent to the model.
+	JPEGQuality int
+
+	// ExtractFormFields reads the values of AcroForm fields from PDFs into
+	// ProcessResult.FormFields.
+	ExtractFormFields bool
+
+	// ExtractPDFMetadata reads the document information dictionary of PDFs
+	// into ProcessResult.PDFInfo.
+	ExtractPDFMetadata bool
+
+	// ThumbnailHint sends a downscaled copy of the image, at most
+	// thumbnailMaxSide pixels per side, ahead of the full image as a layout
+	// overview. Images that small already are sent alone.
+	ThumbnailHint bool
+
+	// CompactPrompt builds the compact variant of the prompt.
+	CompactPrompt bool
+
+	// LogprobConfidence requests token log probabilities and derives each
+	// line's confidence from them instead of the model's self-report. If
+	// the backend returns none, the reported confidences are kept. It needs
+	// WithConfidenceScores.
+	LogprobConfidence bool
+
+	// FewShotExamples are added ahead of the prompt; see prompt.WithExamples.
+	FewShotExamples []prompt.Example
+
+	// RetryOnEmptyText retries once, with a stronger instruction, when the
+	// model's answer parses but holds no text. The retry takes from
+	// RetryBudget; without one left the answer is accepted as is.
+	RetryOnEmptyText bool
+
+	// OnPartial, if set and the backend can stream, is called with the
+	// response parsed so far each time a top-level field of the model's
+	// answer completes. A retried request starts over. It is not called
+	// with RawJSON.
+	OnPartial func(*models.OllamaVisionResponse)
 }
 
 // ProcessResult holds the engine output.
@@ -50,15 +167,117 @@ type ProcessResult struct {
 	PromptTokens   int
 	EvalTokens     int
 	Latency        time.Duration
+
+	// Stage breakdown of Latency: PDF page rendering, model inference and
+	// parsing of the model output.
+	PreprocessLatency time.Duration
+	ModelLatency      time.Duration
+	ParseLatency      time.Duration
+
+	// RawData is the model's JSON, decoded without a schema. It is only set
+	// with ProcessConfig.RawJSON; merged results hold the parts in a list
+	// under "pages" or "regions".
+	RawData map[string]any
+
+	// FormFields holds the filled-in AcroForm fields of a PDF by fully
+	// qualified name. It is only set with ProcessConfig.ExtractFormFields.
+	FormFields map[string]string
+
+	// PDFInfo is the document information dictionary of a PDF. It is only
+	// set with ProcessConfig.ExtractPDFMetadata.
+	PDFInfo *models.PDFInfo
+
+	// Warnings lists non-fatal limitations of the engine for this request.
+	Warnings []string
+
+	// BudgetExceeded is set when the pages of a PDF used more tokens than
+	// ProcessConfig.MaxTotalTokens.
+	BudgetExceeded bool
+
+	// Transforms lists the geometric transforms applied to the image before
+	// the model saw it, in order, so boxes can be mapped back.
+	Transforms []models.ImageTransform
+
+	// SentImage is the image as sent to the model, after any JPEG
+	// re-encoding. It is only set for a single image, not for PDFs.
+	SentImage []byte
+}
+
+// logger returns cfg.Logger, or fallback if it is not set.
+func (cfg ProcessConfig) logger(fallback *slog.Logger) *slog.Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return fallback
 }
 
 // Process runs OCR on a single image (as bytes) using the Ollama vision model.
+// If the primary model fails, each of cfg.FallbackModels is tried in order.
 func (e *VisionEngine) Process(ctx context.Context, imageData []byte, cfg ProcessConfig) (*ProcessResult, error) {
+	logger := cfg.logger(e.logger)
+	if cfg.JPEGQuality > 0 {
+		jpg, err := utils.EncodeJPEG(imageData, cfg.JPEGQuality)
+		if err != nil {
+			// e.g. a raw PDF that could not be rendered; send it as is
+			logger.Warn("JPEG re-encoding failed, sending the original image",
+				slog.String("request_id", cfg.RequestID),
+				slog.String("error", err.Error()),
+			)
+		} else {
+			imageData = jpg
+		}
+	}
+
+	var thumbnail []byte
+	if cfg.ThumbnailHint {
+		thumb, err := utils.Thumbnail(imageData, thumbnailMaxSide)
+		if err != nil {
+			logger.Warn("thumbnail generation failed, sending the full image only",
+				slog.String("request_id", cfg.RequestID),
+				slog.String("error", err.Error()),
+			)
+		}
+		thumbnail = thumb
+	}
+
+	candidates := append([]string{cfg.Model}, cfg.FallbackModels...)
+
+	var lastErr error
+	for i, model := range candidates {
+		if i > 0 {
+			logger.Warn("falling back to alternate model",
+				slog.String("request_id", cfg.RequestID),
+				slog.String("failed_model", candidates[i-1]),
+				slog.String("fallback_model", model),
+				slog.String("error", lastErr.Error()),
+			)
+		}
+
+		result, err := e.processWithModel(ctx, imageData, thumbnail, model, cfg)
+		if err == nil {
+			result.SentImage = imageData
+			return result, nil
+		}
+		lastErr = fmt.Errorf("model %q: %w", model, err)
+
+		// Don't burn through fallbacks once the request is canceled
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// processWithModel runs OCR on a single image with one specific model. A
+// non-nil thumbnail is sent ahead of the image as a layout overview.
+func (e *VisionEngine) processWithModel(ctx context.Context, imageData, thumbnail []byte, model string, cfg ProcessConfig) (*ProcessResult, error) {
 	startTime := time.Now()
+	logger := cfg.logger(e.logger)
 
-	e.logger.Info("starting OCR processing",
+	logger.Info("starting OCR processing",
 		slog.String("request_id", cfg.RequestID),
-		slog.String("model", cfg.Model),
+		slog.String("model", model),
 		slog.Int("image_bytes", len(imageData)),
 	)
 
@@ -69,41 +288,106 @@ func (e *VisionEngine) Process(ctx context.Context, imageData []byte, cfg Proces
 		WithStructuredExtraction: cfg.WithStructuredExtraction,
 		WithBoundingBoxes:        cfg.WithBoundingBoxes,
 		WithConfidenceScores:     cfg.WithConfidenceScores,
+		WithKeyValueConfidence:   cfg.WithKeyValueConfidence,
+		WithTableConfidence:      cfg.WithTableConfidence,
+		WithTableSpans:           cfg.WithTableSpans,
+		WithTextDirection:        cfg.WithTextDirection,
+		WithDocumentRegions:      cfg.WithDocumentRegions,
+		WithWatermarks:           cfg.WithWatermarks,
+		FreeformDocumentType:     cfg.FreeformDocumentType,
+		WithThumbnail:            thumbnail != nil,
+		Compact:                  cfg.CompactPrompt,
+		ExpectedDocumentType:     cfg.ExpectedDocumentType,
+		SummaryLength:            cfg.SummaryLength,
+		SummaryMaxWords:          cfg.SummaryMaxWords,
+		Model:                    model,
 	}
-	ocrPrompt := prompt.BuildOCRPrompt(promptCfg)
+	ocrPrompt := prompt.WithExamples(prompt.BuildOCRPrompt(promptCfg), cfg.FewShotExamples)
 
-	// Encode image
-	base64Image := utils.EncodeBase64(imageData)
+	// Encode image, after its thumbnail if there is one
+	images := []string{utils.EncodeBase64(imageData)}
+	if thumbnail != nil {
+		images = append([]string{utils.EncodeBase64(thumbnail)}, images...)
+	}
 
 	// Build Ollama request
 	req := client.GenerateRequest{
-		Model:  cfg.Model,
+		Model:  model,
 		Prompt: ocrPrompt,
-		Images: []string{base64Image},
+		Images: images,
 		Stream: false,
 		Format: "json",
 		Options: &client.ModelOptions{
 			Temperature: cfg.Temperature,
-			NumPredict:  4096,
+			NumPredict:  cmp.Or(cfg.NumPredict, DefaultNumPredict),
+			TopP:        cfg.TopP,
+			Stop:        cfg.Stop,
+			Seed:        cfg.Seed,
+			NumGPU:      cfg.NumGPU,
+			NumThread:   cfg.NumThread,
 		},
+		Logprobs: cfg.LogprobConfidence && cfg.WithConfidenceScores,
 	}
 
-	// Call Ollama — attempt + 1 retry on JSON parse failure
-	var lastErr error
-	for attempt := 0; attempt <= 1; attempt++ {
-		if attempt > 0 {
-			e.logger.Warn("retrying OCR request due to JSON parse failure",
+	if cfg.DebugRequestLog {
+		logger.Debug("ollama request",
+			slog.String("request_id", cfg.RequestID),
+			slog.Any("request", req.Redacted(cfg.DebugPromptLength)),
+		)
+	}
+
+	// Call Ollama — attempt + 1 retry on an empty or unparsable response,
+	// plus one with RetryOnEmptyText
+	var (
+		lastErr      error
+		modelLatency time.Duration
+		parseLatency time.Duration
+
+		// emptyText is a parsed response without text, kept in case its
+		// retry fails
+		emptyText *ProcessResult
+	)
+	maxAttempts := 2
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 && emptyText == nil {
+			if !cfg.RetryBudget.Take() {
+				logger.Warn("retry budget exhausted, not retrying",
+					slog.String("request_id", cfg.RequestID),
+				)
+				return nil, fmt.Errorf("retry budget exhausted: %w", lastErr)
+			}
+			logger.Warn("retrying OCR request after an unusable response",
 				slog.String("request_id", cfg.RequestID),
 				slog.Int("attempt", attempt),
 			)
 		}
+		if attempt > 0 {
+			delay := backoff(cfg.RetryBackoff, cfg.RetryMaxBackoff, attempt)
+			if err := waitRetry(ctx, delay); err != nil {
+				logger.Warn("retry abandoned during backoff",
+					slog.String("request_id", cfg.RequestID),
+					slog.Duration("backoff", delay),
+					slog.String("error", err.Error()),
+				)
+				if emptyText != nil {
+					break
+				}
+				return nil, fmt.Errorf("%w: %w", err, lastErr)
+			}
+		}
 
-		resp, err := e.client.Generate(ctx, req)
+		generateStart := time.Now()
+		generateCtx, cancel := generateContext(ctx, cfg.DeadlinePadding)
+		generateCtx, cancelResponse := responseContext(generateCtx, cfg.ResponseTimeout)
+		resp, err := e.generate(generateCtx, req, cfg)
+		cancelResponse()
+		cancel()
+		modelLatency += time.Since(generateStart)
 		if err != nil {
 			return nil, fmt.Errorf("ollama generate (attempt %d): %w", attempt, err)
 		}
 
-		e.logger.Info("ollama response received",
+		logger.Info("ollama response received",
 			slog.String("request_id", cfg.RequestID),
 			slog.Int("prompt_eval_count", resp.PromptEvalCount),
 			slog.Int("eval_count", resp.EvalCount),
@@ -111,11 +395,35 @@ func (e *VisionEngine) Process(ctx context.Context, imageData []byte, cfg Proces
 			slog.Int("response_length", len(resp.Response)),
 		)
 
+		// Some Ollama errors come back as an empty 200
+		if strings.TrimSpace(resp.Response) == "" {
+			lastErr = fmt.Errorf("attempt %d: %w", attempt, ErrEmptyResponse)
+			logger.Warn("model returned empty response",
+				slog.String("request_id", cfg.RequestID),
+			)
+			continue
+		}
+
 		// Parse JSON
-		visionResp, err := utils.ParseAndValidateJSON(resp.Response)
+		parseStart := time.Now()
+		var (
+			visionResp *models.OllamaVisionResponse
+			rawData    map[string]any
+		)
+		if cfg.RawJSON {
+			rawData, visionResp, err = utils.ParseRawJSON(resp.Response)
+		} else {
+			visionResp, err = utils.ParseAndValidateJSON(resp.Response)
+		}
+		if err == nil && cfg.SchemaValidation && !cfg.RawJSON {
+			if err = utils.ValidateResponseSchema(resp.Response); err != nil {
+				err = fmt.Errorf("schema validation: %w", err)
+			}
+		}
+		parseLatency += time.Since(parseStart)
 		if err != nil {
 			lastErr = fmt.Errorf("parse response (attempt %d): %w", attempt, err)
-			e.logger.Warn("JSON parse failed",
+			logger.Warn("JSON parse failed",
 				slog.String("request_id", cfg.RequestID),
 				slog.String("error", err.Error()),
 				slog.String("raw_response_preview", truncate(resp.Response, 500)),
@@ -123,131 +431,108 @@ func (e *VisionEngine) Process(ctx context.Context, imageData []byte, cfg Proces
 			continue
 		}
 
+		if req.Logprobs && !cfg.RawJSON && !applyLogprobConfidence(visionResp, resp.Response, resp.Logprobs) {
+			logger.Warn("no usable token logprobs, keeping model-reported confidences",
+				slog.String("request_id", cfg.RequestID),
+				slog.Int("logprobs", len(resp.Logprobs)),
+			)
+		}
+
 		latency := time.Since(startTime)
-		e.logger.Info("OCR processing complete",
+		logger.Info("OCR processing complete",
 			slog.String("request_id", cfg.RequestID),
 			slog.Duration("latency", latency),
 		)
 
-		return &ProcessResult{
+		usedModel := resp.Model
+		if usedModel == "" {
+			usedModel = model
+		}
+
+		result := &ProcessResult{
 			VisionResponse: visionResp,
-			Model:          resp.Model,
+			RawData:        rawData,
+			Model:          usedModel,
 			PromptTokens:   resp.PromptEvalCount,
 			EvalTokens:     resp.EvalCount,
 			Latency:        latency,
-		}, nil
-	}
-
-	return nil, fmt.Errorf("all attempts failed: %w", lastErr)
-}
-
-// ProcessPDF handles multi-page PDF processing by converting pages to images
-// and processing each page, then merging results.
-func (e *VisionEngine) ProcessPDF(ctx context.Context, pdfPath string, cfg ProcessConfig) (*ProcessResult, error) {
-	e.logger.Info("processing PDF",
-		slog.String("request_id", cfg.RequestID),
-		slog.String("path", pdfPath),
-	)
-
-	pages, err := utils.PDFToImages(pdfPath)
-	if err != nil {
-		return nil, fmt.Errorf("convert PDF to images: %w", err)
-	}
-
-	if len(pages) == 0 {
-		return nil, fmt.Errorf("PDF produced no pages")
-	}
-
-	// If single page, process directly
-	if len(pages) == 1 {
-		return e.Process(ctx, pages[0], cfg)
-	}
-
-	// Multi-page: process each and merge
-	var allResults []*ProcessResult
-	for i, page := range pages {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+			ModelLatency:   modelLatency,
+			ParseLatency:   parseLatency,
 		}
 
-		e.logger.Info("processing PDF page",
-			slog.String("request_id", cfg.RequestID),
-			slog.Int("page", i+1),
-			slog.Int("total_pages", len(pages)),
-		)
-
-		result, err := e.Process(ctx, page, cfg)
-		if err != nil {
-			return nil, fmt.Errorf("process page %d: %w", i+1, err)
+		if cfg.RetryOnEmptyText && !cfg.RawJSON && emptyText == nil && !hasText(visionResp) {
+			if !cfg.RetryBudget.Take() {
+				logger.Warn("retry budget exhausted, accepting response without text",
+					slog.String("request_id", cfg.RequestID),
+				)
+				return result, nil
+			}
+			logger.Warn("model returned no text, retrying with a stronger instruction",
+				slog.String("request_id", cfg.RequestID),
+			)
+			emptyText = result
+			req.Prompt += prompt.EmptyTextRetryInstruction
+			maxAttempts = attempt + 2
+			continue
 		}
-		allResults = append(allResults, result)
-	}
-
-	// Merge results
-	return mergeResults(allResults), nil
-}
 
-// mergeResults combines multiple page results into a single result.
-func mergeResults(results []*ProcessResult) *ProcessResult {
-	if len(results) == 0 {
-		return nil
-	}
-	if len(results) == 1 {
-		return results[0]
+		return result, nil
 	}
 
-	merged := &ProcessResult{
-		VisionResponse: &models.OllamaVisionResponse{
-			Metadata: results[0].VisionResponse.Metadata,
-			Text: &models.OllamaTextResult{
-				Raw:   "",
-				Lines: nil,
-			},
-			StructuredData: &models.OllamaStructuredData{
-				KeyValuePairs: make(map[string]string),
-				Tables:        nil,
-			},
-			Summary: nil,
-		},
-		Model: results[0].Model,
+	if emptyText != nil {
+		// The retry failed; the response without text is still valid
+		emptyText.ModelLatency, emptyText.ParseLatency = modelLatency, parseLatency
+		emptyText.Latency = time.Since(startTime)
+		return emptyText, nil
 	}
+	return nil, fmt.Errorf("all attempts failed: %w", lastErr)
+}
 
-	var rawParts []string
-	var totalLatency time.Duration
-
-	for i, r := range results {
-		totalLatency += r.Latency
-		merged.PromptTokens += r.PromptTokens
-		merged.EvalTokens += r.EvalTokens
+// hasText reports whether resp holds any raw text or lines.
+func hasText(resp *models.OllamaVisionResponse) bool {
+	return resp.Text != nil && (strings.TrimSpace(resp.Text.Raw) != "" || len(resp.Text.Lines) > 0)
+}
 
-		if r.VisionResponse.Text != nil {
-			pagePrefix := fmt.Sprintf("--- Page %d ---\n", i+1)
-			rawParts = append(rawParts, pagePrefix+r.VisionResponse.Text.Raw)
-			merged.VisionResponse.Text.Lines = append(merged.VisionResponse.Text.Lines, r.VisionResponse.Text.Lines...)
-		}
+// generate calls the backend, streaming the answer into cfg.OnPartial if it
+// is set and the backend supports streaming.
+func (e *VisionEngine) generate(ctx context.Context, req client.GenerateRequest, cfg ProcessConfig) (*client.GenerateResponse, error) {
+	streamer, ok := e.client.(client.StreamingBackend)
+	if cfg.OnPartial == nil || cfg.RawJSON || !ok {
+		return e.client.Generate(ctx, req)
+	}
 
-		if r.VisionResponse.StructuredData != nil {
-			for k, v := range r.VisionResponse.StructuredData.KeyValuePairs {
-				merged.VisionResponse.StructuredData.KeyValuePairs[k] = v
-			}
-			merged.VisionResponse.StructuredData.Tables = append(
-				merged.VisionResponse.StructuredData.Tables,
-				r.VisionResponse.StructuredData.Tables...,
-			)
+	var partial partialResponse
+	return streamer.GenerateStream(ctx, req, func(chunk string) {
+		if resp := partial.feed(chunk); resp != nil {
+			cfg.OnPartial(resp)
 		}
+	})
+}
 
-		// Use the summary from the last page if available
-		if r.VisionResponse.Summary != nil {
-			merged.VisionResponse.Summary = r.VisionResponse.Summary
-		}
+// generateContext returns ctx with its deadline moved padding earlier. If ctx
+// has no deadline, or less than padding remains, ctx is returned unchanged so
+// the model call keeps whatever time is left.
+func generateContext(ctx context.Context, padding time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if padding <= 0 || !ok || time.Until(deadline) <= padding {
+		return ctx, func() {}
 	}
+	return context.WithDeadline(ctx, deadline.Add(-padding))
+}
 
-	merged.VisionResponse.Text.Raw = strings.Join(rawParts, "\n")
-	merged.Latency = totalLatency
+// responseContext returns ctx bounded by timeout, or ctx unchanged if timeout
+// is not positive. The result keeps the deadline of ctx if it is earlier.
+func responseContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
 
-	return merged
+// ProcessPDF handles multi-page PDF processing by converting pages to images
+// and processing each page, then merging results.
+func (e *VisionEngine) ProcessPDF(ctx context.Context, pdfPath string, cfg ProcessConfig) (*ProcessResult, error) {
+	return processPDF(ctx, e.logger, pdfPath, cfg, e.Process)
 }
 
 // IsPDF checks if a file extension indicates a PDF.
@@ -0,0 +1,655 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/prompt"
+)
+
+const validModelResponse = `{"metadata":{"document_type":"receipt","confidence_score":0.9},"text":{"raw":"TOTAL 9.99","lines":[{"text":"TOTAL 9.99","confidence":0.9}]},"structured_data":{"key_value_pairs":{},"tables":[]},"summary":null}`
+
+// newTestEngine starts a mock Ollama server backed by handler and returns an
+// engine pointed at it.
+func newTestEngine(t *testing.T, handler func(req client.GenerateRequest) (int, string)) *VisionEngine {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req client.GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		status, response := handler(req)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+			return
+		}
+		json.NewEncoder(w).Encode(client.GenerateResponse{
+			Model:    req.Model,
+			Response: response,
+			Done:     true,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	return NewVisionEngine(client.NewOllamaClient(server.URL, 10*time.Second), logger)
+}
+
+func TestProcess_FallbackModel(t *testing.T) {
+	var calls []string
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		calls = append(calls, req.Model)
+		if req.Model == "model-a" {
+			return http.StatusNotFound, `{"error":"model 'model-a' not found"}`
+		}
+		return http.StatusOK, validModelResponse
+	})
+
+	result, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{
+		Model:          "model-a",
+		FallbackModels: []string{"model-b"},
+	})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	if result.Model != "model-b" {
+		t.Errorf("Model = %q, want %q", result.Model, "model-b")
+	}
+	if len(calls) != 2 || calls[0] != "model-a" || calls[1] != "model-b" {
+		t.Errorf("calls = %v, want [model-a model-b]", calls)
+	}
+}
+
+func TestProcess_FallbackAfterParseFailures(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		if req.Model == "model-a" {
+			return http.StatusOK, "not json"
+		}
+		return http.StatusOK, validModelResponse
+	})
+
+	result, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{
+		Model:          "model-a",
+		FallbackModels: []string{"model-b"},
+	})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Model != "model-b" {
+		t.Errorf("Model = %q, want %q", result.Model, "model-b")
+	}
+}
+
+func TestProcess_RetryOnEmptyText(t *testing.T) {
+	const emptyText = `{"metadata":{"document_type":"unknown","confidence_score":0.1},"text":{"raw":"","lines":[]},"structured_data":{"key_value_pairs":{},"tables":[]},"summary":null}`
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		budget    *RetryBudget
+		retryBody string
+		wantCalls int
+		wantRaw   string
+	}{
+		{"retried", true, nil, validModelResponse, 2, "TOTAL 9.99"},
+		{"disabled", false, nil, validModelResponse, 1, ""},
+		{"budget exhausted", true, NewRetryBudget(0), validModelResponse, 1, ""},
+		{"retry unparsable", true, nil, "not json", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts []string
+			eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+				prompts = append(prompts, req.Prompt)
+				if len(prompts) == 1 {
+					return http.StatusOK, emptyText
+				}
+				return http.StatusOK, tt.retryBody
+			})
+
+			result, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{
+				Model:            "m",
+				RetryOnEmptyText: tt.enabled,
+				RetryBudget:      tt.budget,
+			})
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if len(prompts) != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", len(prompts), tt.wantCalls)
+			}
+			if tt.wantCalls > 1 && !strings.HasSuffix(prompts[1], prompt.EmptyTextRetryInstruction) {
+				t.Error("retry prompt should ask again for all text")
+			}
+			if got := result.VisionResponse.Text.Raw; got != tt.wantRaw {
+				t.Errorf("Text.Raw = %q, want %q", got, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestProcess_NoFallbackFails(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		return http.StatusInternalServerError, "out of memory"
+	})
+
+	_, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{Model: "model-a"})
+	if err == nil {
+		t.Fatal("expected error when primary model fails without fallbacks")
+	}
+}
+
+func TestProcess_StageLatencies(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		time.Sleep(5 * time.Millisecond)
+		return http.StatusOK, validModelResponse
+	})
+
+	result, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{Model: "primary"})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.ModelLatency < 5*time.Millisecond {
+		t.Errorf("ModelLatency = %v, want at least the server delay", result.ModelLatency)
+	}
+	if result.ParseLatency <= 0 {
+		t.Errorf("ParseLatency = %v, want > 0", result.ParseLatency)
+	}
+	if result.ModelLatency+result.ParseLatency > result.Latency {
+		t.Errorf("stage latencies exceed total latency %v", result.Latency)
+	}
+}
+
+func TestProcessPages_SharedRetryBudget(t *testing.T) {
+	// Every page fails to parse on its first attempt and succeeds on retry.
+	calls := 0
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		calls++
+		if calls%2 == 1 {
+			return http.StatusOK, "not json"
+		}
+		return http.StatusOK, validModelResponse
+	})
+	pages := [][]byte{[]byte("page1"), []byte("page2"), []byte("page3"), []byte("page4")}
+
+	budget := NewRetryBudget(1)
+	_, err := processPages(context.Background(), eng.logger, pages, ProcessConfig{Model: "m", RetryBudget: budget}, eng.Process)
+	if err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Fatalf("err = %v, want retry budget exhausted", err)
+	}
+	if !strings.Contains(err.Error(), "page 2") {
+		t.Errorf("err = %v, want failure on page 2", err)
+	}
+	// page 1: attempt + retry, page 2: attempt only, pages 3-4 never run
+	if calls != 3 {
+		t.Errorf("model calls = %d, want 3", calls)
+	}
+	if budget.Remaining() != 0 {
+		t.Errorf("Remaining = %d, want 0", budget.Remaining())
+	}
+
+	// Without a budget every page gets its retry
+	calls = 0
+	if _, err := processPages(context.Background(), eng.logger, pages, ProcessConfig{Model: "m"}, eng.Process); err != nil {
+		t.Fatalf("processPages without budget: %v", err)
+	}
+	if calls != 8 {
+		t.Errorf("model calls = %d, want 8", calls)
+	}
+}
+
+func TestProcessPages_TokenBudget(t *testing.T) {
+	// Every page reports 100 prompt and 50 eval tokens.
+	var processed int
+	process := func(ctx context.Context, imageData []byte, cfg ProcessConfig) (*ProcessResult, error) {
+		processed++
+		return &ProcessResult{
+			VisionResponse: &models.OllamaVisionResponse{Text: &models.OllamaTextResult{Raw: string(imageData)}},
+			PromptTokens:   100,
+			EvalTokens:     50,
+		}, nil
+	}
+	pages := [][]byte{[]byte("page1"), []byte("page2"), []byte("page3"), []byte("page4")}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	tests := []struct {
+		name          string
+		budget        int
+		wantProcessed int
+		wantExceeded  bool
+		wantWarning   string
+	}{
+		{"no budget", 0, 4, false, ""},
+		{"budget not reached", 600, 4, false, ""},
+		{"stops after crossing page", 250, 2, true, "pages 3-4 were not processed"},
+		{"crossed on last page", 500, 4, true, "after page 4 of 4 (600 tokens)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed = 0
+			result, err := processPages(context.Background(), logger, pages, ProcessConfig{MaxTotalTokens: tt.budget}, process)
+			if err != nil {
+				t.Fatalf("processPages: %v", err)
+			}
+			if processed != tt.wantProcessed {
+				t.Errorf("processed %d pages, want %d", processed, tt.wantProcessed)
+			}
+			if result.BudgetExceeded != tt.wantExceeded {
+				t.Errorf("BudgetExceeded = %v, want %v", result.BudgetExceeded, tt.wantExceeded)
+			}
+			if got := result.PromptTokens + result.EvalTokens; got != 150*tt.wantProcessed {
+				t.Errorf("tokens = %d, want %d", got, 150*tt.wantProcessed)
+			}
+			warnings := strings.Join(result.Warnings, "\n")
+			if tt.wantWarning == "" && warnings != "" || !strings.Contains(warnings, tt.wantWarning) {
+				t.Errorf("Warnings = %q, want %q", result.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestProcessPages_PageNumbers(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		return http.StatusOK, validModelResponse
+	})
+	cfg := ProcessConfig{Model: "m"}
+
+	result, err := processPages(context.Background(), eng.logger, [][]byte{[]byte("page1"), []byte("page2")}, cfg, eng.Process)
+	if err != nil {
+		t.Fatalf("processPages: %v", err)
+	}
+	lines := result.VisionResponse.Text.Lines
+	if len(lines) != 2 || lines[0].PageNumber != 1 || lines[1].PageNumber != 2 {
+		t.Errorf("Lines = %+v, want one line on each of pages 1 and 2", lines)
+	}
+
+	result, err = processPages(context.Background(), eng.logger, [][]byte{[]byte("page1")}, cfg, eng.Process)
+	if err != nil {
+		t.Fatalf("processPages single page: %v", err)
+	}
+	if lines := result.VisionResponse.Text.Lines; len(lines) != 1 || lines[0].PageNumber != 1 {
+		t.Errorf("single page: Lines = %+v, want page 1", lines)
+	}
+}
+
+func TestProcess_DebugRequestLogLevel(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		return http.StatusOK, validModelResponse
+	})
+	cfg := ProcessConfig{Model: "m", DebugRequestLog: true}
+
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		var buf bytes.Buffer
+		eng.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+		if _, err := eng.Process(context.Background(), []byte("img"), cfg); err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		logged := strings.Contains(buf.String(), `"msg":"ollama request"`)
+		if want := level == slog.LevelDebug; logged != want {
+			t.Errorf("request logged at %v = %v, want %v", level, logged, want)
+		}
+	}
+}
+
+func TestProcessPages_PageLogs(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		return http.StatusOK, validModelResponse
+	})
+	var buf bytes.Buffer
+	eng.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	_, err := processPages(context.Background(), eng.logger, [][]byte{[]byte("page1"), []byte("page2")},
+		ProcessConfig{Model: "m", RequestID: "req-1"}, eng.Process)
+	if err != nil {
+		t.Fatalf("processPages: %v", err)
+	}
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	pages := map[float64]int{}
+	for _, e := range entries[:len(entries)-1] {
+		page, ok := e["page"].(float64)
+		if !ok {
+			t.Errorf("log line %v has no page", e)
+			continue
+		}
+		pages[page]++
+	}
+	if pages[1] == 0 || pages[1] != pages[2] {
+		t.Errorf("log lines per page = %v, want the same non-zero count for pages 1 and 2", pages)
+	}
+
+	summary := entries[len(entries)-1]
+	if summary["msg"] != "PDF processing complete" || summary["total_pages"] != 2.0 || summary["request_id"] != "req-1" {
+		t.Errorf("last log line = %v, want the document summary", summary)
+	}
+	if _, ok := summary["page"]; ok {
+		t.Errorf("document summary %v should not carry a page", summary)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var nilBudget *RetryBudget
+	if !nilBudget.Take() {
+		t.Error("nil budget should be unlimited")
+	}
+
+	b := NewRetryBudget(2)
+	if !b.Take() || !b.Take() {
+		t.Fatal("Take should succeed while retries remain")
+	}
+	if b.Take() {
+		t.Error("Take should fail once the budget is spent")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		base, max time.Duration
+		n         int
+		want      time.Duration
+	}{
+		{"first retry", time.Second, 0, 1, time.Second},
+		{"doubles", time.Second, 0, 3, 4 * time.Second},
+		{"capped", time.Second, 3 * time.Second, 3, 3 * time.Second},
+		{"below cap", time.Second, 3 * time.Second, 2, 2 * time.Second},
+		{"cap below base", time.Second, 100 * time.Millisecond, 1, 100 * time.Millisecond},
+		{"many retries stay capped", time.Second, time.Minute, 100, time.Minute},
+		{"no backoff", 0, time.Minute, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.base, tt.max, tt.n); got != tt.want {
+			t.Errorf("%s: backoff(%v, %v, %d) = %v, want %v", tt.name, tt.base, tt.max, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestWaitRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := waitRetry(ctx, time.Hour); !errors.Is(err, ErrRetryDeadline) {
+		t.Errorf("err = %v, want ErrRetryDeadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("waited %v, want no sleep past the deadline", elapsed)
+	}
+
+	start = time.Now()
+	if err := waitRetry(ctx, 20*time.Millisecond); err != nil {
+		t.Errorf("err = %v, want nil within the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("waited %v, want at least 20ms", elapsed)
+	}
+
+	if err := waitRetry(context.Background(), 0); err != nil {
+		t.Errorf("err = %v, want nil without a backoff", err)
+	}
+}
+
+func TestProcess_RetryBackoff(t *testing.T) {
+	unparsableOnce := func() func(client.GenerateRequest) (int, string) {
+		calls := 0
+		return func(client.GenerateRequest) (int, string) {
+			if calls++; calls == 1 {
+				return http.StatusOK, "not json"
+			}
+			return http.StatusOK, validModelResponse
+		}
+	}
+
+	// The cap bounds the wait however large the base
+	eng := newTestEngine(t, unparsableOnce())
+	start := time.Now()
+	_, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{
+		Model:           "m",
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: 30 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("took %v, want the capped 30ms backoff", elapsed)
+	}
+
+	// A backoff past the deadline fails at once with the last error
+	eng = newTestEngine(t, unparsableOnce())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start = time.Now()
+	_, err = eng.Process(ctx, []byte("image"), ProcessConfig{Model: "m", RetryBackoff: time.Minute})
+	if !errors.Is(err, ErrRetryDeadline) || !strings.Contains(err.Error(), "parse response") {
+		t.Errorf("err = %v, want ErrRetryDeadline with the parse error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want no sleep before failing", elapsed)
+	}
+}
+
+func BenchmarkVisionEngine_Process(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		json.NewEncoder(w).Encode(client.GenerateResponse{Model: "bench", Response: validModelResponse, Done: true})
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	eng := NewVisionEngine(client.NewOllamaClient(server.URL, 10*time.Second), logger)
+	image := bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 256*1024) // 1 MiB
+	cfg := ProcessConfig{Model: "bench", WithBoundingBoxes: true, WithConfidenceScores: true, WithStructuredExtraction: true}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(image)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := eng.Process(context.Background(), image, cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestProcess_EmptyResponseBodyRetried(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			return // empty 200 body
+		}
+		json.NewEncoder(w).Encode(client.GenerateResponse{Model: "m", Response: validModelResponse, Done: true})
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	eng := NewVisionEngine(client.NewOllamaClient(server.URL, 10*time.Second), logger)
+
+	result, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{Model: "m"})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want a retry after the empty body", calls)
+	}
+	if result.VisionResponse.Text.Raw != "TOTAL 9.99" {
+		t.Errorf("Raw = %q, want the retried response", result.VisionResponse.Text.Raw)
+	}
+}
+
+func TestProcess_SchemaValidation(t *testing.T) {
+	// Parses, but the line confidence is a string
+	const wrongType = `{"metadata":{"language":"en","document_type":"receipt","confidence_score":0.9},"text":{"raw":"TOTAL 9.99","lines":[{"text":"TOTAL 9.99","bounding_box":null,"confidence":"high"}]},"structured_data":{"key_value_pairs":{},"tables":[]},"summary":null}`
+	const conforming = `{"metadata":{"language":"en","document_type":"receipt","confidence_score":0.9},"text":{"raw":"TOTAL 9.99","lines":[{"text":"TOTAL 9.99","bounding_box":null,"confidence":0.9}]},"structured_data":{"key_value_pairs":{},"tables":[]},"summary":null}`
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		retryBody string
+		wantCalls int
+		wantErr   bool
+	}{
+		{"retried", true, conforming, 2, false},
+		{"disabled", false, conforming, 1, false},
+		{"retry also invalid", true, wrongType, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+				calls++
+				if calls == 1 {
+					return http.StatusOK, wrongType
+				}
+				return http.StatusOK, tt.retryBody
+			})
+
+			_, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{
+				Model:            "m",
+				SchemaValidation: tt.enabled,
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "text.lines[0].confidence") {
+					t.Errorf("err = %v, want the schema violation", err)
+				}
+			} else if err != nil {
+				t.Errorf("Process: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcess_EmptyResponse(t *testing.T) {
+	eng := newTestEngine(t, func(req client.GenerateRequest) (int, string) {
+		return http.StatusOK, "  \n"
+	})
+
+	_, err := eng.Process(context.Background(), []byte("image"), ProcessConfig{Model: "m"})
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Fatalf("err = %v, want ErrEmptyResponse", err)
+	}
+}
+
+// slowBackend answers Generate only once its context is done, like a model
+// whose response arrives right at the deadline.
+type slowBackend struct {
+	deadline time.Time
+}
+
+func (b *slowBackend) Generate(ctx context.Context, req client.GenerateRequest) (*client.GenerateResponse, error) {
+	b.deadline, _ = ctx.Deadline()
+	<-ctx.Done()
+	return &client.GenerateResponse{Model: req.Model, Response: validModelResponse, Done: true}, nil
+}
+
+func (b *slowBackend) Ping(ctx context.Context) error { return nil }
+
+func TestProcess_DeadlinePadding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	outer, _ := ctx.Deadline()
+
+	backend := &slowBackend{}
+	eng := NewVisionEngine(backend, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	result, err := eng.Process(ctx, []byte("image"), ProcessConfig{Model: "m", DeadlinePadding: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got := outer.Sub(backend.deadline); got != 100*time.Millisecond {
+		t.Errorf("generate deadline %v before the request deadline, want 100ms", got)
+	}
+	if ctx.Err() != nil {
+		t.Error("request context expired before parsing finished")
+	}
+	if result.VisionResponse.Text.Raw != "TOTAL 9.99" {
+		t.Errorf("Raw = %q, want the parsed response", result.VisionResponse.Text.Raw)
+	}
+}
+
+// stalledBackend never answers; Generate returns once ctx is done.
+type stalledBackend struct{}
+
+func (stalledBackend) Generate(ctx context.Context, req client.GenerateRequest) (*client.GenerateResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stalledBackend) Ping(ctx context.Context) error { return nil }
+
+func TestProcess_ResponseTimeout(t *testing.T) {
+	eng := NewVisionEngine(stalledBackend{}, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := eng.Process(ctx, []byte("image"), ProcessConfig{Model: "m", ResponseTimeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Process took %v, want the response timeout to stop the call", elapsed)
+	}
+	if ctx.Err() != nil {
+		t.Error("the overall deadline should not have expired")
+	}
+}
+
+func TestProcess_ResponseTimeoutWithinBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	outer, _ := ctx.Deadline()
+
+	backend := &slowBackend{}
+	eng := NewVisionEngine(backend, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if _, err := eng.Process(ctx, []byte("image"), ProcessConfig{Model: "m", ResponseTimeout: time.Hour}); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if !backend.deadline.Equal(outer) {
+		t.Errorf("generate deadline = %v, want the overall deadline %v", backend.deadline, outer)
+	}
+}
+
+func TestGenerateContext(t *testing.T) {
+	ctx, cancel := generateContext(context.Background(), time.Second)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("no deadline should stay no deadline")
+	}
+
+	parent, cancelParent := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelParent()
+	ctx, cancel = generateContext(parent, time.Second)
+	defer cancel()
+	if ctx != parent {
+		t.Error("padding larger than the remaining time should leave ctx unchanged")
+	}
+}
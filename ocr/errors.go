@@ -4,24 +4,44 @@ package ocr
 import (
 	"errors"
 	"fmt"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
 )
 
-// Sentinel errors for common failure modes.
+// Sentinel errors for common failure modes. ErrValidationFailed is no longer
+// returned, since schema validation failures are only logged; it is kept so
+// existing errors.Is checks still compile.
 var (
-	ErrUnsupportedFormat   = errors.New("ocr: unsupported file format")
-	ErrFileTooLarge        = errors.New("ocr: file exceeds maximum allowed size")
-	ErrInvalidURL          = errors.New("ocr: invalid or unsafe URL")
-	ErrFileNotFound        = errors.New("ocr: file not found")
-	ErrFileReadFailed      = errors.New("ocr: failed to read file")
-	ErrImageDecodeFailed   = errors.New("ocr: failed to decode image")
-	ErrPDFParseFailed      = errors.New("ocr: failed to parse PDF")
-	ErrOllamaUnavailable   = errors.New("ocr: ollama server is unavailable")
-	ErrOllamaRequestFailed = errors.New("ocr: ollama API request failed")
-	ErrInvalidJSONResponse = errors.New("ocr: model returned invalid JSON")
-	ErrContextCanceled     = errors.New("ocr: context canceled or deadline exceeded")
-	ErrValidationFailed    = errors.New("ocr: output validation failed")
-	ErrEmptySource         = errors.New("ocr: source path or URL is empty")
-	ErrURLFetchFailed      = errors.New("ocr: failed to fetch image from URL")
+	ErrUnsupportedFormat    = errors.New("ocr: unsupported file format")
+	ErrFileTooLarge         = errors.New("ocr: file exceeds maximum allowed size")
+	ErrInvalidURL           = errors.New("ocr: invalid or unsafe URL")
+	ErrFileNotFound         = errors.New("ocr: file not found")
+	ErrFileReadFailed       = errors.New("ocr: failed to read file")
+	ErrImageDecodeFailed    = errors.New("ocr: failed to decode image")
+	ErrPDFParseFailed       = errors.New("ocr: failed to parse PDF")
+	ErrOllamaUnavailable    = errors.New("ocr: ollama server is unavailable")
+	ErrBackendUnavailable   = errors.New("ocr: model server is unavailable")
+	ErrOllamaRequestFailed  = errors.New("ocr: ollama API request failed")
+	ErrInvalidJSONResponse  = errors.New("ocr: model returned invalid JSON")
+	ErrContextCanceled      = errors.New("ocr: context canceled or deadline exceeded")
+	ErrValidationFailed     = errors.New("ocr: output validation failed")
+	ErrEmptySource          = errors.New("ocr: source path or URL is empty")
+	ErrURLFetchFailed       = errors.New("ocr: failed to fetch image from URL")
+	ErrDocumentTypeMismatch = errors.New("ocr: document type does not match expected type")
+	ErrTesseractFailed      = errors.New("ocr: tesseract engine failed")
+	ErrInvalidCropRegion    = errors.New("ocr: crop region is outside the image")
+	ErrImageTooSmall        = errors.New("ocr: image resolution is too low")
+	ErrImageTooLarge        = errors.New("ocr: image has too many pixels")
+	ErrSourceRejected       = errors.New("ocr: source rejected by validator")
+	ErrSelfTestFailed       = errors.New("ocr: self-test failed")
+	ErrNotANumber           = errors.New("ocr: value is not a number")
+	ErrModelNotFound        = errors.New("ocr: model not found on the server")
+	ErrUnknownSchema        = errors.New("ocr: unknown output schema")
+	ErrChecksumMismatch     = errors.New("ocr: checksum does not match the expected checksum")
+	ErrResultProcessor      = errors.New("ocr: result processor failed")
+	ErrLanguageNotAllowed   = errors.New("ocr: detected language is not allowed")
+	ErrNoBoundingBoxes      = errors.New("ocr: result has no bounding boxes")
+	ErrUnknownFormat        = errors.New("ocr: unknown batch output format")
 )
 
 // OCRError wraps errors with additional context.
@@ -29,6 +49,10 @@ type OCRError struct {
 	Op        string // Operation that failed (e.g., "Extract", "LoadImage")
 	RequestID string // Request ID for tracing
 	Err       error  // Underlying error
+
+	// Timings tells how long each stage took before the context was
+	// canceled or its deadline passed. It is nil for other errors.
+	Timings *models.Timings
 }
 
 func (e *OCRError) Error() string {
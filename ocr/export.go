@@ -0,0 +1,269 @@
+package ocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// ocrSystem identifies this package in hOCR and ALTO output.
+const ocrSystem = "ocr-go-prototype"
+
+// WriteHOCR renders the lines of r as an hOCR 1.2 document with a single
+// ocr_page. Each line becomes an ocr_line whose title carries its pixel
+// bounding box and, when available, its confidence as x_wconf (0-100).
+// Lines without a usable bounding box are written without coordinates.
+func WriteHOCR(w io.Writer, r *models.OCRResult) error {
+	page := hocrDiv{Class: "ocr_page", ID: "page_1"}
+	if r.Image.Width > 0 && r.Image.Height > 0 {
+		page.Title = fmt.Sprintf("bbox 0 0 %d %d", r.Image.Width, r.Image.Height)
+	}
+
+	units := lineBoxUnits(r)
+	for i, line := range r.Text.Lines {
+		var props []string
+		if x0, y0, x1, y1, ok := pixelBox(line.BoundingBox, units, r.Image); ok {
+			props = append(props, fmt.Sprintf("bbox %d %d %d %d", x0, y0, x1, y1))
+		}
+		if line.Confidence > 0 {
+			props = append(props, fmt.Sprintf("x_wconf %d", int(math.Round(line.Confidence*100))))
+		}
+		page.Lines = append(page.Lines, hocrSpan{
+			Class: "ocr_line",
+			ID:    fmt.Sprintf("line_1_%d", i+1),
+			Title: strings.Join(props, "; "),
+			Text:  line.Text,
+		})
+	}
+
+	doc := hocrHTML{
+		XMLNS: "http://www.w3.org/1999/xhtml",
+		Head: hocrHead{
+			Title: r.Source.Path,
+			Meta: []hocrMeta{
+				{HTTPEquiv: "Content-Type", Content: "text/html;charset=utf-8"},
+				{Name: "ocr-system", Content: ocrSystem},
+				{Name: "ocr-capabilities", Content: "ocr_page ocr_line"},
+			},
+		},
+		Body: hocrBody{Page: page},
+	}
+	if r.Metadata.Language != nil && *r.Metadata.Language != "" {
+		doc.Lang = *r.Metadata.Language
+		doc.XMLLang = *r.Metadata.Language
+	}
+
+	header := xml.Header + `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">` + "\n"
+	return writeXML(w, header, doc)
+}
+
+// WriteALTO renders the lines of r as an ALTO v4 document with one page and
+// one text block. Measurements are in pixels. Each line is split into
+// String elements at whitespace; all words of a line share its confidence
+// (WC). Empty lines are skipped. Lines without a usable bounding box are
+// written without HPOS, VPOS, WIDTH and HEIGHT.
+func WriteALTO(w io.Writer, r *models.OCRResult) error {
+	page := altoPage{ID: "page_1", PhysicalImgNr: 1}
+	if r.Image.Width > 0 && r.Image.Height > 0 {
+		page.Width = intPtr(r.Image.Width)
+		page.Height = intPtr(r.Image.Height)
+	}
+
+	units := lineBoxUnits(r)
+	block := altoTextBlock{ID: "block_1"}
+	stringID := 0
+	for i, line := range r.Text.Lines {
+		words := strings.Fields(line.Text)
+		if len(words) == 0 {
+			continue // ALTO requires at least one String per TextLine
+		}
+
+		tl := altoTextLine{ID: fmt.Sprintf("line_%d", i+1)}
+		if x0, y0, x1, y1, ok := pixelBox(line.BoundingBox, units, r.Image); ok {
+			tl.HPos, tl.VPos = intPtr(x0), intPtr(y0)
+			tl.Width, tl.Height = intPtr(x1-x0), intPtr(y1-y0)
+		}
+
+		var wc string
+		if line.Confidence > 0 {
+			wc = strconv.FormatFloat(line.Confidence, 'f', -1, 64)
+		}
+		for j, word := range words {
+			if j > 0 {
+				tl.Content = append(tl.Content, altoSP{})
+			}
+			stringID++
+			tl.Content = append(tl.Content, altoString{
+				ID:      fmt.Sprintf("string_%d", stringID),
+				Content: word,
+				WC:      wc,
+			})
+		}
+		block.Lines = append(block.Lines, tl)
+	}
+	page.PrintSpace.Blocks = []altoTextBlock{block}
+
+	doc := altoDoc{
+		XMLNS: "http://www.loc.gov/standards/alto/ns-v4#",
+		Description: altoDescription{
+			MeasurementUnit: "pixel",
+			FileName:        r.Source.Path,
+			OCRProcessing:   altoOCRProcessing{ID: "OCR_0", SoftwareName: ocrSystem},
+		},
+		Layout: altoLayout{Page: page},
+	}
+	return writeXML(w, xml.Header, doc)
+}
+
+// writeXML writes header followed by v as indented XML and a trailing newline.
+func writeXML(w io.Writer, header string, v any) error {
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// lineBoxUnits returns the coordinate system of the line boxes in r,
+// detecting it when the result does not record one.
+func lineBoxUnits(r *models.OCRResult) models.BoundingBoxUnits {
+	if r.Text.BoundingBoxUnits != "" {
+		return r.Text.BoundingBoxUnits
+	}
+	boxes := make([]*models.BoundingBox, 0, len(r.Text.Lines))
+	for _, line := range r.Text.Lines {
+		boxes = append(boxes, line.BoundingBox)
+	}
+	return utils.DetectBoundingBoxUnits(boxes)
+}
+
+// pixelBox converts b to integer pixel corners (x0, y0, x1, y1). It returns
+// false if b is nil or cannot be expressed in pixels.
+func pixelBox(b *models.BoundingBox, units models.BoundingBoxUnits, img models.ImageInfo) (x0, y0, x1, y1 int, ok bool) {
+	if b == nil {
+		return 0, 0, 0, 0, false
+	}
+	px, ok := utils.ConvertBoundingBox(*b, units, models.BoundingBoxUnitsPixels, img.Width, img.Height)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	x0, y0 = int(math.Round(px.X)), int(math.Round(px.Y))
+	x1, y1 = int(math.Round(px.X+px.Width)), int(math.Round(px.Y+px.Height))
+	return x0, y0, x1, y1, true
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// hOCR document structure.
+
+type hocrHTML struct {
+	XMLName xml.Name `xml:"html"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	XMLLang string   `xml:"xml:lang,attr,omitempty"`
+	Lang    string   `xml:"lang,attr,omitempty"`
+	Head    hocrHead `xml:"head"`
+	Body    hocrBody `xml:"body"`
+}
+
+type hocrHead struct {
+	Title string     `xml:"title"`
+	Meta  []hocrMeta `xml:"meta"`
+}
+
+type hocrMeta struct {
+	HTTPEquiv string `xml:"http-equiv,attr,omitempty"`
+	Name      string `xml:"name,attr,omitempty"`
+	Content   string `xml:"content,attr"`
+}
+
+type hocrBody struct {
+	Page hocrDiv `xml:"div"`
+}
+
+type hocrDiv struct {
+	Class string     `xml:"class,attr"`
+	ID    string     `xml:"id,attr"`
+	Title string     `xml:"title,attr,omitempty"`
+	Lines []hocrSpan `xml:"span"`
+}
+
+type hocrSpan struct {
+	Class string `xml:"class,attr"`
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+// ALTO document structure.
+
+type altoDoc struct {
+	XMLName     xml.Name        `xml:"alto"`
+	XMLNS       string          `xml:"xmlns,attr"`
+	Description altoDescription `xml:"Description"`
+	Layout      altoLayout      `xml:"Layout"`
+}
+
+type altoDescription struct {
+	MeasurementUnit string            `xml:"MeasurementUnit"`
+	FileName        string            `xml:"sourceImageInformation>fileName"`
+	OCRProcessing   altoOCRProcessing `xml:"OCRProcessing"`
+}
+
+type altoOCRProcessing struct {
+	ID           string `xml:"ID,attr"`
+	SoftwareName string `xml:"ocrProcessingStep>processingSoftware>softwareName"`
+}
+
+type altoLayout struct {
+	Page altoPage `xml:"Page"`
+}
+
+type altoPage struct {
+	ID            string         `xml:"ID,attr"`
+	PhysicalImgNr int            `xml:"PHYSICAL_IMG_NR,attr"`
+	Width         *int           `xml:"WIDTH,attr,omitempty"`
+	Height        *int           `xml:"HEIGHT,attr,omitempty"`
+	PrintSpace    altoPrintSpace `xml:"PrintSpace"`
+}
+
+type altoPrintSpace struct {
+	Blocks []altoTextBlock `xml:"TextBlock"`
+}
+
+type altoTextBlock struct {
+	ID    string         `xml:"ID,attr"`
+	Lines []altoTextLine `xml:"TextLine"`
+}
+
+type altoTextLine struct {
+	ID      string `xml:"ID,attr"`
+	HPos    *int   `xml:"HPOS,attr,omitempty"`
+	VPos    *int   `xml:"VPOS,attr,omitempty"`
+	Width   *int   `xml:"WIDTH,attr,omitempty"`
+	Height  *int   `xml:"HEIGHT,attr,omitempty"`
+	Content []any  `xml:",any"`
+}
+
+type altoString struct {
+	XMLName xml.Name `xml:"String"`
+	ID      string   `xml:"ID,attr"`
+	Content string   `xml:"CONTENT,attr"`
+	WC      string   `xml:"WC,attr,omitempty"`
+}
+
+type altoSP struct {
+	XMLName xml.Name `xml:"SP"`
+}
@@ -0,0 +1,90 @@
+package ocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// exportFixture is a receipt with normalized boxes, one unboxed line and
+// text that needs escaping.
+func exportFixture() *models.OCRResult {
+	lang := "en"
+	return &models.OCRResult{
+		Source:   models.Source{Type: models.SourceTypeFile, Path: "receipts/acme.png"},
+		Image:    models.ImageInfo{Width: 600, Height: 400},
+		Metadata: models.Metadata{Language: &lang, DocumentType: models.DocumentTypeReceipt},
+		Text: models.TextResult{
+			BoundingBoxUnits: models.BoundingBoxUnitsNormalized,
+			Lines: []models.TextLine{
+				{Text: "ACME Store", BoundingBox: &models.BoundingBox{X: 0.1, Y: 0.05, Width: 0.5, Height: 0.05}, Confidence: 0.97},
+				{Text: "Fish & Chips <large>", BoundingBox: &models.BoundingBox{X: 0.1, Y: 0.2, Width: 0.6, Height: 0.05}, Confidence: 0.88},
+				{Text: ""},
+				{Text: "TOTAL 9.99", Confidence: 0.9},
+			},
+		},
+	}
+}
+
+func TestWriteHOCR_Golden(t *testing.T) {
+	checkGolden(t, "receipt.hocr", WriteHOCR, exportFixture())
+}
+
+func TestWriteALTO_Golden(t *testing.T) {
+	checkGolden(t, "receipt.alto.xml", WriteALTO, exportFixture())
+}
+
+func TestWriteHOCR_NoBoundingBoxes(t *testing.T) {
+	r := exportFixture()
+	r.Image = models.ImageInfo{}
+	r.Metadata.Language = nil
+	checkGolden(t, "receipt_nobbox.hocr", WriteHOCR, r)
+}
+
+func TestWriteALTO_NoBoundingBoxes(t *testing.T) {
+	r := exportFixture()
+	r.Image = models.ImageInfo{}
+	checkGolden(t, "receipt_nobbox.alto.xml", WriteALTO, r)
+}
+
+// checkGolden renders r with write and compares the output, which must be
+// well-formed XML, to testdata/name. Run with -update to rewrite it.
+func checkGolden(t *testing.T, name string, write func(io.Writer, *models.OCRResult) error, r *models.OCRResult) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := write(&buf, r); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("output is not well-formed XML: %v", err)
+		}
+	}
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output does not match %s:\n%s", path, buf.String())
+	}
+}
@@ -0,0 +1,40 @@
+package ocr
+
+import "github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+
+// FanOut copies every result received from in to n subscriber channels, for
+// pipelines that hand each result to several consumers such as a database,
+// a search index and a webhook. Results are delivered in the order they are
+// received, and every subscriber channel is closed once in is closed and
+// its last result has been delivered.
+//
+// The subscriber channels are unbuffered and a result is only read from in
+// after every subscriber has received the previous one, so the slowest
+// consumer sets the pace and each subscriber must drain its channel until
+// it is closed. Subscribers share the same *OCRResult and must not modify
+// it. n below 1 returns nil and leaves in unread.
+func FanOut(in <-chan *models.OCRResult, n int) []<-chan *models.OCRResult {
+	if n < 1 {
+		return nil
+	}
+	outs := make([]chan *models.OCRResult, n)
+	subscribers := make([]<-chan *models.OCRResult, n)
+	for i := range outs {
+		outs[i] = make(chan *models.OCRResult)
+		subscribers[i] = outs[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for r := range in {
+			for _, out := range outs {
+				out <- r
+			}
+		}
+	}()
+	return subscribers
+}
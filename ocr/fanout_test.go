@@ -0,0 +1,81 @@
+package ocr
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestFanOut(t *testing.T) {
+	in := make(chan *models.OCRResult)
+	subscribers := FanOut(in, 3)
+	if len(subscribers) != 3 {
+		t.Fatalf("len(subscribers) = %d, want 3", len(subscribers))
+	}
+
+	var want []string
+	for i := range 5 {
+		want = append(want, fmt.Sprintf("doc%d.png", i))
+	}
+	go func() {
+		for _, path := range want {
+			in <- &models.OCRResult{Source: models.Source{Path: path}}
+		}
+		close(in)
+	}()
+
+	got := make([][]string, len(subscribers))
+	var wg sync.WaitGroup
+	for i, sub := range subscribers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range sub {
+				got[i] = append(got[i], r.Source.Path)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber channels were not closed after in was closed")
+	}
+
+	for i := range got {
+		if !slices.Equal(got[i], want) {
+			t.Errorf("subscriber %d received %q, want %q", i, got[i], want)
+		}
+	}
+}
+
+func TestFanOut_ClosedInput(t *testing.T) {
+	in := make(chan *models.OCRResult)
+	close(in)
+
+	for i, sub := range FanOut(in, 2) {
+		select {
+		case r, ok := <-sub:
+			if ok {
+				t.Errorf("subscriber %d received %v, want a closed channel", i, r)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscriber %d was not closed", i)
+		}
+	}
+}
+
+func TestFanOut_NoSubscribers(t *testing.T) {
+	if got := FanOut(make(chan *models.OCRResult), 0); got != nil {
+		t.Errorf("FanOut(in, 0) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,105 @@
+//go:build integration
+
+package ocr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// writeTestImage writes a small PNG to a temp dir and returns its path.
+func writeTestImage(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "receipt.png")
+	if err := os.WriteFile(path, testPNG(t), 0o644); err != nil {
+		t.Fatalf("write test image: %v", err)
+	}
+	return path
+}
+
+func TestIntegration_ExtractFile(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	path := writeTestImage(t)
+
+	result, err := Extract(context.Background(), path,
+		WithOllamaURL(srv.URL),
+		WithSummary(true),
+	)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	if err := utils.ValidateOCRResult(result); err != nil {
+		t.Errorf("result does not conform to the schema: %v", err)
+	}
+
+	// Source
+	if result.Source.Type != models.SourceTypeFile || result.Source.Path != path {
+		t.Errorf("Source = %+v, want file %q", result.Source, path)
+	}
+	wantChecksum, _ := utils.SHA256File(path)
+	if result.Source.Checksum != wantChecksum {
+		t.Errorf("Source.Checksum = %q, want %q", result.Source.Checksum, wantChecksum)
+	}
+
+	// Image
+	if result.Image.Width != 32 || result.Image.Height != 32 {
+		t.Errorf("Image = %dx%d, want 32x32", result.Image.Width, result.Image.Height)
+	}
+
+	// Metadata
+	if result.Metadata.DocumentType != models.DocumentTypeReceipt {
+		t.Errorf("DocumentType = %q, want %q", result.Metadata.DocumentType, models.DocumentTypeReceipt)
+	}
+	if result.Metadata.Language == nil || *result.Metadata.Language != "en" {
+		t.Errorf("Language = %v, want en", result.Metadata.Language)
+	}
+
+	// Text, structured data, summary
+	if len(result.Text.Lines) != 2 || result.Text.Lines[0].Text != "ACME Store" {
+		t.Errorf("Text.Lines = %+v, want the two canned lines", result.Text.Lines)
+	}
+	if result.StructuredData.KeyValuePairs["total"] != "9.99" {
+		t.Errorf("KeyValuePairs = %v, want total=9.99", result.StructuredData.KeyValuePairs)
+	}
+	if result.Summary == nil {
+		t.Error("Summary should be set")
+	}
+
+	// Request wiring
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("server received %d generate requests, want 1", len(reqs))
+	}
+	if reqs[0].Model != DefaultModel || len(reqs[0].Images) != 1 {
+		t.Errorf("request model=%q images=%d, want %q and 1 image", reqs[0].Model, len(reqs[0].Images), DefaultModel)
+	}
+}
+
+// TestIntegration_RealOllama runs against a real Ollama server when
+// OCR_INTEGRATION_OLLAMA_URL is set.
+func TestIntegration_RealOllama(t *testing.T) {
+	url := os.Getenv("OCR_INTEGRATION_OLLAMA_URL")
+	if url == "" {
+		t.Skip("OCR_INTEGRATION_OLLAMA_URL not set")
+	}
+
+	opts := []Option{WithOllamaURL(url)}
+	if model := os.Getenv("OCR_INTEGRATION_MODEL"); model != "" {
+		opts = append(opts, WithModel(model))
+	}
+
+	result, err := Extract(context.Background(), writeTestImage(t), opts...)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if result.Source.Type != models.SourceTypeFile || result.Image.Width != 32 {
+		t.Errorf("unexpected result: source=%+v image=%+v", result.Source, result.Image)
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import "encoding/json"
+
+// parseBoundingBox decodes a bounding box from model output. Besides the
+// schema's {"x":..,"y":..,"width":..,"height":..} object it accepts an
+// [x, y, width, height] array. Missing coordinates, negative positions and
+// empty boxes yield nil so that one malformed box does not abort the parse.
+func parseBoundingBox(data json.RawMessage) *BoundingBox {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var b BoundingBox
+	var arr []float64
+	var obj struct {
+		X, Y          *float64
+		Width, Height *float64
+	}
+	switch {
+	case json.Unmarshal(data, &arr) == nil:
+		if len(arr) != 4 {
+			return nil
+		}
+		b = BoundingBox{X: arr[0], Y: arr[1], Width: arr[2], Height: arr[3]}
+	case json.Unmarshal(data, &obj) == nil:
+		if obj.X == nil || obj.Y == nil || obj.Width == nil || obj.Height == nil {
+			return nil
+		}
+		b = BoundingBox{X: *obj.X, Y: *obj.Y, Width: *obj.Width, Height: *obj.Height}
+	default:
+		return nil
+	}
+
+	if b.X < 0 || b.Y < 0 || b.Width <= 0 || b.Height <= 0 {
+		return nil
+	}
+	return &b
+}
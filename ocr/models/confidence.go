@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Confidence is a confidence value from model output. Models do not always
+// return a plain float, so it accepts any of:
+//
+//	0.95      a fraction
+//	95        a percentage (any number above 1)
+//	"0.95"    either of the above as a string
+//	"95%"     a percentage string
+//
+// Values are normalized to [0, 1]. null, empty and unparsable values decode
+// as 0 so that one malformed score does not abort the whole parse.
+type Confidence float64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Confidence) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case float64:
+		*c = normalizeConfidence(v, false)
+	case string:
+		s := strings.TrimSpace(v)
+		percent := strings.HasSuffix(s, "%")
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			*c = 0
+			return nil
+		}
+		*c = normalizeConfidence(f, percent)
+	default:
+		*c = 0
+	}
+	return nil
+}
+
+// normalizeConfidence converts v to a fraction in [0, 1]. Values above 1 are
+// taken as percentages.
+func normalizeConfidence(v float64, percent bool) Confidence {
+	if percent || v > 1 {
+		v /= 100
+	}
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return Confidence(v)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestConfidence_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want float64
+	}{
+		{"float", `0.95`, 0.95},
+		{"one", `1`, 1},
+		{"zero", `0`, 0},
+		{"integer percent", `95`, 0.95},
+		{"float percent", `87.5`, 0.875},
+		{"string float", `"0.95"`, 0.95},
+		{"string percent number", `"95"`, 0.95},
+		{"percent sign", `"95%"`, 0.95},
+		{"percent sign with spaces", `" 42 % "`, 0.42},
+		{"small percent", `"0.5%"`, 0.005},
+		{"above 100 clamped", `250`, 1},
+		{"negative clamped", `-0.2`, 0},
+		{"null", `null`, 0},
+		{"empty string", `""`, 0},
+		{"garbage string", `"high"`, 0},
+		{"boolean", `true`, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Confidence
+			if err := json.Unmarshal([]byte(tt.json), &c); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", tt.json, err)
+			}
+			if math.Abs(float64(c)-tt.want) > 1e-9 {
+				t.Errorf("Unmarshal(%s) = %v, want %v", tt.json, float64(c), tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaVisionResponse_FlexibleConfidence(t *testing.T) {
+	fixtures := []string{
+		`{"metadata":{"confidence_score":"0.9"},"text":{"lines":[{"text":"a","confidence":"90%"}]}}`,
+		`{"metadata":{"confidence_score":90},"text":{"lines":[{"text":"a","confidence":90}]}}`,
+		`{"metadata":{"confidence_score":"90%"},"text":{"lines":[{"text":"a","confidence":0.9}]}}`,
+	}
+
+	for _, fixture := range fixtures {
+		var resp OllamaVisionResponse
+		if err := json.Unmarshal([]byte(fixture), &resp); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", fixture, err)
+		}
+		if math.Abs(float64(resp.Metadata.ConfidenceScore)-0.9) > 1e-9 {
+			t.Errorf("%s: ConfidenceScore = %v, want 0.9", fixture, resp.Metadata.ConfidenceScore)
+		}
+		if math.Abs(float64(resp.Text.Lines[0].Confidence)-0.9) > 1e-9 {
+			t.Errorf("%s: line Confidence = %v, want 0.9", fixture, resp.Text.Lines[0].Confidence)
+		}
+	}
+}
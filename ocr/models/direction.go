@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// TextDirection is the reading direction of a document's text.
+type TextDirection string
+
+const (
+	TextDirectionLTR TextDirection = "ltr"
+	TextDirectionRTL TextDirection = "rtl"
+)
+
+// UnmarshalJSON implements json.Unmarshaler. Models spell directions in
+// several ways, so "rtl", "RTL", "right-to-left" and "right_to_left" all
+// decode as TextDirectionRTL. Anything else, including null and non-string
+// values, decodes as TextDirectionLTR.
+func (d *TextDirection) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*d = TextDirectionLTR
+	if s, ok := v.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+		s = strings.NewReplacer("-", "", "_", "", " ", "").Replace(s)
+		if s == "rtl" || s == "righttoleft" {
+			*d = TextDirectionRTL
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTextDirection_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want TextDirection
+	}{
+		{`"rtl"`, TextDirectionRTL},
+		{`"RTL"`, TextDirectionRTL},
+		{`" right-to-left "`, TextDirectionRTL},
+		{`"Right_To_Left"`, TextDirectionRTL},
+		{`"ltr"`, TextDirectionLTR},
+		{`"left-to-right"`, TextDirectionLTR},
+		{`"vertical"`, TextDirectionLTR},
+		{`""`, TextDirectionLTR},
+		{`null`, TextDirectionLTR},
+		{`1`, TextDirectionLTR},
+		{`{"dir":"rtl"}`, TextDirectionLTR},
+	}
+
+	for _, tt := range tests {
+		var m OllamaMetadata
+		if err := json.Unmarshal([]byte(`{"direction":`+tt.in+`}`), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if m.Direction != tt.want {
+			t.Errorf("Unmarshal(%s) = %q, want %q", tt.in, m.Direction, tt.want)
+		}
+	}
+}
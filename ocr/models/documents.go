@@ -0,0 +1,52 @@
+package models
+
+import "encoding/json"
+
+// DetectedDocument is one of several documents found in a single image,
+// such as receipts scanned side by side.
+type DetectedDocument struct {
+	// BoundingBox is the region of the document, in the units given by
+	// TextResult.BoundingBoxUnits.
+	BoundingBox  BoundingBox  `json:"bounding_box"`
+	DocumentType DocumentType `json:"document_type"`
+}
+
+// OllamaDocument is a forgiving document region from Ollama.
+type OllamaDocument struct {
+	BoundingBox  *BoundingBox
+	DocumentType string
+}
+
+// OllamaDocuments is the forgiving list of document regions from Ollama.
+type OllamaDocuments []OllamaDocument
+
+// UnmarshalJSON implements json.Unmarshaler. Entries without a usable
+// bounding box are dropped, and a value that is not a list yields no
+// documents, rather than failing the whole parse.
+func (d *OllamaDocuments) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		*d = nil
+		return nil
+	}
+
+	docs := make(OllamaDocuments, 0, len(raw))
+	for _, r := range raw {
+		var entry struct {
+			BoundingBox  json.RawMessage `json:"bounding_box"`
+			DocumentType json.RawMessage `json:"document_type"`
+		}
+		if json.Unmarshal(r, &entry) != nil {
+			continue
+		}
+		box := parseBoundingBox(entry.BoundingBox)
+		if box == nil {
+			continue
+		}
+		doc := OllamaDocument{BoundingBox: box}
+		_ = json.Unmarshal(entry.DocumentType, &doc.DocumentType)
+		docs = append(docs, doc)
+	}
+	*d = docs
+	return nil
+}
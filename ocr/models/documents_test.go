@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOllamaDocuments_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want OllamaDocuments
+	}{
+		{
+			name: "objects and arrays",
+			json: `[{"bounding_box":{"x":0,"y":0,"width":10,"height":20},"document_type":"receipt"},` +
+				`{"bounding_box":[10,0,5,20],"document_type":"invoice"}]`,
+			want: OllamaDocuments{
+				{BoundingBox: &BoundingBox{Width: 10, Height: 20}, DocumentType: "receipt"},
+				{BoundingBox: &BoundingBox{X: 10, Width: 5, Height: 20}, DocumentType: "invoice"},
+			},
+		},
+		{
+			name: "malformed entries dropped",
+			json: `[{"bounding_box":{"x":1,"y":1}},{"document_type":"receipt"},"receipt",` +
+				`{"bounding_box":[1,1,2,2],"document_type":7}]`,
+			want: OllamaDocuments{{BoundingBox: &BoundingBox{X: 1, Y: 1, Width: 2, Height: 2}}},
+		},
+		{
+			name: "not a list",
+			json: `"two receipts"`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got OllamaDocuments
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("documents = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts key/value pairs in
+// the shapes models actually produce:
+//
+//	"total": "9.99"                                 a string
+//	"total": 9.99                                   a number or boolean
+//	"total": {"value": "9.99", "confidence": 0.8}   a value with its confidence
+//	"total": {"value": "9.99", "bounding_box": {}}  a value with its region
+//
+// Confidences and boxes may also be given in separate key_value_confidence
+// and key_value_boxes objects; inline ones take precedence. Values of any
+// other shape, and malformed confidences or boxes, are dropped rather than
+// failing the whole parse.
+func (d *OllamaStructuredData) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		KeyValuePairs      map[string]json.RawMessage `json:"key_value_pairs"`
+		KeyValueConfidence json.RawMessage            `json:"key_value_confidence"`
+		KeyValueBoxes      json.RawMessage            `json:"key_value_boxes"`
+		Tables             []Table                    `json:"tables"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*d = OllamaStructuredData{Tables: raw.Tables}
+
+	// A malformed confidence or box map is ignored; the values are what matter.
+	var confidence map[string]Confidence
+	if len(raw.KeyValueConfidence) > 0 {
+		if err := json.Unmarshal(raw.KeyValueConfidence, &confidence); err != nil {
+			confidence = nil
+		}
+	}
+	var boxes map[string]json.RawMessage
+	if len(raw.KeyValueBoxes) > 0 {
+		if err := json.Unmarshal(raw.KeyValueBoxes, &boxes); err != nil {
+			boxes = nil
+		}
+	}
+
+	if raw.KeyValuePairs != nil {
+		d.KeyValuePairs = make(map[string]string, len(raw.KeyValuePairs))
+	}
+	keys := objectKeys(data, "key_value_pairs")
+	if len(keys) != len(raw.KeyValuePairs) {
+		// The field was matched case-insensitively; fall back to a stable order
+		keys = slices.Sorted(maps.Keys(raw.KeyValuePairs))
+	}
+	for _, k := range keys {
+		kv, ok := parseKeyValue(raw.KeyValuePairs[k])
+		if !ok {
+			continue
+		}
+		d.KeyValuePairs[k] = kv.value
+		d.KeyOrder = append(d.KeyOrder, k)
+
+		if !kv.hasConf {
+			kv.conf, kv.hasConf = confidence[k]
+		}
+		if kv.hasConf {
+			if d.KeyValueConfidence == nil {
+				d.KeyValueConfidence = make(map[string]Confidence)
+			}
+			d.KeyValueConfidence[k] = kv.conf
+		}
+
+		if kv.box == nil {
+			kv.box = parseBoundingBox(boxes[k])
+		}
+		if kv.box != nil {
+			if d.KeyValueBoxes == nil {
+				d.KeyValueBoxes = make(map[string]*BoundingBox)
+			}
+			d.KeyValueBoxes[k] = kv.box
+		}
+	}
+	return nil
+}
+
+// objectKeys returns the keys of the object under field in the JSON object
+// data, in the order they appear. Duplicate keys are listed once.
+func objectKeys(data []byte, field string) []string {
+	var outer map[string]json.RawMessage
+	if err := json.Unmarshal(data, &outer); err != nil {
+		return nil
+	}
+	inner, ok := outer[field]
+	if !ok {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(inner))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return keys
+		}
+		key, _ := tok.(string)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return keys
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// keyValue is one decoded key/value pair value.
+type keyValue struct {
+	value   string
+	conf    Confidence
+	hasConf bool
+	box     *BoundingBox
+}
+
+// parseKeyValue decodes one key/value pair value. ok is false for values
+// that cannot be represented as a string.
+func parseKeyValue(data json.RawMessage) (kv keyValue, ok bool) {
+	if kv.value, ok = scalarString(data); ok {
+		return kv, true
+	}
+
+	var obj struct {
+		Value       json.RawMessage `json:"value"`
+		Confidence  *Confidence     `json:"confidence"`
+		BoundingBox json.RawMessage `json:"bounding_box"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil || obj.Value == nil {
+		return keyValue{}, false
+	}
+	if kv.value, ok = scalarString(obj.Value); !ok {
+		return keyValue{}, false
+	}
+	if obj.Confidence != nil {
+		kv.conf, kv.hasConf = *obj.Confidence, true
+	}
+	kv.box = parseBoundingBox(obj.BoundingBox)
+	return kv, true
+}
+
+// UnmarshalJSON implements json.Unmarshaler. A malformed bounding box or
+// spans are dropped rather than failing the whole table, and the confidence
+// is accepted in any form Confidence understands.
+func (t *Table) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Headers     []string        `json:"headers"`
+		Rows        [][]string      `json:"rows"`
+		BoundingBox json.RawMessage `json:"bounding_box"`
+		Confidence  Confidence      `json:"confidence"`
+		Spans       json.RawMessage `json:"spans"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = Table{
+		Headers:     raw.Headers,
+		Rows:        raw.Rows,
+		BoundingBox: parseBoundingBox(raw.BoundingBox),
+		Confidence:  float64(raw.Confidence),
+		Spans:       parseCellSpans(raw.Spans, raw.Rows),
+	}
+	return nil
+}
+
+// scalarString returns a JSON string, number, boolean or null as a string.
+func scalarString(data json.RawMessage) (string, bool) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", true
+	case float64, bool:
+		return strings.TrimSpace(string(data)), true
+	}
+	return "", false
+}
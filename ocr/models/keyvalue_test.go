@@ -0,0 +1,185 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestOllamaStructuredData_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		wantValue map[string]string
+		wantConf  map[string]float64
+	}{
+		{
+			name:      "plain strings",
+			json:      `{"key_value_pairs":{"total":"9.99"}}`,
+			wantValue: map[string]string{"total": "9.99"},
+		},
+		{
+			name:      "separate confidence map",
+			json:      `{"key_value_pairs":{"total":"9.99","date":"2024-01-02"},"key_value_confidence":{"total":0.9}}`,
+			wantValue: map[string]string{"total": "9.99", "date": "2024-01-02"},
+			wantConf:  map[string]float64{"total": 0.9},
+		},
+		{
+			name:      "inline value objects",
+			json:      `{"key_value_pairs":{"total":{"value":"9.99","confidence":"80%"},"vat":{"value":1.5}}}`,
+			wantValue: map[string]string{"total": "9.99", "vat": "1.5"},
+			wantConf:  map[string]float64{"total": 0.8},
+		},
+		{
+			name:      "inline confidence wins",
+			json:      `{"key_value_pairs":{"total":{"value":"9.99","confidence":0.4}},"key_value_confidence":{"total":0.9}}`,
+			wantValue: map[string]string{"total": "9.99"},
+			wantConf:  map[string]float64{"total": 0.4},
+		},
+		{
+			name:      "numbers, booleans and null",
+			json:      `{"key_value_pairs":{"total":9.99,"paid":true,"note":null}}`,
+			wantValue: map[string]string{"total": "9.99", "paid": "true", "note": ""},
+		},
+		{
+			name:      "unusable values dropped",
+			json:      `{"key_value_pairs":{"items":["a","b"],"nested":{"a":1},"total":"9.99"}}`,
+			wantValue: map[string]string{"total": "9.99"},
+		},
+		{
+			name:      "malformed confidence map ignored",
+			json:      `{"key_value_pairs":{"total":"9.99"},"key_value_confidence":[0.9]}`,
+			wantValue: map[string]string{"total": "9.99"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d OllamaStructuredData
+			if err := json.Unmarshal([]byte(tt.json), &d); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(d.KeyValuePairs) != len(tt.wantValue) {
+				t.Fatalf("KeyValuePairs = %v, want %v", d.KeyValuePairs, tt.wantValue)
+			}
+			for k, v := range tt.wantValue {
+				if d.KeyValuePairs[k] != v {
+					t.Errorf("KeyValuePairs[%q] = %q, want %q", k, d.KeyValuePairs[k], v)
+				}
+			}
+			if len(d.KeyValueConfidence) != len(tt.wantConf) {
+				t.Fatalf("KeyValueConfidence = %v, want %v", d.KeyValueConfidence, tt.wantConf)
+			}
+			for k, c := range tt.wantConf {
+				if math.Abs(float64(d.KeyValueConfidence[k])-c) > 1e-9 {
+					t.Errorf("KeyValueConfidence[%q] = %v, want %v", k, d.KeyValueConfidence[k], c)
+				}
+			}
+		})
+	}
+}
+
+func TestOllamaStructuredData_UnmarshalJSONKeyOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"model order", `{"key_value_pairs":{"vendor":"ACME","total":"9.99","date":"2024-01-02"}}`, []string{"vendor", "total", "date"}},
+		{"duplicate keys", `{"key_value_pairs":{"total":"1","vendor":"ACME","total":"2"}}`, []string{"total", "vendor"}},
+		{"unusable values dropped", `{"key_value_pairs":{"b":"1","items":[1,2],"a":"2"}}`, []string{"b", "a"}},
+		{"case-insensitive field", `{"Key_Value_Pairs":{"b":"1","a":"2"}}`, []string{"a", "b"}},
+		{"no pairs", `{"tables":[]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d OllamaStructuredData
+			if err := json.Unmarshal([]byte(tt.json), &d); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(d.KeyOrder, tt.want) {
+				t.Errorf("KeyOrder = %q, want %q", d.KeyOrder, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaStructuredData_UnmarshalJSONTables(t *testing.T) {
+	var d OllamaStructuredData
+	err := json.Unmarshal([]byte(`{"tables":[{"headers":["a"],"rows":[["1"]]}]}`), &d)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(d.Tables) != 1 || d.Tables[0].Rows[0][0] != "1" {
+		t.Errorf("Tables = %+v", d.Tables)
+	}
+	if d.KeyValuePairs != nil {
+		t.Errorf("KeyValuePairs = %v, want nil when absent", d.KeyValuePairs)
+	}
+}
+
+func TestTable_UnmarshalJSONConfidence(t *testing.T) {
+	var d OllamaStructuredData
+	err := json.Unmarshal([]byte(`{"tables":[
+		{"rows":[["1"]],"confidence":0.4},
+		{"rows":[["2"]],"confidence":"85%"},
+		{"rows":[["3"]],"confidence":"high"},
+		{"rows":[["4"]]}
+	]}`), &d)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	var got []float64
+	for _, table := range d.Tables {
+		got = append(got, table.Confidence)
+	}
+	if want := []float64{0.4, 0.85, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("confidences = %v, want %v", got, want)
+	}
+}
+
+func TestOllamaStructuredData_UnmarshalJSONBoxes(t *testing.T) {
+	var d OllamaStructuredData
+	err := json.Unmarshal([]byte(`{
+		"key_value_pairs": {
+			"total": {"value": "9.99", "bounding_box": [10, 20, 30, 5]},
+			"date": "2024-01-02",
+			"vendor": "ACME",
+			"bad": {"value": "x", "bounding_box": {"x": 1, "y": 1, "width": 0, "height": 2}}
+		},
+		"key_value_boxes": {
+			"total": {"x": 0, "y": 0, "width": 1, "height": 1},
+			"date": {"x": 1, "y": 2, "width": 3, "height": 4},
+			"vendor": "top left"
+		},
+		"tables": [
+			{"headers": ["a"], "rows": [["1"]], "bounding_box": {"x": 5, "y": 6, "width": 7, "height": 8}},
+			{"headers": ["b"], "rows": [["2"]], "bounding_box": "whole page"}
+		]
+	}`), &d)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	want := map[string]*BoundingBox{
+		"total": {X: 10, Y: 20, Width: 30, Height: 5},
+		"date":  {X: 1, Y: 2, Width: 3, Height: 4},
+	}
+	if !reflect.DeepEqual(d.KeyValueBoxes, want) {
+		t.Errorf("KeyValueBoxes = %+v, want %+v", d.KeyValueBoxes, want)
+	}
+	if d.KeyValuePairs["bad"] != "x" {
+		t.Errorf("KeyValuePairs[bad] = %q, want the value kept without its box", d.KeyValuePairs["bad"])
+	}
+
+	if len(d.Tables) != 2 {
+		t.Fatalf("len(Tables) = %d, want 2", len(d.Tables))
+	}
+	if got := d.Tables[0].BoundingBox; got == nil || *got != (BoundingBox{X: 5, Y: 6, Width: 7, Height: 8}) {
+		t.Errorf("Tables[0].BoundingBox = %+v", got)
+	}
+	if d.Tables[1].BoundingBox != nil || d.Tables[1].Rows[0][0] != "2" {
+		t.Errorf("Tables[1] = %+v, want the table without its malformed box", d.Tables[1])
+	}
+}
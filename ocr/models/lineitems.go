@@ -0,0 +1,214 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrNoLineItems is returned by LineItems when no table looks like a list
+// of line items.
+var ErrNoLineItems = errors.New("no line item table found")
+
+// LineItem is one typed row of an invoice or receipt. Quantity, UnitPrice
+// and Total are 0 when the table has no such column or the cell is empty.
+type LineItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Total       float64 `json:"total"`
+}
+
+// lineItemColumn is the role of a line item table column.
+type lineItemColumn int
+
+const (
+	columnOther lineItemColumn = iota
+	columnDescription
+	columnQuantity
+	columnUnitPrice
+	columnTotal
+)
+
+// lineItemHeaderWords map words of a column header to the column's role.
+// Headers are matched word by word in the order total, quantity, unit price,
+// description, so "Total Price" is a total and "Item Price" a unit price.
+var lineItemHeaderWords = []struct {
+	column lineItemColumn
+	words  map[string]bool
+}{
+	{columnTotal, wordSet("total", "amount", "sum", "subtotal", "betrag", "montant", "importe")},
+	{columnQuantity, wordSet("qty", "quantity", "quant", "units", "pcs", "count", "hours", "hrs", "menge", "qté", "qte", "cantidad")},
+	{columnUnitPrice, wordSet("price", "rate", "cost", "each", "unit", "preis", "prix", "precio")},
+	{columnDescription, wordSet("description", "desc", "item", "items", "product", "article", "service", "details", "name", "bezeichnung", "artikel", "désignation", "designation")},
+}
+
+// summaryRow matches the label of a totals row below the line items.
+var summaryRow = regexp.MustCompile(`(?i)^\s*(sub\s*-?\s*total|grand\s+total|total|sales\s+tax|tax|vat|gst|balance(\s+due)?|amount\s+due)\b`)
+
+func wordSet(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+// LineItems finds the table that lists line items, such as the items of an
+// invoice or receipt, and returns its rows with typed amounts.
+//
+// Columns are identified by their headers ("Qty", "Unit Price", "Amount",
+// ...); a table without headers may carry them in its first row. A table
+// qualifies if it has a unit price or total column, and the one with the
+// most recognized columns wins, then the one with the most rows. Without a
+// description column the first unrecognized column is used.
+//
+// Amounts are parsed with NormalizeAmount, guessing the decimal separator;
+// LineItemsLocale takes the locale to use instead. Totals rows such as "Subtotal" or "Tax" are skipped, and a row with a
+// description but no numbers continues the previous item's description.
+// If Total is missing it is computed from Quantity and UnitPrice.
+//
+// The error wraps ErrNoLineItems if no table qualifies, or ErrNotANumber if
+// an amount cell cannot be read.
+func (sd StructuredData) LineItems() ([]LineItem, error) {
+	return sd.LineItemsLocale("")
+}
+
+// LineItemsLocale is like LineItems but parses amounts with the decimal
+// separator of locale, as NormalizeAmount does. An empty locale guesses it.
+func (sd StructuredData) LineItemsLocale(locale string) ([]LineItem, error) {
+	var (
+		bestCols   []lineItemColumn
+		bestRows   [][]string
+		bestScore  int
+		bestLength int
+	)
+	for _, t := range sd.Tables {
+		header, rows := t.Headers, t.Rows
+		cols, score := classifyColumns(header)
+		if score == 0 && len(rows) > 0 {
+			header, rows = rows[0], rows[1:]
+			cols, score = classifyColumns(header)
+		}
+		if !hasAmountColumn(cols) || len(rows) == 0 {
+			continue
+		}
+		if score > bestScore || score == bestScore && len(rows) > bestLength {
+			bestCols, bestRows, bestScore, bestLength = cols, rows, score, len(rows)
+		}
+	}
+	if bestCols == nil {
+		return nil, ErrNoLineItems
+	}
+
+	if !slices.Contains(bestCols, columnDescription) {
+		for i, c := range bestCols {
+			if c == columnOther {
+				bestCols[i] = columnDescription
+				break
+			}
+		}
+	}
+
+	items := []LineItem{}
+	for i, row := range bestRows {
+		cells := make(map[lineItemColumn]string, 4)
+		for j, c := range bestCols {
+			if c != columnOther && j < len(row) && cells[c] == "" {
+				cells[c] = strings.TrimSpace(row[j])
+			}
+		}
+
+		desc := cells[columnDescription]
+		numeric := cells[columnQuantity] != "" || cells[columnUnitPrice] != "" || cells[columnTotal] != ""
+		switch {
+		case !numeric && desc == "":
+			continue
+		case !numeric:
+			if len(items) > 0 {
+				last := &items[len(items)-1]
+				last.Description = strings.TrimSpace(last.Description + " " + desc)
+			}
+			continue
+		case cells[columnQuantity] == "" && (desc == "" || isSummaryRow(row)):
+			continue
+		}
+
+		item := LineItem{Description: desc}
+		var err error
+		if item.Quantity, err = parseLineItemAmount(cells[columnQuantity], locale); err != nil {
+			return nil, fmt.Errorf("row %d quantity: %w", i+1, err)
+		}
+		if item.UnitPrice, err = parseLineItemAmount(cells[columnUnitPrice], locale); err != nil {
+			return nil, fmt.Errorf("row %d unit price: %w", i+1, err)
+		}
+		if item.Total, err = parseLineItemAmount(cells[columnTotal], locale); err != nil {
+			return nil, fmt.Errorf("row %d total: %w", i+1, err)
+		}
+		if cells[columnTotal] == "" && item.Quantity != 0 && item.UnitPrice != 0 {
+			item.Total = item.Quantity * item.UnitPrice
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// classifyColumns returns the role of each header and how many distinct
+// roles were recognized. Only the first column of each role is assigned.
+func classifyColumns(header []string) ([]lineItemColumn, int) {
+	cols := make([]lineItemColumn, len(header))
+	seen := make(map[lineItemColumn]bool)
+	for i, h := range header {
+		c := classifyHeader(h)
+		if c == columnOther || seen[c] {
+			continue
+		}
+		cols[i] = c
+		seen[c] = true
+	}
+	return cols, len(seen)
+}
+
+func classifyHeader(h string) lineItemColumn {
+	words := strings.FieldsFunc(strings.ToLower(h), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, group := range lineItemHeaderWords {
+		for _, w := range words {
+			if group.words[w] {
+				return group.column
+			}
+		}
+	}
+	return columnOther
+}
+
+// isSummaryRow reports whether a cell of row labels it as a totals row.
+func isSummaryRow(row []string) bool {
+	for _, cell := range row {
+		if summaryRow.MatchString(cell) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAmountColumn(cols []lineItemColumn) bool {
+	return slices.Contains(cols, columnUnitPrice) || slices.Contains(cols, columnTotal)
+}
+
+// parseLineItemAmount parses an amount cell, or returns 0 for an empty one.
+func parseLineItemAmount(s, locale string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := NormalizeAmount(s, locale)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(n, 64)
+}
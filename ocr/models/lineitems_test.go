@@ -0,0 +1,198 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStructuredData_LineItems(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []Table
+		want   []LineItem
+	}{
+		{
+			name: "invoice with totals rows",
+			tables: []Table{{
+				Headers: []string{"Description", "Qty", "Unit Price", "Amount"},
+				Rows: [][]string{
+					{"Widget", "2", "$4.50", "$9.00"},
+					{"Gadget", "1", "$1,250.00", "$1,250.00"},
+					{"", "", "Subtotal", "$1,259.00"},
+					{"Tax (8%)", "", "", "$100.72"},
+					{"Total", "", "", "$1,359.72"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "Widget", Quantity: 2, UnitPrice: 4.5, Total: 9},
+				{Description: "Gadget", Quantity: 1, UnitPrice: 1250, Total: 1250},
+			},
+		},
+		{
+			name: "headers in first row",
+			tables: []Table{{
+				Rows: [][]string{
+					{"Item", "Price"},
+					{"Coffee", "3.50"},
+					{"Bagel", "2.25"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "Coffee", UnitPrice: 3.5},
+				{Description: "Bagel", UnitPrice: 2.25},
+			},
+		},
+		{
+			name: "comma decimals and currency codes",
+			tables: []Table{{
+				Headers: []string{"Artikel", "Menge", "Preis", "Betrag"},
+				Rows: [][]string{
+					{"Schrauben", "2,5", "1.000,00 EUR", "2.500,00 EUR"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "Schrauben", Quantity: 2.5, UnitPrice: 1000, Total: 2500},
+			},
+		},
+		{
+			name: "total computed from quantity and unit price",
+			tables: []Table{{
+				Headers: []string{"Service", "Hours", "Rate"},
+				Rows: [][]string{
+					{"Consulting", "4", "150"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "Consulting", Quantity: 4, UnitPrice: 150, Total: 600},
+			},
+		},
+		{
+			name: "description falls back to first unrecognized column",
+			tables: []Table{{
+				Headers: []string{"SKU", "Qty", "Total"},
+				Rows: [][]string{
+					{"A-100", "3", "12.00"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "A-100", Quantity: 3, Total: 12},
+			},
+		},
+		{
+			name: "continuation row and short rows",
+			tables: []Table{{
+				Headers: []string{"Description", "Qty", "Amount"},
+				Rows: [][]string{
+					{"Annual support", "1", "500.00"},
+					{"incl. updates"},
+					{},
+					{"Setup fee", "1"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "Annual support incl. updates", Quantity: 1, Total: 500},
+				{Description: "Setup fee", Quantity: 1},
+			},
+		},
+		{
+			name: "negative amounts",
+			tables: []Table{{
+				Headers: []string{"Item", "Amount"},
+				Rows: [][]string{
+					{"Shirt", "20.00"},
+					{"Discount", "(5.00)"},
+				},
+			}},
+			want: []LineItem{
+				{Description: "Shirt", Total: 20},
+				{Description: "Discount", Total: -5},
+			},
+		},
+		{
+			name: "best table wins",
+			tables: []Table{
+				{
+					Headers: []string{"Tax Rate", "Amount"},
+					Rows:    [][]string{{"8%", "100.72"}},
+				},
+				{
+					Headers: []string{"Item", "Qty", "Price", "Total"},
+					Rows:    [][]string{{"Widget", "2", "4.50", "9.00"}},
+				},
+				{
+					Headers: []string{"Item", "Qty", "Price", "Total"},
+					Rows:    [][]string{{"Gadget", "1", "1.00", "1.00"}, {"Gizmo", "1", "2.00", "2.00"}},
+				},
+			},
+			want: []LineItem{
+				{Description: "Gadget", Quantity: 1, UnitPrice: 1, Total: 1},
+				{Description: "Gizmo", Quantity: 1, UnitPrice: 2, Total: 2},
+			},
+		},
+		{
+			name: "only totals rows",
+			tables: []Table{{
+				Headers: []string{"Description", "Amount"},
+				Rows:    [][]string{{"Total", "9.99"}},
+			}},
+			want: []LineItem{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StructuredData{Tables: tt.tables}.LineItems()
+			if err != nil {
+				t.Fatalf("LineItems: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LineItems = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructuredData_LineItemsNoTable(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []Table
+	}{
+		{"no tables", nil},
+		{"no amount column", []Table{{Headers: []string{"Name", "Qty"}, Rows: [][]string{{"a", "1"}}}}},
+		{"unrecognized headers", []Table{{Headers: []string{"Day", "Opening hours"}, Rows: [][]string{{"Mon", "9-5"}}}}},
+		{"no rows", []Table{{Headers: []string{"Item", "Price"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (StructuredData{Tables: tt.tables}).LineItems(); !errors.Is(err, ErrNoLineItems) {
+				t.Errorf("err = %v, want ErrNoLineItems", err)
+			}
+		})
+	}
+}
+
+func TestStructuredData_LineItemsBadAmount(t *testing.T) {
+	sd := StructuredData{Tables: []Table{{
+		Headers: []string{"Item", "Qty", "Price"},
+		Rows:    [][]string{{"Widget", "two", "4.50"}},
+	}}}
+	if _, err := sd.LineItems(); !errors.Is(err, ErrNotANumber) {
+		t.Errorf("err = %v, want ErrNotANumber", err)
+	}
+}
+
+func TestStructuredData_LineItemsLocale(t *testing.T) {
+	sd := StructuredData{Tables: []Table{{
+		Headers: []string{"Item", "Qty", "Unit Price", "Total"},
+		Rows:    [][]string{{"Screw", "1.000", "0,125", "125,00"}},
+	}}}
+	want := []LineItem{{Description: "Screw", Quantity: 1000, UnitPrice: 0.125, Total: 125}}
+
+	got, err := sd.LineItemsLocale("de-DE")
+	if err != nil {
+		t.Fatalf("LineItemsLocale: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LineItemsLocale = %+v, want %+v", got, want)
+	}
+}
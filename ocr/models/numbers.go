@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrNotANumber is returned by NormalizeAmount for text without a number.
+var ErrNotANumber = errors.New("not a number")
+
+// commaDecimalLanguages write the decimal separator as a comma.
+var commaDecimalLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true,
+	"es": true, "et": true, "fi": true, "fr": true, "hr": true, "hu": true,
+	"id": true, "it": true, "lt": true, "lv": true, "nb": true, "nl": true,
+	"nn": true, "no": true, "pl": true, "pt": true, "ro": true, "ru": true,
+	"sk": true, "sl": true, "sr": true, "sv": true, "tr": true, "uk": true,
+	"vi": true,
+}
+
+// pointDecimalRegions are regions whose decimal separator differs from the
+// default of their language, e.g. Switzerland writes 1'234.56.
+var pointDecimalRegions = map[string]bool{
+	"de-ch": true, "de-li": true, "fr-ch": true, "it-ch": true,
+	"es-mx": true, "es-us": true, "es-pr": true,
+}
+
+// DecimalSeparator returns the decimal separator of a BCP 47 locale such as
+// "de-DE" or "en_US", or 0 if the locale is empty. Unknown languages use a
+// point.
+func DecimalSeparator(locale string) rune {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if locale == "" {
+		return 0
+	}
+	lang, region, _ := strings.Cut(locale, "-")
+	if region != "" && pointDecimalRegions[lang+"-"+region] {
+		return '.'
+	}
+	if commaDecimalLanguages[lang] {
+		return ','
+	}
+	return '.'
+}
+
+// NormalizeAmount converts a number or amount as printed on a document, such
+// as "1.234,56 €", "$1,234.56", "CHF 1'234.50" or "(12.00)", to a plain
+// decimal string like "1234.56" that strconv.ParseFloat or a decimal library
+// can read. Currency symbols and codes are dropped and parentheses or a
+// trailing minus mean a negative amount.
+//
+// locale selects the decimal separator. If it is empty the separator is
+// guessed: with both "." and "," present the last one is the decimal
+// separator, a separator that repeats groups thousands, and a single one
+// followed by exactly three digits is taken as a thousands separator unless
+// the integer part is "0", as in "0.125". A sign is only accepted before or
+// after the number.
+func NormalizeAmount(s, locale string) (string, error) {
+	negative, trailing := false, false
+	var digits []rune // digits and separators only
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '.' || r == ',':
+			// Skip punctuation of a label such as "Nr. 12" or "12 EUR."
+			if trailing || len(digits) == 0 && (i+1 == len(runes) || !unicode.IsDigit(runes[i+1])) {
+				continue
+			}
+			fallthrough
+		case r >= '0' && r <= '9':
+			if trailing {
+				return "", fmt.Errorf("%w: %q", ErrNotANumber, s)
+			}
+			digits = append(digits, r)
+		case r == '-' || r == '−' || r == '(':
+			// A sign after the number ends it, so "2024-01-05" and
+			// "12 - 3" are rejected rather than read as negative.
+			negative = true
+			trailing = len(digits) > 0
+		case r == '\'' || r == '’' || r == ')' || r == '+' || unicode.IsSpace(r):
+		case unicode.IsLetter(r) || unicode.Is(unicode.Sc, r):
+			// A currency symbol, code or unit before or after the number
+			trailing = len(digits) > 0
+		default:
+			return "", fmt.Errorf("%w: unexpected %q in %q", ErrNotANumber, r, s)
+		}
+	}
+	if !containsDigit(digits) {
+		return "", fmt.Errorf("%w: %q", ErrNotANumber, s)
+	}
+
+	dec := DecimalSeparator(locale)
+	if dec == 0 {
+		dec = guessDecimalSeparator(digits)
+	}
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+	seenDecimal := false
+	for _, r := range digits {
+		switch {
+		case r == dec:
+			if seenDecimal {
+				return "", fmt.Errorf("%w: more than one decimal separator in %q", ErrNotANumber, s)
+			}
+			seenDecimal = true
+			sb.WriteByte('.')
+		case r == '.' || r == ',':
+			if seenDecimal {
+				return "", fmt.Errorf("%w: thousands separator after the decimal separator in %q", ErrNotANumber, s)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	out := strings.TrimSuffix(sb.String(), ".")
+	out = strings.Replace(out, "-.", "-0.", 1)
+	if strings.HasPrefix(out, ".") {
+		out = "0" + out
+	}
+	return out, nil
+}
+
+// guessDecimalSeparator picks the decimal separator of a locale-less number.
+// It returns 0 if the number has no decimal part.
+func guessDecimalSeparator(digits []rune) rune {
+	lastDot := lastIndex(digits, '.')
+	lastComma := lastIndex(digits, ',')
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			return '.'
+		}
+		return ','
+	case lastDot < 0 && lastComma < 0:
+		return 0
+	}
+
+	sep, last := '.', lastDot
+	if lastComma >= 0 {
+		sep, last = ',', lastComma
+	}
+	if count(digits, sep) > 1 {
+		return 0 // thousands only
+	}
+	if intPart := string(digits[:last]); len(digits)-last-1 == 3 && intPart != "" && intPart != "0" {
+		return 0 // thousands only
+	}
+	return sep
+}
+
+func containsDigit(rs []rune) bool {
+	for _, r := range rs {
+		if r >= '0' && r <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+func lastIndex(rs []rune, r rune) int {
+	for i := len(rs) - 1; i >= 0; i-- {
+		if rs[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func count(rs []rune, r rune) int {
+	n := 0
+	for _, c := range rs {
+		if c == r {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeAmount(t *testing.T) {
+	tests := []struct {
+		in, locale, want string
+	}{
+		// Explicit locales
+		{"1.234,56", "de-DE", "1234.56"},
+		{"1,234.56", "en-US", "1234.56"},
+		{"1.234", "de-DE", "1234"},
+		{"1.234", "en-US", "1.234"},
+		{"1 234,56 €", "fr-FR", "1234.56"},
+		{"1 234,56", "fr_FR", "1234.56"},
+		{"CHF 1'234.50", "de-CH", "1234.50"},
+		{"R$ 1.234,56", "pt-BR", "1234.56"},
+		{"$1,234.56", "es-MX", "1234.56"},
+		{"12,5", "it", "12.5"},
+		{"¥12,345", "ja-JP", "12345"},
+		{"1,23,456.00", "hi-IN", "123456.00"},
+
+		// Heuristic
+		{"1.234,56", "", "1234.56"},
+		{"1,234.56", "", "1234.56"},
+		{"12,50", "", "12.50"},
+		{"12.50", "", "12.50"},
+		{"1,234", "", "1234"},
+		{"1.234.567", "", "1234567"},
+		{"0.125", "", "0.125"},
+		{"$0.125", "", "0.125"},
+		{"0,750", "", "0.750"},
+		{".125", "", "0.125"},
+		{"42", "", "42"},
+
+		// Signs, labels and odd forms
+		{"(12.00)", "", "-12.00"},
+		{"12.00-", "", "-12.00"},
+		{"-€5", "", "-5"},
+		{".50", "", "0.50"},
+		{"12.", "en", "12"},
+		{"Nr. 12", "", "12"},
+		{"9.99 EUR.", "", "9.99"},
+		{"USD 9.99", "", "9.99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in+"/"+tt.locale, func(t *testing.T) {
+			got, err := NormalizeAmount(tt.in, tt.locale)
+			if err != nil {
+				t.Fatalf("NormalizeAmount(%q, %q): %v", tt.in, tt.locale, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeAmount(%q, %q) = %q, want %q", tt.in, tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeAmount_Invalid(t *testing.T) {
+	tests := []struct {
+		in, locale string
+	}{
+		{"", ""},
+		{"n/a", ""},
+		{"EUR", ""},
+		{"12 and 13", ""},
+		{"1.234,56", "en-US"},
+		{"1,2,3", "de-DE"},
+		{"12%", ""},
+		{"2024-01-05", ""},
+		{"12 - 3", ""},
+		{"12(3)", ""},
+	}
+
+	for _, tt := range tests {
+		if got, err := NormalizeAmount(tt.in, tt.locale); !errors.Is(err, ErrNotANumber) {
+			t.Errorf("NormalizeAmount(%q, %q) = %q, %v; want ErrNotANumber", tt.in, tt.locale, got, err)
+		}
+	}
+}
+
+func TestDecimalSeparator(t *testing.T) {
+	tests := map[string]rune{
+		"":      0,
+		"en-US": '.',
+		"de-DE": ',',
+		"de_AT": ',',
+		"DE-CH": '.',
+		"pt-BR": ',',
+		"xx":    '.',
+	}
+	for locale, want := range tests {
+		if got := DecimalSeparator(locale); got != want {
+			t.Errorf("DecimalSeparator(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
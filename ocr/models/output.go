@@ -2,15 +2,55 @@
 // All structs map directly to the mandatory JSON schema.
 package models
 
+import "time"
+
 // OCRResult is the top-level output of an OCR extraction.
 // Every field is strictly typed and maps 1:1 to the required JSON schema.
 type OCRResult struct {
+	SchemaVersion  string         `json:"schema_version"`
 	Source         Source         `json:"source"`
 	Image          ImageInfo      `json:"image"`
+	ReportedImage  *ReportedImage `json:"reported_image,omitempty"`
 	Metadata       Metadata       `json:"metadata"`
 	Text           TextResult     `json:"text"`
 	StructuredData StructuredData `json:"structured_data"`
 	Summary        *string        `json:"summary"`
+	Usage          Usage          `json:"usage"`
+	Quality        *QualityReport `json:"quality,omitempty"`
+	Timings        *Timings       `json:"timings,omitempty"`
+	Warnings       []string       `json:"warnings,omitempty"`
+
+	// Documents lists the documents found in the image with
+	// WithDetectMultipleDocuments.
+	Documents []DetectedDocument `json:"documents,omitempty"`
+
+	// DocumentRegion is set on the results of ExtractMultiDoc: the region
+	// of the original image, in pixels, that this result was extracted from.
+	DocumentRegion *BoundingBox `json:"document_region,omitempty"`
+
+	// Transforms lists the geometric transforms applied to the image before
+	// OCR, in order. With WithOriginalCoordinates the bounding boxes have
+	// been mapped back through them to the coordinates of Image.
+	Transforms []ImageTransform `json:"transforms,omitempty"`
+
+	// PDFInfo is the PDF's document information dictionary. It is only set
+	// for PDFs with WithExtractPDFMetadata.
+	PDFInfo *PDFInfo `json:"pdf_info,omitempty"`
+
+	// RawData is the model's JSON verbatim, including fields outside this
+	// schema. It is only set by WithRawJSON, in which case Text and
+	// StructuredData are left empty.
+	RawData map[string]any `json:"raw_data,omitempty"`
+
+	// Candidates lists every extraction generated with WithCandidates,
+	// most confident first. The result itself is the first of them, with
+	// usage covering every candidate.
+	Candidates []OCRResult `json:"candidates,omitempty"`
+
+	// retainedImage and retainedContentType hold the exact bytes sent to the
+	// model when WithRetainImage is enabled. They are never serialized.
+	retainedImage       []byte
+	retainedContentType string
 }
 
 // Source describes how the image was provided.
@@ -18,22 +58,95 @@ type Source struct {
 	Type     SourceType `json:"type"`
 	Path     string     `json:"path"`
 	Checksum string     `json:"checksum"`
+
+	// Metadata is the caller's own data from WithSourceMetadata, passed
+	// through unchanged.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // SourceType is an enum for source types.
 type SourceType string
 
 const (
-	SourceTypeFile SourceType = "file"
-	SourceTypeURL  SourceType = "url"
+	SourceTypeFile  SourceType = "file"
+	SourceTypeURL   SourceType = "url"
+	SourceTypeBytes SourceType = "bytes"
 )
 
 // ImageInfo holds metadata about the image itself.
 type ImageInfo struct {
-	Width     int        `json:"width"`
-	Height    int        `json:"height"`
-	DPI       *int       `json:"dpi"`
-	ColorMode ColorMode  `json:"color_mode"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	DPI       *int      `json:"dpi"`
+	ColorMode ColorMode `json:"color_mode"`
+
+	// Rotation is the clockwise rotation in degrees applied to the image
+	// before OCR. It is only set by WithAutoRotate.
+	Rotation int `json:"rotation,omitempty"`
+}
+
+// ImageTransform is one geometric step applied to the image before it was
+// sent to the model.
+type ImageTransform struct {
+	Type TransformType `json:"type"`
+
+	// Width and Height are the size of the image after the transform, in
+	// pixels.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// X and Y are the top-left corner of a crop in the image it was taken
+	// from.
+	X float64 `json:"x,omitempty"`
+	Y float64 `json:"y,omitempty"`
+
+	// ScaleX and ScaleY are the output size of a scale divided by its input
+	// size.
+	ScaleX float64 `json:"scale_x,omitempty"`
+	ScaleY float64 `json:"scale_y,omitempty"`
+
+	// Degrees is the clockwise angle of a rotation: 90, 180 or 270.
+	Degrees int `json:"degrees,omitempty"`
+}
+
+// TransformType is an enum for geometric image transforms.
+type TransformType string
+
+const (
+	TransformCrop   TransformType = "crop"
+	TransformScale  TransformType = "scale"
+	TransformRotate TransformType = "rotate"
+)
+
+// ReportedImage is the image info the model reported where it differs from
+// the decoded image. The decoded info in OCRResult.Image is authoritative,
+// since models guess image sizes; the model's values only fill in what could
+// not be decoded, such as the size of PDF pages.
+type ReportedImage struct {
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	DPI       *int      `json:"dpi,omitempty"`
+	ColorMode ColorMode `json:"color_mode,omitempty"`
+
+	// Mismatch is set when the reported width or height differs from the
+	// decoded image.
+	Mismatch bool `json:"mismatch"`
+}
+
+// PDFInfo is the document information dictionary of a PDF: metadata set by
+// the author or the producing software, not read from the pages. Empty
+// entries are omitted.
+type PDFInfo struct {
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Keywords string `json:"keywords,omitempty"`
+	Creator  string `json:"creator,omitempty"`
+	Producer string `json:"producer,omitempty"`
+
+	// CreationDate and ModDate are nil when missing or unparseable.
+	CreationDate *time.Time `json:"creation_date,omitempty"`
+	ModDate      *time.Time `json:"mod_date,omitempty"`
 }
 
 // ColorMode is an enum for color modes.
@@ -51,9 +164,15 @@ type Metadata struct {
 	Language        *string      `json:"language"`
 	DocumentType    DocumentType `json:"document_type"`
 	ConfidenceScore float64      `json:"confidence_score"`
+	Blank           bool         `json:"blank"`
+
+	// Direction is the reading direction of the text. It is only set by
+	// WithDetectTextDirection.
+	Direction TextDirection `json:"direction,omitempty"`
 }
 
-// DocumentType is an enum for document types.
+// DocumentType is an enum for document types. With
+// WithAllowFreeformDocumentType it may also hold any other non-empty name.
 type DocumentType string
 
 const (
@@ -66,8 +185,22 @@ const (
 
 // TextResult holds the OCR text output.
 type TextResult struct {
-	Raw   string     `json:"raw"`
-	Lines []TextLine `json:"lines"`
+	Raw              string           `json:"raw"`
+	Lines            []TextLine       `json:"lines"`
+	BoundingBoxUnits BoundingBoxUnits `json:"bounding_box_units,omitempty"`
+
+	// Truncated is set when Lines was cut to the WithMaxLines limit. Raw
+	// always holds the complete text.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// DroppedEmptyLines is the number of empty or whitespace-only lines
+	// left out of Lines. Empty lines are kept with WithKeepEmptyLines.
+	DroppedEmptyLines int `json:"dropped_empty_lines,omitempty"`
+
+	// Watermarks lists the watermark and background texts, such as
+	// "DRAFT", left out of Raw and Lines. It is only set by
+	// WithFilterWatermarks.
+	Watermarks []string `json:"watermarks,omitempty"`
 }
 
 // TextLine is a single line detected during OCR.
@@ -75,6 +208,18 @@ type TextLine struct {
 	Text        string       `json:"text"`
 	BoundingBox *BoundingBox `json:"bounding_box"`
 	Confidence  float64      `json:"confidence"`
+
+	// Region is the index of the crop region the line was found in. It is
+	// only set when WithCropRegions is used.
+	Region *int `json:"region,omitempty"`
+
+	// LineNumber is the 1-based position of the line in the model's output
+	// for the whole document, before lines are merged or dropped.
+	LineNumber int `json:"line_number"`
+
+	// PageNumber is the 1-based PDF page the line was found on. It is not
+	// set for images.
+	PageNumber int `json:"page_number,omitempty"`
 }
 
 // BoundingBox is a rectangular region in the image.
@@ -85,16 +230,117 @@ type BoundingBox struct {
 	Height float64 `json:"height"`
 }
 
+// BoundingBoxUnits is an enum for bounding box coordinate systems.
+type BoundingBoxUnits string
+
+const (
+	BoundingBoxUnitsPixels     BoundingBoxUnits = "pixels"
+	BoundingBoxUnitsNormalized BoundingBoxUnits = "normalized"
+)
+
 // StructuredData holds tables and key-value pairs extracted from the document.
 type StructuredData struct {
 	KeyValuePairs map[string]string `json:"key_value_pairs"`
 	Tables        []Table           `json:"tables"`
+
+	// KeyValueDetails repeats KeyValuePairs with a confidence per field. It
+	// is only set when WithKeyValueConfidence is used.
+	KeyValueDetails map[string]KeyValueDetail `json:"key_value_details,omitempty"`
+
+	// Extracted reports whether the model returned structured data at all,
+	// telling "none found" (true, both empty) apart from "not attempted".
+	Extracted bool `json:"extracted"`
+
+	// KeyValueBoxes holds, per key in KeyValuePairs, the region of the value
+	// in the image, in the units of the text line boxes. It is only set with
+	// WithBoundingBoxes and only for keys the model located.
+	KeyValueBoxes map[string]*BoundingBox `json:"key_value_boxes,omitempty"`
+
+	// KeyValueSources tells, per key in KeyValuePairs, whether the value
+	// came from a PDF form field or from OCR. It is only set when
+	// WithExtractFormFields is used.
+	KeyValueSources map[string]KeyValueSource `json:"key_value_sources,omitempty"`
+
+	// OrderedKeyValues repeats KeyValuePairs in the order the model returned
+	// the keys. Keys the model did not return, such as PDF form fields,
+	// follow in sorted order.
+	OrderedKeyValues []KeyValue `json:"ordered_key_values,omitempty"`
+}
+
+// KeyValue is one key-value pair.
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// KeyValueSource is where a key-value pair came from.
+type KeyValueSource string
+
+const (
+	KeyValueSourceOCR  KeyValueSource = "ocr"
+	KeyValueSourceForm KeyValueSource = "form"
+)
+
+// KeyValueDetail is a key-value pair value with the model's confidence in
+// it. A confidence of 0 means the model did not report one.
+type KeyValueDetail struct {
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Timings breaks down where the time of an extraction went. For PDFs each
+// stage is summed across pages.
+type Timings struct {
+	DownloadMs   int64 `json:"download_ms"`
+	PreprocessMs int64 `json:"preprocess_ms"`
+	ModelMs      int64 `json:"model_ms"`
+	ParseMs      int64 `json:"parse_ms"`
+	TotalMs      int64 `json:"total_ms"`
+}
+
+// QualityReport summarizes line-level confidence for quality dashboards.
+// Confidence statistics cover non-empty lines only.
+type QualityReport struct {
+	LineCount              int     `json:"line_count"`
+	EmptyLineCount         int     `json:"empty_line_count"`
+	MeanConfidence         float64 `json:"mean_confidence"`
+	MedianConfidence       float64 `json:"median_confidence"`
+	MinConfidence          float64 `json:"min_confidence"`
+	LowConfidenceThreshold float64 `json:"low_confidence_threshold"`
+	LowConfidenceFraction  float64 `json:"low_confidence_fraction"`
 }
 
 // Table is a single table detected in the document.
 type Table struct {
 	Headers []string   `json:"headers"`
 	Rows    [][]string `json:"rows"`
+
+	// BoundingBox is the region of the table in the image, in the units of
+	// the text line boxes. It is only set with WithBoundingBoxes.
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+
+	// Confidence is the model's confidence in [0, 1] that this is a real
+	// table. 0 means the model did not report one. It is only requested
+	// with WithMinTableConfidence.
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// Spans, parallel to Rows, gives the extent of every cell when a cell
+	// spans several columns or rows. It is only set with WithTableSpans,
+	// and only for tables with such a cell.
+	Spans [][]CellSpan `json:"spans,omitempty"`
+}
+
+// Usage describes the model resources consumed to produce a result.
+type Usage struct {
+	Model        string `json:"model"`
+	PromptTokens int    `json:"prompt_tokens"`
+	EvalTokens   int    `json:"eval_tokens"`
+	LatencyMs    int64  `json:"latency_ms"`
+
+	// BudgetExceeded is set when a PDF used more tokens than the
+	// WithMaxTotalTokens budget. Pages after the one that crossed it were
+	// not processed.
+	BudgetExceeded bool `json:"budget_exceeded,omitempty"`
 }
 
 // OllamaVisionResponse is the intermediate struct for parsing the Ollama model's JSON response.
@@ -105,13 +351,16 @@ type OllamaVisionResponse struct {
 	StructuredData *OllamaStructuredData `json:"structured_data,omitempty"`
 	Summary        *string               `json:"summary,omitempty"`
 	Image          *OllamaImageInfo      `json:"image,omitempty"`
+	Documents      OllamaDocuments       `json:"documents,omitempty"`
+	Watermarks     Watermarks            `json:"watermarks,omitempty"`
 }
 
 // OllamaMetadata is the forgiving metadata from Ollama.
 type OllamaMetadata struct {
-	Language        *string `json:"language,omitempty"`
-	DocumentType    string  `json:"document_type,omitempty"`
-	ConfidenceScore float64 `json:"confidence_score,omitempty"`
+	Language        *string       `json:"language,omitempty"`
+	DocumentType    string        `json:"document_type,omitempty"`
+	ConfidenceScore Confidence    `json:"confidence_score,omitempty"`
+	Direction       TextDirection `json:"direction,omitempty"`
 }
 
 // OllamaTextResult is the forgiving text result from Ollama.
@@ -124,13 +373,23 @@ type OllamaTextResult struct {
 type OllamaTextLine struct {
 	Text        string       `json:"text,omitempty"`
 	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
-	Confidence  float64      `json:"confidence,omitempty"`
+	Confidence  Confidence   `json:"confidence,omitempty"`
+
+	// Region and PageNumber are set by the pipeline, never by the model.
+	Region     *int `json:"-"`
+	PageNumber int  `json:"-"`
 }
 
 // OllamaStructuredData is the forgiving structured data from Ollama.
 type OllamaStructuredData struct {
-	KeyValuePairs map[string]string `json:"key_value_pairs,omitempty"`
-	Tables        []Table           `json:"tables,omitempty"`
+	KeyValuePairs      map[string]string       `json:"key_value_pairs,omitempty"`
+	KeyValueConfidence map[string]Confidence   `json:"key_value_confidence,omitempty"`
+	KeyValueBoxes      map[string]*BoundingBox `json:"key_value_boxes,omitempty"`
+	Tables             []Table                 `json:"tables,omitempty"`
+
+	// KeyOrder lists the keys of KeyValuePairs in the order the model
+	// returned them.
+	KeyOrder []string `json:"-"`
 }
 
 // OllamaImageInfo is the forgiving image info from Ollama.
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// SetRetainedImage attaches the processed image bytes and their content type
+// to the result. It is used by the ocr package when WithRetainImage is enabled.
+func (r *OCRResult) SetRetainedImage(data []byte, contentType string) {
+	r.retainedImage = data
+	r.retainedContentType = contentType
+}
+
+// RetainedImage returns the image bytes that were processed for this result
+// and their content type. Both are empty unless WithRetainImage was enabled.
+func (r *OCRResult) RetainedImage() ([]byte, string) {
+	return r.retainedImage, r.retainedContentType
+}
+
+// SummaryText returns the summary, or "" if there is none.
+func (r *OCRResult) SummaryText() string {
+	if r.Summary == nil {
+		return ""
+	}
+	return *r.Summary
+}
+
+// LanguageCode returns the detected language, or "" if none was detected.
+func (r *OCRResult) LanguageCode() string {
+	if r.Metadata.Language == nil {
+		return ""
+	}
+	return *r.Metadata.Language
+}
+
+// CleanText returns the text of every line whose confidence is at least
+// minConf, joined by newlines. It gives a denoised version of the document
+// for indexing. Empty lines are skipped.
+//
+// Results extracted without confidence scores report 0 for every line; in
+// that case no line can be judged and all lines are included.
+func (r *OCRResult) CleanText(minConf float64) string {
+	scored := false
+	for _, line := range r.Text.Lines {
+		if line.Confidence != 0 {
+			scored = true
+			break
+		}
+	}
+
+	kept := make([]string, 0, len(r.Text.Lines))
+	for _, line := range r.Text.Lines {
+		if strings.TrimSpace(line.Text) == "" {
+			continue
+		}
+		if scored && line.Confidence < minConf {
+			continue
+		}
+		kept = append(kept, line.Text)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// WriteJSON writes r to w as JSON followed by a newline, indented by two
+// spaces if indent is set. Fields are written in schema order and map keys
+// sorted, so equal results always encode identically. Unset optional values
+// are written as null, nil collections as [] or {}, and characters like <, >
+// and & are not escaped. r is not modified.
+func (r *OCRResult) WriteJSON(w io.Writer, indent bool) error {
+	out := *r
+	if out.Text.Lines == nil {
+		out.Text.Lines = []TextLine{}
+	}
+	if out.StructuredData.KeyValuePairs == nil {
+		out.StructuredData.KeyValuePairs = map[string]string{}
+	}
+	if out.StructuredData.Tables == nil {
+		out.StructuredData.Tables = []Table{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(&out)
+}
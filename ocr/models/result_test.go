@@ -0,0 +1,162 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestOCRResult_RetainedImage(t *testing.T) {
+	r := &OCRResult{}
+
+	data, contentType := r.RetainedImage()
+	if data != nil || contentType != "" {
+		t.Errorf("RetainedImage() on empty result = (%v, %q), want (nil, \"\")", data, contentType)
+	}
+
+	r.SetRetainedImage([]byte("png bytes"), "image/png")
+	data, contentType = r.RetainedImage()
+	if string(data) != "png bytes" {
+		t.Errorf("data = %q, want %q", data, "png bytes")
+	}
+	if contentType != "image/png" {
+		t.Errorf("contentType = %q, want %q", contentType, "image/png")
+	}
+}
+
+func TestOCRResult_SummaryText(t *testing.T) {
+	r := &OCRResult{}
+	if got := r.SummaryText(); got != "" {
+		t.Errorf("SummaryText() without summary = %q, want \"\"", got)
+	}
+
+	summary := "A receipt from ACME Store."
+	r.Summary = &summary
+	if got := r.SummaryText(); got != summary {
+		t.Errorf("SummaryText() = %q, want %q", got, summary)
+	}
+}
+
+func TestOCRResult_LanguageCode(t *testing.T) {
+	r := &OCRResult{}
+	if got := r.LanguageCode(); got != "" {
+		t.Errorf("LanguageCode() without language = %q, want \"\"", got)
+	}
+
+	lang := "de"
+	r.Metadata.Language = &lang
+	if got := r.LanguageCode(); got != "de" {
+		t.Errorf("LanguageCode() = %q, want %q", got, "de")
+	}
+}
+
+func TestOCRResult_RetainedImageNotSerialized(t *testing.T) {
+	r := &OCRResult{}
+	r.SetRetainedImage([]byte("secret-image-bytes"), "image/png")
+
+	out, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(out), "secret-image-bytes") || strings.Contains(string(out), "image/png") {
+		t.Errorf("retained image leaked into JSON: %s", out)
+	}
+}
+
+func TestOCRResult_CleanText(t *testing.T) {
+	r := &OCRResult{Text: TextResult{Lines: []TextLine{
+		{Text: "INVOICE", Confidence: 0.95},
+		{Text: "~~%#", Confidence: 0.2},
+		{Text: "", Confidence: 0.9},
+		{Text: "Total: $12.50", Confidence: 0.7},
+	}}}
+
+	tests := []struct {
+		minConf float64
+		want    string
+	}{
+		{0, "INVOICE\n~~%#\nTotal: $12.50"},
+		{0.5, "INVOICE\nTotal: $12.50"},
+		{0.7, "INVOICE\nTotal: $12.50"},
+		{0.99, ""},
+	}
+	for _, tt := range tests {
+		if got := r.CleanText(tt.minConf); got != tt.want {
+			t.Errorf("CleanText(%v) = %q, want %q", tt.minConf, got, tt.want)
+		}
+	}
+}
+
+func TestOCRResult_CleanTextWithoutConfidence(t *testing.T) {
+	r := &OCRResult{Text: TextResult{Lines: []TextLine{
+		{Text: "INVOICE"},
+		{Text: "Total: $12.50"},
+	}}}
+
+	if got, want := r.CleanText(0.8), "INVOICE\nTotal: $12.50"; got != want {
+		t.Errorf("CleanText = %q, want %q (all lines kept without scores)", got, want)
+	}
+}
+
+func TestOCRResult_WriteJSON(t *testing.T) {
+	lang := "en"
+	r := &OCRResult{
+		SchemaVersion: "1.0.0",
+		Source:        Source{Type: SourceTypeFile, Path: "receipts/acme.png", Checksum: "abc123"},
+		Image:         ImageInfo{Width: 600, Height: 400, ColorMode: ColorModeRGB},
+		Metadata:      Metadata{Language: &lang, DocumentType: DocumentTypeReceipt, ConfidenceScore: 0.9},
+		Text: TextResult{
+			Raw: "Fish & Chips <large>\nTOTAL 9.99",
+			Lines: []TextLine{
+				{Text: "Fish & Chips <large>", BoundingBox: &BoundingBox{X: 10, Y: 20, Width: 300, Height: 18}, Confidence: 0.88, LineNumber: 1},
+				{Text: "TOTAL 9.99", Confidence: 0.9, LineNumber: 2},
+			},
+		},
+		StructuredData: StructuredData{
+			KeyValuePairs: map[string]string{"total": "9.99", "date": "2024-01-02", "currency": "USD"},
+			Extracted:     true,
+		},
+		Usage: Usage{Model: "llama3.2-vision", PromptTokens: 100, EvalTokens: 50, LatencyMs: 1200},
+	}
+	r.SetRetainedImage([]byte("png bytes"), "image/png")
+
+	var indented bytes.Buffer
+	if err := r.WriteJSON(&indented, true); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	path := filepath.Join("testdata", "result.json")
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Errorf("output does not match %s:\n%s", path, indented.String())
+	}
+
+	var compact, wantCompact bytes.Buffer
+	if err := r.WriteJSON(&compact, false); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	if err := json.Compact(&wantCompact, want); err != nil {
+		t.Fatal(err)
+	}
+	wantCompact.WriteByte('\n')
+	if compact.String() != wantCompact.String() {
+		t.Errorf("compact output = %s, want %s", compact.String(), wantCompact.String())
+	}
+
+	if r.StructuredData.Tables != nil {
+		t.Error("WriteJSON modified the result")
+	}
+}
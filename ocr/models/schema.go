@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the OCRResult JSON schema. Bump the minor
+// version when fields are added and the major version on breaking changes.
+//
+//	1.0.0  initial schema (no schema_version field)
+//	1.1.0  adds schema_version, usage, warnings and metadata.blank
+//	1.2.0  adds text.bounding_box_units
+//	1.3.0  adds quality
+//	1.4.0  adds source.type "bytes" for in-memory sources
+//	1.5.0  adds timings
+//	1.6.0  adds text.lines[].region
+//	1.7.0  adds structured_data.key_value_details
+//	1.8.0  adds image.rotation
+//	1.9.0  adds raw_data
+//	1.10.0 adds metadata.direction
+//	1.11.0 adds text.truncated
+//	1.12.0 adds structured_data.extracted
+//	1.13.0 adds text.lines[].line_number and text.lines[].page_number
+//	1.14.0 adds structured_data.key_value_sources
+//	1.15.0 adds structured_data.key_value_boxes and tables[].bounding_box
+//	1.16.0 adds text.dropped_empty_lines
+//	1.17.0 adds reported_image
+//	1.18.0 adds documents and document_region
+//	1.19.0 adds usage.budget_exceeded
+//	1.20.0 adds structured_data.tables[].confidence
+//	1.21.0 adds transforms
+//	1.22.0 adds pdf_info
+//	1.23.0 adds structured_data.ordered_key_values
+//	1.24.0 adds source.metadata
+//	1.25.0 adds text.watermarks
+//	1.26.0 adds candidates
+//	1.27.0 adds structured_data.tables[].spans
+const SchemaVersion = "1.27.0"
+
+// legacySchemaVersion is assumed for documents without a schema_version.
+const legacySchemaVersion = "1.0.0"
+
+// UnmarshalOCRResult decodes a serialized OCRResult written by this or an
+// older version of the package. Documents that predate versioning are
+// reported as version 1.0.0, and fields missing from older versions are
+// filled with their empty values. Documents from a newer major version are
+// rejected.
+func UnmarshalOCRResult(data []byte) (*OCRResult, error) {
+	var r OCRResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("unmarshal OCR result: %w", err)
+	}
+
+	if r.SchemaVersion == "" {
+		r.SchemaVersion = legacySchemaVersion
+	}
+
+	major, err := schemaMajor(r.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	current, _ := schemaMajor(SchemaVersion)
+	if major > current {
+		return nil, fmt.Errorf("unsupported schema version %q (this package supports up to %s)", r.SchemaVersion, SchemaVersion)
+	}
+
+	if r.StructuredData.KeyValuePairs == nil {
+		r.StructuredData.KeyValuePairs = make(map[string]string)
+	}
+	if r.StructuredData.Tables == nil {
+		r.StructuredData.Tables = []Table{}
+	}
+	if r.Text.Lines == nil {
+		r.Text.Lines = []TextLine{}
+	}
+
+	return &r, nil
+}
+
+// schemaMajor returns the major component of a "major.minor.patch" version.
+func schemaMajor(version string) (int, error) {
+	majorStr, _, _ := strings.Cut(version, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q", version)
+	}
+	return major, nil
+}
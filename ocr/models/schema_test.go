@@ -0,0 +1,71 @@
+package models
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersion_Documented(t *testing.T) {
+	readme, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatalf("read README: %v", err)
+	}
+
+	want := `"schema_version": "` + SchemaVersion + `"`
+	if !strings.Contains(string(readme), want) {
+		t.Errorf("README output schema does not document %s", want)
+	}
+}
+
+func TestUnmarshalOCRResult_Legacy(t *testing.T) {
+	// A 1.0.0 document: no schema_version, usage, warnings or metadata.blank
+	legacy := `{
+		"source": {"type": "file", "path": "/tmp/a.png", "checksum": "abc"},
+		"image": {"width": 10, "height": 20, "dpi": null, "color_mode": "RGB"},
+		"metadata": {"language": "en", "document_type": "receipt", "confidence_score": 0.9},
+		"text": {"raw": "hello", "lines": [{"text": "hello", "bounding_box": null, "confidence": 0.8}]},
+		"structured_data": {"key_value_pairs": {}, "tables": []},
+		"summary": null
+	}`
+
+	r, err := UnmarshalOCRResult([]byte(legacy))
+	if err != nil {
+		t.Fatalf("UnmarshalOCRResult: %v", err)
+	}
+
+	if r.SchemaVersion != "1.0.0" {
+		t.Errorf("SchemaVersion = %q, want %q", r.SchemaVersion, "1.0.0")
+	}
+	if r.Metadata.DocumentType != DocumentTypeReceipt {
+		t.Errorf("DocumentType = %q, want %q", r.Metadata.DocumentType, DocumentTypeReceipt)
+	}
+	if r.Text.Raw != "hello" || len(r.Text.Lines) != 1 {
+		t.Errorf("Text = %+v, want one line %q", r.Text, "hello")
+	}
+	if r.Usage.Model != "" || r.Metadata.Blank {
+		t.Error("fields added after 1.0.0 should have their zero values")
+	}
+}
+
+func TestUnmarshalOCRResult_MissingCollections(t *testing.T) {
+	r, err := UnmarshalOCRResult([]byte(`{"schema_version": "1.1.0", "source": {"type": "url"}}`))
+	if err != nil {
+		t.Fatalf("UnmarshalOCRResult: %v", err)
+	}
+	if r.StructuredData.KeyValuePairs == nil || r.StructuredData.Tables == nil || r.Text.Lines == nil {
+		t.Error("missing collections should be filled with empty values")
+	}
+}
+
+func TestUnmarshalOCRResult_NewerMajorRejected(t *testing.T) {
+	if _, err := UnmarshalOCRResult([]byte(`{"schema_version": "99.0.0"}`)); err == nil {
+		t.Fatal("expected error for newer major schema version")
+	}
+}
+
+func TestUnmarshalOCRResult_InvalidVersion(t *testing.T) {
+	if _, err := UnmarshalOCRResult([]byte(`{"schema_version": "latest"}`)); err == nil {
+		t.Fatal("expected error for malformed schema version")
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FilterTables returns the tables whose confidence is at least
+// minConfidence. Tables without a confidence (0) are kept, as if the model
+// were fully confident in them. tables itself is not modified.
+func FilterTables(tables []Table, minConfidence float64) []Table {
+	if tables == nil {
+		return nil
+	}
+	kept := make([]Table, 0, len(tables))
+	for _, t := range tables {
+		if t.Confidence == 0 || t.Confidence >= minConfidence {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// CellSpan is the extent of one cell of Table.Rows in columns and rows. A
+// cell that spans nothing is 1 by 1. The cells covered by a spanning cell,
+// to its right and below it, are 0 by 0.
+type CellSpan struct {
+	ColSpan int `json:"colspan"`
+	RowSpan int `json:"rowspan"`
+}
+
+// spanningCell is a cell the model reported as spanning more than itself.
+type spanningCell struct {
+	row, col int
+	span     CellSpan
+}
+
+// parseCellSpans reads the spans the model reported for rows, either as a
+// list of spanning cells ({"row", "column", "colspan", "rowspan"}) or as a
+// grid of {"colspan", "rowspan"} parallel to rows. Numbers may be strings
+// and a missing colspan or rowspan is 1. Spans outside the table are
+// clipped to it, and spans overlapping an earlier one are dropped. It
+// returns the grid of spans, shaped like rows, or nil if no cell spans
+// more than itself.
+func parseCellSpans(data json.RawMessage, rows [][]string) [][]CellSpan {
+	var entries []json.RawMessage
+	if len(data) == 0 || json.Unmarshal(data, &entries) != nil {
+		return nil
+	}
+
+	var cells []spanningCell
+	for i, e := range entries {
+		// Grid form: one list of spans per row
+		var row []json.RawMessage
+		if json.Unmarshal(e, &row) == nil {
+			for j, r := range row {
+				if span, ok := parseCellSpan(r); ok {
+					cells = append(cells, spanningCell{row: i, col: j, span: span})
+				}
+			}
+			continue
+		}
+
+		var pos struct {
+			Row    json.RawMessage `json:"row"`
+			Column json.RawMessage `json:"column"`
+		}
+		if json.Unmarshal(e, &pos) != nil {
+			continue
+		}
+		r, okRow := spanInt(pos.Row)
+		c, okCol := spanInt(pos.Column)
+		span, ok := parseCellSpan(e)
+		if okRow && okCol && ok {
+			cells = append(cells, spanningCell{row: r, col: c, span: span})
+		}
+	}
+	return cellSpanGrid(rows, cells)
+}
+
+// parseCellSpan reads one {"colspan", "rowspan"} object.
+func parseCellSpan(data json.RawMessage) (CellSpan, bool) {
+	var raw struct {
+		ColSpan json.RawMessage `json:"colspan"`
+		RowSpan json.RawMessage `json:"rowspan"`
+	}
+	if json.Unmarshal(data, &raw) != nil {
+		return CellSpan{}, false
+	}
+	span := CellSpan{ColSpan: 1, RowSpan: 1}
+	if raw.ColSpan != nil {
+		n, ok := spanInt(raw.ColSpan)
+		if !ok {
+			return CellSpan{}, false
+		}
+		span.ColSpan = n
+	}
+	if raw.RowSpan != nil {
+		n, ok := spanInt(raw.RowSpan)
+		if !ok {
+			return CellSpan{}, false
+		}
+		span.RowSpan = n
+	}
+	return span, true
+}
+
+// spanInt reads a whole number given as a JSON number or string.
+func spanInt(data json.RawMessage) (int, bool) {
+	var v any
+	if json.Unmarshal(data, &v) != nil {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), true
+		}
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// cellSpanGrid returns the spans of every cell of rows, with cells marked
+// as spanning and covered as given, or nil if none spans more than itself.
+func cellSpanGrid(rows [][]string, cells []spanningCell) [][]CellSpan {
+	single := CellSpan{ColSpan: 1, RowSpan: 1}
+	grid := make([][]CellSpan, len(rows))
+	for i, row := range rows {
+		grid[i] = make([]CellSpan, len(row))
+		for j := range grid[i] {
+			grid[i][j] = single
+		}
+	}
+
+	// inside reports whether row i has a cell in column j.
+	inside := func(i, j int) bool {
+		return i >= 0 && i < len(grid) && j >= 0 && j < len(grid[i])
+	}
+
+	spanned := false
+	for _, c := range cells {
+		if !inside(c.row, c.col) || grid[c.row][c.col] != single {
+			continue
+		}
+		span := CellSpan{
+			ColSpan: min(c.span.ColSpan, len(grid[c.row])-c.col),
+			RowSpan: min(c.span.RowSpan, len(grid)-c.row),
+		}
+		if span.ColSpan < 1 || span.RowSpan < 1 || span == single {
+			continue
+		}
+
+		free := true
+		for i := c.row; i < c.row+span.RowSpan && free; i++ {
+			for j := c.col; j < c.col+span.ColSpan; j++ {
+				if inside(i, j) && grid[i][j] != single {
+					free = false
+					break
+				}
+			}
+		}
+		if !free {
+			continue
+		}
+
+		for i := c.row; i < c.row+span.RowSpan; i++ {
+			for j := c.col; j < c.col+span.ColSpan; j++ {
+				if inside(i, j) {
+					grid[i][j] = CellSpan{}
+				}
+			}
+		}
+		grid[c.row][c.col] = span
+		spanned = true
+	}
+
+	if !spanned {
+		return nil
+	}
+	return grid
+}
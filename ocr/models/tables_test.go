@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFilterTables(t *testing.T) {
+	tables := []Table{
+		{Headers: []string{"a"}, Confidence: 0.9},
+		{Headers: []string{"b"}, Confidence: 0.5},
+		{Headers: []string{"c"}, Confidence: 0.49},
+		{Headers: []string{"d"}},
+	}
+
+	got := FilterTables(tables, 0.5)
+	var headers []string
+	for _, table := range got {
+		headers = append(headers, table.Headers[0])
+	}
+	if want := []string{"a", "b", "d"}; !reflect.DeepEqual(headers, want) {
+		t.Errorf("kept tables %q, want %q", headers, want)
+	}
+	if len(tables) != 4 || tables[2].Headers[0] != "c" {
+		t.Error("input tables were modified")
+	}
+
+	if got := FilterTables(nil, 0.5); got != nil {
+		t.Errorf("FilterTables(nil) = %v, want nil", got)
+	}
+	if got := FilterTables([]Table{{Confidence: 0.1}}, 0.5); got == nil || len(got) != 0 {
+		t.Errorf("FilterTables = %v, want an empty, non-nil slice", got)
+	}
+}
+
+func TestTable_UnmarshalJSONSpans(t *testing.T) {
+	single := CellSpan{ColSpan: 1, RowSpan: 1}
+	rows := `"rows":[["a","b","c"],["d","e","f"]]`
+	tests := []struct {
+		name  string
+		spans string
+		want  [][]CellSpan
+	}{
+		{
+			"cell list",
+			`[{"row":0,"column":0,"colspan":2},{"row":0,"column":2,"rowspan":"2"}]`,
+			[][]CellSpan{{{ColSpan: 2, RowSpan: 1}, {}, {ColSpan: 1, RowSpan: 2}}, {single, single, {}}},
+		},
+		{
+			"grid",
+			`[[{"colspan":1,"rowspan":1},{"colspan":2,"rowspan":1},{"colspan":0,"rowspan":0}],[]]`,
+			[][]CellSpan{{single, {ColSpan: 2, RowSpan: 1}, {}}, {single, single, single}},
+		},
+		{
+			"clipped to the table",
+			`[{"row":1,"column":1,"colspan":5,"rowspan":5}]`,
+			[][]CellSpan{{single, single, single}, {single, {ColSpan: 2, RowSpan: 1}, {}}},
+		},
+		{
+			"overlap dropped",
+			`[{"row":0,"column":0,"rowspan":2},{"row":1,"column":0,"colspan":2}]`,
+			[][]CellSpan{{{ColSpan: 1, RowSpan: 2}, single, single}, {{}, single, single}},
+		},
+		{"outside the table", `[{"row":2,"column":0,"colspan":2},{"row":0,"column":-1,"colspan":2}]`, nil},
+		{"no spanning cell", `[{"row":0,"column":0,"colspan":1,"rowspan":1}]`, nil},
+		{"malformed", `[{"row":0},{"row":0,"column":0,"colspan":1.5},"x",null]`, nil},
+		{"not a list", `{"row":0,"column":0,"colspan":2}`, nil},
+		{"empty", `[]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var table Table
+			if err := json.Unmarshal([]byte(`{"headers":["x","y","z"],`+rows+`,"spans":`+tt.spans+`}`), &table); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(table.Spans, tt.want) {
+				t.Errorf("Spans = %v, want %v", table.Spans, tt.want)
+			}
+			if len(table.Rows) != 2 {
+				t.Errorf("Rows = %q, want them unaffected by the spans", table.Rows)
+			}
+		})
+	}
+}
+
+// Spans written by WriteJSON read back unchanged.
+func TestTable_SpansRoundTrip(t *testing.T) {
+	want := Table{
+		Headers: []string{"x", "y"},
+		Rows:    [][]string{{"a", ""}, {"b", "c"}},
+		Spans:   [][]CellSpan{{{ColSpan: 2, RowSpan: 1}, {}}, {{ColSpan: 1, RowSpan: 1}, {ColSpan: 1, RowSpan: 1}}},
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Table
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
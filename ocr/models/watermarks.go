@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Watermarks is the forgiving list of watermark and background texts from
+// Ollama, such as "DRAFT" or "CONFIDENTIAL".
+type Watermarks []string
+
+// UnmarshalJSON implements json.Unmarshaler. Entries may be strings or
+// objects with a "text" field, and a single string is read as a list of
+// one. Blank entries and repeats, compared case-insensitively, are dropped;
+// any other value yields no watermarks rather than failing the whole parse.
+func (w *Watermarks) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var entries []any
+	switch v := v.(type) {
+	case []any:
+		entries = v
+	case string:
+		entries = []any{v}
+	}
+
+	*w = nil
+	for _, e := range entries {
+		if obj, ok := e.(map[string]any); ok {
+			e = obj["text"]
+		}
+		s, ok := e.(string)
+		if !ok {
+			continue
+		}
+		if s = strings.TrimSpace(s); s != "" && !w.Contains(s) {
+			*w = append(*w, s)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether text is one of the watermarks, ignoring case and
+// differences in whitespace.
+func (w Watermarks) Contains(text string) bool {
+	text = normalizeWatermark(text)
+	for _, m := range w {
+		if normalizeWatermark(m) == text {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWatermark lowercases s and collapses its whitespace.
+func normalizeWatermark(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
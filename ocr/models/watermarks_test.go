@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWatermarks_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want Watermarks
+	}{
+		{"strings", `["DRAFT", "CONFIDENTIAL"]`, Watermarks{"DRAFT", "CONFIDENTIAL"}},
+		{"single string", `"DRAFT"`, Watermarks{"DRAFT"}},
+		{"objects", `[{"text":"COPY","opacity":0.2}]`, Watermarks{"COPY"}},
+		{"blanks and repeats dropped", `[" DRAFT ", "", "draft", 7, null, {"text":3}]`, Watermarks{"DRAFT"}},
+		{"not a list", `{"text":"DRAFT"}`, nil},
+		{"null", `null`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Watermarks
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatermarks_Contains(t *testing.T) {
+	w := Watermarks{"DRAFT", "For internal use only"}
+	for _, text := range []string{"DRAFT", "draft", "  for internal\tuse ONLY "} {
+		if !w.Contains(text) {
+			t.Errorf("Contains(%q) = false, want true", text)
+		}
+	}
+	for _, text := range []string{"DRAFT 2", "Draft agreement", ""} {
+		if w.Contains(text) {
+			t.Errorf("Contains(%q) = true, want false", text)
+		}
+	}
+}
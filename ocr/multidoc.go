@@ -0,0 +1,118 @@
+package ocr
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// ExtractMultiDoc runs OCR on an image that may hold several documents. See
+// Client.ExtractMultiDoc.
+func ExtractMultiDoc(ctx context.Context, source string, opts ...Option) ([]*models.OCRResult, error) {
+	return NewClient(opts...).ExtractMultiDoc(ctx, source)
+}
+
+// ExtractMultiDoc runs OCR on an image that may hold several documents, such
+// as receipts scanned side by side, and returns one result per document.
+//
+// The model is first asked for the documents in the whole image. If it finds
+// more than one inside the image, each document's region is cropped and
+// extracted on its own, and its result carries the region in DocumentRegion.
+// Otherwise, and for PDFs, the result for the whole image is returned alone.
+// Timeout applies to the download and each model pass separately, and
+// CropRegions is ignored.
+func (c *Client) ExtractMultiDoc(ctx context.Context, source string, opts ...Option) ([]*models.OCRResult, error) {
+	cfg := c.config(opts...)
+	cfg.DetectMultipleDocuments = true
+	cfg.CropRegions = nil
+
+	start := time.Now()
+	requestID := generateRequestID(cfg)
+	logger := newLogger(requestID, cfg)
+
+	logger.Info("multi-document extraction started",
+		slog.String("source", source),
+	)
+
+	loadCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	in, err := load(loadCtx, source, cfg, requestID, logger, start)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	whole, err := processWithTimeout(ctx, cfg, requestID, logger, in)
+	if err != nil {
+		return nil, err
+	}
+	if len(whole.Documents) < 2 || in.ext == ".pdf" {
+		return []*models.OCRResult{whole}, nil
+	}
+
+	regions := make([]models.BoundingBox, 0, len(whole.Documents))
+	for _, d := range whole.Documents {
+		region, ok := documentPixels(d.BoundingBox, whole.Text.BoundingBoxUnits, whole.Image)
+		if !ok {
+			whole.Warnings = append(whole.Warnings, "documents could not be split: image size unknown")
+			return []*models.OCRResult{whole}, nil
+		}
+		if region.Width > 0 && region.Height > 0 {
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) < 2 {
+		return []*models.OCRResult{whole}, nil
+	}
+
+	logger.Info("splitting documents",
+		slog.Int("documents", len(regions)),
+	)
+
+	results := make([]*models.OCRResult, 0, len(regions))
+	for i, region := range regions {
+		docCfg := cfg.Clone()
+		docCfg.DetectMultipleDocuments = false
+		docCfg.CropRegions = []models.BoundingBox{region}
+
+		result, err := processWithTimeout(ctx, docCfg, requestID, logger.With(slog.Int("document", i+1)), in)
+		if err != nil {
+			return nil, err
+		}
+		result.DocumentRegion = &region
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// processWithTimeout runs process with its own cfg.Timeout.
+func processWithTimeout(ctx context.Context, cfg *Config, requestID string, logger *slog.Logger, in input) (*models.OCRResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	return process(ctx, cfg, requestID, logger, in)
+}
+
+// documentPixels converts a document box to whole pixels of the image,
+// clipped to its bounds. It returns false if the box is normalized and the
+// image size is unknown.
+func documentPixels(b models.BoundingBox, units models.BoundingBoxUnits, image models.ImageInfo) (models.BoundingBox, bool) {
+	if units == models.BoundingBoxUnitsNormalized {
+		var ok bool
+		if b, ok = utils.ConvertBoundingBox(b, units, models.BoundingBoxUnitsPixels, image.Width, image.Height); !ok {
+			return b, false
+		}
+	}
+
+	x0, y0 := math.Floor(b.X), math.Floor(b.Y)
+	x1, y1 := math.Ceil(b.X+b.Width), math.Ceil(b.Y+b.Height)
+	if image.Width > 0 {
+		x1 = min(x1, float64(image.Width))
+	}
+	if image.Height > 0 {
+		y1 = min(y1, float64(image.Height))
+	}
+	return models.BoundingBox{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, true
+}
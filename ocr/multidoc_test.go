@@ -0,0 +1,159 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+)
+
+// multiDocServer answers document detection prompts with the two-document
+// fixture and every other prompt with the default response.
+func multiDocServer(t *testing.T) *ollamatest.Server {
+	t.Helper()
+	fixture, err := os.ReadFile(filepath.Join("testdata", "multidoc_response.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ollamatest.NewServer(t, func(req client.GenerateRequest) (int, string) {
+		if strings.Contains(req.Prompt, `"documents"`) {
+			return http.StatusOK, string(fixture)
+		}
+		return http.StatusOK, ollamatest.Response
+	})
+}
+
+func TestExtractBytes_DetectMultipleDocuments(t *testing.T) {
+	srv := multiDocServer(t)
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithDetectMultipleDocuments(true), WithBoundingBoxUnits(models.BoundingBoxUnitsNormalized))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	want := []models.DetectedDocument{
+		{BoundingBox: models.BoundingBox{Width: 0.5, Height: 1}, DocumentType: models.DocumentTypeReceipt},
+		{BoundingBox: models.BoundingBox{X: 0.5, Width: 0.625, Height: 1}, DocumentType: models.DocumentTypeInvoice},
+	}
+	if !reflect.DeepEqual(result.Documents, want) {
+		t.Errorf("Documents = %+v, want %+v", result.Documents, want)
+	}
+	if !slices.ContainsFunc(result.Warnings, func(w string) bool {
+		return strings.HasPrefix(w, "2 documents detected")
+	}) {
+		t.Errorf("Warnings = %q, want a hint to use ExtractMultiDoc", result.Warnings)
+	}
+
+	// Without the option the documents are neither requested nor reported
+	result, err = ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Documents != nil || strings.Contains(srv.Requests()[1].Prompt, `"documents"`) {
+		t.Error("documents should not be detected by default")
+	}
+}
+
+func TestExtractMultiDoc(t *testing.T) {
+	srv := multiDocServer(t)
+	path := filepath.Join(t.TempDir(), "scan.png")
+	if err := os.WriteFile(path, testPNG(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ExtractMultiDoc(context.Background(), path, WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("ExtractMultiDoc: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	// The second box reaches past the 32px wide image and is clipped
+	wantRegions := []models.BoundingBox{{Width: 16, Height: 32}, {X: 16, Width: 16, Height: 32}}
+	for i, r := range results {
+		if r.DocumentRegion == nil || *r.DocumentRegion != wantRegions[i] {
+			t.Errorf("results[%d].DocumentRegion = %+v, want %+v", i, r.DocumentRegion, wantRegions[i])
+		}
+		if r.Source.Path != path || r.Documents != nil {
+			t.Errorf("results[%d] = source %q, documents %+v", i, r.Source.Path, r.Documents)
+		}
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("generate requests = %d, want 3", len(reqs))
+	}
+	for i, req := range reqs[1:] {
+		if strings.Contains(req.Prompt, `"documents"`) {
+			t.Errorf("request %d for a single document asked for documents", i+1)
+		}
+		data, err := base64.StdEncoding.DecodeString(req.Images[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Width != 16 || cfg.Height != 32 {
+			t.Errorf("request %d image = %dx%d, want the 16x32 document", i+1, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestExtractMultiDoc_SingleDocument(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	path := filepath.Join(t.TempDir(), "scan.png")
+	if err := os.WriteFile(path, testPNG(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := NewClient(WithOllamaURL(srv.URL)).ExtractMultiDoc(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ExtractMultiDoc: %v", err)
+	}
+	if len(results) != 1 || results[0].DocumentRegion != nil {
+		t.Fatalf("results = %+v, want the whole image alone", results)
+	}
+	if got := len(srv.Requests()); got != 1 {
+		t.Errorf("generate requests = %d, want 1", got)
+	}
+}
+
+func TestExtractMultiDoc_DownloadTimeout(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	start := time.Now()
+	_, err := ExtractMultiDoc(context.Background(), "http://bucket.example.com/receipts.png",
+		WithProxy(proxyURL), WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, ErrContextCanceled) {
+		t.Fatalf("err = %v, want ErrContextCanceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ExtractMultiDoc took %v, want the download cut off by the timeout", elapsed)
+	}
+}
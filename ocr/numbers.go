@@ -0,0 +1,50 @@
+package ocr
+
+import (
+	"fmt"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// NormalizeAmount converts a number or amount as printed on a document, such
+// as a key-value pair value like "1.234,56 €" or "$1,234.56", to a plain
+// decimal string like "1234.56" that strconv.ParseFloat or a decimal library
+// can read. Currency symbols and codes are dropped and parentheses or a
+// trailing minus mean a negative amount.
+//
+// The decimal separator comes from WithNumberLocale. Without it, it is
+// guessed from the value: with both "." and "," present the last one is the
+// decimal separator, and a single separator followed by exactly three digits
+// groups thousands unless the integer part is "0". A sign is only accepted
+// before or after the number. The error wraps ErrNotANumber.
+func NormalizeAmount(s string, opts ...Option) (string, error) {
+	return NewClient(opts...).NormalizeAmount(s)
+}
+
+// NormalizeAmount is like the package-level NormalizeAmount but uses the
+// client's number locale unless opts override it.
+func (c *Client) NormalizeAmount(s string, opts ...Option) (string, error) {
+	cfg := c.config(opts...)
+	amount, err := models.NormalizeAmount(s, cfg.NumberLocale)
+	if err != nil {
+		return "", NewOCRError("NormalizeAmount", "", fmt.Errorf("%w: %v", ErrNotANumber, err))
+	}
+	return amount, nil
+}
+
+// LineItems returns the line items of sd like StructuredData.LineItems, but
+// parses amounts with the decimal separator of WithNumberLocale.
+func LineItems(sd models.StructuredData, opts ...Option) ([]models.LineItem, error) {
+	return NewClient(opts...).LineItems(sd)
+}
+
+// LineItems is like the package-level LineItems but uses the client's number
+// locale unless opts override it.
+func (c *Client) LineItems(sd models.StructuredData, opts ...Option) ([]models.LineItem, error) {
+	cfg := c.config(opts...)
+	items, err := sd.LineItemsLocale(cfg.NumberLocale)
+	if err != nil {
+		return nil, NewOCRError("LineItems", "", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,81 @@
+package ocr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestNormalizeAmount(t *testing.T) {
+	tests := []struct {
+		in     string
+		locale string
+		want   string
+	}{
+		{"1.234,56 €", "de-DE", "1234.56"},
+		{"$1,234.56", "en-US", "1234.56"},
+		{"1.234", "de-DE", "1234"},
+		{"1.234", "en-US", "1.234"},
+		{"1 234,56", "fr-FR", "1234.56"},
+		{"CHF 1'234.50", "de-CH", "1234.50"},
+		{"1.234,56", "", "1234.56"},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeAmount(tt.in, WithNumberLocale(tt.locale))
+		if err != nil {
+			t.Errorf("NormalizeAmount(%q, %q): %v", tt.in, tt.locale, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeAmount(%q, %q) = %q, want %q", tt.in, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestClient_NormalizeAmount(t *testing.T) {
+	c := NewClient(WithNumberLocale("de-DE"))
+
+	if got, _ := c.NormalizeAmount("1.234"); got != "1234" {
+		t.Errorf("client locale: got %q, want %q", got, "1234")
+	}
+	if got, _ := c.NormalizeAmount("1.234", WithNumberLocale("en-US")); got != "1.234" {
+		t.Errorf("per-call locale: got %q, want %q", got, "1.234")
+	}
+	if _, err := c.NormalizeAmount("n/a"); !errors.Is(err, ErrNotANumber) {
+		t.Errorf("err = %v, want ErrNotANumber", err)
+	}
+}
+
+func TestLineItems_NumberLocale(t *testing.T) {
+	sd := models.StructuredData{Tables: []models.Table{{
+		Headers: []string{"Item", "Qty", "Unit Price"},
+		Rows:    [][]string{{"Screw", "1000", "0.125"}},
+	}}}
+
+	tests := []struct {
+		locale    string
+		wantPrice float64
+		wantTotal float64
+	}{
+		{"", 0.125, 125},
+		{"en-US", 0.125, 125},
+		{"de-DE", 125, 125000},
+	}
+	for _, tt := range tests {
+		items, err := LineItems(sd, WithNumberLocale(tt.locale))
+		if err != nil {
+			t.Fatalf("LineItems(%q): %v", tt.locale, err)
+		}
+		if items[0].UnitPrice != tt.wantPrice || items[0].Total != tt.wantTotal {
+			t.Errorf("LineItems(%q) = %+v, want unit price %v and total %v",
+				tt.locale, items[0], tt.wantPrice, tt.wantTotal)
+		}
+	}
+
+	c := NewClient(WithNumberLocale("de-DE"))
+	if _, err := c.LineItems(models.StructuredData{}); !errors.Is(err, models.ErrNoLineItems) {
+		t.Errorf("err = %v, want ErrNoLineItems", err)
+	}
+}
@@ -1,6 +1,6 @@
 // Package ocr provides the public API for performing OCR using locally running
-// Ollama vision models. Users interact with this package through a single
-// function: Extract.
+// Ollama vision models. Users interact with this package through Extract, or
+// through a reusable Client when the same base options apply to many calls.
 //
 // Example usage:
 //
@@ -11,10 +11,21 @@
 package ocr
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"image"
+	"io"
+	"io/fs"
 	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
@@ -32,162 +43,1096 @@ import (
 //	result, err := ocr.Extract(ctx, "/path/to/image.png")
 //	result, err := ocr.Extract(ctx, "https://example.com/doc.jpg", ocr.WithSummary(true))
 func Extract(ctx context.Context, source string, opts ...Option) (*models.OCRResult, error) {
-	// Generate request ID
-	requestID := generateRequestID()
+	return NewClient(opts...).Extract(ctx, source)
+}
 
-	// Build config
-	cfg := DefaultConfig()
-	for _, opt := range opts {
-		opt(cfg)
-	}
+// ExtractBytes runs OCR on in-memory image data. ext is the file extension
+// of the data (e.g. ".png" or "pdf") and selects how it is processed.
+func ExtractBytes(ctx context.Context, data []byte, ext string, opts ...Option) (*models.OCRResult, error) {
+	return NewClient(opts...).ExtractBytes(ctx, data, ext)
+}
 
-	// Create logger
-	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
-	logger = logger.With(
-		slog.String("request_id", requestID),
-		slog.String("model", cfg.Model),
-	)
+// ExtractReader runs OCR on image data read from r, such as a multipart
+// upload. The data is buffered in memory and the read fails as soon as it
+// exceeds the configured maximum file size, so oversized uploads are never
+// read in full. ext is the file extension of the data.
+func ExtractReader(ctx context.Context, r io.Reader, ext string, opts ...Option) (*models.OCRResult, error) {
+	return NewClient(opts...).ExtractReader(ctx, r, ext)
+}
+
+// ExtractFS runs OCR on the file name in fsys, such as an embed.FS of bundled
+// sample documents, without touching the OS filesystem. The extension of name
+// selects how the data is processed, as with ExtractBytes, and the checksum
+// is computed from the bytes read.
+func ExtractFS(ctx context.Context, fsys fs.FS, name string, opts ...Option) (*models.OCRResult, error) {
+	return NewClient(opts...).ExtractFS(ctx, fsys, name)
+}
+
+// extract runs the full OCR pipeline for a single source with a resolved config.
+func extract(ctx context.Context, source string, cfg *Config) (*models.OCRResult, error) {
+	start := time.Now()
+	requestID := generateRequestID(cfg)
+	logger := newLogger(requestID, cfg)
 
 	logger.Info("OCR extraction started",
 		slog.String("source", source),
 	)
 
-	// Validate source
-	if source == "" {
-		return nil, NewOCRError("Extract", requestID, ErrEmptySource)
-	}
-
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
 	defer cancel()
 
+	in, err := load(ctx, source, cfg, requestID, logger, start)
+	if err != nil {
+		return nil, err
+	}
+	return process(ctx, cfg, requestID, logger, in)
+}
+
+// load validates source and downloads or reads its data. A download is
+// aborted when ctx is done. start is when the extraction began.
+func load(ctx context.Context, source string, cfg *Config, requestID string, logger *slog.Logger, start time.Time) (input, error) {
+	if source == "" {
+		return input{}, NewOCRError("Extract", requestID, ErrEmptySource)
+	}
+
 	// Determine source type and load image data
-	var (
-		imageData  []byte
-		sourceType models.SourceType
-		checksum   string
-		ext        string
-		imageInfo  models.ImageInfo
-		isPDF      bool
-		err        error
-	)
+	in := input{source: source, start: start}
+	var err error
 
 	if utils.IsURL(source) {
-		sourceType = models.SourceTypeURL
+		in.sourceType = models.SourceTypeURL
 
 		if err := utils.ValidateURL(source); err != nil {
-			return nil, NewOCRError("Extract.ValidateURL", requestID, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+			return input{}, NewOCRError("Extract.ValidateURL", requestID, fmt.Errorf("%w: %v", ErrInvalidURL, err))
 		}
 
-		ext = utils.FileExtension(source)
-		isPDF = ext == ".pdf"
+		in.ext = utils.FileExtension(source)
+
+		if err := validateSource(cfg, source, SourceInfo{
+			Type: in.sourceType,
+			Host: urlHost(source),
+			Ext:  in.ext,
+			Size: -1,
+		}); err != nil {
+			return input{}, NewOCRError("Extract.ValidateSource", requestID, err)
+		}
 
 		logger.Info("downloading image from URL",
 			slog.String("url", source),
 		)
 
-		imageData, err = utils.DownloadImage(source, cfg.MaxFileSize)
+		downloader := utils.NewDownloadClient(cfg.Proxy)
+		in.data, err = utils.DownloadImageContext(ctx, downloader, source, cfg.MaxFileSize)
+		if cfg.URLRefresher != nil && isAuthFailure(err) {
+			in.data, err = downloadRefreshed(ctx, downloader, source, cfg, requestID, logger, err)
+		}
+		downloader.CloseIdleConnections()
 		if err != nil {
-			return nil, NewOCRError("Extract.DownloadImage", requestID, fmt.Errorf("%w: %v", ErrURLFetchFailed, err))
+			var ocrErr *OCRError
+			if errors.As(err, &ocrErr) {
+				return input{}, ocrErr
+			}
+			if ctx.Err() != nil {
+				elapsed := time.Since(start).Milliseconds()
+				return input{}, budgetExceeded(ctx, NewOCRError("Extract.DownloadImage", requestID, err), requestID,
+					models.Timings{DownloadMs: elapsed, TotalMs: elapsed})
+			}
+			return input{}, NewOCRError("Extract.DownloadImage", requestID, fmt.Errorf("%w: %v", ErrURLFetchFailed, err))
 		}
 
-		checksum = utils.SHA256Bytes(imageData)
+		in.checksum = utils.SHA256Bytes(in.data)
 	} else {
-		sourceType = models.SourceTypeFile
-		ext = utils.FileExtension(source)
-		isPDF = ext == ".pdf"
+		in.sourceType = models.SourceTypeFile
+		in.ext = utils.FileExtension(source)
 
 		if err := utils.ValidateFilePath(source, cfg.MaxFileSize); err != nil {
-			return nil, NewOCRError("Extract.ValidateFile", requestID, fmt.Errorf("%w: %v", ErrFileNotFound, err))
+			return input{}, NewOCRError("Extract.ValidateFile", requestID, fmt.Errorf("%w: %v", ErrFileNotFound, err))
 		}
 
-		imageData, err = utils.LoadImageFromFile(source)
+		if cfg.SourceValidator != nil {
+			info := SourceInfo{Type: in.sourceType, Ext: in.ext, Size: -1}
+			if fi, err := os.Stat(source); err == nil {
+				info.Size = fi.Size()
+			}
+			if err := validateSource(cfg, source, info); err != nil {
+				return input{}, NewOCRError("Extract.ValidateSource", requestID, err)
+			}
+		}
+
+		in.data, err = utils.LoadImageFromFile(source)
 		if err != nil {
-			return nil, NewOCRError("Extract.LoadImage", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+			return input{}, NewOCRError("Extract.LoadImage", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+		}
+
+		in.checksum, err = utils.SHA256File(source)
+		if err != nil {
+			return input{}, NewOCRError("Extract.Checksum", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+		}
+	}
+	in.loadLatency = time.Since(start)
+	return in, nil
+}
+
+// isAuthFailure reports whether a download was refused with 401 or 403, as
+// happens when a presigned URL has expired.
+func isAuthFailure(err error) bool {
+	var status *utils.HTTPStatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	return status.StatusCode == http.StatusUnauthorized || status.StatusCode == http.StatusForbidden
+}
+
+// downloadRefreshed asks cfg.URLRefresher for a new URL for source after
+// the download failed with cause and retries once with it. The new URL must
+// pass the same URL and source validator checks as the original. If the
+// refresher fails, cause is returned with its error.
+func downloadRefreshed(ctx context.Context, downloader *http.Client, source string, cfg *Config, requestID string, logger *slog.Logger, cause error) ([]byte, error) {
+	refreshed, err := cfg.URLRefresher(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w; refresh URL: %v", cause, err)
+	}
+	if err := utils.ValidateURL(refreshed); err != nil {
+		return nil, NewOCRError("Extract.ValidateURL", requestID, fmt.Errorf("%w: refreshed URL: %v", ErrInvalidURL, err))
+	}
+	if err := validateSource(cfg, refreshed, SourceInfo{
+		Type: models.SourceTypeURL,
+		Host: urlHost(refreshed),
+		Ext:  utils.FileExtension(refreshed),
+		Size: -1,
+	}); err != nil {
+		return nil, NewOCRError("Extract.ValidateSource", requestID, fmt.Errorf("refreshed URL: %w", err))
+	}
+
+	logger.Info("download refused, retrying with a refreshed URL",
+		slog.String("error", cause.Error()),
+	)
+	return utils.DownloadImageContext(ctx, downloader, refreshed, cfg.MaxFileSize)
+}
+
+// extractBytes runs the OCR pipeline over in-memory image data.
+func extractBytes(ctx context.Context, data []byte, ext string, cfg *Config) (*models.OCRResult, error) {
+	start := time.Now()
+	requestID := generateRequestID(cfg)
+	logger := newLogger(requestID, cfg)
+
+	logger.Info("OCR extraction started",
+		slog.String("source", string(models.SourceTypeBytes)),
+		slog.Int("bytes", len(data)),
+	)
+
+	ext, err := normalizeExtension(ext)
+	if err != nil {
+		return nil, NewOCRError("ExtractBytes", requestID, err)
+	}
+	if len(data) == 0 {
+		return nil, NewOCRError("ExtractBytes", requestID, ErrEmptySource)
+	}
+	if int64(len(data)) > cfg.MaxFileSize {
+		return nil, NewOCRError("ExtractBytes", requestID,
+			fmt.Errorf("%w: %d bytes exceeds maximum %d bytes", ErrFileTooLarge, len(data), cfg.MaxFileSize))
+	}
+	if err := validateSource(cfg, "", SourceInfo{Type: models.SourceTypeBytes, Ext: ext, Size: int64(len(data))}); err != nil {
+		return nil, NewOCRError("ExtractBytes.ValidateSource", requestID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	return process(ctx, cfg, requestID, logger, input{
+		sourceType:  models.SourceTypeBytes,
+		data:        data,
+		checksum:    utils.SHA256Bytes(data),
+		ext:         ext,
+		start:       start,
+		loadLatency: time.Since(start),
+	})
+}
+
+// extractFS reads name from fsys and runs the OCR pipeline over its data.
+func extractFS(ctx context.Context, fsys fs.FS, name string, cfg *Config) (*models.OCRResult, error) {
+	start := time.Now()
+	requestID := generateRequestID(cfg)
+	logger := newLogger(requestID, cfg)
+
+	logger.Info("OCR extraction started",
+		slog.String("source", name),
+	)
+
+	if fsys == nil || name == "" {
+		return nil, NewOCRError("ExtractFS", requestID, ErrEmptySource)
+	}
+	ext, err := normalizeExtension(path.Ext(name))
+	if err != nil {
+		return nil, NewOCRError("ExtractFS", requestID, err)
+	}
+
+	// Stat first so an oversized file is rejected without reading it
+	fi, err := fs.Stat(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, NewOCRError("ExtractFS", requestID, fmt.Errorf("%w: %v", ErrFileNotFound, err))
+		}
+		return nil, NewOCRError("ExtractFS", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+	}
+	if fi.Size() > cfg.MaxFileSize {
+		return nil, NewOCRError("ExtractFS", requestID,
+			fmt.Errorf("%w: %d bytes exceeds maximum %d bytes", ErrFileTooLarge, fi.Size(), cfg.MaxFileSize))
+	}
+	if err := validateSource(cfg, name, SourceInfo{Type: models.SourceTypeBytes, Ext: ext, Size: fi.Size()}); err != nil {
+		return nil, NewOCRError("ExtractFS.ValidateSource", requestID, err)
+	}
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, NewOCRError("ExtractFS", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+	}
+	if len(data) == 0 {
+		return nil, NewOCRError("ExtractFS", requestID, ErrEmptySource)
+	}
+	if int64(len(data)) > cfg.MaxFileSize {
+		return nil, NewOCRError("ExtractFS", requestID,
+			fmt.Errorf("%w: %d bytes exceeds maximum %d bytes", ErrFileTooLarge, len(data), cfg.MaxFileSize))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	return process(ctx, cfg, requestID, logger, input{
+		source:      name,
+		sourceType:  models.SourceTypeBytes,
+		data:        data,
+		checksum:    utils.SHA256Bytes(data),
+		ext:         ext,
+		start:       start,
+		loadLatency: time.Since(start),
+	})
+}
+
+// extractReader streams r into memory, enforcing cfg.MaxFileSize while
+// reading, and runs the OCR pipeline over the result.
+func extractReader(ctx context.Context, r io.Reader, ext string, cfg *Config) (*models.OCRResult, error) {
+	start := time.Now()
+	requestID := generateRequestID(cfg)
+	logger := newLogger(requestID, cfg)
+
+	logger.Info("OCR extraction started",
+		slog.String("source", string(models.SourceTypeBytes)),
+	)
+
+	ext, err := normalizeExtension(ext)
+	if err != nil {
+		return nil, NewOCRError("ExtractReader", requestID, err)
+	}
+	if r == nil {
+		return nil, NewOCRError("ExtractReader", requestID, ErrEmptySource)
+	}
+	if err := validateSource(cfg, "", SourceInfo{Type: models.SourceTypeBytes, Ext: ext, Size: -1}); err != nil {
+		return nil, NewOCRError("ExtractReader.ValidateSource", requestID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	data, checksum, err := utils.ReadLimited(r, cfg.MaxFileSize)
+	if err != nil {
+		if errors.Is(err, utils.ErrSizeLimitExceeded) {
+			return nil, NewOCRError("ExtractReader", requestID, fmt.Errorf("%w: %v", ErrFileTooLarge, err))
+		}
+		return nil, NewOCRError("ExtractReader", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+	}
+	if len(data) == 0 {
+		return nil, NewOCRError("ExtractReader", requestID, ErrEmptySource)
+	}
+
+	return process(ctx, cfg, requestID, logger, input{
+		sourceType:  models.SourceTypeBytes,
+		data:        data,
+		checksum:    checksum,
+		ext:         ext,
+		start:       start,
+		loadLatency: time.Since(start),
+	})
+}
+
+// pdfFailure returns the sentinel for a failed PDF run: ErrPDFParseFailed if
+// the PDF could not be opened, otherwise the engine's failure.
+func pdfFailure(err, failure error) error {
+	if errors.Is(err, utils.ErrPDFEncrypted) {
+		return ErrPDFParseFailed
+	}
+	return engineFailure(err, failure)
+}
+
+// engineFailure returns the sentinel for a failed engine run:
+// ErrModelNotFound if the model is not pulled, otherwise failure.
+func engineFailure(err, failure error) error {
+	if errors.Is(err, client.ErrModelNotFound) {
+		return ErrModelNotFound
+	}
+	return failure
+}
+
+// budgetExceeded turns err, returned after ctx was done, into an
+// ErrContextCanceled error whose message breaks the time spent down by stage,
+// e.g. "deadline exceeded after 118s (download 2s, model 115s)", and whose
+// Timings holds the same figures. The Op of err, if it is an *OCRError, is
+// kept, and so is err in the chain.
+func budgetExceeded(ctx context.Context, err error, requestID string, timings models.Timings) *OCRError {
+	op := "Extract"
+	var ocrErr *OCRError
+	if errors.As(err, &ocrErr) {
+		op, err = ocrErr.Op, ocrErr.Err
+	}
+
+	reason := "canceled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "deadline exceeded"
+	}
+	msg := reason + " after " + formatStageMs(timings.TotalMs)
+	var stages []string
+	for _, s := range []struct {
+		name string
+		ms   int64
+	}{
+		{"download", timings.DownloadMs},
+		{"preprocess", timings.PreprocessMs},
+		{"model", timings.ModelMs},
+	} {
+		if s.ms > 0 {
+			stages = append(stages, s.name+" "+formatStageMs(s.ms))
+		}
+	}
+	if len(stages) > 0 {
+		msg += " (" + strings.Join(stages, ", ") + ")"
+	}
+
+	wrapped := NewOCRError(op, requestID, fmt.Errorf("%w: %s: %w", ErrContextCanceled, msg, err))
+	wrapped.Timings = &timings
+	return wrapped
+}
+
+// formatStageMs formats a stage duration in milliseconds as whole seconds
+// from 10s, tenths of a second from 1s and milliseconds below.
+func formatStageMs(ms int64) string {
+	switch {
+	case ms >= 10000:
+		return fmt.Sprintf("%ds", (ms+500)/1000)
+	case ms >= 1000:
+		return fmt.Sprintf("%.1fs", float64(ms)/1000)
+	default:
+		return fmt.Sprintf("%dms", ms)
+	}
+}
+
+// verifyChecksum checks data against the checksum set with
+// WithExpectedChecksum, if any.
+func verifyChecksum(data []byte, cfg *Config) error {
+	if cfg.ExpectedChecksum == "" {
+		return nil
+	}
+	sum, err := utils.Checksum(cfg.ChecksumAlgorithm, data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChecksumMismatch, err)
+	}
+	if sum != cfg.ExpectedChecksum {
+		return fmt.Errorf("%w: %s is %s, want %s", ErrChecksumMismatch, strings.ToLower(cfg.ChecksumAlgorithm), sum, cfg.ExpectedChecksum)
+	}
+	return nil
+}
+
+// validateSource runs the configured SourceValidator, if any.
+func validateSource(cfg *Config, source string, info SourceInfo) error {
+	if cfg.SourceValidator == nil {
+		return nil
+	}
+	if err := cfg.SourceValidator(source, info); err != nil {
+		return fmt.Errorf("%w: %w", ErrSourceRejected, err)
+	}
+	return nil
+}
+
+// urlHost returns the host of rawURL without its port.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// input is a loaded source ready for the OCR pipeline.
+type input struct {
+	source     string // path, URL or ExtractFS name; empty for other in-memory sources
+	sourceType models.SourceType
+	data       []byte
+	checksum   string
+	ext        string
+
+	// start is when the extraction began and loadLatency how long it took
+	// to download or read the source.
+	start       time.Time
+	loadLatency time.Duration
+}
+
+// process runs the model over a loaded source and builds the result.
+func process(ctx context.Context, cfg *Config, requestID string, logger *slog.Logger, in input) (*models.OCRResult, error) {
+	preprocessStart := time.Now()
+
+	if err := verifyChecksum(in.data, cfg); err != nil {
+		return nil, NewOCRError("Extract.VerifyChecksum", requestID, err)
+	}
+
+	// Check the pixel count from the header, before anything decodes the image
+	if cfg.MaxImagePixels > 0 {
+		if err := checkMaxImagePixels(utils.GetImageInfo(in.data, in.ext), in.ext, cfg); err != nil {
+			return nil, NewOCRError("Extract.ImageSize", requestID, err)
 		}
+	}
 
-		checksum, err = utils.SHA256File(source)
+	// Vision models reliably accept only PNG and JPEG
+	var err error
+	if in.ext != ".pdf" {
+		in.data, err = utils.NormalizeImageFormat(in.data, in.ext)
 		if err != nil {
-			return nil, NewOCRError("Extract.Checksum", requestID, fmt.Errorf("%w: %v", ErrFileReadFailed, err))
+			return nil, NewOCRError("Extract.NormalizeImage", requestID, fmt.Errorf("%w: %v", ErrImageDecodeFailed, err))
+		}
+		if cfg.ValidateImageBytes {
+			if err := utils.VerifyImage(in.data); err != nil {
+				return nil, NewOCRError("Extract.ValidateImage", requestID, fmt.Errorf("%w: %v", ErrImageDecodeFailed, err))
+			}
 		}
 	}
 
 	// Get image info
-	imageInfo = utils.GetImageInfo(imageData, ext)
+	imageInfo := utils.GetImageInfo(in.data, in.ext)
+	if err := checkMinImageDimension(imageInfo, in.ext, cfg); err != nil {
+		return nil, NewOCRError("Extract.ImageSize", requestID, err)
+	}
 
-	// Create Ollama client
-	ollamaClient := client.NewOllamaClient(cfg.OllamaURL, cfg.Timeout)
+	// Run the model, unless the image is blank and we were asked to skip it
+	var (
+		result     *engine.ProcessResult
+		candidates []*engine.ProcessResult
+		rotation   int
+	)
+	blank := cfg.SkipBlank && in.ext != ".pdf" && utils.IsLikelyBlank(in.data)
+	preprocessLatency := time.Since(preprocessStart)
+	if blank {
+		logger.Info("blank image detected, skipping model call")
+		result = &engine.ProcessResult{VisionResponse: &models.OllamaVisionResponse{}}
+	} else {
+		var retries *engine.RetryBudget
+		if cfg.MaxTotalRetries != nil {
+			retries = engine.NewRetryBudget(*cfg.MaxTotalRetries)
+		}
+		strips := autoStrips(imageInfo, in.ext, cfg)
+		modelStart := time.Now()
+		if len(cfg.CropRegions) > 0 && in.ext != ".pdf" {
+			result, err = runRegions(ctx, cfg, requestID, logger, in, retries)
+		} else if len(strips) > 0 {
+			result, err = runStrips(ctx, cfg, requestID, logger, in, strips, retries)
+		} else if cfg.Candidates > 1 {
+			candidates, err = runCandidates(ctx, cfg, requestID, logger, in, retries)
+			if err == nil {
+				// The best candidate, accounting for every call
+				best := *candidates[0]
+				for _, c := range candidates[1:] {
+					addUsage(&best, c)
+				}
+				result = &best
+			}
+		} else {
+			result, err = runEngine(ctx, cfg, requestID, logger, in, retries)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, budgetExceeded(ctx, err, requestID, models.Timings{
+					DownloadMs:   in.loadLatency.Milliseconds(),
+					PreprocessMs: preprocessLatency.Milliseconds(),
+					ModelMs:      time.Since(modelStart).Milliseconds(),
+					TotalMs:      time.Since(in.start).Milliseconds(),
+				})
+			}
+			return nil, err
+		}
+		if cfg.AutoRotate && cfg.WithConfidenceScores && in.ext != ".pdf" && len(cfg.CropRegions) == 0 && len(strips) == 0 && candidates == nil {
+			result, in, rotation = autoRotate(ctx, cfg, requestID, logger, in, result, retries)
+		}
+	}
 
-	// Ping Ollama
-	if err := ollamaClient.Ping(ctx); err != nil {
-		return nil, NewOCRError("Extract.Ping", requestID, fmt.Errorf("%w: %v", ErrOllamaUnavailable, err))
+	// Build OCRResult from engine result
+	buildStart := time.Now()
+	var ocrResult *models.OCRResult
+	if candidates != nil {
+		ocrResult = buildCandidates(in, imageInfo, result, candidates, cfg)
+	} else {
+		ocrResult = buildOCRResult(in.source, in.sourceType, in.checksum, imageInfo, result, cfg)
 	}
+	ocrResult.Metadata.Blank = blank
+	ocrResult.Image.Rotation = rotation
+	if cfg.AutoRotate && !cfg.WithConfidenceScores {
+		ocrResult.Warnings = append(ocrResult.Warnings, "auto-rotation requires confidence scores")
+	}
+	if cfg.LogprobConfidence && !cfg.WithConfidenceScores {
+		ocrResult.Warnings = append(ocrResult.Warnings, "logprob confidence requires confidence scores")
+	}
+	ocrResult.Warnings = append(ocrResult.Warnings, result.Warnings...)
+	if len(cfg.CropRegions) > 0 && in.ext == ".pdf" {
+		ocrResult.Warnings = append(ocrResult.Warnings, "crop regions are not supported for PDFs and were ignored")
+	}
+	if cfg.RetainImage {
+		// Keep what the model saw, which differs from in.data with JPEG
+		// encoding
+		retained := in.data
+		if result.SentImage != nil {
+			retained = result.SentImage
+		}
+		ocrResult.SetRetainedImage(retained, utils.DetectContentType(retained))
+	}
+
+	if len(cfg.AllowedLanguages) > 0 && !blank {
+		if !cfg.WithLanguageDetection {
+			ocrResult.Warnings = append(ocrResult.Warnings, "allowed languages require language detection")
+		} else if err := checkAllowedLanguage(ocrResult, cfg); err != nil {
+			if cfg.StrictMode {
+				return nil, NewOCRError("Extract.Language", requestID, err)
+			}
+			logger.Warn("detected language is not allowed",
+				slog.String("language", ocrResult.LanguageCode()),
+				slog.Any("allowed", cfg.AllowedLanguages),
+			)
+			ocrResult.Warnings = append(ocrResult.Warnings, err.Error())
+		}
+	}
+
+	// Validate
+	var validation []utils.ValidationOption
+	if cfg.AllowFreeformDocumentType {
+		validation = append(validation, utils.AllowFreeformDocumentType())
+	}
+	if err := utils.ValidateOCRResult(ocrResult, validation...); err != nil {
+		logger.Warn("output validation failed, returning result anyway",
+			slog.String("validation_error", err.Error()),
+		)
+	}
+
+	if err := checkExpectedDocumentType(ocrResult, cfg); err != nil && !blank {
+		if cfg.StrictMode {
+			return nil, NewOCRError("Extract.DocumentType", requestID, err)
+		}
+		logger.Warn("document type mismatch",
+			slog.String("expected", string(cfg.ExpectedDocumentType)),
+			slog.String("actual", string(ocrResult.Metadata.DocumentType)),
+		)
+		ocrResult.Warnings = append(ocrResult.Warnings, err.Error())
+	}
+
+	if msg := checkStructuredData(ocrResult, cfg); msg != "" && !blank {
+		logger.Warn("structured extraction yielded nothing",
+			slog.String("document_type", string(ocrResult.Metadata.DocumentType)),
+			slog.String("reason", msg),
+		)
+		ocrResult.Warnings = append(ocrResult.Warnings, msg)
+	}
+
+	for i, fn := range cfg.ResultProcessors {
+		if err := fn(ocrResult); err != nil {
+			err = fmt.Errorf("%w: processor %d: %w", ErrResultProcessor, i, err)
+			if cfg.StrictMode {
+				return nil, NewOCRError("Extract.ResultProcessor", requestID, err)
+			}
+			logger.Warn("result processor failed",
+				slog.Int("processor", i),
+				slog.String("error", err.Error()),
+			)
+			ocrResult.Warnings = append(ocrResult.Warnings, err.Error())
+		}
+	}
+
+	if cfg.Timings {
+		ocrResult.Timings = &models.Timings{
+			DownloadMs:   in.loadLatency.Milliseconds(),
+			PreprocessMs: (preprocessLatency + result.PreprocessLatency).Milliseconds(),
+			ModelMs:      result.ModelLatency.Milliseconds(),
+			ParseMs:      (result.ParseLatency + time.Since(buildStart)).Milliseconds(),
+			TotalMs:      time.Since(in.start).Milliseconds(),
+		}
+	}
+
+	logger.Info("OCR extraction complete",
+		slog.String("used_model", result.Model),
+		slog.Duration("total_latency", result.Latency),
+		slog.Int("prompt_tokens", result.PromptTokens),
+		slog.Int("eval_tokens", result.EvalTokens),
+	)
+
+	return ocrResult, nil
+}
 
-	// Create engine
-	eng := engine.NewVisionEngine(ollamaClient, logger)
+// runEngine pings Ollama and runs the vision engine over the loaded image or
+// PDF. retries, if non-nil, bounds parse-failure retries across all pages.
+func runEngine(
+	ctx context.Context,
+	cfg *Config,
+	requestID string,
+	logger *slog.Logger,
+	in input,
+	retries *engine.RetryBudget,
+) (*engine.ProcessResult, error) {
+	eng, err := selectEngine(ctx, cfg, requestID, logger)
+	if err != nil {
+		return nil, err
+	}
+	return processWith(ctx, eng, cfg, requestID, in, processConfig(cfg, requestID, in, retries))
+}
 
+// processConfig returns the engine parameters for the request.
+func processConfig(cfg *Config, requestID string, in input, retries *engine.RetryBudget) engine.ProcessConfig {
 	processCfg := engine.ProcessConfig{
 		Model:                    cfg.Model,
+		FallbackModels:           cfg.FallbackModels,
 		Temperature:              cfg.Temperature,
+		NumPredict:               cfg.NumPredict,
+		TopP:                     cfg.TopP,
+		Stop:                     cfg.Stop,
+		NumGPU:                   cfg.NumGPU,
+		NumThread:                cfg.NumThread,
 		RequestID:                requestID,
 		WithSummary:              cfg.WithSummary,
 		WithLanguageDetection:    cfg.WithLanguageDetection,
 		WithStructuredExtraction: cfg.WithStructuredExtraction,
 		WithBoundingBoxes:        cfg.WithBoundingBoxes,
 		WithConfidenceScores:     cfg.WithConfidenceScores,
+		WithKeyValueConfidence:   cfg.KeyValueConfidence,
+		WithTableConfidence:      cfg.MinTableConfidence > 0,
+		WithTableSpans:           cfg.TableSpans,
+		WithTextDirection:        cfg.DetectTextDirection,
+		WithDocumentRegions:      cfg.DetectMultipleDocuments,
+		WithWatermarks:           cfg.FilterWatermarks,
+		FreeformDocumentType:     cfg.AllowFreeformDocumentType,
+		ExpectedDocumentType:     string(cfg.ExpectedDocumentType),
+		SummaryLength:            string(cfg.SummaryLength),
+		SummaryMaxWords:          cfg.SummaryMaxWords,
+		DebugRequestLog:          cfg.DebugRequestLog,
+		DebugPromptLength:        cfg.DebugPromptLength,
+		RetryBudget:              retries,
+		RetryBackoff:             cfg.RetryBackoff,
+		RetryMaxBackoff:          cfg.RetryMaxBackoff,
+		MaxTotalTokens:           cfg.MaxTotalTokens,
+		DeadlinePadding:          cfg.DeadlinePadding,
+		ResponseTimeout:          cfg.ResponseTimeout,
+		RawJSON:                  cfg.RawJSON,
+		PDFPassword:              cfg.PDFPassword,
+		ExtractFormFields:        cfg.ExtractFormFields && !cfg.RawJSON,
+		ExtractPDFMetadata:       cfg.ExtractPDFMetadata,
+		RetryOnEmptyText:         cfg.RetryOnEmptyText,
+		SchemaValidation:         cfg.SchemaValidation,
+		ThumbnailHint:            cfg.ThumbnailHint,
+		CompactPrompt:            cfg.CompactPrompt,
+		FewShotExamples:          cfg.FewShotExamples,
+		LogprobConfidence:        cfg.LogprobConfidence,
+	}
+	if cfg.ImageEncoding == ImageEncodingJPEG {
+		processCfg.JPEGQuality = cfg.JPEGQuality
+	}
+	if cfg.ProgressiveParse != nil && in.ext != ".pdf" && len(cfg.CropRegions) == 0 {
+		imageInfo := utils.GetImageInfo(in.data, in.ext)
+		processCfg.OnPartial = func(resp *models.OllamaVisionResponse) {
+			partial := &engine.ProcessResult{VisionResponse: resp, Model: cfg.Model}
+			cfg.ProgressiveParse(buildOCRResult(in.source, in.sourceType, in.checksum, imageInfo, partial, cfg))
+		}
+	}
+	return processCfg
+}
+
+// processWith runs eng over the loaded image or PDF.
+func processWith(ctx context.Context, eng engine.Engine, cfg *Config, requestID string, in input, processCfg engine.ProcessConfig) (*engine.ProcessResult, error) {
+	failure := ErrOllamaRequestFailed
+	if _, ok := eng.(*engine.TesseractEngine); ok {
+		failure = ErrTesseractFailed
+	}
+	record := func(err error) {
+		if failure == ErrOllamaRequestFailed {
+			cfg.breaker.record(ctx, err)
+		}
+	}
+
+	// Process
+	var (
+		result *engine.ProcessResult
+		err    error
+	)
+	if in.ext == ".pdf" {
+		if in.sourceType != models.SourceTypeFile {
+			// For downloaded or in-memory PDFs, save to tmp and process
+			tmpFile, err := os.CreateTemp("", "ocr-pdf-*.pdf")
+			if err != nil {
+				return nil, NewOCRError("Extract.TempFile", requestID, err)
+			}
+			defer os.Remove(tmpFile.Name())
+			if _, err := tmpFile.Write(in.data); err != nil {
+				tmpFile.Close()
+				return nil, NewOCRError("Extract.WriteTempFile", requestID, err)
+			}
+			tmpFile.Close()
+			result, err = eng.ProcessPDF(ctx, tmpFile.Name(), processCfg)
+			record(err)
+			if err != nil {
+				return nil, NewOCRError("Extract.ProcessPDF", requestID, fmt.Errorf("%w: %v", pdfFailure(err, failure), err))
+			}
+		} else {
+			result, err = eng.ProcessPDF(ctx, in.source, processCfg)
+			record(err)
+			if err != nil {
+				return nil, NewOCRError("Extract.ProcessPDF", requestID, fmt.Errorf("%w: %v", pdfFailure(err, failure), err))
+			}
+		}
+	} else {
+		result, err = eng.Process(ctx, in.data, processCfg)
+		record(err)
+		if err != nil {
+			return nil, NewOCRError("Extract.Process", requestID, fmt.Errorf("%w: %v", engineFailure(err, failure), err))
+		}
+	}
+
+	return result, nil
+}
+
+// autoRotate re-runs the engine on a 180°-rotated copy of the image when
+// the model's confidence in the first result is below
+// AutoRotateConfidenceThreshold. It returns the more confident result, the
+// input it came from and the rotation applied in degrees. Usage covers both
+// runs. If the rotated run fails the first result is kept.
+func autoRotate(
+	ctx context.Context,
+	cfg *Config,
+	requestID string,
+	logger *slog.Logger,
+	in input,
+	result *engine.ProcessResult,
+	retries *engine.RetryBudget,
+) (*engine.ProcessResult, input, int) {
+	conf := resultConfidence(result, cfg)
+	if conf >= AutoRotateConfidenceThreshold {
+		return result, in, 0
+	}
+
+	rotated, err := utils.RotateImage180(in.data)
+	if err != nil {
+		logger.Warn("cannot rotate image, keeping original orientation",
+			slog.String("error", err.Error()),
+		)
+		return result, in, 0
+	}
+
+	logger.Info("low confidence, retrying with the image rotated 180 degrees",
+		slog.Float64("confidence", conf),
+	)
+
+	rotatedIn := in
+	rotatedIn.data = rotated
+	rotatedIn.ext = ".png"
+	rotatedResult, err := runEngine(ctx, cfg, requestID, logger, rotatedIn, retries)
+	if err != nil {
+		logger.Warn("rotated OCR failed, keeping original orientation",
+			slog.String("error", err.Error()),
+		)
+		return result, in, 0
+	}
+
+	if resultConfidence(rotatedResult, cfg) > conf {
+		addUsage(rotatedResult, result)
+		info := utils.GetImageInfo(in.data, in.ext)
+		rotatedResult.Transforms = append(rotatedResult.Transforms, utils.RotateTransform(180, info.Width, info.Height))
+		return rotatedResult, rotatedIn, 180
+	}
+	addUsage(result, rotatedResult)
+	return result, in, 0
+}
+
+// resultConfidence returns the model's calibrated overall confidence in
+// result.
+func resultConfidence(result *engine.ProcessResult, cfg *Config) float64 {
+	if result.VisionResponse == nil || result.VisionResponse.Metadata == nil {
+		return 0
+	}
+	return calibrate(float64(result.VisionResponse.Metadata.ConfidenceScore), cfg)
+}
+
+// addUsage adds the tokens and latencies of src to dst.
+func addUsage(dst, src *engine.ProcessResult) {
+	dst.PromptTokens += src.PromptTokens
+	dst.EvalTokens += src.EvalTokens
+	dst.Latency += src.Latency
+	dst.PreprocessLatency += src.PreprocessLatency
+	dst.ModelLatency += src.ModelLatency
+	dst.ParseLatency += src.ParseLatency
+}
+
+// runCandidates generates cfg.Candidates results with one engine, varying
+// the seed and, after the first, raising the temperature to at least
+// CandidateTemperature. It returns them ranked by calibrated confidence,
+// most confident first. Failed candidates are dropped with a warning on the
+// first result; it fails only if all of them do.
+func runCandidates(ctx context.Context, cfg *Config, requestID string, logger *slog.Logger, in input, retries *engine.RetryBudget) ([]*engine.ProcessResult, error) {
+	eng, err := selectEngine(ctx, cfg, requestID, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	base := processConfig(cfg, requestID, in, retries)
+	// Partial results of one candidate would not describe the ranked result
+	base.OnPartial = nil
+
+	var (
+		results  []*engine.ProcessResult
+		warnings []string
+		firstErr error
+	)
+	for i := range cfg.Candidates {
+		processCfg := base
+		seed := i
+		processCfg.Seed = &seed
+		if i > 0 {
+			processCfg.Temperature = max(processCfg.Temperature, CandidateTemperature)
+		}
+
+		logger.Info("generating candidate",
+			slog.Int("candidate", i),
+			slog.Int("candidates", cfg.Candidates),
+			slog.Float64("temperature", processCfg.Temperature),
+		)
+
+		result, err := processWith(ctx, eng, cfg, requestID, in, processCfg)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			logger.Warn("candidate failed",
+				slog.Int("candidate", i),
+				slog.String("error", err.Error()),
+			)
+			warnings = append(warnings, fmt.Sprintf("candidate %d failed: %v", i, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, firstErr
+	}
+
+	slices.SortStableFunc(results, func(a, b *engine.ProcessResult) int {
+		return cmp.Compare(resultConfidence(b, cfg), resultConfidence(a, cfg))
+	})
+	results[0].Warnings = append(results[0].Warnings, warnings...)
+	return results, nil
+}
+
+// runRegions crops the image to each of cfg.CropRegions, runs the engine on
+// every crop and merges the results. Line boxes are mapped back to
+// original-image pixel coordinates and labeled with their region index.
+func runRegions(ctx context.Context, cfg *Config, requestID string, logger *slog.Logger, in input, retries *engine.RetryBudget) (*engine.ProcessResult, error) {
+	results := make([]*engine.ProcessResult, 0, len(cfg.CropRegions))
+	for i, region := range cfg.CropRegions {
+		crop, rect, err := utils.CropImage(in.data, region)
+		if err != nil {
+			return nil, NewOCRError("Extract.CropRegion", requestID, fmt.Errorf("%w: region %d: %v", ErrInvalidCropRegion, i, err))
+		}
+
+		logger.Info("processing crop region",
+			slog.Int("region", i),
+			slog.String("rect", rect.String()),
+		)
+
+		regionIn := in
+		regionIn.data = crop
+		regionIn.ext = ".png"
+		result, err := runEngine(ctx, cfg, requestID, logger, regionIn, retries)
+		if err != nil {
+			return nil, err
+		}
+
+		placeRegion(result.VisionResponse, i, rect)
+		// Image info reported for a crop does not describe the original
+		result.VisionResponse.Image = nil
+		result.SentImage = nil
+		results = append(results, result)
+	}
+	return engine.MergeResults(results, "Region"), nil
+}
+
+// placeRegion labels the lines of one region's response with the region
+// index and moves all its boxes from crop to original-image pixel
+// coordinates.
+func placeRegion(resp *models.OllamaVisionResponse, region int, rect image.Rectangle) {
+	placeCrop(resp, rect)
+	if resp.Text == nil {
+		return
+	}
+	for i := range resp.Text.Lines {
+		idx := region
+		resp.Text.Lines[i].Region = &idx
+	}
+}
+
+// placeCrop moves all boxes of the response for the crop rect from crop to
+// original-image pixel coordinates.
+func placeCrop(resp *models.OllamaVisionResponse, rect image.Rectangle) {
+	var structured []*models.BoundingBox
+	if resp.StructuredData != nil {
+		structured = structuredBoxes(resp.StructuredData.Tables, resp.StructuredData.KeyValueBoxes)
+	}
+	var lines []models.OllamaTextLine
+	if resp.Text != nil {
+		lines = resp.Text.Lines
+	}
+
+	boxes := make([]*models.BoundingBox, 0, len(lines)+len(structured))
+	for _, line := range lines {
+		boxes = append(boxes, line.BoundingBox)
+	}
+	units := utils.DetectBoundingBoxUnits(append(boxes, structured...))
+
+	crop := []models.ImageTransform{utils.CropTransform(rect)}
+	place := func(b models.BoundingBox) models.BoundingBox {
+		if units == models.BoundingBoxUnitsNormalized {
+			b, _ = utils.ConvertBoundingBox(b, units, models.BoundingBoxUnitsPixels, rect.Dx(), rect.Dy())
+		}
+		return utils.MapToOriginal(b, crop)
+	}
+
+	for i := range lines {
+		line := &lines[i]
+		if line.BoundingBox == nil {
+			continue
+		}
+		b := place(*line.BoundingBox)
+		line.BoundingBox = &b
+	}
+	for _, b := range structured {
+		*b = place(*b)
+	}
+}
+
+// runStrips splits a tall image into horizontal strips, runs the engine on
+// each from top to bottom and concatenates the results in that order, with
+// boxes mapped back to original-image pixel coordinates.
+func runStrips(ctx context.Context, cfg *Config, requestID string, logger *slog.Logger, in input, strips []models.BoundingBox, retries *engine.RetryBudget) (*engine.ProcessResult, error) {
+	// Partial results of one strip would not describe the image
+	stripCfg := cfg.Clone()
+	stripCfg.ProgressiveParse = nil
+
+	results := make([]*engine.ProcessResult, 0, len(strips))
+	var raws []string
+	for i, strip := range strips {
+		crop, rect, err := utils.CropImage(in.data, strip)
+		if err != nil {
+			return nil, NewOCRError("Extract.Strip", requestID, fmt.Errorf("%w: strip %d: %v", ErrImageDecodeFailed, i, err))
+		}
+
+		logger.Info("processing strip",
+			slog.Int("strip", i),
+			slog.Int("strips", len(strips)),
+			slog.String("rect", rect.String()),
+		)
+
+		stripIn := in
+		stripIn.data = crop
+		stripIn.ext = ".png"
+		result, err := runEngine(ctx, stripCfg, requestID, logger, stripIn, retries)
+		if err != nil {
+			return nil, err
+		}
+
+		placeCrop(result.VisionResponse, rect)
+		// Image info reported for a strip does not describe the original
+		result.VisionResponse.Image = nil
+		result.SentImage = nil
+		if text := result.VisionResponse.Text; text != nil && strings.TrimSpace(text.Raw) != "" {
+			raws = append(raws, strings.TrimRight(text.Raw, "\n"))
+		}
+		results = append(results, result)
 	}
 
-	// Process
-	var result *engine.ProcessResult
-	if isPDF {
-		if sourceType == models.SourceTypeURL {
-			// For URL-sourced PDFs, save to tmp and process
-			tmpFile, err := os.CreateTemp("", "ocr-pdf-*.pdf")
-			if err != nil {
-				return nil, NewOCRError("Extract.TempFile", requestID, err)
-			}
-			defer os.Remove(tmpFile.Name())
-			if _, err := tmpFile.Write(imageData); err != nil {
-				tmpFile.Close()
-				return nil, NewOCRError("Extract.WriteTempFile", requestID, err)
-			}
-			tmpFile.Close()
-			result, err = eng.ProcessPDF(ctx, tmpFile.Name(), processCfg)
-			if err != nil {
-				return nil, NewOCRError("Extract.ProcessPDF", requestID, fmt.Errorf("%w: %v", ErrOllamaRequestFailed, err))
-			}
-		} else {
-			result, err = eng.ProcessPDF(ctx, source, processCfg)
-			if err != nil {
-				return nil, NewOCRError("Extract.ProcessPDF", requestID, fmt.Errorf("%w: %v", ErrOllamaRequestFailed, err))
-			}
+	merged := engine.MergeResults(results, "Strip")
+	if merged.VisionResponse.Text != nil {
+		// One document: join the strips without section headers
+		merged.VisionResponse.Text.Raw = strings.Join(raws, "\n")
+	}
+	return merged, nil
+}
+
+// structuredBoxes returns the boxes of tables and key-value pairs, so they
+// can be converted in place.
+func structuredBoxes(tables []models.Table, kv map[string]*models.BoundingBox) []*models.BoundingBox {
+	var boxes []*models.BoundingBox
+	for _, t := range tables {
+		if t.BoundingBox != nil {
+			boxes = append(boxes, t.BoundingBox)
 		}
-	} else {
-		result, err = eng.Process(ctx, imageData, processCfg)
-		if err != nil {
-			return nil, NewOCRError("Extract.Process", requestID, fmt.Errorf("%w: %v", ErrOllamaRequestFailed, err))
+	}
+	for _, b := range kv {
+		if b != nil {
+			boxes = append(boxes, b)
 		}
 	}
+	return boxes
+}
 
-	// Build OCRResult from engine result
-	ocrResult := buildOCRResult(source, sourceType, checksum, imageInfo, result, cfg)
+// newBackend returns the configured backend, or an Ollama client.
+func newBackend(cfg *Config) client.VisionBackend {
+	if cfg.Backend != nil {
+		return cfg.Backend
+	}
+	c := client.NewOllamaClient(cfg.OllamaURL, cfg.Timeout, client.WithTransport(cfg.Transport))
+	c.SetFormatCache(cfg.formats)
+	return c
+}
 
-	// Validate
-	if err := utils.ValidateOCRResult(ocrResult); err != nil {
-		logger.Warn("output validation failed, returning result anyway",
-			slog.String("validation_error", err.Error()),
-		)
+// backendName names backend in errors and logs by its type, e.g.
+// "client.OpenAICompatClient".
+func backendName(backend client.VisionBackend) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", backend), "*")
+}
+
+// selectEngine returns the engine to use for this request. For the Ollama
+// engines it pings the server first; EngineAuto degrades to Tesseract if
+// that fails.
+func selectEngine(ctx context.Context, cfg *Config, requestID string, logger *slog.Logger) (engine.Engine, error) {
+	if cfg.Engine == EngineTesseract {
+		return engine.NewTesseractEngine(logger), nil
 	}
 
-	logger.Info("OCR extraction complete",
-		slog.Duration("total_latency", result.Latency),
-		slog.Int("prompt_tokens", result.PromptTokens),
-		slog.Int("eval_tokens", result.EvalTokens),
-	)
+	backend := newBackend(cfg)
 
-	return ocrResult, nil
+	// Ping the model server, unless the circuit breaker has given up on it
+	err := cfg.breaker.allow()
+	if err == nil {
+		err = backend.Ping(ctx)
+		cfg.breaker.record(ctx, err)
+	}
+	if err != nil {
+		if cfg.Engine == EngineAuto && engine.TesseractAvailable() {
+			logger.Warn("model server unavailable, falling back to tesseract engine",
+				slog.String("backend", backendName(backend)),
+				slog.String("error", err.Error()),
+			)
+			return engine.NewTesseractEngine(logger), nil
+		}
+		if cfg.Backend != nil {
+			err = fmt.Errorf("%w: %s: %v", ErrBackendUnavailable, backendName(backend), err)
+		} else {
+			err = fmt.Errorf("%w: %v", ErrOllamaUnavailable, err)
+		}
+		return nil, NewOCRError("Extract.Ping", requestID, err)
+	}
+
+	return engine.NewVisionEngine(backend, logger), nil
 }
 
 // buildOCRResult assembles the final OCRResult from engine output.
@@ -199,43 +1144,228 @@ func buildOCRResult(
 	result *engine.ProcessResult,
 	cfg *Config,
 ) *models.OCRResult {
+	resp := result.VisionResponse
+	if result.RawData != nil {
+		// The model's JSON is returned verbatim, so text and structured
+		// data are not coerced into the schema.
+		resp = &models.OllamaVisionResponse{Metadata: resp.Metadata, Image: resp.Image, Summary: resp.Summary}
+	}
+
 	ocrResult := &models.OCRResult{
+		SchemaVersion: models.SchemaVersion,
 		Source: models.Source{
 			Type:     sourceType,
 			Path:     source,
 			Checksum: checksum,
+			Metadata: maps.Clone(cfg.SourceMetadata),
 		},
 		Image:          imageInfo,
-		Metadata:       buildMetadata(result.VisionResponse),
-		Text:           buildText(result.VisionResponse, cfg),
-		StructuredData: buildStructuredData(result.VisionResponse, cfg),
-		Summary:        buildSummary(result.VisionResponse, cfg),
+		Metadata:       buildMetadata(resp, cfg),
+		Text:           buildText(resp, cfg),
+		StructuredData: buildStructuredData(resp, cfg),
+		Summary:        buildSummary(resp, cfg),
+		Usage:          buildUsage(result),
+		RawData:        result.RawData,
+		Transforms:     result.Transforms,
+		PDFInfo:        result.PDFInfo,
+	}
+
+	if cfg.ExtractFormFields && result.RawData == nil {
+		mergeFormFields(&ocrResult.StructuredData, result.FormFields, cfg)
+	}
+	if resp.StructuredData != nil {
+		ocrResult.StructuredData.OrderedKeyValues = orderedKeyValues(ocrResult.StructuredData.KeyValuePairs, resp.StructuredData.KeyOrder, cfg)
+	}
+
+	if resp.Image != nil {
+		ocrResult.ReportedImage = mergeReportedImage(&ocrResult.Image, resp.Image)
+		if r := ocrResult.ReportedImage; r != nil && r.Mismatch {
+			ocrResult.Warnings = append(ocrResult.Warnings, fmt.Sprintf(
+				"model reported a %dx%d image, decoded image is %dx%d",
+				r.Width, r.Height, ocrResult.Image.Width, ocrResult.Image.Height))
+		}
 	}
 
-	// Override image info if the model provided it
-	if result.VisionResponse.Image != nil {
-		vi := result.VisionResponse.Image
-		if vi.Width > 0 {
-			ocrResult.Image.Width = vi.Width
+	if cfg.DetectMultipleDocuments {
+		ocrResult.Documents = buildDocuments(resp, cfg)
+		if n := len(ocrResult.Documents); n > 1 {
+			ocrResult.Warnings = append(ocrResult.Warnings, fmt.Sprintf(
+				"%d documents detected; use ExtractMultiDoc to split them", n))
 		}
-		if vi.Height > 0 {
-			ocrResult.Image.Height = vi.Height
+	}
+
+	if cfg.OriginalCoordinates {
+		mapBoundingBoxesToOriginal(&ocrResult.Text, &ocrResult.StructuredData, ocrResult.Documents, ocrResult.Image, result.Transforms)
+	}
+	normalizeBoundingBoxes(&ocrResult.Text, &ocrResult.StructuredData, ocrResult.Documents, ocrResult.Image, cfg)
+
+	if cfg.MergeAdjacentLines {
+		if cfg.WithBoundingBoxes {
+			ocrResult.Text.Lines = utils.MergeAdjacentLines(ocrResult.Text.Lines)
+		} else {
+			ocrResult.Warnings = append(ocrResult.Warnings, "merging adjacent lines requires bounding boxes")
 		}
-		if vi.DPI != nil {
-			ocrResult.Image.DPI = vi.DPI
+	}
+
+	if cfg.ReflowLines > 0 {
+		ocrResult.Text.Lines = utils.ReflowLines(ocrResult.Text.Lines, cfg.ReflowLines)
+		if cfg.MaxLines > 0 && len(ocrResult.Text.Lines) > cfg.MaxLines {
+			ocrResult.Text.Lines = ocrResult.Text.Lines[:cfg.MaxLines]
+			ocrResult.Text.Truncated = true
 		}
-		if vi.ColorMode != "" {
-			cm := models.ColorMode(vi.ColorMode)
-			if _, ok := utils.ValidColorModes[cm]; ok {
-				ocrResult.Image.ColorMode = cm
-			}
+	}
+
+	if cfg.KeyValueConfidence && !cfg.WithConfidenceScores {
+		ocrResult.Warnings = append(ocrResult.Warnings, "key-value confidence requires confidence scores")
+	}
+
+	if cfg.MinTableConfidence > 0 && !cfg.WithConfidenceScores {
+		ocrResult.Warnings = append(ocrResult.Warnings, "table confidence filtering requires confidence scores")
+	}
+
+	if cfg.QualityReport {
+		if cfg.WithConfidenceScores {
+			ocrResult.Quality = utils.BuildQualityReport(ocrResult.Text.Lines)
+		} else {
+			ocrResult.Warnings = append(ocrResult.Warnings, "quality report requires confidence scores")
 		}
 	}
 
 	return ocrResult
 }
 
-func buildMetadata(resp *models.OllamaVisionResponse) models.Metadata {
+// buildUsage returns the model and usage of result.
+func buildUsage(result *engine.ProcessResult) models.Usage {
+	return models.Usage{
+		Model:        result.Model,
+		PromptTokens: result.PromptTokens,
+		EvalTokens:   result.EvalTokens,
+		LatencyMs:    result.Latency.Milliseconds(),
+
+		BudgetExceeded: result.BudgetExceeded,
+	}
+}
+
+// buildCandidates builds an OCRResult for each of the ranked candidates and
+// returns a copy of the first with all of them in Candidates and the usage
+// of total, which covers every candidate.
+func buildCandidates(in input, imageInfo models.ImageInfo, total *engine.ProcessResult, candidates []*engine.ProcessResult, cfg *Config) *models.OCRResult {
+	ranked := make([]models.OCRResult, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = *buildOCRResult(in.source, in.sourceType, in.checksum, imageInfo, c, cfg)
+	}
+	best := ranked[0]
+	best.Usage = buildUsage(total)
+	best.Warnings = slices.Clone(best.Warnings)
+	best.Candidates = ranked
+	return &best
+}
+
+// normalizeBoundingBoxes detects the units of the line, table, key-value and
+// document boxes and, if requested, converts them to cfg.BoundingBoxUnits.
+func normalizeBoundingBoxes(text *models.TextResult, sd *models.StructuredData, docs []models.DetectedDocument, image models.ImageInfo, cfg *Config) {
+	structured := structuredBoxes(sd.Tables, sd.KeyValueBoxes)
+	for i := range docs {
+		structured = append(structured, &docs[i].BoundingBox)
+	}
+	boxes := make([]*models.BoundingBox, 0, len(text.Lines)+len(structured))
+	for _, line := range text.Lines {
+		boxes = append(boxes, line.BoundingBox)
+	}
+
+	from := utils.DetectBoundingBoxUnits(append(boxes, structured...))
+	text.BoundingBoxUnits = from
+	if from == "" || cfg.BoundingBoxUnits == "" || from == cfg.BoundingBoxUnits {
+		return
+	}
+
+	for i, line := range text.Lines {
+		if line.BoundingBox == nil {
+			continue
+		}
+		converted, ok := utils.ConvertBoundingBox(*line.BoundingBox, from, cfg.BoundingBoxUnits, image.Width, image.Height)
+		if !ok {
+			// Unknown dimensions: leave every box in its original units
+			return
+		}
+		text.Lines[i].BoundingBox = &converted
+	}
+	// The structured boxes are copies owned by sd and docs, so convert in place
+	for _, b := range structured {
+		converted, ok := utils.ConvertBoundingBox(*b, from, cfg.BoundingBoxUnits, image.Width, image.Height)
+		if !ok {
+			return
+		}
+		*b = converted
+	}
+	text.BoundingBoxUnits = cfg.BoundingBoxUnits
+}
+
+// mapBoundingBoxesToOriginal moves the line, table, key-value and document
+// boxes from the coordinates of the transformed image the model saw back to
+// those of the original image, keeping their units.
+func mapBoundingBoxesToOriginal(text *models.TextResult, sd *models.StructuredData, docs []models.DetectedDocument, image models.ImageInfo, transforms []models.ImageTransform) {
+	if len(transforms) == 0 {
+		return
+	}
+	boxes := structuredBoxes(sd.Tables, sd.KeyValueBoxes)
+	for i := range docs {
+		boxes = append(boxes, &docs[i].BoundingBox)
+	}
+	for i := range text.Lines {
+		if text.Lines[i].BoundingBox != nil {
+			// Copy, as the line may share its box with the engine response
+			b := *text.Lines[i].BoundingBox
+			text.Lines[i].BoundingBox = &b
+			boxes = append(boxes, &b)
+		}
+	}
+
+	units := utils.DetectBoundingBoxUnits(boxes)
+	width, height := utils.TransformedSize(transforms, image.Width, image.Height)
+	for _, b := range boxes {
+		px, ok := utils.ConvertBoundingBox(*b, units, models.BoundingBoxUnitsPixels, width, height)
+		if !ok {
+			return
+		}
+		*b, _ = utils.ConvertBoundingBox(utils.MapToOriginal(px, transforms), models.BoundingBoxUnitsPixels, units, image.Width, image.Height)
+	}
+}
+
+// buildDocuments returns the document regions the model reported.
+func buildDocuments(resp *models.OllamaVisionResponse, cfg *Config) []models.DetectedDocument {
+	docs := make([]models.DetectedDocument, 0, len(resp.Documents))
+	for _, d := range resp.Documents {
+		docs = append(docs, models.DetectedDocument{
+			BoundingBox:  *d.BoundingBox,
+			DocumentType: documentType(d.DocumentType, cfg),
+		})
+	}
+	return docs
+}
+
+// documentType maps the model's document type onto a DocumentType. Types
+// outside the DocumentType constants are "unknown" unless freeform types are
+// allowed.
+func documentType(s string, cfg *Config) models.DocumentType {
+	dt := models.DocumentType(s)
+	if utils.ValidDocumentTypes[dt] {
+		return dt
+	}
+	if !cfg.AllowFreeformDocumentType {
+		return models.DocumentTypeUnknown
+	}
+	s = strings.TrimSpace(s)
+	if known := models.DocumentType(strings.ToLower(s)); utils.ValidDocumentTypes[known] {
+		return known
+	}
+	if s == "" {
+		return models.DocumentTypeUnknown
+	}
+	return models.DocumentType(s)
+}
+
+func buildMetadata(resp *models.OllamaVisionResponse, cfg *Config) models.Metadata {
 	md := models.Metadata{
 		Language:        nil,
 		DocumentType:    models.DocumentTypeUnknown,
@@ -244,11 +1374,15 @@ func buildMetadata(resp *models.OllamaVisionResponse) models.Metadata {
 
 	if resp.Metadata != nil {
 		md.Language = resp.Metadata.Language
-		md.ConfidenceScore = resp.Metadata.ConfidenceScore
+		md.ConfidenceScore = calibrate(float64(resp.Metadata.ConfidenceScore), cfg)
 
-		dt := models.DocumentType(resp.Metadata.DocumentType)
-		if _, ok := utils.ValidDocumentTypes[dt]; ok {
-			md.DocumentType = dt
+		md.DocumentType = documentType(resp.Metadata.DocumentType, cfg)
+	}
+
+	if cfg.DetectTextDirection {
+		md.Direction = models.TextDirectionLTR
+		if resp.Metadata != nil && resp.Metadata.Direction == models.TextDirectionRTL {
+			md.Direction = models.TextDirectionRTL
 		}
 	}
 
@@ -265,12 +1399,38 @@ func buildText(resp *models.OllamaVisionResponse, cfg *Config) models.TextResult
 		return text
 	}
 
-	text.Raw = resp.Text.Raw
+	var watermarks models.Watermarks
+	if cfg.FilterWatermarks {
+		watermarks = resp.Watermarks
+		text.Watermarks = slices.Clone(watermarks)
+	}
+
+	text.Raw = normalizeWhitespace(sanitize(removeWatermarkLines(resp.Text.Raw, watermarks), cfg), cfg)
+	if cfg.DejoinHyphens {
+		text.Raw = utils.DejoinHyphens(text.Raw)
+	}
+	text.Raw = normalizeLineEndings(text.Raw, cfg)
+
+	for i, line := range resp.Text.Lines {
+		if watermarks.Contains(line.Text) {
+			continue
+		}
+		lineText := normalizeLineEndings(normalizeWhitespace(sanitize(line.Text, cfg), cfg), cfg)
+		if !cfg.KeepEmptyLines && strings.TrimSpace(lineText) == "" {
+			text.DroppedEmptyLines++
+			continue
+		}
+		if cfg.MaxLines > 0 && len(text.Lines) == cfg.MaxLines {
+			text.Truncated = true
+			break
+		}
 
-	for _, line := range resp.Text.Lines {
 		tl := models.TextLine{
-			Text:       line.Text,
-			Confidence: line.Confidence,
+			Text:       lineText,
+			Confidence: calibrate(float64(line.Confidence), cfg),
+			Region:     line.Region,
+			LineNumber: i + 1,
+			PageNumber: line.PageNumber,
 		}
 
 		if cfg.WithBoundingBoxes && line.BoundingBox != nil {
@@ -287,6 +1447,26 @@ func buildText(resp *models.OllamaVisionResponse, cfg *Config) models.TextResult
 	return text
 }
 
+// removeWatermarkLines drops the lines of raw that match one of watermarks.
+func removeWatermarkLines(raw string, watermarks models.Watermarks) string {
+	if len(watermarks) == 0 {
+		return raw
+	}
+	lines := strings.SplitAfter(raw, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !watermarks.Contains(line) {
+			kept = append(kept, line)
+		}
+	}
+	out := strings.Join(kept, "")
+	if !strings.HasSuffix(raw, "\n") {
+		// If the last line was a watermark, drop the break before it
+		out = strings.TrimSuffix(out, "\n")
+	}
+	return out
+}
+
 func buildStructuredData(resp *models.OllamaVisionResponse, cfg *Config) models.StructuredData {
 	sd := models.StructuredData{
 		KeyValuePairs: make(map[string]string),
@@ -296,6 +1476,7 @@ func buildStructuredData(resp *models.OllamaVisionResponse, cfg *Config) models.
 	if !cfg.WithStructuredExtraction || resp.StructuredData == nil {
 		return sd
 	}
+	sd.Extracted = true
 
 	if resp.StructuredData.KeyValuePairs != nil {
 		sd.KeyValuePairs = resp.StructuredData.KeyValuePairs
@@ -305,17 +1486,446 @@ func buildStructuredData(resp *models.OllamaVisionResponse, cfg *Config) models.
 		sd.Tables = resp.StructuredData.Tables
 	}
 
+	if cfg.KeyValueConfidence && cfg.WithConfidenceScores {
+		sd.KeyValueDetails = buildKeyValueDetails(sd.KeyValuePairs, resp.StructuredData.KeyValueConfidence, cfg)
+	}
+
+	if cfg.WithBoundingBoxes {
+		sd.KeyValueBoxes = buildKeyValueBoxes(resp.StructuredData.KeyValueBoxes, cfg)
+	}
+	sd.Tables = copyTableBoxes(sd.Tables, cfg.WithBoundingBoxes)
+	if !cfg.TableSpans {
+		for i := range sd.Tables {
+			sd.Tables[i].Spans = nil
+		}
+	}
+	for i := range sd.Tables {
+		sd.Tables[i].Confidence = calibrate(sd.Tables[i].Confidence, cfg)
+	}
+	if cfg.MinTableConfidence > 0 && cfg.WithConfidenceScores {
+		sd.Tables = models.FilterTables(sd.Tables, cfg.MinTableConfidence)
+	}
+
+	if cfg.SanitizeText {
+		sd.KeyValuePairs = sanitizeKeyValuePairs(sd.KeyValuePairs)
+		sd.Tables = sanitizeTables(sd.Tables)
+	}
+
+	if cfg.TableMergePolicy != "" {
+		sd.Tables = normalizeMergedCells(sd.Tables, cfg.TableMergePolicy)
+	}
+
 	return sd
 }
 
+// normalizeMergedCells returns a copy of tables with merged cells rewritten
+// according to policy.
+func normalizeMergedCells(tables []models.Table, policy TableMergePolicy) []models.Table {
+	out := make([]models.Table, len(tables))
+	for i, t := range tables {
+		switch policy {
+		case TableMergeFillDown:
+			out[i] = utils.FillDownMergedCells(t)
+		case TableMergeEmpty:
+			out[i] = utils.EmptyMergedCells(t)
+		case TableMergeMark:
+			out[i] = utils.MarkMergedCells(t, MergedCellMarker)
+		default:
+			out[i] = t
+		}
+	}
+	return out
+}
+
+// mergeFormFields adds PDF form field values to sd, replacing OCR values
+// under the same key, and records the source of every key.
+func mergeFormFields(sd *models.StructuredData, fields map[string]string, cfg *Config) {
+	sd.KeyValueSources = make(map[string]models.KeyValueSource, len(sd.KeyValuePairs)+len(fields))
+	for k := range sd.KeyValuePairs {
+		sd.KeyValueSources[k] = models.KeyValueSourceOCR
+	}
+	for k, v := range fields {
+		key := sanitize(k, cfg)
+		if key == "" {
+			continue
+		}
+		value := sanitize(v, cfg)
+		sd.KeyValuePairs[key] = value
+		sd.KeyValueSources[key] = models.KeyValueSourceForm
+		if sd.KeyValueDetails != nil {
+			// Form values are read, not recognized
+			sd.KeyValueDetails[key] = models.KeyValueDetail{Value: value, Confidence: 1}
+		}
+	}
+}
+
+// orderedKeyValues lists the pairs of kv in order, the model's keys sanitized
+// the same way as kv, followed by any remaining keys sorted.
+func orderedKeyValues(kv map[string]string, order []string, cfg *Config) []models.KeyValue {
+	if len(kv) == 0 {
+		return nil
+	}
+	out := make([]models.KeyValue, 0, len(kv))
+	seen := make(map[string]bool, len(kv))
+	for _, k := range order {
+		k = sanitize(k, cfg)
+		v, ok := kv[k]
+		if !ok || seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, models.KeyValue{Key: k, Value: v})
+	}
+	for _, k := range slices.Sorted(maps.Keys(kv)) {
+		if !seen[k] {
+			out = append(out, models.KeyValue{Key: k, Value: kv[k]})
+		}
+	}
+	return out
+}
+
+// buildKeyValueDetails pairs each key-value pair with its confidence. Keys
+// and values are sanitized the same way as KeyValuePairs.
+func buildKeyValueDetails(kv map[string]string, confidence map[string]models.Confidence, cfg *Config) map[string]models.KeyValueDetail {
+	details := make(map[string]models.KeyValueDetail, len(kv))
+	for k, v := range kv {
+		key := sanitize(k, cfg)
+		if key == "" {
+			continue
+		}
+		details[key] = models.KeyValueDetail{
+			Value:      sanitize(v, cfg),
+			Confidence: calibrate(float64(confidence[k]), cfg),
+		}
+	}
+	return details
+}
+
+// buildKeyValueBoxes copies the model's key-value boxes under the same
+// keys as the sanitized key-value pairs.
+func buildKeyValueBoxes(boxes map[string]*models.BoundingBox, cfg *Config) map[string]*models.BoundingBox {
+	if len(boxes) == 0 {
+		return nil
+	}
+	out := make(map[string]*models.BoundingBox, len(boxes))
+	for k, b := range boxes {
+		key := sanitize(k, cfg)
+		if key == "" || b == nil {
+			continue
+		}
+		box := *b
+		out[key] = &box
+	}
+	return out
+}
+
+// copyTableBoxes returns tables with their boxes copied, so they can be
+// converted without touching the model response, or removed if keep is
+// false.
+func copyTableBoxes(tables []models.Table, keep bool) []models.Table {
+	if tables == nil {
+		return nil
+	}
+	out := make([]models.Table, len(tables))
+	for i, t := range tables {
+		out[i] = t
+		if t.BoundingBox == nil {
+			continue
+		}
+		if !keep {
+			out[i].BoundingBox = nil
+			continue
+		}
+		box := *t.BoundingBox
+		out[i].BoundingBox = &box
+	}
+	return out
+}
+
+// mergeReportedImage fills the fields of info that could not be decoded from
+// the model's image info and returns what the model reported where it
+// differs from info, or nil if it agrees.
+func mergeReportedImage(info *models.ImageInfo, vi *models.OllamaImageInfo) *models.ReportedImage {
+	var reported models.ReportedImage
+	differs := false
+
+	if vi.Width > 0 {
+		if info.Width == 0 {
+			info.Width = vi.Width
+		} else if vi.Width != info.Width {
+			reported.Mismatch = true
+		}
+	}
+	if vi.Height > 0 {
+		if info.Height == 0 {
+			info.Height = vi.Height
+		} else if vi.Height != info.Height {
+			reported.Mismatch = true
+		}
+	}
+	if vi.DPI != nil {
+		if info.DPI == nil {
+			info.DPI = vi.DPI
+		} else if *vi.DPI != *info.DPI {
+			reported.DPI = vi.DPI
+			differs = true
+		}
+	}
+	if cm := models.ColorMode(vi.ColorMode); cm != models.ColorModeUnknown && utils.ValidColorModes[cm] {
+		if info.ColorMode == "" || info.ColorMode == models.ColorModeUnknown {
+			info.ColorMode = cm
+		} else if cm != info.ColorMode {
+			reported.ColorMode = cm
+			differs = true
+		}
+	}
+
+	if !reported.Mismatch && !differs {
+		return nil
+	}
+	if reported.Mismatch {
+		reported.Width, reported.Height = vi.Width, vi.Height
+	}
+	return &reported
+}
+
 func buildSummary(resp *models.OllamaVisionResponse, cfg *Config) *string {
-	if !cfg.WithSummary {
+	if !cfg.WithSummary || resp.Summary == nil {
+		return nil
+	}
+	summary := sanitize(*resp.Summary, cfg)
+	if cfg.SummaryMaxWords > 0 {
+		summary = utils.TruncateWords(summary, cfg.SummaryMaxWords)
+	}
+	return &summary
+}
+
+// normalizeLineEndings applies cfg.LineEndings to s.
+func normalizeLineEndings(s string, cfg *Config) string {
+	switch cfg.LineEndings {
+	case LineEndingsLF:
+		return utils.NormalizeLineEndings(s, "\n")
+	case LineEndingsCRLF:
+		return utils.NormalizeLineEndings(s, "\r\n")
+	}
+	return s
+}
+
+// normalizeWhitespace tidies the spacing of s when cfg.NormalizeWhitespace is
+// on.
+func normalizeWhitespace(s string, cfg *Config) string {
+	if !cfg.NormalizeWhitespace {
+		return s
+	}
+	return utils.NormalizeWhitespace(s)
+}
+
+// calibrate maps a model confidence through cfg.ConfidenceCalibrator and
+// clamps the result to [0, 1]. 0 means the model reported no confidence and
+// is left alone.
+func calibrate(c float64, cfg *Config) float64 {
+	if cfg.ConfidenceCalibrator == nil || c == 0 {
+		return c
+	}
+	return min(max(cfg.ConfidenceCalibrator(c), 0), 1)
+}
+
+// sanitize strips invalid UTF-8 and control characters from s when
+// cfg.SanitizeText is on.
+func sanitize(s string, cfg *Config) string {
+	if !cfg.SanitizeText {
+		return s
+	}
+	return utils.SanitizeString(s)
+}
+
+// sanitizeKeyValuePairs returns a copy of kv with keys and values sanitized.
+// Keys that become empty are dropped; keys that collide keep the last value
+// in iteration order.
+func sanitizeKeyValuePairs(kv map[string]string) map[string]string {
+	out := make(map[string]string, len(kv))
+	for k, v := range kv {
+		k = utils.SanitizeString(k)
+		if k == "" {
+			continue
+		}
+		out[k] = utils.SanitizeString(v)
+	}
+	return out
+}
+
+// sanitizedCopy returns a sanitized copy of ss, preserving nil.
+func sanitizedCopy(ss []string) []string {
+	if ss == nil {
+		return nil
+	}
+	out := make([]string, len(ss))
+	copy(out, ss)
+	utils.SanitizeStrings(out)
+	return out
+}
+
+// sanitizeTables returns a copy of tables with every cell sanitized, leaving
+// the model response untouched.
+func sanitizeTables(tables []models.Table) []models.Table {
+	out := make([]models.Table, len(tables))
+	for i, t := range tables {
+		table := models.Table{Headers: sanitizedCopy(t.Headers), BoundingBox: t.BoundingBox, Confidence: t.Confidence, Spans: t.Spans}
+		if t.Rows != nil {
+			table.Rows = make([][]string, len(t.Rows))
+			for j, row := range t.Rows {
+				table.Rows[j] = sanitizedCopy(row)
+			}
+		}
+		out[i] = table
+	}
+	return out
+}
+
+// autoStrips returns the horizontal strips to process the image in, top to
+// bottom, if cfg.AutoStrip is set and the image is more than
+// AutoStripAspectRatio times taller than wide. Otherwise, and for PDFs and
+// crop regions, it returns nil.
+func autoStrips(info models.ImageInfo, ext string, cfg *Config) []models.BoundingBox {
+	if !cfg.AutoStrip || ext == ".pdf" || len(cfg.CropRegions) > 0 || info.Width <= 0 {
+		return nil
+	}
+	if float64(info.Height) <= AutoStripAspectRatio*float64(info.Width) {
+		return nil
+	}
+	return utils.StripRegions(info.Width, info.Height, stripAspectRatio)
+}
+
+// checkMinImageDimension returns ErrImageTooSmall if the image is smaller
+// than cfg.MinImageDimension on either side. PDFs and images whose size could
+// not be determined are not checked.
+func checkMinImageDimension(info models.ImageInfo, ext string, cfg *Config) error {
+	if cfg.MinImageDimension == 0 || ext == ".pdf" || info.Width == 0 || info.Height == 0 {
+		return nil
+	}
+	if info.Width < cfg.MinImageDimension || info.Height < cfg.MinImageDimension {
+		return fmt.Errorf("%w: image is %dx%d pixels, minimum is %d on each side; use a higher-resolution source",
+			ErrImageTooSmall, info.Width, info.Height, cfg.MinImageDimension)
+	}
+	return nil
+}
+
+// checkMaxImagePixels returns ErrImageTooLarge if the image has more than
+// cfg.MaxImagePixels pixels. PDFs and images whose size could not be
+// determined are not checked.
+func checkMaxImagePixels(info models.ImageInfo, ext string, cfg *Config) error {
+	if cfg.MaxImagePixels == 0 || ext == ".pdf" || info.Width == 0 || info.Height == 0 {
+		return nil
+	}
+	if pixels := int64(info.Width) * int64(info.Height); pixels > cfg.MaxImagePixels {
+		return fmt.Errorf("%w: image is %dx%d (%d pixels), maximum is %d; downscale the source",
+			ErrImageTooLarge, info.Width, info.Height, pixels, cfg.MaxImagePixels)
+	}
+	return nil
+}
+
+// checkExpectedDocumentType returns ErrDocumentTypeMismatch if an expected
+// document type is configured and the result reports a different one.
+func checkExpectedDocumentType(result *models.OCRResult, cfg *Config) error {
+	if cfg.ExpectedDocumentType == "" || result.Metadata.DocumentType == cfg.ExpectedDocumentType {
 		return nil
 	}
-	return resp.Summary
+	return fmt.Errorf("%w: model returned %q, expected %q",
+		ErrDocumentTypeMismatch, result.Metadata.DocumentType, cfg.ExpectedDocumentType)
+}
+
+// checkAllowedLanguage returns ErrLanguageNotAllowed if the result's
+// language is missing or not in cfg.AllowedLanguages.
+func checkAllowedLanguage(result *models.OCRResult, cfg *Config) error {
+	lang := strings.TrimSpace(result.LanguageCode())
+	if lang == "" {
+		return fmt.Errorf("%w: no language detected", ErrLanguageNotAllowed)
+	}
+	for _, allowed := range cfg.AllowedLanguages {
+		if languageMatches(lang, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: detected %q, allowed %q", ErrLanguageNotAllowed, lang, cfg.AllowedLanguages)
+}
+
+// languageMatches reports whether the language code lang is allowed by
+// allowed, either exactly or as a regional variant of it.
+func languageMatches(lang, allowed string) bool {
+	lang = strings.ReplaceAll(lang, "_", "-")
+	allowed = strings.ReplaceAll(allowed, "_", "-")
+	if strings.EqualFold(lang, allowed) {
+		return true
+	}
+	primary, _, found := strings.Cut(lang, "-")
+	return found && !strings.Contains(allowed, "-") && strings.EqualFold(primary, allowed)
+}
+
+// structuredDocumentTypes are the document types that almost always contain
+// key-value pairs or tables.
+var structuredDocumentTypes = map[models.DocumentType]bool{
+	models.DocumentTypeInvoice: true,
+	models.DocumentTypeReceipt: true,
+	models.DocumentTypeIDCard:  true,
+}
+
+// checkStructuredData returns a warning if cfg.FlagEmptyStructuredData is set
+// and structured extraction silently yielded nothing: the model omitted
+// structured data, or returned none for a document type that usually has
+// some. It returns "" otherwise.
+func checkStructuredData(result *models.OCRResult, cfg *Config) string {
+	if !cfg.FlagEmptyStructuredData || !cfg.WithStructuredExtraction || cfg.RawJSON {
+		return ""
+	}
+	sd := result.StructuredData
+	if !sd.Extracted {
+		return "model did not return structured data"
+	}
+	if len(sd.KeyValuePairs) == 0 && len(sd.Tables) == 0 && structuredDocumentTypes[result.Metadata.DocumentType] {
+		return fmt.Sprintf("no structured data found in %s", result.Metadata.DocumentType)
+	}
+	return ""
+}
+
+// logOutput is where request logs are written. Tests replace it.
+var logOutput io.Writer = os.Stderr
+
+// newLogger creates the structured logger for one request. It logs at info
+// level, or at debug level if cfg.DebugRequestLog is set so the request log
+// is shown.
+func newLogger(requestID string, cfg *Config) *slog.Logger {
+	level := slog.LevelInfo
+	if cfg.DebugRequestLog {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewJSONHandler(logOutput, &slog.HandlerOptions{
+		Level: level,
+	}))
+	return logger.With(
+		slog.String("request_id", requestID),
+		slog.String("model", cfg.Model),
+	)
+}
+
+// normalizeExtension lower-cases ext, adds a leading dot if missing and
+// checks that the format is supported.
+func normalizeExtension(ext string) (string, error) {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if !utils.SupportedExtensions[ext] {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, ext)
+	}
+	return ext, nil
 }
 
-// generateRequestID creates a unique request ID using timestamp + random component.
-func generateRequestID() string {
-	return fmt.Sprintf("ocr-%d", time.Now().UnixNano())
+// generateRequestID creates a request ID from the current time of cfg.Clock,
+// or of time.Now if it is nil.
+func generateRequestID(cfg *Config) string {
+	now := time.Now
+	if cfg.Clock != nil {
+		now = cfg.Clock
+	}
+	return fmt.Sprintf("ocr-%d", now().UnixNano())
 }
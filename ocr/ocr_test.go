@@ -0,0 +1,2966 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/image/tiff"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/engine"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/prompt"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// testPNG returns a small PNG with some non-uniform content.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x * y) % 256)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckAllowedLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		language string
+		wantErr  bool
+	}{
+		{"exact", []string{"de", "en"}, "en", false},
+		{"case-insensitive", []string{"EN"}, "en", false},
+		{"regional variant", []string{"en"}, "en-US", false},
+		{"underscore variant", []string{"en"}, "en_GB", false},
+		{"other region", []string{"en-GB"}, "en-US", true},
+		{"region not widened", []string{"en-US"}, "en", true},
+		{"not allowed", []string{"de", "fr"}, "en", true},
+		{"not detected", []string{"en"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.AllowedLanguages = tt.allowed
+			result := &models.OCRResult{}
+			if tt.language != "" {
+				result.Metadata.Language = &tt.language
+			}
+
+			err := checkAllowedLanguage(result, cfg)
+			if tt.wantErr != errors.Is(err, ErrLanguageNotAllowed) || (!tt.wantErr && err != nil) {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractBytes_AllowedLanguages(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+	data := testPNG(t)
+
+	result, err := ExtractBytes(context.Background(), data, ".png", WithOllamaURL(url),
+		WithAllowedLanguages([]string{"en"}), WithStrictMode(true))
+	if err != nil {
+		t.Fatalf("allowed language: %v", err)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none for an allowed language", result.Warnings)
+	}
+
+	_, err = ExtractBytes(context.Background(), data, ".png", WithOllamaURL(url),
+		WithAllowedLanguages([]string{"de", "fr"}), WithStrictMode(true))
+	if !errors.Is(err, ErrLanguageNotAllowed) {
+		t.Fatalf("err = %v, want ErrLanguageNotAllowed in strict mode", err)
+	}
+
+	result, err = ExtractBytes(context.Background(), data, ".png", WithOllamaURL(url),
+		WithAllowedLanguages([]string{"de", "fr"}))
+	if err != nil {
+		t.Fatalf("lenient mode: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], `detected "en"`) {
+		t.Errorf("Warnings = %v, want one disallowed-language warning", result.Warnings)
+	}
+
+	result, err = ExtractBytes(context.Background(), data, ".png", WithOllamaURL(url),
+		WithAllowedLanguages([]string{"de"}), WithLanguageDetection(false), WithStrictMode(true))
+	if err != nil {
+		t.Fatalf("without language detection: %v", err)
+	}
+	if !slices.Contains(result.Warnings, "allowed languages require language detection") {
+		t.Errorf("Warnings = %v, want a missing-detection warning", result.Warnings)
+	}
+}
+
+func TestCheckExpectedDocumentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected models.DocumentType
+		actual   models.DocumentType
+		wantErr  bool
+	}{
+		{"not configured", "", models.DocumentTypeContract, false},
+		{"match", models.DocumentTypeReceipt, models.DocumentTypeReceipt, false},
+		{"mismatch", models.DocumentTypeReceipt, models.DocumentTypeContract, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ExpectedDocumentType = tt.expected
+			result := &models.OCRResult{Metadata: models.Metadata{DocumentType: tt.actual}}
+
+			err := checkExpectedDocumentType(result, cfg)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDocumentTypeMismatch) {
+					t.Errorf("err = %v, want ErrDocumentTypeMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractBytes_ExpectedDocumentTypeMismatch(t *testing.T) {
+	// The fake model sees a receipt although an invoice is expected.
+	srv := ollamatest.NewServer(t, nil)
+
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL),
+		WithExpectedDocumentType(models.DocumentTypeInvoice), WithStrictMode(true))
+	if !errors.Is(err, ErrDocumentTypeMismatch) {
+		t.Fatalf("strict mode: err = %v, want ErrDocumentTypeMismatch", err)
+	}
+	if prompt := srv.Requests()[0].Prompt; !strings.Contains(prompt, `expected to be of type "invoice"`) ||
+		strings.Contains(prompt, `Set "document_type" to "invoice"`) {
+		t.Error("prompt should give the expected type as context without forcing it")
+	}
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL),
+		WithExpectedDocumentType(models.DocumentTypeInvoice))
+	if err != nil {
+		t.Fatalf("without strict mode: %v", err)
+	}
+	if len(result.Warnings) == 0 || !strings.Contains(result.Warnings[0], "document type") {
+		t.Errorf("Warnings = %q, want a document type mismatch", result.Warnings)
+	}
+}
+
+func TestNormalizeBoundingBoxes(t *testing.T) {
+	image := models.ImageInfo{Width: 1000, Height: 500}
+
+	tests := []struct {
+		name      string
+		requested models.BoundingBoxUnits
+		box       models.BoundingBox
+		wantBox   models.BoundingBox
+		wantUnits models.BoundingBoxUnits
+	}{
+		{
+			name:      "pixels to normalized",
+			requested: models.BoundingBoxUnitsNormalized,
+			box:       models.BoundingBox{X: 100, Y: 50, Width: 500, Height: 25},
+			wantBox:   models.BoundingBox{X: 0.1, Y: 0.1, Width: 0.5, Height: 0.05},
+			wantUnits: models.BoundingBoxUnitsNormalized,
+		},
+		{
+			name:      "normalized to pixels",
+			requested: models.BoundingBoxUnitsPixels,
+			box:       models.BoundingBox{X: 0.1, Y: 0.1, Width: 0.5, Height: 0.05},
+			wantBox:   models.BoundingBox{X: 100, Y: 50, Width: 500, Height: 25},
+			wantUnits: models.BoundingBoxUnitsPixels,
+		},
+		{
+			name:      "not requested keeps model units",
+			requested: "",
+			box:       models.BoundingBox{X: 0.1, Y: 0.1, Width: 0.5, Height: 0.05},
+			wantBox:   models.BoundingBox{X: 0.1, Y: 0.1, Width: 0.5, Height: 0.05},
+			wantUnits: models.BoundingBoxUnitsNormalized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.BoundingBoxUnits = tt.requested
+			box := tt.box
+			text := models.TextResult{Lines: []models.TextLine{{Text: "a", BoundingBox: &box}, {Text: "b"}}}
+			tableBox, kvBox := tt.box, tt.box
+			sd := models.StructuredData{
+				Tables:        []models.Table{{BoundingBox: &tableBox}, {}},
+				KeyValueBoxes: map[string]*models.BoundingBox{"total": &kvBox},
+			}
+
+			normalizeBoundingBoxes(&text, &sd, nil, image, cfg)
+
+			if *text.Lines[0].BoundingBox != tt.wantBox {
+				t.Errorf("box = %+v, want %+v", *text.Lines[0].BoundingBox, tt.wantBox)
+			}
+			if *sd.Tables[0].BoundingBox != tt.wantBox || *sd.KeyValueBoxes["total"] != tt.wantBox {
+				t.Errorf("table box = %+v, key-value box = %+v; want %+v",
+					*sd.Tables[0].BoundingBox, *sd.KeyValueBoxes["total"], tt.wantBox)
+			}
+			if text.Lines[1].BoundingBox != nil {
+				t.Error("line without a box should stay without a box")
+			}
+			if text.BoundingBoxUnits != tt.wantUnits {
+				t.Errorf("BoundingBoxUnits = %q, want %q", text.BoundingBoxUnits, tt.wantUnits)
+			}
+		})
+	}
+}
+
+func TestNormalizeBoundingBoxes_UnknownDimensions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BoundingBoxUnits = models.BoundingBoxUnitsNormalized
+	box := models.BoundingBox{X: 100, Y: 50, Width: 500, Height: 25}
+	text := models.TextResult{Lines: []models.TextLine{{Text: "a", BoundingBox: &box}}}
+
+	normalizeBoundingBoxes(&text, &models.StructuredData{}, nil, models.ImageInfo{}, cfg)
+
+	if *text.Lines[0].BoundingBox != box {
+		t.Errorf("box = %+v, want unchanged %+v", *text.Lines[0].BoundingBox, box)
+	}
+	if text.BoundingBoxUnits != models.BoundingBoxUnitsPixels {
+		t.Errorf("BoundingBoxUnits = %q, want detected %q", text.BoundingBoxUnits, models.BoundingBoxUnitsPixels)
+	}
+}
+
+// dirtyVisionResponse is model output carrying invalid UTF-8 and control
+// characters in every string field.
+func dirtyVisionResponse() *models.OllamaVisionResponse {
+	summary := "A receipt\x00 \xff"
+	return &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Raw:   "ACME\x1b Store\n\xc3Total",
+			Lines: []models.OllamaTextLine{{Text: "ACME\x07 Store"}},
+		},
+		StructuredData: &models.OllamaStructuredData{
+			KeyValuePairs: map[string]string{"tot\xffal": "12\x00.50", "\x01": "dropped"},
+			Tables: []models.Table{{
+				Headers: []string{"Item\x02"},
+				Rows:    [][]string{{"Milk\xfe"}},
+			}},
+		},
+		Summary: &summary,
+	}
+}
+
+func TestBuildOCRResult_SanitizesText(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WithSummary = true
+	result := buildOCRResult("receipt.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: dirtyVisionResponse()}, cfg)
+
+	if result.Text.Raw != "ACME Store\nTotal" {
+		t.Errorf("Raw = %q", result.Text.Raw)
+	}
+	if result.Text.Lines[0].Text != "ACME Store" {
+		t.Errorf("Lines[0].Text = %q", result.Text.Lines[0].Text)
+	}
+	if len(result.StructuredData.KeyValuePairs) != 1 || result.StructuredData.KeyValuePairs["total"] != "12.50" {
+		t.Errorf("KeyValuePairs = %q, want only total=12.50", result.StructuredData.KeyValuePairs)
+	}
+	table := result.StructuredData.Tables[0]
+	if table.Headers[0] != "Item" || table.Rows[0][0] != "Milk" {
+		t.Errorf("Table = %+v", table)
+	}
+	if result.Summary == nil || *result.Summary != "A receipt " {
+		t.Errorf("Summary = %v", result.Summary)
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !utf8.Valid(out) {
+		t.Error("serialized result is not valid UTF-8")
+	}
+}
+
+func TestBuildOCRResult_SanitizeDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SanitizeText = false
+	resp := dirtyVisionResponse()
+	result := buildOCRResult("receipt.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+
+	if result.Text.Raw != resp.Text.Raw {
+		t.Errorf("Raw = %q, want untouched %q", result.Text.Raw, resp.Text.Raw)
+	}
+	if _, ok := result.StructuredData.KeyValuePairs["\x01"]; !ok {
+		t.Error("key value pairs should be untouched when sanitization is disabled")
+	}
+}
+
+func TestBuildOCRResult_QualityReport(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Lines: []models.OllamaTextLine{{Text: "a", Confidence: 0.9}, {Text: "b", Confidence: 0.3}},
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.QualityReport = true
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.Quality == nil || result.Quality.LineCount != 2 {
+		t.Fatalf("Quality = %+v, want report over 2 lines", result.Quality)
+	}
+
+	cfg.WithConfidenceScores = false
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.Quality != nil {
+		t.Error("Quality should be omitted without confidence scores")
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want one warning", result.Warnings)
+	}
+}
+
+func TestBuildOCRResult_LineEndings(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Raw:   "ACME Store\r\nTOTAL\n9.99\r",
+			Lines: []models.OllamaTextLine{{Text: "TOTAL\r\n9.99"}},
+		},
+	}
+
+	tests := []struct {
+		endings  LineEndings
+		wantRaw  string
+		wantLine string
+	}{
+		{LineEndingsLF, "ACME Store\nTOTAL\n9.99\n", "TOTAL\n9.99"},
+		{LineEndingsCRLF, "ACME Store\r\nTOTAL\r\n9.99\r\n", "TOTAL\r\n9.99"},
+		{LineEndingsPreserve, resp.Text.Raw, "TOTAL\r\n9.99"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.endings), func(t *testing.T) {
+			cfg := DefaultConfig()
+			WithLineEndings(tt.endings)(cfg)
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+				&engine.ProcessResult{VisionResponse: resp}, cfg)
+			if result.Text.Raw != tt.wantRaw {
+				t.Errorf("Raw = %q, want %q", result.Text.Raw, tt.wantRaw)
+			}
+			if result.Text.Lines[0].Text != tt.wantLine {
+				t.Errorf("Lines[0].Text = %q, want %q", result.Text.Lines[0].Text, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestBuildOCRResult_LineEndingsMergedPages(t *testing.T) {
+	page := func(raw string) *engine.ProcessResult {
+		return &engine.ProcessResult{VisionResponse: &models.OllamaVisionResponse{Text: &models.OllamaTextResult{Raw: raw}}}
+	}
+	merged := engine.MergeResults([]*engine.ProcessResult{page("a\r\nb"), page("c\nd")}, "Page")
+
+	cfg := DefaultConfig()
+	WithLineEndings(LineEndingsCRLF)(cfg)
+	result := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, merged, cfg)
+
+	want := "--- Page 1 ---\r\na\r\nb\r\n--- Page 2 ---\r\nc\r\nd"
+	if result.Text.Raw != want {
+		t.Errorf("Raw = %q, want %q", result.Text.Raw, want)
+	}
+}
+
+func TestBuildOCRResult_TableMergePolicy(t *testing.T) {
+	newResp := func() *models.OllamaVisionResponse {
+		return &models.OllamaVisionResponse{
+			StructuredData: &models.OllamaStructuredData{
+				Tables: []models.Table{{
+					Headers: []string{"Category", "Item"},
+					Rows:    [][]string{{"Fruit", "Apple"}, {"", "Pear"}, {"Fruit", "Plum"}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		policy TableMergePolicy
+		want   []string
+	}{
+		{"", []string{"Fruit", "", "Fruit"}},
+		{TableMergeFillDown, []string{"Fruit", "Fruit", "Fruit"}},
+		{TableMergeEmpty, []string{"Fruit", "", ""}},
+		{TableMergeMark, []string{"Fruit", MergedCellMarker, MergedCellMarker}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			cfg := DefaultConfig()
+			WithTableMergePolicy(tt.policy)(cfg)
+			resp := newResp()
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+				&engine.ProcessResult{VisionResponse: resp}, cfg)
+
+			rows := result.StructuredData.Tables[0].Rows
+			var got []string
+			for _, row := range rows {
+				got = append(got, row[0])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("first column = %q, want %q", got, tt.want)
+			}
+			if resp.StructuredData.Tables[0].Rows[1][0] != "" {
+				t.Error("model response was modified")
+			}
+		})
+	}
+}
+
+func TestBuildOCRResult_TableMergePolicyKeepsConfidence(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		StructuredData: &models.OllamaStructuredData{
+			Tables: []models.Table{
+				{Headers: []string{"Category", "Item"}, Rows: [][]string{{"Fruit", "Apple"}, {"", "Pear"}}, Confidence: 0.8},
+				{Headers: []string{"Name"}, Rows: [][]string{{"John"}}, Confidence: 0.3},
+			},
+		},
+	}
+	cfg := DefaultConfig()
+	WithTableMergePolicy(TableMergeFillDown)(cfg)
+	WithMinTableConfidence(0.5)(cfg)
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+
+	tables := result.StructuredData.Tables
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables, want 1", len(tables))
+	}
+	if tables[0].Confidence != 0.8 {
+		t.Errorf("Confidence = %v, want 0.8", tables[0].Confidence)
+	}
+	if tables[0].Rows[1][0] != "Fruit" {
+		t.Errorf("Rows[1][0] = %q, want Fruit", tables[0].Rows[1][0])
+	}
+}
+
+func TestBuildOCRResult_MinTableConfidence(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		StructuredData: &models.OllamaStructuredData{
+			Tables: []models.Table{
+				{Headers: []string{"Item", "Price"}, Rows: [][]string{{"Coffee", "3.50"}}, Confidence: 0.95},
+				{Headers: []string{"Name"}, Rows: [][]string{{"John"}}, Confidence: 0.3},
+				{Headers: []string{"Day", "Hours"}, Rows: [][]string{{"Mon", "9-5"}}},
+			},
+		},
+	}
+	headers := func(tables []models.Table) []string {
+		var got []string
+		for _, table := range tables {
+			got = append(got, table.Headers[0])
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    []string
+		warning bool
+	}{
+		{"default keeps all", nil, []string{"Item", "Name", "Day"}, false},
+		{"drops low confidence", []Option{WithMinTableConfidence(0.5)}, []string{"Item", "Day"}, false},
+		{"without confidence scores", []Option{WithMinTableConfidence(0.5), WithConfidenceScores(false)}, []string{"Item", "Name", "Day"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			for _, opt := range tt.opts {
+				opt(cfg)
+			}
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+				&engine.ProcessResult{VisionResponse: resp}, cfg)
+
+			if got := headers(result.StructuredData.Tables); !slices.Equal(got, tt.want) {
+				t.Errorf("tables = %q, want %q", got, tt.want)
+			}
+			if got := slices.Contains(result.Warnings, "table confidence filtering requires confidence scores"); got != tt.warning {
+				t.Errorf("Warnings = %q, want warning %v", result.Warnings, tt.warning)
+			}
+		})
+	}
+	if len(resp.StructuredData.Tables) != 3 {
+		t.Error("model response was modified")
+	}
+}
+
+func TestBuildOCRResult_ReflowLines(t *testing.T) {
+	const doc = "Dear customer, thank you for your order. It ships tomorrow. Regards, ACME"
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Raw:   doc,
+			Lines: []models.OllamaTextLine{{Text: doc}},
+		},
+	}
+	build := func(opts ...Option) models.TextResult {
+		cfg := DefaultConfig()
+		for _, opt := range opts {
+			opt(cfg)
+		}
+		return buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+			&engine.ProcessResult{VisionResponse: resp}, cfg).Text
+	}
+	texts := func(lines []models.TextLine) []string {
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.Text)
+		}
+		return got
+	}
+
+	if got := texts(build().Lines); !slices.Equal(got, []string{doc}) {
+		t.Errorf("default lines = %q, want the line unchanged", got)
+	}
+
+	text := build(WithReflowLines(40))
+	want := []string{"Dear customer, thank you for your order.", "It ships tomorrow. Regards, ACME"}
+	if got := texts(text.Lines); !slices.Equal(got, want) {
+		t.Errorf("reflowed lines = %q, want %q", got, want)
+	}
+	if text.Raw != doc {
+		t.Errorf("Raw = %q, want it unchanged", text.Raw)
+	}
+	if text.Lines[1].LineNumber != 1 {
+		t.Errorf("LineNumber = %d, want the original line's 1", text.Lines[1].LineNumber)
+	}
+
+	text = build(WithReflowLines(40), WithMaxLines(1))
+	if got := texts(text.Lines); !slices.Equal(got, want[:1]) || !text.Truncated {
+		t.Errorf("lines = %q (truncated %v), want %q truncated", got, text.Truncated, want[:1])
+	}
+}
+
+func TestBuildOCRResult_MaxLines(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Raw:   "a\nb\nc",
+			Lines: []models.OllamaTextLine{{Text: "a"}, {Text: "b"}, {Text: "c"}},
+		},
+	}
+
+	tests := []struct {
+		maxLines      int
+		wantLines     int
+		wantTruncated bool
+	}{
+		{0, 3, false},
+		{2, 2, true},
+		{3, 3, false},
+		{4, 3, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		WithMaxLines(tt.maxLines)(cfg)
+		result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+			&engine.ProcessResult{VisionResponse: resp}, cfg)
+		if len(result.Text.Lines) != tt.wantLines || result.Text.Truncated != tt.wantTruncated {
+			t.Errorf("MaxLines %d: %d lines, truncated %v; want %d, %v",
+				tt.maxLines, len(result.Text.Lines), result.Text.Truncated, tt.wantLines, tt.wantTruncated)
+		}
+		if result.Text.Raw != "a\nb\nc" {
+			t.Errorf("MaxLines %d: Raw = %q, want it complete", tt.maxLines, result.Text.Raw)
+		}
+	}
+}
+
+func TestBuildOCRResult_FreeformDocumentType(t *testing.T) {
+	tests := []struct {
+		reported string
+		strict   models.DocumentType
+		freeform models.DocumentType
+	}{
+		{"invoice", models.DocumentTypeInvoice, models.DocumentTypeInvoice},
+		{" Receipt ", models.DocumentTypeUnknown, models.DocumentTypeReceipt},
+		{"bank_statement", models.DocumentTypeUnknown, "bank_statement"},
+		{"  ", models.DocumentTypeUnknown, models.DocumentTypeUnknown},
+	}
+	for _, tt := range tests {
+		resp := &models.OllamaVisionResponse{Metadata: &models.OllamaMetadata{DocumentType: tt.reported}}
+		for _, freeform := range []bool{false, true} {
+			want := tt.strict
+			if freeform {
+				want = tt.freeform
+			}
+			cfg := DefaultConfig()
+			WithAllowFreeformDocumentType(freeform)(cfg)
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "abc", models.ImageInfo{ColorMode: models.ColorModeRGB},
+				&engine.ProcessResult{VisionResponse: resp}, cfg)
+			if result.Metadata.DocumentType != want {
+				t.Errorf("%q with freeform %v: DocumentType = %q, want %q",
+					tt.reported, freeform, result.Metadata.DocumentType, want)
+			}
+		}
+	}
+}
+
+func TestBuildOCRResult_EmptyLines(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Raw:   "a\n\nb",
+			Lines: []models.OllamaTextLine{{Text: "a"}, {Text: ""}, {Text: " \t\u00a0"}, {Text: "b"}, {Text: "\n"}},
+		},
+	}
+
+	cfg := DefaultConfig()
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "abc", models.ImageInfo{ColorMode: models.ColorModeRGB},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	var got []string
+	for _, l := range result.Text.Lines {
+		got = append(got, fmt.Sprintf("%d:%s", l.LineNumber, l.Text))
+	}
+	if want := []string{"1:a", "4:b"}; !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if result.Text.DroppedEmptyLines != 3 {
+		t.Errorf("DroppedEmptyLines = %d, want 3", result.Text.DroppedEmptyLines)
+	}
+	if err := utils.ValidateOCRResult(result); err != nil {
+		t.Errorf("ValidateOCRResult: %v", err)
+	}
+
+	// Dropped lines do not count towards MaxLines
+	WithMaxLines(2)(cfg)
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if len(result.Text.Lines) != 2 || result.Text.Truncated {
+		t.Errorf("MaxLines 2: %d lines, truncated %v; want 2, false", len(result.Text.Lines), result.Text.Truncated)
+	}
+
+	WithKeepEmptyLines(true)(cfg)
+	WithMaxLines(0)(cfg)
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if len(result.Text.Lines) != 5 || result.Text.DroppedEmptyLines != 0 {
+		t.Errorf("KeepEmptyLines: %d lines, %d dropped; want 5, 0", len(result.Text.Lines), result.Text.DroppedEmptyLines)
+	}
+}
+
+func TestBuildOCRResult_ReportedImage(t *testing.T) {
+	dpi72, dpi300 := 72, 300
+	decoded := models.ImageInfo{Width: 200, Height: 100, DPI: &dpi72, ColorMode: models.ColorModeGrayscale}
+
+	tests := []struct {
+		name         string
+		decoded      models.ImageInfo
+		reported     *models.OllamaImageInfo
+		wantImage    models.ImageInfo
+		wantReported *models.ReportedImage
+	}{
+		{
+			name:      "wrong dimensions",
+			decoded:   decoded,
+			reported:  &models.OllamaImageInfo{Width: 1024, Height: 768},
+			wantImage: decoded,
+			wantReported: &models.ReportedImage{
+				Width: 1024, Height: 768, Mismatch: true,
+			},
+		},
+		{
+			name:      "wrong DPI and color mode",
+			decoded:   decoded,
+			reported:  &models.OllamaImageInfo{Width: 200, Height: 100, DPI: &dpi300, ColorMode: "RGB"},
+			wantImage: decoded,
+			wantReported: &models.ReportedImage{
+				DPI: &dpi300, ColorMode: models.ColorModeRGB,
+			},
+		},
+		{
+			name:      "agrees",
+			decoded:   decoded,
+			reported:  &models.OllamaImageInfo{Width: 200, Height: 100, ColorMode: "Unknown"},
+			wantImage: decoded,
+		},
+		{
+			name:      "fills what could not be decoded",
+			decoded:   models.ImageInfo{ColorMode: models.ColorModeUnknown},
+			reported:  &models.OllamaImageInfo{Width: 612, Height: 792, DPI: &dpi72, ColorMode: "CMYK"},
+			wantImage: models.ImageInfo{Width: 612, Height: 792, DPI: &dpi72, ColorMode: models.ColorModeCMYK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &models.OllamaVisionResponse{Image: tt.reported}
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "", tt.decoded,
+				&engine.ProcessResult{VisionResponse: resp}, DefaultConfig())
+			if !reflect.DeepEqual(result.Image, tt.wantImage) {
+				t.Errorf("Image = %+v, want %+v", result.Image, tt.wantImage)
+			}
+			if !reflect.DeepEqual(result.ReportedImage, tt.wantReported) {
+				t.Errorf("ReportedImage = %+v, want %+v", result.ReportedImage, tt.wantReported)
+			}
+			mismatch := tt.wantReported != nil && tt.wantReported.Mismatch
+			if warned := slices.ContainsFunc(result.Warnings, func(w string) bool {
+				return strings.Contains(w, "model reported a")
+			}); warned != mismatch {
+				t.Errorf("Warnings = %q, want a size warning: %v", result.Warnings, mismatch)
+			}
+		})
+	}
+}
+
+func TestBuildOCRResult_MaxLinesMergedPages(t *testing.T) {
+	page := func(lines ...string) *engine.ProcessResult {
+		text := &models.OllamaTextResult{Raw: strings.Join(lines, "\n")}
+		for _, l := range lines {
+			text.Lines = append(text.Lines, models.OllamaTextLine{Text: l})
+		}
+		return &engine.ProcessResult{VisionResponse: &models.OllamaVisionResponse{Text: text}}
+	}
+	merged := engine.MergeResults([]*engine.ProcessResult{page("a", "b"), page("c", "d")}, "Page")
+
+	cfg := DefaultConfig()
+	WithMaxLines(3)(cfg)
+	result := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, merged, cfg)
+
+	if len(result.Text.Lines) != 3 || result.Text.Lines[2].Text != "c" || !result.Text.Truncated {
+		t.Errorf("Lines = %+v, truncated %v; want the first 3 lines across pages", result.Text.Lines, result.Text.Truncated)
+	}
+	if !strings.Contains(result.Text.Raw, "d") {
+		t.Errorf("Raw = %q, want every page", result.Text.Raw)
+	}
+}
+
+func TestBuildOCRResult_LineNumbersMergedPages(t *testing.T) {
+	// The last line of page 1 and the first line of page 2 sit at the same
+	// spot on their pages, so they must not be merged into one line.
+	page := func(n int, lines ...string) *engine.ProcessResult {
+		text := &models.OllamaTextResult{Raw: strings.Join(lines, "\n")}
+		for i, l := range lines {
+			text.Lines = append(text.Lines, models.OllamaTextLine{
+				Text:        l,
+				BoundingBox: &models.BoundingBox{X: float64(10 + 60*i), Y: 10, Width: 50, Height: 20},
+				PageNumber:  n,
+			})
+		}
+		return &engine.ProcessResult{VisionResponse: &models.OllamaVisionResponse{Text: text}}
+	}
+	merged := engine.MergeResults([]*engine.ProcessResult{page(1, "Grand", "Total"), page(2, "Page", "two")}, "Page")
+
+	cfg := DefaultConfig()
+	cfg.MergeAdjacentLines = true
+	result := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, merged, cfg)
+
+	want := []models.TextLine{
+		{Text: "Grand Total", LineNumber: 1, PageNumber: 1},
+		{Text: "Page two", LineNumber: 3, PageNumber: 2},
+	}
+	if len(result.Text.Lines) != len(want) {
+		t.Fatalf("Lines = %+v, want %d lines", result.Text.Lines, len(want))
+	}
+	for i, w := range want {
+		got := result.Text.Lines[i]
+		if got.Text != w.Text || got.LineNumber != w.LineNumber || got.PageNumber != w.PageNumber {
+			t.Errorf("line %d = %q (line %d, page %d), want %q (line %d, page %d)",
+				i, got.Text, got.LineNumber, got.PageNumber, w.Text, w.LineNumber, w.PageNumber)
+		}
+	}
+}
+
+func TestBuildOCRResult_StructuredDataExtracted(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want bool
+	}{
+		{"found", `{"structured_data":{"key_value_pairs":{"total":"9.99"},"tables":[]}}`, true},
+		{"none found", `{"structured_data":{"key_value_pairs":{},"tables":[]}}`, true},
+		{"empty object", `{"structured_data":{}}`, true},
+		{"null", `{"structured_data":null}`, false},
+		{"missing", `{"text":{"raw":"TOTAL 9.99"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := utils.ParseAndValidateJSON(tt.json)
+			if err != nil {
+				t.Fatalf("ParseAndValidateJSON: %v", err)
+			}
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+				&engine.ProcessResult{VisionResponse: resp}, DefaultConfig())
+			if result.StructuredData.Extracted != tt.want {
+				t.Errorf("Extracted = %v, want %v", result.StructuredData.Extracted, tt.want)
+			}
+		})
+	}
+
+	resp, _ := utils.ParseAndValidateJSON(`{"structured_data":{"key_value_pairs":{"a":"b"}}}`)
+	cfg := DefaultConfig()
+	WithStructuredExtraction(false)(cfg)
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.StructuredData.Extracted {
+		t.Error("Extracted should be false when structured extraction is disabled")
+	}
+}
+
+func TestBuildOCRResult_StructuredBoxes(t *testing.T) {
+	resp, err := utils.ParseAndValidateJSON(`{"structured_data":{
+		"key_value_pairs":{"total":{"value":"9.99","bounding_box":[10,20,30,5]}},
+		"tables":[{"headers":["a"],"rows":[["1"]],"bounding_box":[0,40,100,50]}]}}`)
+	if err != nil {
+		t.Fatalf("ParseAndValidateJSON: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{Width: 200, Height: 100},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	sd := result.StructuredData
+	if got := sd.KeyValueBoxes["total"]; got == nil || *got != (models.BoundingBox{X: 10, Y: 20, Width: 30, Height: 5}) {
+		t.Errorf("KeyValueBoxes[total] = %+v", got)
+	}
+	if got := sd.Tables[0].BoundingBox; got == nil || *got != (models.BoundingBox{X: 0, Y: 40, Width: 100, Height: 50}) {
+		t.Errorf("Tables[0].BoundingBox = %+v", got)
+	}
+
+	WithBoundingBoxes(false)(cfg)
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{Width: 200, Height: 100},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.StructuredData.KeyValueBoxes != nil || result.StructuredData.Tables[0].BoundingBox != nil {
+		t.Errorf("structured boxes should be dropped without WithBoundingBoxes: %+v", result.StructuredData)
+	}
+}
+
+func TestBuildOCRResult_StructuredDataExtractedMergedPages(t *testing.T) {
+	page := func(json string) *engine.ProcessResult {
+		resp, err := utils.ParseAndValidateJSON(json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &engine.ProcessResult{VisionResponse: resp}
+	}
+	none := engine.MergeResults([]*engine.ProcessResult{page(`{"text":{"raw":"a"}}`), page(`{"text":{"raw":"b"}}`)}, "Page")
+	some := engine.MergeResults([]*engine.ProcessResult{page(`{"text":{"raw":"a"}}`), page(`{"structured_data":{}}`)}, "Page")
+
+	if r := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, none, DefaultConfig()); r.StructuredData.Extracted {
+		t.Error("Extracted should be false when no page returned structured data")
+	}
+	if r := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, some, DefaultConfig()); !r.StructuredData.Extracted {
+		t.Error("Extracted should be true when a page returned structured data")
+	}
+}
+
+func TestBuildOCRResult_OrderedKeyValuesMergedPages(t *testing.T) {
+	page := func(json string) *engine.ProcessResult {
+		resp, err := utils.ParseAndValidateJSON(json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &engine.ProcessResult{VisionResponse: resp}
+	}
+	merged := engine.MergeResults([]*engine.ProcessResult{
+		page(`{"structured_data":{"key_value_pairs":{"vendor":"ACME","total":"1"}}}`),
+		page(`{"structured_data":{"key_value_pairs":{"page":"2","total":"9.99"}}}`),
+	}, "Page")
+
+	r := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, merged, DefaultConfig())
+	want := []models.KeyValue{
+		{Key: "vendor", Value: "ACME"},
+		{Key: "total", Value: "9.99"},
+		{Key: "page", Value: "2"},
+	}
+	if got := r.StructuredData.OrderedKeyValues; !reflect.DeepEqual(got, want) {
+		t.Errorf("OrderedKeyValues = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckStructuredData(t *testing.T) {
+	result := func(docType models.DocumentType, extracted bool, kv map[string]string) *models.OCRResult {
+		return &models.OCRResult{
+			Metadata:       models.Metadata{DocumentType: docType},
+			StructuredData: models.StructuredData{KeyValuePairs: kv, Tables: []models.Table{}, Extracted: extracted},
+		}
+	}
+
+	cfg := DefaultConfig()
+	if msg := checkStructuredData(result(models.DocumentTypeInvoice, false, nil), cfg); msg != "" {
+		t.Errorf("warning %q without WithFlagEmptyStructuredData", msg)
+	}
+
+	WithFlagEmptyStructuredData(true)(cfg)
+	tests := []struct {
+		name   string
+		result *models.OCRResult
+		want   string
+	}{
+		{"not returned", result(models.DocumentTypeContract, false, nil), "model did not return structured data"},
+		{"empty invoice", result(models.DocumentTypeInvoice, true, map[string]string{}), "no structured data found in invoice"},
+		{"empty contract", result(models.DocumentTypeContract, true, map[string]string{}), ""},
+		{"invoice with data", result(models.DocumentTypeInvoice, true, map[string]string{"total": "1"}), ""},
+	}
+	for _, tt := range tests {
+		if got := checkStructuredData(tt.result, cfg); got != tt.want {
+			t.Errorf("%s: warning = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildOCRResult_KeyValueDetails(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		StructuredData: &models.OllamaStructuredData{
+			KeyValuePairs:      map[string]string{"total": "9.99", "date\x00": "2024-01-02"},
+			KeyValueConfidence: map[string]models.Confidence{"total": 0.9},
+		},
+	}
+
+	cfg := DefaultConfig()
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.StructuredData.KeyValueDetails != nil {
+		t.Error("KeyValueDetails should be omitted unless requested")
+	}
+
+	cfg.KeyValueConfidence = true
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	details := result.StructuredData.KeyValueDetails
+	if got := details["total"]; got.Value != "9.99" || got.Confidence != 0.9 {
+		t.Errorf("details[total] = %+v, want 9.99 at 0.9", got)
+	}
+	if got, ok := details["date"]; !ok || got.Value != "2024-01-02" || got.Confidence != 0 {
+		t.Errorf("details[date] = %+v, want sanitized key with unknown confidence", got)
+	}
+	if result.StructuredData.KeyValuePairs["total"] != "9.99" {
+		t.Error("plain KeyValuePairs should still be populated")
+	}
+
+	cfg.WithConfidenceScores = false
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.StructuredData.KeyValueDetails != nil {
+		t.Error("KeyValueDetails should be omitted without confidence scores")
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want one warning", result.Warnings)
+	}
+}
+
+func TestBuildOCRResult_ConfidenceCalibrator(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		Metadata: &models.OllamaMetadata{DocumentType: "receipt", ConfidenceScore: 0.9},
+		Text: &models.OllamaTextResult{
+			Lines: []models.OllamaTextLine{{Text: "ACME", Confidence: 0.8}, {Text: "TOTAL"}},
+		},
+		StructuredData: &models.OllamaStructuredData{
+			KeyValuePairs:      map[string]string{"total": "9.99"},
+			KeyValueConfidence: map[string]models.Confidence{"total": 0.95},
+			Tables: []models.Table{
+				{Headers: []string{"a"}, Confidence: 0.7},
+				{Headers: []string{"b"}, Confidence: 0.95},
+			},
+		},
+	}
+	// squash pulls the model's overconfident scores down; 0.7 becomes 0.4
+	squash := func(c float64) float64 { return (c - 0.5) * 2 }
+
+	tests := []struct {
+		name       string
+		calibrator func(float64) float64
+		wantDoc    float64
+		wantLines  []float64
+		wantKV     float64
+		wantTables int
+	}{
+		{"identity", func(c float64) float64 { return c }, 0.9, []float64{0.8, 0}, 0.95, 2},
+		{"squashing", squash, 0.8, []float64{0.6, 0}, 0.9, 1},
+		{"clamped", func(c float64) float64 { return c * 2 }, 1, []float64{1, 0}, 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			WithKeyValueConfidence(true)(cfg)
+			WithMinTableConfidence(0.5)(cfg)
+			WithConfidenceCalibrator(tt.calibrator)(cfg)
+
+			result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+				&engine.ProcessResult{VisionResponse: resp}, cfg)
+
+			near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+			if !near(result.Metadata.ConfidenceScore, tt.wantDoc) {
+				t.Errorf("ConfidenceScore = %v, want %v", result.Metadata.ConfidenceScore, tt.wantDoc)
+			}
+			for i, want := range tt.wantLines {
+				if got := result.Text.Lines[i].Confidence; !near(got, want) {
+					t.Errorf("line %d confidence = %v, want %v", i, got, want)
+				}
+			}
+			if got := result.StructuredData.KeyValueDetails["total"].Confidence; !near(got, tt.wantKV) {
+				t.Errorf("key-value confidence = %v, want %v", got, tt.wantKV)
+			}
+			if got := len(result.StructuredData.Tables); got != tt.wantTables {
+				t.Errorf("tables = %d, want %d kept after calibrated filtering", got, tt.wantTables)
+			}
+		})
+	}
+
+	if resp.Metadata.ConfidenceScore != 0.9 || resp.StructuredData.Tables[0].Confidence != 0.7 {
+		t.Error("calibration should not modify the model response")
+	}
+}
+
+func TestBuildOCRResult_DejoinHyphens(t *testing.T) {
+	raw := "An inter-\nnational, well-known\nstore."
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Raw:   raw,
+			Lines: []models.OllamaTextLine{{Text: "An inter-"}, {Text: "national, well-known"}, {Text: "store."}},
+		},
+	}
+
+	cfg := DefaultConfig()
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if result.Text.Raw != raw {
+		t.Errorf("Raw = %q, want it unchanged by default", result.Text.Raw)
+	}
+
+	WithDejoinHyphens(true)(cfg)
+	WithLineEndings(LineEndingsCRLF)(cfg)
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if want := "An international,\r\nwell-known\r\nstore."; result.Text.Raw != want {
+		t.Errorf("Raw = %q, want %q", result.Text.Raw, want)
+	}
+	if result.Text.Lines[0].Text != "An inter-" {
+		t.Errorf("Lines[0] = %q, want lines unchanged", result.Text.Lines[0].Text)
+	}
+}
+
+func TestBuildOCRResult_MergeAdjacentLines(t *testing.T) {
+	resp := &models.OllamaVisionResponse{
+		Text: &models.OllamaTextResult{
+			Lines: []models.OllamaTextLine{
+				{Text: "Grand", BoundingBox: &models.BoundingBox{X: 10, Y: 10, Width: 50, Height: 20}},
+				{Text: "Total", BoundingBox: &models.BoundingBox{X: 65, Y: 10, Width: 50, Height: 20}},
+			},
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.MergeAdjacentLines = true
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if len(result.Text.Lines) != 1 || result.Text.Lines[0].Text != "Grand Total" {
+		t.Errorf("Lines = %+v, want one merged line", result.Text.Lines)
+	}
+
+	cfg.WithBoundingBoxes = false
+	result = buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, cfg)
+	if len(result.Text.Lines) != 2 || len(result.Warnings) != 1 {
+		t.Errorf("without boxes: Lines = %d, Warnings = %v; want 2 lines and one warning",
+			len(result.Text.Lines), result.Warnings)
+	}
+}
+
+func TestExtractBytes(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+	data := testPNG(t)
+
+	result, err := ExtractBytes(context.Background(), data, "png", WithOllamaURL(url))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Source.Type != models.SourceTypeBytes {
+		t.Errorf("Source.Type = %q, want %q", result.Source.Type, models.SourceTypeBytes)
+	}
+	if result.Source.Checksum == "" {
+		t.Error("Source.Checksum should be set")
+	}
+	if result.Image.Width != 32 {
+		t.Errorf("Image.Width = %d, want 32", result.Image.Width)
+	}
+	if result.Text.Raw != "ACME Store\nTOTAL 9.99" {
+		t.Errorf("Text.Raw = %q, want the canned response text", result.Text.Raw)
+	}
+}
+
+func TestExtractBytes_UnsupportedFormat(t *testing.T) {
+	_, err := ExtractBytes(context.Background(), []byte("data"), ".docx")
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestExtractBytes_TooLarge(t *testing.T) {
+	_, err := ExtractBytes(context.Background(), make([]byte, 2048), ".png", WithMaxFileSize(1024))
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("err = %v, want ErrFileTooLarge", err)
+	}
+}
+
+func TestExtractReader(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+	data := testPNG(t)
+
+	result, err := ExtractReader(context.Background(), bytes.NewReader(data), ".png", WithOllamaURL(url))
+	if err != nil {
+		t.Fatalf("ExtractReader: %v", err)
+	}
+	if result.Source.Type != models.SourceTypeBytes {
+		t.Errorf("Source.Type = %q, want %q", result.Source.Type, models.SourceTypeBytes)
+	}
+	if want := utils.SHA256Bytes(data); result.Source.Checksum != want {
+		t.Errorf("Source.Checksum = %q, want %q", result.Source.Checksum, want)
+	}
+}
+
+func TestExtractFS(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+	data := testPNG(t)
+	fsys := fstest.MapFS{
+		"samples/receipt.PNG": {Data: data},
+		"samples/notes.txt":   {Data: []byte("not an image")},
+		"samples/empty.png":   {},
+	}
+
+	result, err := ExtractFS(context.Background(), fsys, "samples/receipt.PNG", WithOllamaURL(url))
+	if err != nil {
+		t.Fatalf("ExtractFS: %v", err)
+	}
+	if result.Source.Type != models.SourceTypeBytes || result.Source.Path != "samples/receipt.PNG" {
+		t.Errorf("Source = %+v, want bytes from samples/receipt.PNG", result.Source)
+	}
+	if want := utils.SHA256Bytes(data); result.Source.Checksum != want {
+		t.Errorf("Source.Checksum = %q, want %q", result.Source.Checksum, want)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		opts []Option
+		want error
+	}{
+		{"missing file", "samples/missing.png", nil, ErrFileNotFound},
+		{"unsupported extension", "samples/notes.txt", nil, ErrUnsupportedFormat},
+		{"empty file", "samples/empty.png", nil, ErrEmptySource},
+		{"too large", "samples/receipt.PNG", []Option{WithMaxFileSize(int64(len(data) - 1))}, ErrFileTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractFS(context.Background(), fsys, tt.file, append(tt.opts, WithOllamaURL(url))...)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract_Clock(t *testing.T) {
+	fixed := time.Date(2024, 1, 2, 15, 4, 5, 6, time.UTC)
+	want := fmt.Sprintf("ocr-%d", fixed.UnixNano())
+
+	// The request ID is the same on every call, e.g. in the error
+	for range 2 {
+		_, err := Extract(context.Background(), filepath.Join(t.TempDir(), "missing.png"),
+			WithClock(func() time.Time { return fixed }))
+		var ocrErr *OCRError
+		if !errors.As(err, &ocrErr) {
+			t.Fatalf("err = %v, want an *OCRError", err)
+		}
+		if ocrErr.RequestID != want {
+			t.Errorf("RequestID = %q, want %q", ocrErr.RequestID, want)
+		}
+	}
+}
+
+func TestExtract_DebugRequestLog(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	path := filepath.Join(t.TempDir(), "scan.png")
+	if err := os.WriteFile(path, testPNG(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := logOutput
+	defer func() { logOutput = saved }()
+
+	for _, enabled := range []bool{false, true} {
+		var buf bytes.Buffer
+		logOutput = &buf
+
+		if _, err := Extract(context.Background(), path, WithOllamaURL(srv.URL), WithDebugRequestLog(enabled)); err != nil {
+			t.Fatalf("Extract: %v", err)
+		}
+		if logged := strings.Contains(buf.String(), `"msg":"ollama request"`); logged != enabled {
+			t.Errorf("WithDebugRequestLog(%v): request logged = %v", enabled, logged)
+		}
+	}
+}
+
+// endlessReader serves an unbounded stream and records how much was read.
+type endlessReader struct {
+	n int64
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestExtractReader_ExceedsLimit(t *testing.T) {
+	r := &endlessReader{}
+
+	_, err := ExtractReader(context.Background(), r, ".png", WithMaxFileSize(4096))
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("err = %v, want ErrFileTooLarge", err)
+	}
+	if r.n > 4097 {
+		t.Errorf("read %d bytes, want the read to stop at the limit", r.n)
+	}
+}
+
+func TestExtractBytes_Timings(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+	data := testPNG(t)
+
+	result, err := ExtractBytes(context.Background(), data, ".png", WithOllamaURL(url))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Timings != nil {
+		t.Error("Timings should be omitted by default")
+	}
+
+	result, err = ExtractBytes(context.Background(), data, ".png", WithOllamaURL(url), WithTimings(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	tm := result.Timings
+	if tm == nil {
+		t.Fatal("Timings should be set with WithTimings(true)")
+	}
+	if tm.TotalMs < tm.DownloadMs+tm.PreprocessMs+tm.ModelMs+tm.ParseMs-4 {
+		// Each stage is truncated to whole milliseconds independently
+		t.Errorf("stage timings %+v exceed the total", *tm)
+	}
+}
+
+func TestExtractBytes_TranscodesToPNG(t *testing.T) {
+	var gifData, tiffData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	if err := tiff.Encode(&tiffData, image.NewGray(image.Rect(0, 0, 8, 6)), nil); err != nil {
+		t.Fatalf("encode tiff: %v", err)
+	}
+	webpData, err := os.ReadFile(filepath.Join("testdata", "video-001.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ext  string
+		data []byte
+		w, h int
+	}{
+		{".gif", gifData.Bytes(), 8, 8},
+		{".webp", webpData, 150, 103},
+		{".tiff", tiffData.Bytes(), 8, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			srv := ollamatest.NewServer(t, nil)
+			result, err := ExtractBytes(context.Background(), tt.data, tt.ext, WithOllamaURL(srv.URL))
+			if err != nil {
+				t.Fatalf("ExtractBytes: %v", err)
+			}
+			sent, _ := base64.StdEncoding.DecodeString(srv.Requests()[0].Images[0])
+			cfg, err := png.DecodeConfig(bytes.NewReader(sent))
+			if err != nil {
+				t.Fatalf("model did not receive a PNG: %v", err)
+			}
+			if cfg.Width != tt.w || cfg.Height != tt.h {
+				t.Errorf("PNG size = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.w, tt.h)
+			}
+			if result.Image.Width != tt.w || result.Image.Height != tt.h {
+				t.Errorf("Image = %dx%d, want %dx%d", result.Image.Width, result.Image.Height, tt.w, tt.h)
+			}
+			if result.Source.Checksum != utils.SHA256Bytes(tt.data) {
+				t.Error("checksum should describe the original source bytes")
+			}
+		})
+	}
+}
+
+func TestExtractBytes_UndecodableFormat(t *testing.T) {
+	_, err := ExtractBytes(context.Background(), []byte("not a webp"), ".webp")
+	if !errors.Is(err, ErrImageDecodeFailed) {
+		t.Fatalf("err = %v, want ErrImageDecodeFailed", err)
+	}
+}
+
+func TestBuildSummary_MaxWords(t *testing.T) {
+	summary := "A grocery receipt from ACME Store totalling twelve dollars."
+	resp := &models.OllamaVisionResponse{Summary: &summary}
+
+	cfg := DefaultConfig()
+	cfg.WithSummary = true
+	cfg.SummaryMaxWords = 4
+
+	got := buildSummary(resp, cfg)
+	if got == nil || *got != "A grocery receipt from" {
+		t.Errorf("summary = %v, want truncated to 4 words", got)
+	}
+	if summary != "A grocery receipt from ACME Store totalling twelve dollars." {
+		t.Error("buildSummary modified the model response")
+	}
+}
+
+func TestPlaceRegionLines(t *testing.T) {
+	rect := image.Rect(100, 200, 300, 300) // 200x100 crop
+
+	tests := []struct {
+		name string
+		box  models.BoundingBox
+		want models.BoundingBox
+	}{
+		{"pixels", models.BoundingBox{X: 10, Y: 20, Width: 50, Height: 10}, models.BoundingBox{X: 110, Y: 220, Width: 50, Height: 10}},
+		{"normalized", models.BoundingBox{X: 0.5, Y: 0.5, Width: 0.25, Height: 0.1}, models.BoundingBox{X: 200, Y: 250, Width: 50, Height: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box
+			resp := &models.OllamaVisionResponse{Text: &models.OllamaTextResult{
+				Lines: []models.OllamaTextLine{{Text: "a", BoundingBox: &box}, {Text: "b"}},
+			}}
+
+			placeRegion(resp, 3, rect)
+
+			got := resp.Text.Lines[0]
+			if *got.BoundingBox != tt.want {
+				t.Errorf("box = %+v, want %+v", *got.BoundingBox, tt.want)
+			}
+			for i, line := range resp.Text.Lines {
+				if line.Region == nil || *line.Region != 3 {
+					t.Errorf("Lines[%d].Region = %v, want 3", i, line.Region)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractBytes_ExpectedChecksum(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	data := testPNG(t)
+	sum := utils.SHA256Bytes(data)
+
+	if _, err := ExtractBytes(context.Background(), data, ".png", WithOllamaURL(srv.URL),
+		WithExpectedChecksum("sha256", strings.ToUpper(sum))); err != nil {
+		t.Fatalf("matching checksum: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		algo string
+		sum  string
+	}{
+		{"mismatch", "sha256", utils.SHA256Bytes([]byte("other"))},
+		{"wrong algorithm", "sha512", sum},
+		{"unknown algorithm", "crc32", sum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractBytes(context.Background(), data, ".png", WithOllamaURL(srv.URL),
+				WithExpectedChecksum(tt.algo, tt.sum))
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("err = %v, want ErrChecksumMismatch", err)
+			}
+		})
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("model requests = %d, want 1 (mismatched data must not be sent)", n)
+	}
+}
+
+func TestExtractBytes_OrderedKeyValues(t *testing.T) {
+	response := `{"metadata":{"document_type":"invoice","confidence_score":0.9},"text":{"raw":"x","lines":[]},` +
+		`"structured_data":{"key_value_pairs":{"vendor":"ACME","total":"9.99","date":"2024-01-02","Invoice No":"42"}}}`
+	url := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, response
+	}).URL
+
+	want := []models.KeyValue{
+		{Key: "vendor", Value: "ACME"},
+		{Key: "total", Value: "9.99"},
+		{Key: "date", Value: "2024-01-02"},
+		{Key: "Invoice No", Value: "42"},
+	}
+	var first []byte
+	for i := 0; i < 5; i++ {
+		result, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(url))
+		if err != nil {
+			t.Fatalf("ExtractBytes: %v", err)
+		}
+		if got := result.StructuredData.OrderedKeyValues; !reflect.DeepEqual(got, want) {
+			t.Fatalf("OrderedKeyValues = %+v, want %+v", got, want)
+		}
+		encoded, err := json.Marshal(result.StructuredData)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		if first == nil {
+			first = encoded
+		} else if !bytes.Equal(encoded, first) {
+			t.Fatalf("run %d encoded %s, want %s", i, encoded, first)
+		}
+	}
+}
+
+func TestOrderedKeyValues(t *testing.T) {
+	kv := map[string]string{"total": "9.99", "b-form": "x", "a-form": "y", "date": "2024"}
+	cfg := DefaultConfig()
+	got := orderedKeyValues(kv, []string{"total", "missing", "date\x00"}, cfg)
+	want := []models.KeyValue{
+		{Key: "total", Value: "9.99"},
+		{Key: "date", Value: "2024"},
+		{Key: "a-form", Value: "y"},
+		{Key: "b-form", Value: "x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orderedKeyValues = %+v, want %+v", got, want)
+	}
+	if got := orderedKeyValues(nil, []string{"total"}, cfg); got != nil {
+		t.Errorf("orderedKeyValues(nil) = %+v, want nil", got)
+	}
+}
+
+func TestExtractBytes_FewShotExamples(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL),
+		WithFewShotExamples([]prompt.Example{{
+			Description: "a pharmacy receipt",
+			Output:      `{"structured_data": {"key_value_pairs": {"rx_number": "RX-20931"}}}`,
+		}}))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	p := srv.Requests()[0].Prompt
+	example := strings.Index(p, `"rx_number":"RX-20931"`)
+	schema := strings.Index(p, "Respond ONLY with valid JSON")
+	if example < 0 || schema < 0 || example > schema {
+		t.Errorf("prompt should show the example before the schema instructions:\n%s", p)
+	}
+}
+
+func TestExtractBytes_SchemaValidation(t *testing.T) {
+	// A key the prompt does not ask for, which the forgiving parser ignores
+	bad := strings.Replace(ollamatest.Response, `"summary":`, `"notes":"handwritten","summary":`, 1)
+	calls := 0
+	srv := ollamatest.NewServer(t, func(req client.GenerateRequest) (int, string) {
+		if calls++; calls == 1 {
+			return http.StatusOK, ollamatest.Response
+		}
+		return http.StatusOK, bad
+	})
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithSchemaValidation(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("requests = %d, want 1 for a conforming answer", n)
+	}
+
+	_, err = ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithSchemaValidation(true))
+	if !errors.Is(err, ErrOllamaRequestFailed) || !strings.Contains(err.Error(), `unexpected key "notes"`) {
+		t.Errorf("err = %v, want the schema violation", err)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("requests = %d, want 3 with the retry", n)
+	}
+}
+
+// stalledBackend never answers; Generate returns once ctx is done.
+type stalledBackend struct{}
+
+func (stalledBackend) Generate(ctx context.Context, req client.GenerateRequest) (*client.GenerateResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stalledBackend) Ping(ctx context.Context) error { return nil }
+
+func TestExtractBytes_DeadlineBudget(t *testing.T) {
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithBackend(stalledBackend{}), WithTimeout(150*time.Millisecond))
+	if !errors.Is(err, ErrContextCanceled) || !errors.Is(err, ErrOllamaRequestFailed) {
+		t.Fatalf("err = %v, want ErrContextCanceled wrapping the request failure", err)
+	}
+	var ocrErr *OCRError
+	if !errors.As(err, &ocrErr) || ocrErr.Timings == nil {
+		t.Fatalf("err = %#v, want an OCRError with timings", err)
+	}
+	if ocrErr.Op != "Extract.Process" {
+		t.Errorf("Op = %q, want Extract.Process", ocrErr.Op)
+	}
+	tm := ocrErr.Timings
+	if tm.ModelMs < 100 || tm.TotalMs < tm.ModelMs {
+		t.Errorf("Timings = %+v, want the model stage to hold most of the budget", *tm)
+	}
+	if !strings.Contains(err.Error(), "deadline exceeded after ") || !strings.Contains(err.Error(), "model ") {
+		t.Errorf("message %q should break the budget down by stage", err)
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	cause := NewOCRError("Extract.Process", "req-1", fmt.Errorf("%w: generate", ErrOllamaRequestFailed))
+	err := budgetExceeded(ctx, cause, "req-1", models.Timings{DownloadMs: 2000, ModelMs: 115400, TotalMs: 118000})
+	want := "ocr [req-1] Extract.Process: ocr: context canceled or deadline exceeded: " +
+		"deadline exceeded after 118s (download 2.0s, model 115s): ocr: ollama API request failed: generate"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, ErrOllamaRequestFailed) {
+		t.Error("the original failure should stay in the chain")
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = budgetExceeded(canceled, errors.New("read"), "", models.Timings{TotalMs: 40})
+	if want := "ocr Extract: ocr: context canceled or deadline exceeded: canceled after 40ms: read"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestExtractBytes_CropRegions(t *testing.T) {
+	response := `{"metadata":{"document_type":"unknown","confidence_score":0.9},"text":{"raw":"field","lines":[{"text":"field","bounding_box":{"x":2,"y":3,"width":5,"height":4},"confidence":0.9}]},"image":{"width":16,"height":16}}`
+	url := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, response
+	}).URL
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(url),
+		WithCropRegions([]models.BoundingBox{
+			{X: 0, Y: 0, Width: 16, Height: 16},
+			{X: 16, Y: 16, Width: 16, Height: 16},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	if len(result.Text.Lines) != 2 {
+		t.Fatalf("len(Lines) = %d, want 2", len(result.Text.Lines))
+	}
+	for i, want := range []models.BoundingBox{{X: 2, Y: 3, Width: 5, Height: 4}, {X: 18, Y: 19, Width: 5, Height: 4}} {
+		line := result.Text.Lines[i]
+		if line.Region == nil || *line.Region != i {
+			t.Errorf("Lines[%d].Region = %v, want %d", i, line.Region, i)
+		}
+		if *line.BoundingBox != want {
+			t.Errorf("Lines[%d].BoundingBox = %+v, want %+v", i, *line.BoundingBox, want)
+		}
+	}
+	if result.Image.Width != 32 {
+		t.Errorf("Image.Width = %d, want the original 32", result.Image.Width)
+	}
+	if !strings.Contains(result.Text.Raw, "--- Region 2 ---") {
+		t.Errorf("Raw = %q, want region headers", result.Text.Raw)
+	}
+}
+
+func TestExtractBytes_AutoStrip(t *testing.T) {
+	// A 10x40 strip: four times taller than wide, split into two 10x20 strips
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 40))); err != nil {
+		t.Fatal(err)
+	}
+	tall := buf.Bytes()
+
+	stripResponse := func(text string) string {
+		return `{"metadata":{"document_type":"receipt","confidence_score":0.9},"text":{"raw":"` + text +
+			`","lines":[{"text":"` + text + `","bounding_box":{"x":1,"y":2,"width":8,"height":5},"confidence":0.9}]},"image":{"width":10,"height":20}}`
+	}
+	var calls int
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		calls++
+		if calls%2 == 1 {
+			return http.StatusOK, stripResponse("ACME STORE")
+		}
+		return http.StatusOK, stripResponse("TOTAL 9.99")
+	})
+
+	result, err := ExtractBytes(context.Background(), tall, ".png", WithOllamaURL(srv.URL), WithAutoStrip(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("model calls = %d, want one per strip", calls)
+	}
+	if result.Text.Raw != "ACME STORE\nTOTAL 9.99" {
+		t.Errorf("Raw = %q, want the strips joined top to bottom", result.Text.Raw)
+	}
+	want := []models.BoundingBox{{X: 1, Y: 2, Width: 8, Height: 5}, {X: 1, Y: 22, Width: 8, Height: 5}}
+	if len(result.Text.Lines) != len(want) {
+		t.Fatalf("len(Lines) = %d, want %d", len(result.Text.Lines), len(want))
+	}
+	for i, line := range result.Text.Lines {
+		if *line.BoundingBox != want[i] {
+			t.Errorf("Lines[%d].BoundingBox = %+v, want %+v", i, *line.BoundingBox, want[i])
+		}
+		if line.Region != nil {
+			t.Errorf("Lines[%d].Region = %d, want none for strips", i, *line.Region)
+		}
+	}
+	if result.Image.Height != 40 {
+		t.Errorf("Image.Height = %d, want the original 40", result.Image.Height)
+	}
+
+	// Off by default, and square images are never split
+	calls = 0
+	if _, err := ExtractBytes(context.Background(), tall, ".png", WithOllamaURL(srv.URL)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithAutoStrip(true)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("model calls = %d, want one per image", calls)
+	}
+}
+
+func TestExtractBytes_Candidates(t *testing.T) {
+	candidate := func(text string, confidence string) string {
+		return `{"metadata":{"document_type":"receipt","confidence_score":` + confidence + `},"text":{"raw":"` + text +
+			`","lines":[{"text":"` + text + `","confidence":0.9}]}}`
+	}
+	// Every seed answers differently; the third never parses
+	srv := ollamatest.NewServer(t, func(req client.GenerateRequest) (int, string) {
+		switch *req.Options.Seed {
+		case 0:
+			return http.StatusOK, candidate("T0TAL 9.99", "0.6")
+		case 1:
+			return http.StatusOK, candidate("TOTAL 9.99", "0.95")
+		default:
+			return http.StatusOK, "not json"
+		}
+	})
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithCandidates(3))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	var seeds []int
+	for _, req := range srv.Requests() {
+		seeds = append(seeds, *req.Options.Seed)
+		if want := CandidateTemperature; *req.Options.Seed > 0 && req.Options.Temperature != want {
+			t.Errorf("seed %d: Temperature = %v, want %v", *req.Options.Seed, req.Options.Temperature, want)
+		}
+	}
+	if want := []int{0, 1, 2, 2}; !slices.Equal(seeds, want) {
+		t.Errorf("seeds = %v, want %v (the failed candidate retried once)", seeds, want)
+	}
+	if got := srv.Requests()[0].Options.Temperature; got != DefaultTemperature {
+		t.Errorf("first candidate Temperature = %v, want the configured %v", got, DefaultTemperature)
+	}
+
+	if len(result.Candidates) != 2 {
+		t.Fatalf("len(Candidates) = %d, want 2", len(result.Candidates))
+	}
+	if result.Candidates[0].Text.Raw != "TOTAL 9.99" || result.Candidates[1].Text.Raw != "T0TAL 9.99" {
+		t.Errorf("Candidates = %q, %q, want the most confident first", result.Candidates[0].Text.Raw, result.Candidates[1].Text.Raw)
+	}
+	if result.Text.Raw != "TOTAL 9.99" {
+		t.Errorf("Raw = %q, want the most confident candidate", result.Text.Raw)
+	}
+	if result.Usage.PromptTokens != 200 || result.Candidates[0].Usage.PromptTokens != 100 {
+		t.Errorf("PromptTokens = %d (best candidate %d), want 200 for both candidates",
+			result.Usage.PromptTokens, result.Candidates[0].Usage.PromptTokens)
+	}
+	if len(result.Candidates[0].Candidates) != 0 {
+		t.Error("candidates should not list candidates of their own")
+	}
+	if !slices.ContainsFunc(result.Warnings, func(w string) bool { return strings.HasPrefix(w, "candidate 2 failed") }) {
+		t.Errorf("Warnings = %q, want the failed candidate", result.Warnings)
+	}
+
+	// One candidate is a normal extraction
+	srv = ollamatest.NewServer(t, nil)
+	result, err = ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithCandidates(1))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Candidates != nil || srv.Requests()[0].Options.Seed != nil {
+		t.Error("a single extraction should not be seeded or list candidates")
+	}
+}
+
+func TestExtractBytes_CropRegionOutsideImage(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(url),
+		WithCropRegions([]models.BoundingBox{{X: 500, Y: 500, Width: 10, Height: 10}}),
+	)
+	if !errors.Is(err, ErrInvalidCropRegion) {
+		t.Fatalf("err = %v, want ErrInvalidCropRegion", err)
+	}
+}
+
+func TestCheckMinImageDimension(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MinImageDimension = 100
+
+	tests := []struct {
+		name    string
+		info    models.ImageInfo
+		ext     string
+		wantErr bool
+	}{
+		{"exactly minimum", models.ImageInfo{Width: 100, Height: 100}, ".png", false},
+		{"larger", models.ImageInfo{Width: 2000, Height: 1000}, ".jpg", false},
+		{"width one below", models.ImageInfo{Width: 99, Height: 500}, ".png", true},
+		{"height one below", models.ImageInfo{Width: 500, Height: 99}, ".png", true},
+		{"pdf skipped", models.ImageInfo{}, ".pdf", false},
+		{"unknown size skipped", models.ImageInfo{}, ".png", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMinImageDimension(tt.info, tt.ext, cfg)
+			if tt.wantErr && !errors.Is(err, ErrImageTooSmall) {
+				t.Errorf("err = %v, want ErrImageTooSmall", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	cfg.MinImageDimension = 0
+	if err := checkMinImageDimension(models.ImageInfo{Width: 1, Height: 1}, ".png", cfg); err != nil {
+		t.Errorf("check should be disabled by default: %v", err)
+	}
+}
+
+func TestCheckMaxImagePixels(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxImagePixels = 50_000_000
+
+	tests := []struct {
+		name    string
+		info    models.ImageInfo
+		ext     string
+		wantErr bool
+	}{
+		{"exactly maximum", models.ImageInfo{Width: 10000, Height: 5000}, ".png", false},
+		{"one column over", models.ImageInfo{Width: 10001, Height: 5000}, ".png", true},
+		{"one row over", models.ImageInfo{Width: 10000, Height: 5001}, ".png", true},
+		{"square within per-side limits", models.ImageInfo{Width: 8000, Height: 8000}, ".jpg", true},
+		{"long strip", models.ImageInfo{Width: 100000, Height: 400}, ".png", false},
+		{"beyond int32 range", models.ImageInfo{Width: 70000, Height: 70000}, ".png", true},
+		{"pdf skipped", models.ImageInfo{}, ".pdf", false},
+		{"unknown size skipped", models.ImageInfo{}, ".png", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMaxImagePixels(tt.info, tt.ext, cfg)
+			if tt.wantErr && !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("err = %v, want ErrImageTooLarge", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	cfg.MaxImagePixels = 0
+	if err := checkMaxImagePixels(models.ImageInfo{Width: 100000, Height: 100000}, ".png", cfg); err != nil {
+		t.Errorf("check should be disabled by default: %v", err)
+	}
+}
+
+func TestExtractBytes_ImageTooLarge(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+
+	// testPNG is 32x32 = 1024 pixels
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithMaxImagePixels(1023))
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("err = %v, want ErrImageTooLarge", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("generate requests = %d, want the image not sent to the model", n)
+	}
+
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithMaxImagePixels(1024)); err != nil {
+		t.Errorf("image at the limit: %v", err)
+	}
+}
+
+func TestExtractBytes_ImageTooSmall(t *testing.T) {
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithMinImageDimension(64))
+	if !errors.Is(err, ErrImageTooSmall) {
+		t.Fatalf("err = %v, want ErrImageTooSmall", err)
+	}
+}
+
+func TestExtract_URLViaProxy(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	imageData := testPNG(t)
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write(imageData)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	result, err := Extract(context.Background(), "http://images.example.com/receipt.png",
+		WithOllamaURL(srv.URL),
+		WithProxy(proxyURL),
+	)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://images.example.com/receipt.png" {
+		t.Errorf("proxy saw %v, want the image URL", proxied)
+	}
+	if result.Source.Checksum != utils.SHA256Bytes(imageData) {
+		t.Error("checksum should match the image served through the proxy")
+	}
+}
+
+func TestExtract_URLRefresher(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	imageData := testPNG(t)
+
+	// The proxy stands in for a storage service whose "old" signature has
+	// expired.
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		if r.URL.Query().Get("sig") != "new" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(imageData)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	const source = "http://bucket.example.com/receipt.png?sig=old"
+	tests := []struct {
+		name       string
+		refreshed  string
+		refreshErr error
+		wantErr    error
+		wantCalls  int
+	}{
+		{"refreshed URL succeeds", "http://bucket.example.com/receipt.png?sig=new", nil, nil, 2},
+		{"refreshed URL still refused", "http://bucket.example.com/receipt.png?sig=stale", nil, ErrURLFetchFailed, 2},
+		{"refreshed URL is private", "http://10.0.0.5/receipt.png?sig=new", nil, ErrInvalidURL, 1},
+		{"refresher fails", "", errors.New("signing key unavailable"), ErrURLFetchFailed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxied = nil
+			var refreshedFor string
+			result, err := Extract(context.Background(), source,
+				WithOllamaURL(srv.URL),
+				WithProxy(proxyURL),
+				WithURLRefresher(func(s string) (string, error) {
+					refreshedFor = s
+					return tt.refreshed, tt.refreshErr
+				}),
+			)
+			if refreshedFor != source {
+				t.Errorf("refresher called with %q, want %q", refreshedFor, source)
+			}
+			if len(proxied) != tt.wantCalls {
+				t.Errorf("downloads = %v, want %d", proxied, tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if result.Source.Path != source {
+				t.Errorf("Source.Path = %q, want the original URL", result.Source.Path)
+			}
+			if result.Source.Checksum != utils.SHA256Bytes(imageData) {
+				t.Error("checksum should match the image from the refreshed URL")
+			}
+		})
+	}
+}
+
+func TestExtract_URLRefresherSourceValidator(t *testing.T) {
+	downloads := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	var hosts []string
+	_, err := Extract(context.Background(), "http://bucket.example.com/receipt.png?sig=old",
+		WithProxy(proxyURL),
+		WithSourceValidator(func(source string, info SourceInfo) error {
+			hosts = append(hosts, info.Host)
+			if info.Host != "bucket.example.com" {
+				return errors.New("host not allowed")
+			}
+			return nil
+		}),
+		WithURLRefresher(func(s string) (string, error) {
+			return "http://evil.example.net/receipt.png?sig=new", nil
+		}),
+	)
+	if !errors.Is(err, ErrSourceRejected) {
+		t.Fatalf("err = %v, want ErrSourceRejected", err)
+	}
+	if want := []string{"bucket.example.com", "evil.example.net"}; !slices.Equal(hosts, want) {
+		t.Errorf("validated hosts = %q, want %q", hosts, want)
+	}
+	if downloads != 1 {
+		t.Errorf("downloads = %d, want 1", downloads)
+	}
+}
+
+func TestExtract_URLRefresherOnlyOnAuthFailure(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	refreshed := false
+	_, err := Extract(context.Background(), "http://bucket.example.com/missing.png",
+		WithProxy(proxyURL),
+		WithURLRefresher(func(s string) (string, error) {
+			refreshed = true
+			return s, nil
+		}),
+	)
+	if !errors.Is(err, ErrURLFetchFailed) {
+		t.Fatalf("err = %v, want ErrURLFetchFailed", err)
+	}
+	if refreshed {
+		t.Error("refresher should only run after a 401 or 403")
+	}
+}
+
+func TestExtract_ProxyDoesNotBypassSSRFCheck(t *testing.T) {
+	proxyURL, _ := url.Parse("http://127.0.0.1:1")
+
+	_, err := Extract(context.Background(), "http://192.168.1.10/receipt.png", WithProxy(proxyURL))
+	if !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("err = %v, want ErrInvalidURL for a private target", err)
+	}
+}
+
+func TestExtract_SourceValidatorRejectsURL(t *testing.T) {
+	downloaded := false
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloaded = true
+	}))
+	defer images.Close()
+	proxyURL, _ := url.Parse(images.URL)
+
+	errHost := errors.New("host not allowed")
+	var got SourceInfo
+	_, err := Extract(context.Background(), "http://images.example.com:8080/scan.JPG",
+		WithProxy(proxyURL),
+		WithSourceValidator(func(source string, info SourceInfo) error {
+			got = info
+			return errHost
+		}),
+	)
+	if !errors.Is(err, ErrSourceRejected) || !errors.Is(err, errHost) {
+		t.Fatalf("err = %v, want ErrSourceRejected wrapping the validator error", err)
+	}
+	if downloaded {
+		t.Error("image was downloaded before the validator ran")
+	}
+	want := SourceInfo{Type: models.SourceTypeURL, Host: "images.example.com", Ext: ".jpg", Size: -1}
+	if got != want {
+		t.Errorf("SourceInfo = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtract_SourceValidatorFileInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.png")
+	data := testPNG(t)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got SourceInfo
+	_, err := Extract(context.Background(), path,
+		WithSourceValidator(func(source string, info SourceInfo) error {
+			got = info
+			return errors.New("too big")
+		}),
+	)
+	if !errors.Is(err, ErrSourceRejected) {
+		t.Fatalf("err = %v, want ErrSourceRejected", err)
+	}
+	want := SourceInfo{Type: models.SourceTypeFile, Ext: ".png", Size: int64(len(data))}
+	if got != want {
+		t.Errorf("SourceInfo = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractBytes_SourceValidatorAccepts(t *testing.T) {
+	url := ollamatest.NewServer(t, nil).URL
+	data := testPNG(t)
+
+	var got SourceInfo
+	_, err := ExtractBytes(context.Background(), data, "png",
+		WithOllamaURL(url),
+		WithSourceValidator(func(source string, info SourceInfo) error {
+			got = info
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	want := SourceInfo{Type: models.SourceTypeBytes, Ext: ".png", Size: int64(len(data))}
+	if got != want {
+		t.Errorf("SourceInfo = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractBytes_ValidateImageBytes(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+
+	img := image.NewGray(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x ^ y)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	truncated := buf.Bytes()[:buf.Len()/2]
+
+	_, err := ExtractBytes(context.Background(), truncated, ".jpg",
+		WithOllamaURL(srv.URL),
+		WithValidateImageBytes(true),
+	)
+	if !errors.Is(err, ErrImageDecodeFailed) {
+		t.Fatalf("err = %v, want ErrImageDecodeFailed", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("model was called %d times for an undecodable image", n)
+	}
+}
+
+func TestExtractBytes_RawJSON(t *testing.T) {
+	response := `{"metadata":{"document_type":"receipt","confidence_score":0.8},` +
+		`"text":"TOTAL 9.99","structured_data":{"key_value_pairs":{"total":9.99}},` +
+		`"stamp":{"present":true,"color":"red"}}`
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, response
+	})
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithRawJSON(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	stamp, ok := result.RawData["stamp"].(map[string]any)
+	if !ok || stamp["color"] != "red" {
+		t.Errorf("RawData[stamp] = %v, want the extra field preserved", result.RawData["stamp"])
+	}
+	if result.RawData["text"] != "TOTAL 9.99" {
+		t.Errorf("RawData[text] = %v, want the model's value", result.RawData["text"])
+	}
+	if result.Text.Raw != "" || len(result.StructuredData.KeyValuePairs) != 0 {
+		t.Errorf("Text = %+v, StructuredData = %+v, want both empty", result.Text, result.StructuredData)
+	}
+	if result.Metadata.DocumentType != models.DocumentTypeReceipt {
+		t.Errorf("DocumentType = %q, want receipt", result.Metadata.DocumentType)
+	}
+	if len(srv.Requests()) != 1 {
+		t.Errorf("requests = %d, want no retry for schema mismatches", len(srv.Requests()))
+	}
+}
+
+func TestBuildOCRResult_RawJSONMergedPages(t *testing.T) {
+	page := func(n float64) *engine.ProcessResult {
+		return &engine.ProcessResult{
+			VisionResponse: &models.OllamaVisionResponse{},
+			RawData:        map[string]any{"page_number": n},
+		}
+	}
+	merged := engine.MergeResults([]*engine.ProcessResult{page(1), page(2)}, "Page")
+
+	result := buildOCRResult("doc.pdf", models.SourceTypeFile, "", models.ImageInfo{}, merged, DefaultConfig())
+	pages, ok := result.RawData["pages"].([]any)
+	if !ok || len(pages) != 2 || pages[1].(map[string]any)["page_number"] != 2.0 {
+		t.Errorf("RawData = %v, want both pages in order", result.RawData)
+	}
+	if result.Text.Raw != "" {
+		t.Errorf("Raw = %q, want empty in raw mode", result.Text.Raw)
+	}
+}
+
+func TestExtract_EncryptedPDF(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+
+	_, err := Extract(context.Background(), filepath.Join("testdata", "encrypted.pdf"), WithOllamaURL(srv.URL))
+	if !errors.Is(err, ErrPDFParseFailed) {
+		t.Fatalf("err = %v, want ErrPDFParseFailed", err)
+	}
+	if !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("err = %v, want it to mention encryption", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("generate requests = %d, want the PDF not sent to the model", n)
+	}
+}
+
+func TestExtract_FormFields(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	path := filepath.Join("testdata", "form.pdf")
+
+	result, err := Extract(context.Background(), path, WithOllamaURL(srv.URL), WithExtractFormFields(true))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	sd := result.StructuredData
+	// The canned OCR response has total 9.99; the form says 10.00
+	if sd.KeyValuePairs["total"] != "10.00" || sd.KeyValuePairs["customer"] != "Jane Doe" {
+		t.Errorf("KeyValuePairs = %v, want the form values", sd.KeyValuePairs)
+	}
+	want := map[string]models.KeyValueSource{"total": models.KeyValueSourceForm, "customer": models.KeyValueSourceForm}
+	if !maps.Equal(sd.KeyValueSources, want) {
+		t.Errorf("KeyValueSources = %v, want %v", sd.KeyValueSources, want)
+	}
+
+	result, err = Extract(context.Background(), path, WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Extract without form fields: %v", err)
+	}
+	if result.StructuredData.KeyValuePairs["total"] != "9.99" || result.StructuredData.KeyValueSources != nil {
+		t.Errorf("StructuredData = %+v, want OCR values only", result.StructuredData)
+	}
+}
+
+func TestExtract_SourceMetadata(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	metadata := map[string]string{"tenant_id": "t-42", "document_uuid": "9f1c", "uploaded_at": "2024-05-01T10:00:00Z"}
+	want := maps.Clone(metadata)
+	opt := WithSourceMetadata(metadata)
+
+	image, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), opt)
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	pdf, err := Extract(context.Background(), filepath.Join("testdata", "form.pdf"), WithOllamaURL(srv.URL), opt)
+	if err != nil {
+		t.Fatalf("Extract PDF: %v", err)
+	}
+	for name, r := range map[string]*models.OCRResult{"image": image, "pdf": pdf} {
+		if !maps.Equal(r.Source.Metadata, want) {
+			t.Errorf("%s: Source.Metadata = %v, want %v", name, r.Source.Metadata, want)
+		}
+	}
+
+	// Each result has its own copy
+	metadata["tenant_id"] = "changed by the caller"
+	image.Source.Metadata["tenant_id"] = "changed on one result"
+	if pdf.Source.Metadata["tenant_id"] != "t-42" {
+		t.Error("results should not share the metadata map")
+	}
+
+	data, err := json.Marshal(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.UnmarshalOCRResult(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(decoded.Source.Metadata, want) {
+		t.Errorf("serialized Source.Metadata = %v, want %v", decoded.Source.Metadata, want)
+	}
+}
+
+func TestExtract_PDFMetadata(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+	path := filepath.Join("testdata", "info.pdf")
+
+	result, err := Extract(context.Background(), path, WithOllamaURL(srv.URL), WithExtractPDFMetadata(true))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	info := result.PDFInfo
+	if info == nil || info.Title != "Invoice 2024-017" || info.Author != "Jürgen" || info.CreationDate == nil {
+		t.Errorf("PDFInfo = %+v, want the info dictionary of the fixture", info)
+	}
+	if result.Text.Raw == "" {
+		t.Error("pages should still be OCRed")
+	}
+
+	result, err = Extract(context.Background(), path, WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Extract without PDF metadata: %v", err)
+	}
+	if result.PDFInfo != nil {
+		t.Errorf("PDFInfo = %+v, want nil without WithExtractPDFMetadata", result.PDFInfo)
+	}
+}
+
+func TestMergeFormFields(t *testing.T) {
+	cfg := DefaultConfig()
+	sd := models.StructuredData{
+		KeyValuePairs:   map[string]string{"total": "9.99", "date": "2024-01-02"},
+		KeyValueDetails: map[string]models.KeyValueDetail{"total": {Value: "9.99", Confidence: 0.6}},
+	}
+
+	mergeFormFields(&sd, map[string]string{"total": "10.00", "": "dropped"}, cfg)
+
+	if sd.KeyValuePairs["total"] != "10.00" || sd.KeyValuePairs["date"] != "2024-01-02" || len(sd.KeyValuePairs) != 2 {
+		t.Errorf("KeyValuePairs = %v", sd.KeyValuePairs)
+	}
+	if sd.KeyValueSources["total"] != models.KeyValueSourceForm || sd.KeyValueSources["date"] != models.KeyValueSourceOCR {
+		t.Errorf("KeyValueSources = %v", sd.KeyValueSources)
+	}
+	if d := sd.KeyValueDetails["total"]; d.Value != "10.00" || d.Confidence != 1 {
+		t.Errorf("KeyValueDetails[total] = %+v, want the form value with confidence 1", d)
+	}
+}
+
+func TestExtractBytes_FilterWatermarks(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "watermark_response.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, string(fixture)
+	})
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithFilterWatermarks(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if !strings.Contains(srv.Requests()[0].Prompt, `"watermarks"`) {
+		t.Error("prompt should ask for watermarks")
+	}
+	if want := []string{"DRAFT", "CONFIDENTIAL"}; !slices.Equal(result.Text.Watermarks, want) {
+		t.Errorf("Watermarks = %q, want %q", result.Text.Watermarks, want)
+	}
+	if want := "SERVICE AGREEMENT\nThis agreement is made between ACME Corp and Jane Doe."; result.Text.Raw != want {
+		t.Errorf("Raw = %q, want %q", result.Text.Raw, want)
+	}
+	var lines []string
+	var numbers []int
+	for _, line := range result.Text.Lines {
+		lines = append(lines, line.Text)
+		numbers = append(numbers, line.LineNumber)
+	}
+	if len(lines) != 2 || lines[0] != "SERVICE AGREEMENT" || !slices.Equal(numbers, []int{1, 3}) {
+		t.Errorf("Lines = %q (line numbers %v), want the two content lines numbered 1 and 3", lines, numbers)
+	}
+
+	// Off by default: the text is returned as the model gave it
+	result, err = ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Text.Watermarks != nil || len(result.Text.Lines) != 4 || !strings.Contains(result.Text.Raw, "DRAFT") {
+		t.Errorf("Text = %+v, want the watermarks kept in the text", result.Text)
+	}
+	if strings.Contains(srv.Requests()[1].Prompt, `"watermarks"`) {
+		t.Error("watermarks should not be requested by default")
+	}
+}
+
+func TestExtractBytes_TableSpans(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "table_spans_response.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, string(fixture)
+	})
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithStructuredExtraction(true), WithTableSpans(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if !strings.Contains(srv.Requests()[0].Prompt, `"spans"`) {
+		t.Error("prompt should ask for cell spans")
+	}
+	tables := result.StructuredData.Tables
+	if len(tables) != 3 {
+		t.Fatalf("len(Tables) = %d, want 3", len(tables))
+	}
+
+	// The second spanning cell covers the third, which is dropped; the
+	// others are outside the table or malformed
+	one, none := models.CellSpan{ColSpan: 1, RowSpan: 1}, models.CellSpan{}
+	want := [][]models.CellSpan{
+		{one, one, one, one, one},
+		{one, {ColSpan: 4, RowSpan: 1}, none, none, none},
+		{one, {ColSpan: 4, RowSpan: 2}, none, none, none},
+		{one, none, none, none, none},
+	}
+	if !reflect.DeepEqual(tables[0].Spans, want) {
+		t.Errorf("Tables[0].Spans = %v, want %v", tables[0].Spans, want)
+	}
+	// A grid of spans, clipped to the two columns
+	want = [][]models.CellSpan{{one, one}, {{ColSpan: 2, RowSpan: 1}, none}}
+	if !reflect.DeepEqual(tables[1].Spans, want) {
+		t.Errorf("Tables[1].Spans = %v, want %v", tables[1].Spans, want)
+	}
+	if tables[2].Spans != nil {
+		t.Errorf("Tables[2].Spans = %v, want none for a table without spanning cells", tables[2].Spans)
+	}
+
+	// Off by default: the plain rows only
+	result, err = ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithStructuredExtraction(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	for i, table := range result.StructuredData.Tables {
+		if table.Spans != nil {
+			t.Errorf("Tables[%d].Spans = %v, want none by default", i, table.Spans)
+		}
+	}
+	if len(result.StructuredData.Tables[0].Rows) != 4 {
+		t.Error("rows should be returned as the model gave them")
+	}
+	if strings.Contains(srv.Requests()[1].Prompt, `"spans"`) {
+		t.Error("spans should not be requested by default")
+	}
+}
+
+func TestRemoveWatermarkLines(t *testing.T) {
+	w := models.Watermarks{"DRAFT"}
+	tests := []struct{ raw, want string }{
+		{"a\nDRAFT\nb", "a\nb"},
+		{"a\nb\ndraft", "a\nb"},
+		{"DRAFT\na\n", "a\n"},
+		{"a DRAFT\nb", "a DRAFT\nb"},
+		{"DRAFT", ""},
+	}
+	for _, tt := range tests {
+		if got := removeWatermarkLines(tt.raw, w); got != tt.want {
+			t.Errorf("removeWatermarkLines(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestExtractBytes_DetectTextDirection(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "rtl_response.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, string(fixture)
+	})
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithDetectTextDirection(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Metadata.Direction != models.TextDirectionRTL {
+		t.Errorf("Direction = %q, want rtl", result.Metadata.Direction)
+	}
+	if !strings.Contains(srv.Requests()[0].Prompt, `"direction"`) {
+		t.Error("prompt should ask for the text direction")
+	}
+
+	// Without a direction from the model, it defaults to ltr
+	srv = ollamatest.NewServer(t, nil)
+	result, err = ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithDetectTextDirection(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if result.Metadata.Direction != models.TextDirectionLTR {
+		t.Errorf("Direction = %q, want ltr", result.Metadata.Direction)
+	}
+}
+
+func TestBuildOCRResult_TextDirectionDisabled(t *testing.T) {
+	resp := &models.OllamaVisionResponse{Metadata: &models.OllamaMetadata{Direction: models.TextDirectionRTL}}
+	result := buildOCRResult("doc.png", models.SourceTypeFile, "", models.ImageInfo{},
+		&engine.ProcessResult{VisionResponse: resp}, DefaultConfig())
+	if result.Metadata.Direction != "" {
+		t.Errorf("Direction = %q, want unset without WithDetectTextDirection", result.Metadata.Direction)
+	}
+}
+
+func TestExtractBytes_RegisteredPromptTemplate(t *testing.T) {
+	docType := string(models.DocumentTypeInvoice)
+	prompt.Register(docType, func(cfg prompt.PromptConfig) string {
+		return prompt.BuildGenericOCRPrompt(cfg) + "\nInvoice specialist."
+	})
+	t.Cleanup(func() { prompt.Register(docType, nil) })
+
+	srv := ollamatest.NewServer(t, nil)
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL),
+		WithExpectedDocumentType(models.DocumentTypeInvoice)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL),
+		WithExpectedDocumentType(models.DocumentTypeReceipt)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if !strings.HasSuffix(reqs[0].Prompt, "Invoice specialist.") {
+		t.Error("invoice request should use the registered template")
+	}
+	if strings.Contains(reqs[1].Prompt, "Invoice specialist.") {
+		t.Error("receipt request should use the generic prompt")
+	}
+}
+
+func TestExtractBytes_JPEGEncodingShrinksPayload(t *testing.T) {
+	// A noisy color gradient compresses poorly as PNG, like a photo
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	seed := uint32(1)
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			seed = seed*1664525 + 1013904223
+			n := uint8(seed >> 28)
+			img.Set(x, y, color.RGBA{R: uint8(x) + n, G: uint8(y) + n, B: uint8(x+y) + n, A: 255})
+		}
+	}
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, img); err != nil {
+		t.Fatal(err)
+	}
+
+	sentImage := func(opts ...Option) []byte {
+		t.Helper()
+		srv := ollamatest.NewServer(t, nil)
+		opts = append(opts, WithOllamaURL(srv.URL))
+		if _, err := ExtractBytes(context.Background(), photo.Bytes(), ".png", opts...); err != nil {
+			t.Fatalf("ExtractBytes: %v", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(srv.Requests()[0].Images[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	pngSent := sentImage()
+	if !bytes.Equal(pngSent, photo.Bytes()) {
+		t.Error("default encoding should send the PNG unchanged")
+	}
+
+	jpegSent := sentImage(WithImageEncoding(ImageEncodingJPEG), WithJPEGQuality(70))
+	if len(jpegSent) >= len(pngSent)/2 {
+		t.Errorf("JPEG payload = %d bytes, want well below the %d byte PNG", len(jpegSent), len(pngSent))
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(jpegSent))
+	if err != nil {
+		t.Fatalf("sent image does not decode as JPEG: %v", err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Errorf("bounds = %v, want %v", decoded.Bounds(), img.Bounds())
+	}
+
+	// The retained image is the one the model saw
+	srv := ollamatest.NewServer(t, nil)
+	result, err := ExtractBytes(context.Background(), photo.Bytes(), ".png", WithOllamaURL(srv.URL),
+		WithImageEncoding(ImageEncodingJPEG), WithJPEGQuality(70), WithRetainImage(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	retained, contentType := result.RetainedImage()
+	if !bytes.Equal(retained, jpegSent) || contentType != "image/jpeg" {
+		t.Errorf("retained %d bytes of %s, want the %d byte JPEG sent", len(retained), contentType, len(jpegSent))
+	}
+}
+
+func TestExtractBytes_OpenAICompatBackend(t *testing.T) {
+	var chatCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data":[]}`))
+		case "/v1/chat/completions":
+			chatCalls++
+			json.NewEncoder(w).Encode(map[string]any{
+				"model":   "llava",
+				"choices": []any{map[string]any{"message": map[string]any{"content": ollamatest.Response}}},
+			})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithBackend(client.NewOpenAICompatClient(srv.URL, 5*time.Second)),
+		WithModel("llava"),
+	)
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if chatCalls != 1 {
+		t.Errorf("chat completion calls = %d, want 1", chatCalls)
+	}
+	if result.Text.Raw != "ACME Store\nTOTAL 9.99" || result.Usage.Model != "llava" {
+		t.Errorf("result = %q from %q, want the canned response from llava", result.Text.Raw, result.Usage.Model)
+	}
+}
+
+func TestExtractBytes_CustomBackendUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithBackend(client.NewOpenAICompatClient(srv.URL, 5*time.Second)),
+	)
+	if !errors.Is(err, ErrBackendUnavailable) || errors.Is(err, ErrOllamaUnavailable) {
+		t.Fatalf("err = %v, want ErrBackendUnavailable", err)
+	}
+	if msg := err.Error(); strings.Contains(strings.ToLower(msg), "ollama") || !strings.Contains(msg, "client.OpenAICompatClient") {
+		t.Errorf("err = %q, want it to name the backend and not Ollama", msg)
+	}
+}
+
+func TestExtractBytes_AutoRotate(t *testing.T) {
+	upsideDown := strings.Replace(ollamatest.Response, `"confidence_score":0.9`, `"confidence_score":0.2`, 1)
+	upsideDown = strings.Replace(upsideDown, "ACME Store", "ǝɹoʇS ƎWƆ∀", -1)
+
+	tests := []struct {
+		name         string
+		responses    []string
+		wantRotation int
+		wantCalls    int
+		wantRaw      string
+	}{
+		{"confident first result", []string{ollamatest.Response}, 0, 1, "ACME Store\nTOTAL 9.99"},
+		{"rotated image wins", []string{upsideDown, ollamatest.Response}, 180, 2, "ACME Store\nTOTAL 9.99"},
+		{"original wins", []string{upsideDown, strings.Replace(upsideDown, "0.2", "0.1", 1)}, 0, 2, "ǝɹoʇS ƎWƆ∀\nTOTAL 9.99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+				resp := tt.responses[calls]
+				calls++
+				return http.StatusOK, resp
+			})
+			data := testPNG(t)
+
+			result, err := ExtractBytes(context.Background(), data, ".png",
+				WithOllamaURL(srv.URL),
+				WithAutoRotate(true),
+				WithRetainImage(true),
+			)
+			if err != nil {
+				t.Fatalf("ExtractBytes: %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("model calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if result.Image.Rotation != tt.wantRotation {
+				t.Errorf("Rotation = %d, want %d", result.Image.Rotation, tt.wantRotation)
+			}
+			if result.Text.Raw != tt.wantRaw {
+				t.Errorf("Raw = %q, want %q", result.Text.Raw, tt.wantRaw)
+			}
+
+			retained, _ := result.RetainedImage()
+			if rotated := !bytes.Equal(retained, data); rotated != (tt.wantRotation == 180) {
+				t.Errorf("retained image rotated = %v, want %v", rotated, tt.wantRotation == 180)
+			}
+		})
+	}
+}
+
+func TestExtractBytes_AutoRotateOriginalCoordinates(t *testing.T) {
+	upright := `{"metadata":{"document_type":"receipt","confidence_score":0.9},` +
+		`"text":{"raw":"TOTAL","lines":[{"text":"TOTAL","bounding_box":{"x":2,"y":3,"width":10,"height":4},"confidence":0.9}]},` +
+		`"structured_data":{"key_value_pairs":{"total":{"value":"9.99","bounding_box":[2,3,10,4]}},"tables":[]}}`
+	upsideDown := strings.Replace(upright, `"confidence_score":0.9`, `"confidence_score":0.2`, 1)
+
+	tests := []struct {
+		name     string
+		original bool
+		want     models.BoundingBox
+	}{
+		{"rotated coordinates", false, models.BoundingBox{X: 2, Y: 3, Width: 10, Height: 4}},
+		{"original coordinates", true, models.BoundingBox{X: 20, Y: 25, Width: 10, Height: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+				calls++
+				if calls == 1 {
+					return http.StatusOK, upsideDown
+				}
+				return http.StatusOK, upright
+			})
+
+			result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+				WithOllamaURL(srv.URL),
+				WithAutoRotate(true),
+				WithOriginalCoordinates(tt.original),
+			)
+			if err != nil {
+				t.Fatalf("ExtractBytes: %v", err)
+			}
+
+			wantTransforms := []models.ImageTransform{{Type: models.TransformRotate, Width: 32, Height: 32, Degrees: 180}}
+			if !reflect.DeepEqual(result.Transforms, wantTransforms) {
+				t.Errorf("Transforms = %+v, want %+v", result.Transforms, wantTransforms)
+			}
+			if b := result.Text.Lines[0].BoundingBox; b == nil || *b != tt.want {
+				t.Errorf("line box = %+v, want %+v", b, tt.want)
+			}
+			if b := result.StructuredData.KeyValueBoxes["total"]; b == nil || *b != tt.want {
+				t.Errorf("key-value box = %+v, want %+v", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBytes_AutoRotateSendsRotatedImage(t *testing.T) {
+	lowConfidence := strings.Replace(ollamatest.Response, `"confidence_score":0.9`, `"confidence_score":0.2`, 1)
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, lowConfidence
+	})
+
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL),
+		WithAutoRotate(true),
+	); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("requests = %d, want 2", len(reqs))
+	}
+	decode := func(b64 string) image.Image {
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			t.Fatalf("decode base64: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode png: %v", err)
+		}
+		return img
+	}
+	original, rotated := decode(reqs[0].Images[0]), decode(reqs[1].Images[0])
+	a := color.GrayModel.Convert(original.At(31, 30)).(color.Gray)
+	b := color.GrayModel.Convert(rotated.At(0, 1)).(color.Gray)
+	if a != b {
+		t.Errorf("rotated pixel (0,1) = %v, want original pixel (31,30) = %v", b, a)
+	}
+}
+
+func TestExtractBytes_ResultProcessors(t *testing.T) {
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, ollamatest.Response
+	})
+
+	var order []string
+	upper := func(r *models.OCRResult) error {
+		order = append(order, "upper")
+		r.Text.Raw = strings.ToUpper(r.Text.Raw)
+		return nil
+	}
+	alias := func(r *models.OCRResult) error {
+		order = append(order, "alias")
+		if !strings.HasPrefix(r.Text.Raw, "ACME STORE") {
+			t.Errorf("second processor saw Raw = %q, want the first processor's change", r.Text.Raw)
+		}
+		r.StructuredData.KeyValuePairs["amount"] = r.StructuredData.KeyValuePairs["total"]
+		delete(r.StructuredData.KeyValuePairs, "total")
+		return nil
+	}
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL),
+		WithResultProcessors(upper, alias),
+	)
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if !reflect.DeepEqual(order, []string{"upper", "alias"}) {
+		t.Errorf("order = %v, want [upper alias]", order)
+	}
+	if result.Text.Raw != "ACME STORE\nTOTAL 9.99" {
+		t.Errorf("Raw = %q, want the upper-cased text", result.Text.Raw)
+	}
+	if got := result.StructuredData.KeyValuePairs; got["amount"] != "9.99" || got["total"] != "" {
+		t.Errorf("KeyValuePairs = %v, want total renamed to amount", got)
+	}
+}
+
+func TestExtractBytes_ResultProcessorError(t *testing.T) {
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, ollamatest.Response
+	})
+	errRedact := errors.New("redaction service down")
+
+	tests := []struct {
+		name   string
+		strict bool
+	}{
+		{"lenient", false},
+		{"strict", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranAfter := false
+			result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+				WithOllamaURL(srv.URL),
+				WithStrictMode(tt.strict),
+				WithResultProcessors(
+					func(*models.OCRResult) error { return errRedact },
+					func(*models.OCRResult) error { ranAfter = true; return nil },
+				),
+			)
+
+			if tt.strict {
+				if !errors.Is(err, ErrResultProcessor) || !errors.Is(err, errRedact) {
+					t.Fatalf("err = %v, want ErrResultProcessor wrapping the processor's error", err)
+				}
+				if ranAfter {
+					t.Error("processors after a failure should not run in strict mode")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ExtractBytes: %v", err)
+			}
+			if !ranAfter {
+				t.Error("processors after a failure should still run")
+			}
+			found := false
+			for _, w := range result.Warnings {
+				if strings.Contains(w, "redaction service down") {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Warnings = %v, want the processor error", result.Warnings)
+			}
+		})
+	}
+}
+
+func TestExtractBytes_ModelNotFound(t *testing.T) {
+	srv := ollamatest.NewServer(t, func(req client.GenerateRequest) (int, string) {
+		return http.StatusNotFound, `{"error":"model '` + req.Model + `' not found, try pulling it first"}`
+	})
+
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL), WithModel("minicpm-v"))
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("err = %v, want ErrModelNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "ollama pull minicpm-v") {
+		t.Errorf("err = %v, want the pull command", err)
+	}
+}
+
+func TestExtractBytes_EmptyModelResponse(t *testing.T) {
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, ""
+	})
+
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL))
+	if !errors.Is(err, ErrOllamaRequestFailed) || !strings.Contains(err.Error(), "model returned empty response") {
+		t.Fatalf("err = %v, want ErrOllamaRequestFailed for an empty response", err)
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Errorf("requests = %d, want the empty response retried once", n)
+	}
+}
+
+func TestExtractBytes_ProgressiveParse(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+
+	var partials []*models.OCRResult
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithSummary(true),
+		WithProgressiveParse(func(r *models.OCRResult) { partials = append(partials, r) }))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if !srv.Requests()[0].Stream {
+		t.Error("request should be streamed")
+	}
+
+	// One snapshot per field: metadata, text, structured_data, summary
+	if len(partials) != 4 {
+		t.Fatalf("got %d partial results, want 4", len(partials))
+	}
+	first := partials[0]
+	if first.Metadata.DocumentType != models.DocumentTypeReceipt || first.Text.Raw != "" {
+		t.Errorf("first partial = %+v, want metadata only", first)
+	}
+	if got := partials[1].Text.Raw; got != result.Text.Raw {
+		t.Errorf("second partial text = %q, want %q", got, result.Text.Raw)
+	}
+	if partials[1].StructuredData.Extracted || partials[1].Summary != nil {
+		t.Error("second partial should not have structured data or a summary yet")
+	}
+	last := partials[3]
+	if last.Summary == nil || *last.Summary != *result.Summary ||
+		!reflect.DeepEqual(last.StructuredData, result.StructuredData) {
+		t.Errorf("last partial = %+v, want every field of the result", last)
+	}
+	if last.Source.Checksum != result.Source.Checksum || last.Image != result.Image {
+		t.Errorf("last partial source/image = %+v/%+v, want %+v/%+v",
+			last.Source, last.Image, result.Source, result.Image)
+	}
+
+	// Without the option the request is not streamed
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if srv.Requests()[1].Stream {
+		t.Error("request should not be streamed by default")
+	}
+}
+
+func TestExtractBytes_ThumbnailHint(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1024, 600))); err != nil {
+		t.Fatal(err)
+	}
+	srv := ollamatest.NewServer(t, nil)
+
+	if _, err := ExtractBytes(context.Background(), buf.Bytes(), ".png",
+		WithOllamaURL(srv.URL), WithThumbnailHint(true)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	req := srv.Requests()[0]
+	if len(req.Images) != 2 {
+		t.Fatalf("sent %d images, want the thumbnail and the image", len(req.Images))
+	}
+	if !strings.Contains(req.Prompt, "small overview") {
+		t.Error("prompt should explain the thumbnail")
+	}
+	for i, want := range []image.Point{{512, 300}, {1024, 600}} {
+		data, err := base64.StdEncoding.DecodeString(req.Images[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode image %d: %v", i, err)
+		}
+		if got := (image.Point{cfg.Width, cfg.Height}); got != want {
+			t.Errorf("image %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// Images no larger than the thumbnail are sent alone
+	if _, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithThumbnailHint(true)); err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if req := srv.Requests()[1]; len(req.Images) != 1 || strings.Contains(req.Prompt, "small overview") {
+		t.Errorf("small image: sent %d images, want 1 without thumbnail guidance", len(req.Images))
+	}
+}
+
+func TestExtractBytes_NormalizeWhitespace(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "messy_response.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := ollamatest.NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusOK, string(fixture)
+	})
+
+	result, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL), WithNormalizeWhitespace(true))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if want := "ACME Store\nTOTAL 9.99\n\n\nThank you!"; result.Text.Raw != want {
+		t.Errorf("Raw = %q, want %q", result.Text.Raw, want)
+	}
+	var lines []string
+	for _, l := range result.Text.Lines {
+		lines = append(lines, l.Text)
+	}
+	if want := []string{"ACME Store", "TOTAL 9.99", "Thank you!"}; !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	// By default the model's spacing is kept
+	result, err = ExtractBytes(context.Background(), testPNG(t), ".png", WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+	if !strings.HasPrefix(result.Text.Raw, "\n\n   ACME    Store") || result.Text.Lines[0].Text != "   ACME    Store  " {
+		t.Errorf("Raw = %q, line 1 = %q; want the spacing unchanged", result.Text.Raw, result.Text.Lines[0].Text)
+	}
+}
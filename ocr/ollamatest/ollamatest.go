@@ -0,0 +1,153 @@
+// Package ollamatest provides a fake Ollama server for tests. It emulates the
+// /api/tags and /api/generate endpoints used by this module, so the whole
+// OCR pipeline can be exercised without a running model.
+//
+// Example usage:
+//
+//	srv := ollamatest.NewServer(t, nil)
+//	result, err := ocr.Extract(ctx, "testdata/receipt.png", ocr.WithOllamaURL(srv.URL))
+package ollamatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+)
+
+// Response is a canned, schema-valid model response for a receipt.
+const Response = `{"metadata":{"language":"en","document_type":"receipt","confidence_score":0.9},` +
+	`"text":{"raw":"ACME Store\nTOTAL 9.99","lines":[` +
+	`{"text":"ACME Store","bounding_box":{"x":10,"y":10,"width":120,"height":20},"confidence":0.95},` +
+	`{"text":"TOTAL 9.99","bounding_box":{"x":10,"y":40,"width":100,"height":20},"confidence":0.9}]},` +
+	`"structured_data":{"key_value_pairs":{"total":"9.99"},"tables":[]},` +
+	`"summary":"A receipt from ACME Store."}`
+
+// Handler answers a generate request with an HTTP status and, for
+// http.StatusOK, the model's response text. Other statuses send the text as
+// the error body. Requests with Stream set get the text in chunks of
+// StreamChunkSize runes.
+type Handler func(req client.GenerateRequest) (status int, response string)
+
+// Server is a fake Ollama server. It records every generate request.
+type Server struct {
+	*httptest.Server
+
+	handler Handler
+
+	mu       sync.Mutex
+	requests []client.GenerateRequest
+	models   []string
+}
+
+// NewServer starts a fake Ollama server that is closed when the test ends.
+// A nil handler answers every generate request with Response.
+func NewServer(tb testing.TB, handler Handler) *Server {
+	tb.Helper()
+
+	if handler == nil {
+		handler = func(client.GenerateRequest) (int, string) { return http.StatusOK, Response }
+	}
+
+	s := &Server{handler: handler}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/api/generate", s.handleGenerate)
+
+	s.Server = httptest.NewServer(mux)
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the generate requests received so far.
+func (s *Server) Requests() []client.GenerateRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]client.GenerateRequest(nil), s.requests...)
+}
+
+// SetModels sets the model names listed by /api/tags. The list is empty by
+// default.
+func (s *Server) SetModels(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = append([]string(nil), names...)
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, _ *http.Request) {
+	type model struct {
+		Name string `json:"name"`
+	}
+	s.mu.Lock()
+	tags := struct {
+		Models []model `json:"models"`
+	}{Models: []model{}}
+	for _, name := range s.models {
+		tags.Models = append(tags.Models, model{Name: name})
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var req client.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	status, response := s.handler(req)
+	if status != http.StatusOK {
+		http.Error(w, response, status)
+		return
+	}
+
+	final := client.GenerateResponse{
+		Model:           req.Model,
+		Response:        response,
+		Done:            true,
+		PromptEvalCount: 100,
+		EvalCount:       50,
+	}
+	if !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(final)
+		return
+	}
+
+	// Stream the response in small pieces, like a model emitting tokens
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, chunk := range chunks(response, StreamChunkSize) {
+		enc.Encode(client.GenerateResponse{Model: req.Model, Response: chunk})
+		w.(http.Flusher).Flush()
+	}
+	final.Response = ""
+	enc.Encode(final)
+}
+
+// StreamChunkSize is the number of runes per chunk of a streamed response.
+const StreamChunkSize = 8
+
+// chunks splits s into pieces of at most n runes.
+func chunks(s string, n int) []string {
+	var out []string
+	runes := []rune(s)
+	for len(runes) > n {
+		out = append(out, string(runes[:n]))
+		runes = runes[n:]
+	}
+	if len(runes) > 0 {
+		out = append(out, string(runes))
+	}
+	return out
+}
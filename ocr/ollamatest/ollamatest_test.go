@@ -0,0 +1,82 @@
+package ollamatest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+func TestServer_DefaultResponse(t *testing.T) {
+	srv := NewServer(t, nil)
+	c := client.NewOllamaClient(srv.URL, 5*time.Second)
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	resp, err := c.Generate(context.Background(), client.GenerateRequest{Model: "m", Images: []string{"aW1n"}})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if _, err := utils.ParseAndValidateJSON(resp.Response); err != nil {
+		t.Errorf("canned Response does not parse: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].Model != "m" {
+		t.Errorf("Requests() = %+v, want one request for model m", reqs)
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	srv := NewServer(t, nil)
+	c := client.NewOllamaClient(srv.URL, 5*time.Second)
+
+	var n int
+	resp, err := c.GenerateStream(context.Background(), client.GenerateRequest{Model: "m"}, func(chunk string) {
+		if len([]rune(chunk)) > StreamChunkSize {
+			t.Errorf("chunk %q is longer than %d runes", chunk, StreamChunkSize)
+		}
+		n++
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream: %v", err)
+	}
+	if resp.Response != Response || resp.EvalCount != 50 {
+		t.Errorf("streamed response = %+v, want Response with its token counts", resp)
+	}
+	if n < 2 {
+		t.Errorf("got %d chunks, want the response split up", n)
+	}
+}
+
+func TestServer_ErrorStatus(t *testing.T) {
+	srv := NewServer(t, func(client.GenerateRequest) (int, string) {
+		return http.StatusInternalServerError, "model crashed"
+	})
+	c := client.NewOllamaClient(srv.URL, 5*time.Second)
+
+	if _, err := c.Generate(context.Background(), client.GenerateRequest{Model: "m"}); err == nil {
+		t.Fatal("expected error for a non-200 response")
+	}
+}
+
+func TestServer_SetModels(t *testing.T) {
+	srv := NewServer(t, nil)
+	c := client.NewOllamaClient(srv.URL, 5*time.Second)
+
+	models, err := c.ListModels(context.Background())
+	if err != nil || len(models) != 0 {
+		t.Fatalf("ListModels = %q, %v; want no models by default", models, err)
+	}
+
+	srv.SetModels("llama3.2-vision:latest")
+	models, err = c.ListModels(context.Background())
+	if err != nil || len(models) != 1 || models[0] != "llama3.2-vision:latest" {
+		t.Errorf("ListModels = %q, %v; want the configured model", models, err)
+	}
+}
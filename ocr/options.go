@@ -1,6 +1,19 @@
 package ocr
 
-import "time"
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/prompt"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
 
 // Option is a functional option for configuring OCR extraction.
 type Option func(*Config)
@@ -33,6 +46,19 @@ func WithBoundingBoxes(enabled bool) Option {
 	}
 }
 
+// WithBoundingBoxUnits converts bounding boxes to pixels or normalized [0,1]
+// coordinates using the image dimensions. The model's units are detected
+// from its output; conversion is skipped when the image size is unknown.
+// The resulting units are reported in Text.BoundingBoxUnits.
+func WithBoundingBoxUnits(units models.BoundingBoxUnits) Option {
+	return func(c *Config) {
+		switch units {
+		case models.BoundingBoxUnitsPixels, models.BoundingBoxUnitsNormalized:
+			c.BoundingBoxUnits = units
+		}
+	}
+}
+
 // WithConfidenceScores enables or disables confidence scores for text lines.
 func WithConfidenceScores(enabled bool) Option {
 	return func(c *Config) {
@@ -40,6 +66,16 @@ func WithConfidenceScores(enabled bool) Option {
 	}
 }
 
+// WithEngine selects the OCR backend. Unknown values are ignored.
+func WithEngine(e EngineType) Option {
+	return func(c *Config) {
+		switch e {
+		case EngineOllama, EngineTesseract, EngineAuto:
+			c.Engine = e
+		}
+	}
+}
+
 // WithModel sets the Ollama model to use for OCR.
 func WithModel(model string) Option {
 	return func(c *Config) {
@@ -49,6 +85,20 @@ func WithModel(model string) Option {
 	}
 }
 
+// WithFallbackModels sets alternate models to try, in order, when the primary
+// model fails (e.g. not pulled, out of memory, or repeated invalid JSON).
+// The model that produced the result is reported in OCRResult.Usage.Model.
+func WithFallbackModels(models []string) Option {
+	return func(c *Config) {
+		c.FallbackModels = nil
+		for _, m := range models {
+			if m != "" {
+				c.FallbackModels = append(c.FallbackModels, m)
+			}
+		}
+	}
+}
+
 // WithTimeout sets the request timeout.
 func WithTimeout(d time.Duration) Option {
 	return func(c *Config) {
@@ -58,6 +108,32 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
+// WithDeadlinePadding reserves d of the request deadline for work after the
+// model call: each call to the model gets a deadline d earlier than the
+// request's, so a response that arrives late can still be parsed and merged
+// instead of failing with a context error. If less than d remains, the model
+// call gets all of it. Negative values are ignored.
+func WithDeadlinePadding(d time.Duration) Option {
+	return func(c *Config) {
+		if d >= 0 {
+			c.DeadlinePadding = d
+		}
+	}
+}
+
+// WithResponseTimeout bounds each model call to d, while WithTimeout bounds
+// the whole Extract call: download, preprocessing and every model call,
+// including retries, fallback models and each page of a PDF. A model call
+// never outlives the overall timeout, so it gets d or whatever remains of the
+// overall budget, whichever is shorter. Values of 0 or less are ignored.
+func WithResponseTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		if d > 0 {
+			c.ResponseTimeout = d
+		}
+	}
+}
+
 // WithOllamaURL sets a custom Ollama API endpoint.
 func WithOllamaURL(url string) Option {
 	return func(c *Config) {
@@ -67,6 +143,157 @@ func WithOllamaURL(url string) Option {
 	}
 }
 
+// WithProxy routes image downloads through the given proxy, independent of
+// the HTTP_PROXY/HTTPS_PROXY environment variables. SSRF checks still apply
+// to the image URL, not to the proxy. Requests to Ollama are not proxied;
+// use WithTransport for that. A nil proxy restores the environment default.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *Config) {
+		c.Proxy = proxy
+	}
+}
+
+// WithMaxConcurrency sets how many ExtractBatch sources are processed by the
+// model at once. A local Ollama server handles one request at a time unless
+// OLLAMA_NUM_PARALLEL is raised, so the default is 1. Values below 1 are
+// ignored.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Config) {
+		if n >= 1 {
+			c.MaxConcurrency = n
+		}
+	}
+}
+
+// WithMaxConcurrentDownloads sets how many ExtractBatch sources are
+// downloaded or read at once, independent of WithMaxConcurrency. Downloads
+// run ahead of the model so it never waits on the network, while the limit
+// keeps a large batch of URLs from saturating the network or tripping rate
+// limits. Values below 1 are ignored.
+func WithMaxConcurrentDownloads(n int) Option {
+	return func(c *Config) {
+		if n >= 1 {
+			c.MaxConcurrentDownloads = n
+		}
+	}
+}
+
+// WithCircuitBreaker makes a Client fail fast when the Ollama server goes
+// down: after threshold consecutive connection failures, calls return
+// ErrOllamaUnavailable (ErrBackendUnavailable with WithBackend) without
+// contacting the server for cooldown. The next call after that probes the
+// server and closes the breaker if it answers. The breaker is shared by every
+// call of the Client, so it takes effect when given to NewClient (or a
+// package-level function), not as a per-call option. Values below 1 or a
+// non-positive cooldown are ignored.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Config) {
+		if threshold >= 1 && cooldown > 0 {
+			c.CircuitBreakerThreshold = threshold
+			c.CircuitBreakerCooldown = cooldown
+		}
+	}
+}
+
+// WithSourceValidator registers fn to approve each source after its type,
+// extension and (when known) size are determined but before anything is
+// downloaded or sent to the model. A non-nil error aborts the extraction with
+// ErrSourceRejected wrapping that error. Nil is ignored.
+func WithSourceValidator(fn func(source string, info SourceInfo) error) Option {
+	return func(c *Config) {
+		if fn != nil {
+			c.SourceValidator = fn
+		}
+	}
+}
+
+// WithURLRefresher handles expiring presigned URLs, e.g. from S3 or Azure
+// Blob Storage: when a download is refused with HTTP 401 or 403, fn is
+// called with the source URL and the download is retried once with the URL
+// it returns. The refreshed URL passes the same SSRF checks and
+// WithSourceValidator as the original and is not logged, since it usually
+// carries credentials; the result keeps the original source. Nil is ignored.
+func WithURLRefresher(fn func(source string) (string, error)) Option {
+	return func(c *Config) {
+		if fn != nil {
+			c.URLRefresher = fn
+		}
+	}
+}
+
+// WithProgressiveParse streams the model's answer and calls fn with a partial
+// OCRResult each time a top-level field of it, such as metadata or text,
+// is complete, so a UI can show the document type and raw text before the
+// structured data is done. Each call gets a new result holding every field
+// so far; fields still missing are empty. fn runs on the goroutine doing the
+// extraction and delays it while it runs.
+//
+// Partial results are only produced for single images, not for PDFs, crop
+// regions or images split by WithAutoStrip, and only by the Ollama backend.
+// If a response is retried, the partial results start over. The final result
+// is returned by Extract as usual. Nil is ignored.
+func WithProgressiveParse(fn func(*models.OCRResult)) Option {
+	return func(c *Config) {
+		if fn != nil {
+			c.ProgressiveParse = fn
+		}
+	}
+}
+
+// WithResultProcessors appends fns to the processors that run, in order,
+// over the final OCRResult before Extract returns it, e.g. to redact values
+// or rename keys. Each processor sees the changes of the ones before it. An
+// error fails the extraction with ErrResultProcessor in strict mode;
+// otherwise it is logged and added to the warnings, and the remaining
+// processors still run. Nil functions are ignored.
+func WithResultProcessors(fns ...func(*models.OCRResult) error) Option {
+	return func(c *Config) {
+		for _, fn := range fns {
+			if fn != nil {
+				c.ResultProcessors = append(c.ResultProcessors, fn)
+			}
+		}
+	}
+}
+
+// WithConfidenceCalibrator maps every confidence the model reports through
+// fn, e.g. a lookup table fitted to your own measurements, since raw model
+// confidences tend to cluster near 0.9. It applies to metadata, line,
+// key-value and table confidences alike, before confidence thresholds such
+// as WithMinTableConfidence and WithAutoRotate are checked. Results are
+// clamped to [0, 1]; confidences of 0, meaning none was reported, and the
+// confidence 1 of PDF form values are left alone. Nil is ignored.
+func WithConfidenceCalibrator(fn func(float64) float64) Option {
+	return func(c *Config) {
+		if fn != nil {
+			c.ConfidenceCalibrator = fn
+		}
+	}
+}
+
+// WithTransport sets a custom HTTP transport for Ollama requests, e.g. for
+// instrumentation or custom TLS. The Timeout option still applies.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Config) {
+		if rt != nil {
+			c.Transport = rt
+		}
+	}
+}
+
+// WithBackend sends model requests to b instead of Ollama, e.g. a
+// client.OpenAICompatClient for a llama.cpp server. The backend is used as
+// configured, so OllamaURL and Transport are not used; Timeout still bounds
+// the whole extraction. If b cannot be reached, calls fail with
+// ErrBackendUnavailable instead of ErrOllamaUnavailable. Nil is ignored.
+func WithBackend(b client.VisionBackend) Option {
+	return func(c *Config) {
+		if b != nil {
+			c.Backend = b
+		}
+	}
+}
+
 // WithTemperature sets the model temperature.
 func WithTemperature(t float64) Option {
 	return func(c *Config) {
@@ -76,6 +303,210 @@ func WithTemperature(t float64) Option {
 	}
 }
 
+// WithNumPredict caps the tokens the model generates per request. Too low a
+// cap cuts the JSON short on dense documents. Values below 1 are ignored.
+func WithNumPredict(n int) Option {
+	return func(c *Config) {
+		if n >= 1 {
+			c.NumPredict = n
+		}
+	}
+}
+
+// WithTopP sets the nucleus sampling cutoff. 0 leaves the server default;
+// values outside [0, 1] are ignored.
+func WithTopP(p float64) Option {
+	return func(c *Config) {
+		if p >= 0 && p <= 1 {
+			c.TopP = p
+		}
+	}
+}
+
+// WithStop sets the sequences that end generation, replacing any set
+// before. No arguments clears them.
+func WithStop(sequences ...string) Option {
+	return func(c *Config) {
+		c.Stop = append([]string(nil), sequences...)
+	}
+}
+
+// WithNumGPU sets the number of model layers Ollama offloads to the GPU.
+// Use 0 to force CPU-only inference. Negative values are ignored.
+func WithNumGPU(n int) Option {
+	return func(c *Config) {
+		if n >= 0 {
+			c.NumGPU = &n
+		}
+	}
+}
+
+// WithNumThread sets the number of CPU threads Ollama uses for inference.
+// Negative values are ignored.
+func WithNumThread(n int) Option {
+	return func(c *Config) {
+		if n >= 0 {
+			c.NumThread = &n
+		}
+	}
+}
+
+// WithCompactPrompt sends a terser prompt that lists the fields of the JSON
+// schema with their types instead of an annotated example and keeps only the
+// essential rules. It is less than half the size of the default prompt,
+// leaving more context to small models, and still demands JSON-only output.
+// Larger models may follow the default prompt more closely. Registered
+// prompt templates decide for themselves whether to honor it.
+func WithCompactPrompt(enabled bool) Option {
+	return func(c *Config) {
+		c.CompactPrompt = enabled
+	}
+}
+
+// WithFewShotExamples shows the model worked examples, each a description of
+// a document and the JSON expected for it, before the prompt and its schema.
+// Examples help with niche document types but are sent with every model call,
+// so only the first prompt.MaxExamples are used; keep their outputs short.
+// Examples whose output is not valid JSON are ignored, and an empty list
+// removes all examples.
+func WithFewShotExamples(examples []prompt.Example) Option {
+	return func(c *Config) {
+		c.FewShotExamples = nil
+		for _, ex := range examples {
+			if json.Valid([]byte(ex.Output)) && len(c.FewShotExamples) < prompt.MaxExamples {
+				c.FewShotExamples = append(c.FewShotExamples, ex)
+			}
+		}
+	}
+}
+
+// WithLogprobConfidence asks the server for the log probability of every
+// generated token and sets each line's confidence to the geometric mean
+// probability of the tokens of its text. This is grounded in what the model
+// actually generated, unlike the confidence it reports for itself. Servers
+// that do not return logprobs, such as older Ollama versions, leave the
+// model-reported confidences in place. It requires WithConfidenceScores.
+func WithLogprobConfidence(enabled bool) Option {
+	return func(c *Config) {
+		c.LogprobConfidence = enabled
+	}
+}
+
+// WithThumbnailHint sends a downscaled overview of the image, at most 512
+// pixels per side, ahead of the full image and tells the model to use it to
+// understand the layout while reading text from the full image. Whether it
+// helps depends on the model and has not been measured; it costs the prompt
+// tokens of a second image. Images that are no larger than the overview are
+// sent alone, and PDF pages each get their own overview.
+func WithThumbnailHint(enabled bool) Option {
+	return func(c *Config) {
+		c.ThumbnailHint = enabled
+	}
+}
+
+// WithSchemaValidation checks each model answer against the JSON Schema of
+// the prompt, utils.ResponseSchema, before accepting it. Answers with a value
+// of the wrong type, an unknown key or a missing required key, which the
+// forgiving parser would otherwise accept, are retried like unparsable ones
+// and count against WithMaxTotalRetries. It has no effect with WithRawJSON.
+func WithSchemaValidation(enabled bool) Option {
+	return func(c *Config) {
+		c.SchemaValidation = enabled
+	}
+}
+
+// WithRetryOnEmptyText retries a model call once, with an instruction to
+// look again for all text, when the answer is valid JSON but holds no text
+// and no lines. Such a retry counts against WithMaxTotalRetries; once that is
+// spent the empty answer is accepted. Blank images skipped by WithSkipBlank
+// are not sent at all.
+func WithRetryOnEmptyText(enabled bool) Option {
+	return func(c *Config) {
+		c.RetryOnEmptyText = enabled
+	}
+}
+
+// WithRetryBackoff waits d before retrying a model call after an unusable
+// answer, doubling the wait for each further retry, so a struggling server
+// gets a moment to recover. By default retries are immediate. A retry whose
+// wait would end after the deadline of the request context, from WithTimeout
+// or the caller, is not attempted: the call fails at once with the last
+// error instead of sleeping for nothing. Values of 0 or less are ignored.
+func WithRetryBackoff(d time.Duration) Option {
+	return func(c *Config) {
+		if d > 0 {
+			c.RetryBackoff = d
+		}
+	}
+}
+
+// WithRetryMaxBackoff caps the wait before any one retry from
+// WithRetryBackoff at d, however many retries came before. Values of 0 or
+// less are ignored.
+func WithRetryMaxBackoff(d time.Duration) Option {
+	return func(c *Config) {
+		if d > 0 {
+			c.RetryMaxBackoff = d
+		}
+	}
+}
+
+// WithMaxTotalRetries caps the JSON parse-failure retries of one extraction
+// at n, shared by every page of a PDF (and every crop region). Without it
+// each page retries once, so a flaky model can double the model calls of a
+// large PDF. Once the budget is spent the next parse failure fails the
+// extraction immediately. Use 0 to disable retries. Negative values are
+// ignored.
+func WithMaxTotalRetries(n int) Option {
+	return func(c *Config) {
+		if n >= 0 {
+			c.MaxTotalRetries = &n
+		}
+	}
+}
+
+// WithMaxTotalTokens caps the prompt and eval tokens spent on one PDF at n,
+// a hard cost ceiling on shared hardware. Pages are processed in order and
+// the running total is checked after each one; once it exceeds n the
+// remaining pages are skipped, and the result holds the pages processed so
+// far with Usage.BudgetExceeded set and a warning naming the skipped pages.
+// The page that crosses the budget is kept, so the total may overshoot n by
+// up to one page. Single images and crop regions are not limited. Values
+// below 1 are ignored.
+func WithMaxTotalTokens(n int) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.MaxTotalTokens = n
+		}
+	}
+}
+
+// WithMaxImagePixels rejects images with more than n pixels (width times
+// height) with ErrImageTooLarge. The size is read from the image header, so
+// the check runs before the pixels are decoded and guards against the memory
+// a 10000x10000 image would take to decode, whatever its aspect ratio. PDFs
+// are not checked since their pages are rendered at a fixed DPI. Values
+// below 1 are ignored.
+func WithMaxImagePixels(n int64) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.MaxImagePixels = n
+		}
+	}
+}
+
+// WithMinImageDimension rejects images narrower or shorter than n pixels
+// with ErrImageTooSmall instead of spending a model call on a thumbnail that
+// cannot yield usable text. PDFs are not checked since their pages are
+// rendered at a fixed DPI. Values below 1 are ignored.
+func WithMinImageDimension(n int) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.MinImageDimension = n
+		}
+	}
+}
+
 // WithMaxFileSize sets the maximum allowed file size in bytes.
 func WithMaxFileSize(size int64) Option {
 	return func(c *Config) {
@@ -84,3 +515,542 @@ func WithMaxFileSize(size int64) Option {
 		}
 	}
 }
+
+// WithExpectedChecksum makes extraction fail with ErrChecksumMismatch unless
+// the source data has the hex checksum sum under algo, "sha256" or "sha512".
+// The check runs after a URL is downloaded (or a file read) and before
+// anything is sent to the model, so a tampered or wrong file is never
+// processed. Since a typo must not silently disable the check, an unknown
+// algorithm also fails extraction rather than being ignored. An empty sum
+// removes the check. It is meant for single calls, e.g.
+//
+//	c.Extract(ctx, url, ocr.WithExpectedChecksum("sha256", sum))
+func WithExpectedChecksum(algo, sum string) Option {
+	return func(c *Config) {
+		c.ChecksumAlgorithm = algo
+		c.ExpectedChecksum = strings.ToLower(strings.TrimSpace(sum))
+	}
+}
+
+// WithQualityReport adds a QualityReport with line counts and confidence
+// statistics to the result, so quality can be tracked over time without
+// custom aggregation. It needs confidence scores; if they are disabled the
+// report is omitted and a warning is added to the result.
+func WithQualityReport(enabled bool) Option {
+	return func(c *Config) {
+		c.QualityReport = enabled
+	}
+}
+
+// WithTimings adds a Timings breakdown (download, preprocess, model, parse
+// and total milliseconds) to the result to help find bottlenecks.
+func WithTimings(enabled bool) Option {
+	return func(c *Config) {
+		c.Timings = enabled
+	}
+}
+
+// WithRetainImage keeps the exact image bytes that were processed on the
+// result, retrievable via OCRResult.RetainedImage. For a single image these
+// are the bytes sent to the model, e.g. the JPEG of WithImageEncoding. The
+// bytes are not serialized to JSON.
+func WithRetainImage(enabled bool) Option {
+	return func(c *Config) {
+		c.RetainImage = enabled
+	}
+}
+
+// WithExpectedDocumentType tells the model which type to expect so it focuses
+// extraction accordingly, while still reporting the type it actually sees. If
+// that differs, Extract fails with ErrDocumentTypeMismatch in strict mode and
+// records a warning otherwise. Invalid types are ignored.
+func WithExpectedDocumentType(dt models.DocumentType) Option {
+	return func(c *Config) {
+		if utils.ValidDocumentTypes[dt] && dt != models.DocumentTypeUnknown {
+			c.ExpectedDocumentType = dt
+		}
+	}
+}
+
+// WithAllowedLanguages restricts the documents Extract accepts to those whose
+// detected language is one of languages. Codes are compared case-insensitively,
+// and a code without a region, like "en", also allows its regional variants,
+// like "en-US". A document in another language, or with no detected language,
+// fails in strict mode with ErrLanguageNotAllowed before validation and result
+// processors run; otherwise it gets a warning. It requires
+// WithLanguageDetection. Empty codes are ignored, and no languages lifts the
+// restriction.
+func WithAllowedLanguages(languages []string) Option {
+	return func(c *Config) {
+		c.AllowedLanguages = nil
+		for _, lang := range languages {
+			if lang = strings.TrimSpace(lang); lang != "" {
+				c.AllowedLanguages = append(c.AllowedLanguages, lang)
+			}
+		}
+	}
+}
+
+// WithSourceMetadata attaches the caller's own data, such as a tenant ID or
+// document UUID, to every result as Source.Metadata, for routing results
+// downstream. The package never reads it. The map is copied, and each result
+// gets its own copy; PDF pages and crop regions share their document's.
+// Nil or an empty map removes it.
+func WithSourceMetadata(metadata map[string]string) Option {
+	return func(c *Config) {
+		c.SourceMetadata = nil
+		if len(metadata) > 0 {
+			c.SourceMetadata = maps.Clone(metadata)
+		}
+	}
+}
+
+// WithStrictMode makes Extract return an error for conditions that are
+// otherwise only logged or recorded as warnings.
+func WithStrictMode(enabled bool) Option {
+	return func(c *Config) {
+		c.StrictMode = enabled
+	}
+}
+
+// WithDebugRequestLog logs every request sent to Ollama at debug level and
+// lowers the log level to debug, so other debug logs are shown too. Images
+// are replaced by size placeholders and the prompt is truncated (see
+// WithDebugPromptLength).
+func WithDebugRequestLog(enabled bool) Option {
+	return func(c *Config) {
+		c.DebugRequestLog = enabled
+	}
+}
+
+// WithDebugPromptLength sets how many characters of the prompt are included
+// in debug request logs.
+func WithDebugPromptLength(n int) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.DebugPromptLength = n
+		}
+	}
+}
+
+// WithClock sets the clock request IDs are generated from, so tests can fix
+// the time and predict IDs: every request then gets the ID
+// "ocr-<nanoseconds of clock()>". Timeouts and latencies always use the
+// real time. Nil is ignored.
+func WithClock(clock func() time.Time) Option {
+	return func(c *Config) {
+		if clock != nil {
+			c.Clock = clock
+		}
+	}
+}
+
+// WithKeyValueConfidence asks the model for a confidence per key-value pair
+// and reports it in StructuredData.KeyValueDetails, next to the plain
+// KeyValuePairs map. It needs confidence scores and structured extraction;
+// without confidence scores no details are reported and a warning is added
+// to the result.
+func WithKeyValueConfidence(enabled bool) Option {
+	return func(c *Config) {
+		c.KeyValueConfidence = enabled
+	}
+}
+
+// WithMinTableConfidence asks the model for a confidence per table and drops
+// tables below min from StructuredData.Tables, removing spurious tables such
+// as aligned form fields. Tables without a confidence from the model are
+// kept. It needs confidence scores and structured extraction; without
+// confidence scores every table is kept and a warning is added to the
+// result. Values outside (0, 1] are ignored.
+func WithMinTableConfidence(min float64) Option {
+	return func(c *Config) {
+		if min > 0 && min <= 1 {
+			c.MinTableConfidence = min
+		}
+	}
+}
+
+// WithMergeAdjacentLines merges lines that a model split into fragments of
+// one visual line, using their bounding boxes. It needs bounding boxes; if
+// they are disabled lines are left as-is and a warning is added to the result.
+func WithMergeAdjacentLines(enabled bool) Option {
+	return func(c *Config) {
+		c.MergeAdjacentLines = enabled
+	}
+}
+
+// WithSummaryLength asks the model for a short (one or two sentences),
+// medium (one paragraph) or long (several paragraphs) summary. The model
+// controls the actual length. Unknown values are ignored.
+func WithSummaryLength(l SummaryLength) Option {
+	return func(c *Config) {
+		switch l {
+		case SummaryLengthShort, SummaryLengthMedium, SummaryLengthLong:
+			c.SummaryLength = l
+		}
+	}
+}
+
+// WithSummaryMaxWords asks the model for a summary of at most n words and
+// truncates the returned summary to n words if the model ignores the
+// instruction. Values below 1 are ignored.
+func WithSummaryMaxWords(n int) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.SummaryMaxWords = n
+		}
+	}
+}
+
+// WithCropRegions restricts OCR to the given pixel regions of the image,
+// e.g. the fields of a fixed-layout form. Each region is cropped and
+// processed separately; returned lines carry the index of their region and
+// bounding boxes are in original-image coordinates. Regions with a negative
+// origin or a non-positive size are ignored. Has no effect on PDFs.
+func WithCropRegions(regions []models.BoundingBox) Option {
+	return func(c *Config) {
+		var valid []models.BoundingBox
+		for _, r := range regions {
+			if r.X >= 0 && r.Y >= 0 && r.Width > 0 && r.Height > 0 {
+				valid = append(valid, r)
+			}
+		}
+		c.CropRegions = valid
+	}
+}
+
+// WithAutoRotate handles upside-down scans: when the model's overall
+// confidence is below AutoRotateConfidenceThreshold, OCR is run again on the
+// image rotated by 180 degrees and the more confident result is kept. The
+// result's image.rotation is 180 when the rotated image won, in which case
+// bounding boxes refer to the rotated image unless WithOriginalCoordinates is
+// set. Costs a second model call for low-confidence images. Needs confidence
+// scores; PDFs, crop regions, images split by WithAutoStrip and requests
+// with WithCandidates are not rotated.
+func WithAutoRotate(enabled bool) Option {
+	return func(c *Config) {
+		c.AutoRotate = enabled
+	}
+}
+
+// WithAutoStrip splits images more than AutoStripAspectRatio times taller
+// than wide, such as a long receipt scanned as one strip, into horizontal
+// strips of equal height, each at most twice as tall as wide, so text keeps
+// its detail at the model's input resolution. Every strip is a separate
+// model call; the results are concatenated top to bottom, with bounding
+// boxes in original-image pixels. Has no effect on PDFs or with
+// WithCropRegions.
+func WithAutoStrip(enabled bool) Option {
+	return func(c *Config) {
+		c.AutoStrip = enabled
+	}
+}
+
+// WithCandidates generates n extractions instead of one, for a reviewer to
+// pick from. The engine is called n times with seeds 0 to n-1; every call
+// after the first uses at least CandidateTemperature, so the answers can
+// differ. All of them are returned in OCRResult.Candidates, ranked by the
+// model's overall confidence, and the most confident is the result itself.
+// A failed call only drops its candidate, with a warning. Costs n model
+// calls; has no effect with WithCropRegions or WithAutoStrip. Values of 0
+// or less are ignored.
+func WithCandidates(n int) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.Candidates = n
+		}
+	}
+}
+
+// WithOriginalCoordinates maps bounding boxes back to the coordinates of the
+// original image when preprocessing changed its geometry, e.g. when
+// WithAutoRotate rotated it. The applied transforms are listed in the
+// result's transforms either way. Boxes keep their units. Crop region
+// boxes are always mapped back.
+func WithOriginalCoordinates(enabled bool) Option {
+	return func(c *Config) {
+		c.OriginalCoordinates = enabled
+	}
+}
+
+// WithValidateImageBytes fully decodes each image before the model call and
+// fails with ErrImageDecodeFailed if the pixels cannot be read, e.g. for a
+// truncated JPEG, instead of leaving Ollama to fail on it. PDFs are not
+// checked.
+func WithValidateImageBytes(enabled bool) Option {
+	return func(c *Config) {
+		c.ValidateImageBytes = enabled
+	}
+}
+
+// WithSkipBlank detects blank images (e.g. an empty scanner page) before the
+// model call and returns an empty but valid result with Metadata.Blank set,
+// instead of spending a model call that may hallucinate content.
+func WithSkipBlank(enabled bool) Option {
+	return func(c *Config) {
+		c.SkipBlank = enabled
+	}
+}
+
+// WithImageEncoding selects the format images are sent to the model in.
+// "png" (the default) sends the preprocessed image as is, keeping full
+// fidelity; JPEG input stays JPEG. "jpeg" re-encodes every image, including
+// crops and rendered PDF pages, as JPEG at WithJPEGQuality, which shrinks
+// requests for photos considerably. Unknown values are ignored.
+func WithImageEncoding(enc ImageEncoding) Option {
+	return func(c *Config) {
+		switch enc {
+		case ImageEncodingPNG, ImageEncodingJPEG:
+			c.ImageEncoding = enc
+		}
+	}
+}
+
+// WithJPEGQuality sets the quality (1-100) used by WithImageEncoding("jpeg").
+// Values outside that range are ignored.
+func WithJPEGQuality(quality int) Option {
+	return func(c *Config) {
+		if quality >= 1 && quality <= 100 {
+			c.JPEGQuality = quality
+		}
+	}
+}
+
+// WithReflowLines splits entries of Text.Lines longer than maxLen characters
+// into several lines, for models that return a whole paragraph or document as
+// one line. Lines are split at sentence ends where possible and otherwise
+// between words, never within a word; a word longer than maxLen stays whole.
+// The pieces keep the bounding box, confidence and line number of the
+// original line. Text.Raw is not changed. Values below 1 are ignored.
+func WithReflowLines(maxLen int) Option {
+	return func(c *Config) {
+		if maxLen > 0 {
+			c.ReflowLines = maxLen
+		}
+	}
+}
+
+// WithMaxLines keeps at most n entries in Text.Lines and sets Text.Truncated
+// when lines were dropped, so very dense documents do not bloat the result.
+// Text.Raw is kept complete. For PDFs the limit applies to the merged lines
+// of all pages. Line merging and the quality report only see the kept lines.
+// 0 (the default) means no limit; negative values are ignored.
+func WithMaxLines(n int) Option {
+	return func(c *Config) {
+		if n >= 0 {
+			c.MaxLines = n
+		}
+	}
+}
+
+// WithNormalizeWhitespace tidies the spacing of the text and each line:
+// runs of spaces and tabs are collapsed to one space, leading and trailing
+// whitespace of every line is trimmed, and blank lines at the start and end
+// of the raw text are removed. Newlines within the text are kept. Disabled by
+// default, which keeps the model's spacing exactly.
+func WithNormalizeWhitespace(enabled bool) Option {
+	return func(c *Config) {
+		c.NormalizeWhitespace = enabled
+	}
+}
+
+// WithDejoinHyphens rejoins words that justified text split across a line
+// break with a hyphen, so "inter-\nnational trade" becomes
+// "international\ntrade" in Text.Raw. Only a hyphen after a letter at the end
+// of a line followed by a lowercase letter is removed; hyphenated compounds
+// within a line and breaks before a capital are kept. Text.Lines are left as
+// the model returned them.
+func WithDejoinHyphens(enabled bool) Option {
+	return func(c *Config) {
+		c.DejoinHyphens = enabled
+	}
+}
+
+// WithSchema selects the output shape Client.Render projects results into.
+// Extraction itself always returns an OCRResult. Unknown schemas are
+// ignored; the default is SchemaStrict.
+func WithSchema(name SchemaName) Option {
+	return func(c *Config) {
+		if _, ok := renderers[name]; ok {
+			c.Schema = name
+		}
+	}
+}
+
+// WithKeepEmptyLines keeps lines whose text is empty or only whitespace in
+// Text.Lines. By default they are dropped and counted in
+// Text.DroppedEmptyLines, since some models emit them and output validation
+// rejects lines without text.
+func WithKeepEmptyLines(keep bool) Option {
+	return func(c *Config) {
+		c.KeepEmptyLines = keep
+	}
+}
+
+// WithLineEndings normalizes the line endings of the raw text and of each
+// line to "lf" (\n, the default) or "crlf" (\r\n), so output is byte-for-byte
+// comparable across models. Merged PDF pages are normalized as a whole.
+// "preserve" keeps whatever the model emitted. Unknown values are ignored.
+func WithLineEndings(le LineEndings) Option {
+	return func(c *Config) {
+		switch le {
+		case LineEndingsLF, LineEndingsCRLF, LineEndingsPreserve:
+			c.LineEndings = le
+		}
+	}
+}
+
+// WithTableMergePolicy normalizes table cells merged with the cell above
+// them, which models return as empty strings, repeated values or short rows.
+// Short rows are padded to the header width, then "fill-down" copies the
+// value above into empty cells, "empty" clears cells that repeat the value
+// above, and "mark" replaces both with MergedCellMarker. Only vertical merges
+// are detected. Unknown values are ignored.
+func WithTableMergePolicy(p TableMergePolicy) Option {
+	return func(c *Config) {
+		switch p {
+		case TableMergeFillDown, TableMergeEmpty, TableMergeMark:
+			c.TableMergePolicy = p
+		}
+	}
+}
+
+// WithTableSpans asks the model which table cells span several columns or
+// rows, as in the grouped headers and merged cells of complex invoices, and
+// reports them in Table.Spans, parallel to Table.Rows, so the layout can be
+// rebuilt. A spanning cell keeps its text in its top-left position; the
+// positions it covers are asked to be left empty and have a 0 by 0 span.
+// Tables without spanning cells, and all tables by default, have only the
+// plain rows. Spans describe rows, not headers.
+func WithTableSpans(enabled bool) Option {
+	return func(c *Config) {
+		c.TableSpans = enabled
+	}
+}
+
+// WithFlagEmptyStructuredData logs and adds a warning to the result when
+// structured extraction silently yields nothing: the model did not return
+// structured data at all, or returned no key-value pairs and no tables for an
+// invoice, receipt or ID card. StructuredData.Extracted tells the two cases
+// apart regardless of this option.
+func WithFlagEmptyStructuredData(enabled bool) Option {
+	return func(c *Config) {
+		c.FlagEmptyStructuredData = enabled
+	}
+}
+
+// WithDetectTextDirection asks the model for the reading direction of the
+// text and reports it as Metadata.Direction, "ltr" or "rtl", so consumers can
+// render Arabic or Hebrew documents correctly. Unclear or missing answers are
+// reported as "ltr".
+func WithDetectTextDirection(enabled bool) Option {
+	return func(c *Config) {
+		c.DetectTextDirection = enabled
+	}
+}
+
+// WithAllowFreeformDocumentType lets the model name the document type freely,
+// e.g. "bank_statement" or "letter", instead of falling back to "unknown" for
+// anything that is not an invoice, receipt, ID card or contract. The type is
+// trimmed, and values matching a DocumentType constant in any case are
+// normalized to it; only an empty type is reported as "unknown". Validation
+// then accepts any non-empty type.
+func WithAllowFreeformDocumentType(enabled bool) Option {
+	return func(c *Config) {
+		c.AllowFreeformDocumentType = enabled
+	}
+}
+
+// WithDetectMultipleDocuments asks the model whether the image holds several
+// documents, such as receipts scanned side by side, and reports the region
+// and type of each in OCRResult.Documents. Extract still returns one result
+// for the whole image and warns if more than one document was found; use
+// ExtractMultiDoc to get a result per document.
+func WithDetectMultipleDocuments(enabled bool) Option {
+	return func(c *Config) {
+		c.DetectMultipleDocuments = enabled
+	}
+}
+
+// WithFilterWatermarks asks the model to report watermarks and other
+// background text, such as "DRAFT" or "CONFIDENTIAL" across the page,
+// separately in Text.Watermarks instead of as part of the text. Lines of
+// Text.Raw and Text.Lines that still match a reported watermark, ignoring
+// case and whitespace, are dropped, so the overlay does not pollute the
+// extracted text. Line numbers keep counting the dropped lines.
+func WithFilterWatermarks(enabled bool) Option {
+	return func(c *Config) {
+		c.FilterWatermarks = enabled
+	}
+}
+
+// WithNumberLocale sets the BCP 47 locale, such as "de-DE" or "en_US", used
+// to read numbers by NormalizeAmount and LineItems, so "1.234,56" is read
+// correctly for German invoices and "1,234.56" for US ones. Without it the
+// decimal separator is guessed from each value, which is ambiguous for
+// values like "1.234". Malformed locales are ignored; an empty one restores
+// guessing.
+func WithNumberLocale(locale string) Option {
+	return func(c *Config) {
+		if locale == "" || localePattern.MatchString(locale) {
+			c.NumberLocale = locale
+		}
+	}
+}
+
+// localePattern matches BCP 47 language tags like "de", "de-DE" or "pt_BR".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+// WithPDFPassword sets the user password used to open encrypted PDFs. It is
+// passed to pdftoppm on its command line. Without it, or when pdftoppm is not
+// installed, encrypted PDFs fail with ErrPDFParseFailed instead of being sent
+// to the model unreadable.
+func WithPDFPassword(password string) Option {
+	return func(c *Config) {
+		c.PDFPassword = password
+	}
+}
+
+// WithExtractFormFields reads the values of filled-in AcroForm fields from
+// PDFs and adds them to StructuredData.KeyValuePairs, keyed by the field's
+// fully qualified name. Form values are exact where OCR can misread, so they
+// replace OCR values under the same key; StructuredData.KeyValueSources
+// tells "form" values from "ocr" ones. Pages are still OCRed as usual. PDFs
+// without a form and images are unaffected; encrypted PDFs get a warning.
+func WithExtractFormFields(enabled bool) Option {
+	return func(c *Config) {
+		c.ExtractFormFields = enabled
+	}
+}
+
+// WithExtractPDFMetadata reads the document information dictionary of PDFs
+// (title, author, subject, keywords, creator, producer and the creation and
+// modification dates) into OCRResult.PDFInfo. The values come from the file,
+// not from OCR. Images and PDFs without the dictionary are unaffected;
+// encrypted PDFs get a warning.
+func WithExtractPDFMetadata(enabled bool) Option {
+	return func(c *Config) {
+		c.ExtractPDFMetadata = enabled
+	}
+}
+
+// WithRawJSON returns the model's JSON as a generic map in OCRResult.RawData,
+// keeping fields the schema does not know about. The JSON is not validated
+// against the schema and Text and StructuredData are left empty; metadata,
+// image info and summary are still filled in where the model's values fit.
+// Multi-page PDFs list each page's JSON under "pages". Intended for
+// experimenting with prompts and models.
+func WithRawJSON(enabled bool) Option {
+	return func(c *Config) {
+		c.RawJSON = enabled
+	}
+}
+
+// WithSanitizeText strips invalid UTF-8 and control characters (other than
+// newlines, carriage returns and tabs) from every string the model returns
+// in text, structured data and summary. Enabled by default.
+func WithSanitizeText(enabled bool) Option {
+	return func(c *Config) {
+		c.SanitizeText = enabled
+	}
+}
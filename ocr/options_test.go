@@ -1,8 +1,14 @@
 package ocr
 
 import (
+	"net/http"
+	"net/url"
 	"testing"
 	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/prompt"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -114,3 +120,1008 @@ func TestOptionEdgeCases(t *testing.T) {
 		t.Error("zero max file size should not override default")
 	}
 }
+
+func TestWithRetainImage(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.RetainImage {
+		t.Fatal("RetainImage should default to false")
+	}
+
+	WithRetainImage(true)(cfg)
+	if !cfg.RetainImage {
+		t.Error("RetainImage should be true")
+	}
+}
+
+func TestWithExpectedDocumentType(t *testing.T) {
+	cfg := DefaultConfig()
+
+	WithExpectedDocumentType(models.DocumentTypeReceipt)(cfg)
+	if cfg.ExpectedDocumentType != models.DocumentTypeReceipt {
+		t.Errorf("ExpectedDocumentType = %q, want %q", cfg.ExpectedDocumentType, models.DocumentTypeReceipt)
+	}
+
+	// Invalid and unknown types should not override
+	WithExpectedDocumentType("banana")(cfg)
+	WithExpectedDocumentType(models.DocumentTypeUnknown)(cfg)
+	if cfg.ExpectedDocumentType != models.DocumentTypeReceipt {
+		t.Errorf("ExpectedDocumentType = %q, want %q", cfg.ExpectedDocumentType, models.DocumentTypeReceipt)
+	}
+}
+
+func TestWithAllowedLanguages(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.AllowedLanguages != nil {
+		t.Fatalf("AllowedLanguages = %v, want nil", cfg.AllowedLanguages)
+	}
+
+	WithAllowedLanguages([]string{"en", " ", " de "})(cfg)
+	if len(cfg.AllowedLanguages) != 2 || cfg.AllowedLanguages[0] != "en" || cfg.AllowedLanguages[1] != "de" {
+		t.Errorf("AllowedLanguages = %q, want [en de]", cfg.AllowedLanguages)
+	}
+
+	WithAllowedLanguages(nil)(cfg)
+	if cfg.AllowedLanguages != nil {
+		t.Errorf("AllowedLanguages = %q, want nil after an empty list", cfg.AllowedLanguages)
+	}
+}
+
+func TestWithStrictMode(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.StrictMode {
+		t.Fatal("StrictMode should default to false")
+	}
+
+	WithStrictMode(true)(cfg)
+	if !cfg.StrictMode {
+		t.Error("StrictMode should be true")
+	}
+}
+
+func TestWithDebugRequestLog(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.DebugRequestLog {
+		t.Fatal("DebugRequestLog should default to false")
+	}
+	if cfg.DebugPromptLength != DefaultDebugPromptLength {
+		t.Errorf("DebugPromptLength = %d, want %d", cfg.DebugPromptLength, DefaultDebugPromptLength)
+	}
+
+	WithDebugRequestLog(true)(cfg)
+	WithDebugPromptLength(100)(cfg)
+	if !cfg.DebugRequestLog {
+		t.Error("DebugRequestLog should be true")
+	}
+	if cfg.DebugPromptLength != 100 {
+		t.Errorf("DebugPromptLength = %d, want %d", cfg.DebugPromptLength, 100)
+	}
+
+	// Non-positive length should not override
+	WithDebugPromptLength(0)(cfg)
+	if cfg.DebugPromptLength != 100 {
+		t.Errorf("DebugPromptLength = %d, want %d", cfg.DebugPromptLength, 100)
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Transport != nil {
+		t.Fatal("Transport should default to nil")
+	}
+
+	rt := http.DefaultTransport
+	WithTransport(rt)(cfg)
+	if cfg.Transport != rt {
+		t.Error("Transport was not set")
+	}
+
+	// Nil should not override
+	WithTransport(nil)(cfg)
+	if cfg.Transport != rt {
+		t.Error("nil transport should not override")
+	}
+}
+
+func TestWithFallbackModels(t *testing.T) {
+	cfg := DefaultConfig()
+
+	WithFallbackModels([]string{"minicpm-v", "", "moondream"})(cfg)
+	if len(cfg.FallbackModels) != 2 || cfg.FallbackModels[0] != "minicpm-v" || cfg.FallbackModels[1] != "moondream" {
+		t.Errorf("FallbackModels = %v, want [minicpm-v moondream]", cfg.FallbackModels)
+	}
+}
+
+func TestWithSkipBlank(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.SkipBlank {
+		t.Fatal("SkipBlank should default to false")
+	}
+
+	WithSkipBlank(true)(cfg)
+	if !cfg.SkipBlank {
+		t.Error("SkipBlank should be true")
+	}
+}
+
+func TestWithSanitizeText(t *testing.T) {
+	cfg := DefaultConfig()
+	if !cfg.SanitizeText {
+		t.Fatal("SanitizeText should default to true")
+	}
+
+	WithSanitizeText(false)(cfg)
+	if cfg.SanitizeText {
+		t.Error("SanitizeText should be false")
+	}
+}
+
+func TestWithQualityReport(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.QualityReport {
+		t.Fatal("QualityReport should default to false")
+	}
+
+	WithQualityReport(true)(cfg)
+	if !cfg.QualityReport {
+		t.Error("QualityReport should be true")
+	}
+}
+
+func TestWithNumGPUAndNumThread(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.NumGPU != nil || cfg.NumThread != nil {
+		t.Fatal("NumGPU and NumThread should default to unset")
+	}
+
+	WithNumGPU(0)(cfg)
+	WithNumThread(8)(cfg)
+	if cfg.NumGPU == nil || *cfg.NumGPU != 0 {
+		t.Errorf("NumGPU = %v, want 0", cfg.NumGPU)
+	}
+	if cfg.NumThread == nil || *cfg.NumThread != 8 {
+		t.Errorf("NumThread = %v, want 8", cfg.NumThread)
+	}
+
+	WithNumGPU(-1)(cfg)
+	WithNumThread(-1)(cfg)
+	if *cfg.NumGPU != 0 || *cfg.NumThread != 8 {
+		t.Error("negative values should not override")
+	}
+}
+
+func TestWithMergeAdjacentLines(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MergeAdjacentLines {
+		t.Fatal("MergeAdjacentLines should default to false")
+	}
+
+	WithMergeAdjacentLines(true)(cfg)
+	if !cfg.MergeAdjacentLines {
+		t.Error("MergeAdjacentLines should be true")
+	}
+}
+
+func TestWithTimings(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Timings {
+		t.Fatal("Timings should default to false")
+	}
+
+	WithTimings(true)(cfg)
+	if !cfg.Timings {
+		t.Error("Timings should be true")
+	}
+}
+
+func TestWithSummaryLength(t *testing.T) {
+	cfg := DefaultConfig()
+
+	WithSummaryLength(SummaryLengthShort)(cfg)
+	if cfg.SummaryLength != SummaryLengthShort {
+		t.Errorf("SummaryLength = %q, want %q", cfg.SummaryLength, SummaryLengthShort)
+	}
+
+	WithSummaryLength("tiny")(cfg)
+	if cfg.SummaryLength != SummaryLengthShort {
+		t.Error("unknown length should not override")
+	}
+}
+
+func TestWithSummaryMaxWords(t *testing.T) {
+	cfg := DefaultConfig()
+
+	WithSummaryMaxWords(30)(cfg)
+	if cfg.SummaryMaxWords != 30 {
+		t.Errorf("SummaryMaxWords = %d, want 30", cfg.SummaryMaxWords)
+	}
+
+	WithSummaryMaxWords(0)(cfg)
+	if cfg.SummaryMaxWords != 30 {
+		t.Error("non-positive value should not override")
+	}
+}
+
+func TestWithCropRegions(t *testing.T) {
+	cfg := DefaultConfig()
+
+	WithCropRegions([]models.BoundingBox{
+		{X: 0, Y: 0, Width: 100, Height: 50},
+		{X: 10, Y: 10, Width: 0, Height: 50},
+		{X: -5, Y: 0, Width: 10, Height: 10},
+	})(cfg)
+	if len(cfg.CropRegions) != 1 {
+		t.Errorf("len(CropRegions) = %d, want 1 (invalid regions dropped)", len(cfg.CropRegions))
+	}
+
+	clone := cfg.Clone()
+	clone.CropRegions[0].X = 99
+	if cfg.CropRegions[0].X != 0 {
+		t.Error("Clone shares CropRegions with the original")
+	}
+}
+
+func TestWithMaxImagePixels(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MaxImagePixels != 0 {
+		t.Fatalf("MaxImagePixels = %d, want 0 (disabled)", cfg.MaxImagePixels)
+	}
+
+	WithMaxImagePixels(40_000_000)(cfg)
+	if cfg.MaxImagePixels != 40_000_000 {
+		t.Errorf("MaxImagePixels = %d, want 40000000", cfg.MaxImagePixels)
+	}
+
+	WithMaxImagePixels(0)(cfg)
+	if cfg.MaxImagePixels != 40_000_000 {
+		t.Error("invalid value should not override")
+	}
+}
+
+func TestWithMinImageDimension(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MinImageDimension != 0 {
+		t.Fatalf("MinImageDimension = %d, want 0 (disabled)", cfg.MinImageDimension)
+	}
+
+	WithMinImageDimension(200)(cfg)
+	if cfg.MinImageDimension != 200 {
+		t.Errorf("MinImageDimension = %d, want 200", cfg.MinImageDimension)
+	}
+
+	WithMinImageDimension(-1)(cfg)
+	if cfg.MinImageDimension != 200 {
+		t.Error("invalid value should not override")
+	}
+}
+
+func TestWithKeyValueConfidence(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.KeyValueConfidence {
+		t.Fatal("KeyValueConfidence should default to false")
+	}
+	WithKeyValueConfidence(true)(cfg)
+	if !cfg.KeyValueConfidence {
+		t.Error("KeyValueConfidence should be enabled")
+	}
+}
+
+func TestWithMaxTotalRetries(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MaxTotalRetries != nil {
+		t.Fatal("MaxTotalRetries should default to nil (per-call retries)")
+	}
+
+	WithMaxTotalRetries(0)(cfg)
+	if cfg.MaxTotalRetries == nil || *cfg.MaxTotalRetries != 0 {
+		t.Fatalf("MaxTotalRetries = %v, want 0", cfg.MaxTotalRetries)
+	}
+
+	WithMaxTotalRetries(-1)(cfg)
+	if *cfg.MaxTotalRetries != 0 {
+		t.Error("negative value should not override")
+	}
+
+	WithMaxTotalRetries(5)(cfg)
+	clone := cfg.Clone()
+	*clone.MaxTotalRetries = 1
+	if *cfg.MaxTotalRetries != 5 {
+		t.Error("Clone shares MaxTotalRetries with the original")
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Proxy != nil {
+		t.Fatal("Proxy should default to nil (environment)")
+	}
+
+	proxy, _ := url.Parse("http://proxy.corp.example:3128")
+	WithProxy(proxy)(cfg)
+	if cfg.Proxy == nil || cfg.Proxy.Host != "proxy.corp.example:3128" {
+		t.Errorf("Proxy = %v, want %v", cfg.Proxy, proxy)
+	}
+
+	clone := cfg.Clone()
+	clone.Proxy.Host = "other:1"
+	if cfg.Proxy.Host != "proxy.corp.example:3128" {
+		t.Error("Clone shares Proxy with the original")
+	}
+}
+
+func TestWithSourceValidator(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.SourceValidator != nil {
+		t.Fatal("SourceValidator should default to nil")
+	}
+
+	WithSourceValidator(func(string, SourceInfo) error { return nil })(cfg)
+	if cfg.SourceValidator == nil {
+		t.Fatal("SourceValidator should be set")
+	}
+
+	WithSourceValidator(nil)(cfg)
+	if cfg.SourceValidator == nil {
+		t.Error("nil should not override")
+	}
+}
+
+func TestWithResultProcessors(t *testing.T) {
+	cfg := DefaultConfig()
+	if len(cfg.ResultProcessors) != 0 {
+		t.Fatal("ResultProcessors should default to empty")
+	}
+
+	noop := func(*models.OCRResult) error { return nil }
+	WithResultProcessors(noop, nil)(cfg)
+	WithResultProcessors(noop)(cfg)
+	if len(cfg.ResultProcessors) != 2 {
+		t.Errorf("len(ResultProcessors) = %d, want 2 (appended, nil ignored)", len(cfg.ResultProcessors))
+	}
+
+	clone := cfg.Clone()
+	WithResultProcessors(noop)(clone)
+	if len(cfg.ResultProcessors) != 2 {
+		t.Error("appending to a clone should not change the original")
+	}
+}
+
+func TestWithURLRefresher(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.URLRefresher != nil {
+		t.Fatal("URLRefresher should default to nil")
+	}
+
+	WithURLRefresher(func(s string) (string, error) { return s, nil })(cfg)
+	if cfg.URLRefresher == nil {
+		t.Fatal("URLRefresher should be set")
+	}
+
+	WithURLRefresher(nil)(cfg)
+	if cfg.URLRefresher == nil {
+		t.Error("nil should not override")
+	}
+}
+
+func TestWithConfidenceCalibrator(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ConfidenceCalibrator != nil {
+		t.Fatal("ConfidenceCalibrator should default to nil")
+	}
+
+	WithConfidenceCalibrator(func(c float64) float64 { return c })(cfg)
+	if cfg.ConfidenceCalibrator == nil {
+		t.Fatal("ConfidenceCalibrator should be set")
+	}
+
+	WithConfidenceCalibrator(nil)(cfg)
+	if cfg.ConfidenceCalibrator == nil {
+		t.Error("nil should not override")
+	}
+}
+
+func TestWithBackend(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Backend != nil {
+		t.Fatal("Backend should default to nil (Ollama)")
+	}
+
+	backend := client.NewOpenAICompatClient("http://localhost:8080", time.Second)
+	WithBackend(backend)(cfg)
+	if cfg.Backend != backend {
+		t.Errorf("Backend = %v, want the given backend", cfg.Backend)
+	}
+
+	WithBackend(nil)(cfg)
+	if cfg.Backend != backend {
+		t.Error("nil should not override")
+	}
+}
+
+func TestWithEngine(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Engine != EngineOllama {
+		t.Errorf("Engine = %q, want %q", cfg.Engine, EngineOllama)
+	}
+
+	WithEngine(EngineTesseract)(cfg)
+	if cfg.Engine != EngineTesseract {
+		t.Errorf("Engine = %q, want %q", cfg.Engine, EngineTesseract)
+	}
+
+	// Unknown engines should not override
+	WithEngine("paddle")(cfg)
+	if cfg.Engine != EngineTesseract {
+		t.Errorf("Engine = %q, want %q", cfg.Engine, EngineTesseract)
+	}
+}
+
+func TestWithBoundingBoxUnits(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.BoundingBoxUnits != "" {
+		t.Fatalf("BoundingBoxUnits = %q, want empty default", cfg.BoundingBoxUnits)
+	}
+
+	WithBoundingBoxUnits(models.BoundingBoxUnitsNormalized)(cfg)
+	if cfg.BoundingBoxUnits != models.BoundingBoxUnitsNormalized {
+		t.Errorf("BoundingBoxUnits = %q, want %q", cfg.BoundingBoxUnits, models.BoundingBoxUnitsNormalized)
+	}
+
+	WithBoundingBoxUnits("inches")(cfg)
+	if cfg.BoundingBoxUnits != models.BoundingBoxUnitsNormalized {
+		t.Error("invalid units should not override")
+	}
+}
+
+func TestWithAutoRotate(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.AutoRotate {
+		t.Fatal("AutoRotate should default to false")
+	}
+	WithAutoRotate(true)(cfg)
+	if !cfg.AutoRotate {
+		t.Error("AutoRotate should be enabled")
+	}
+}
+
+func TestWithOriginalCoordinates(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.OriginalCoordinates {
+		t.Fatal("OriginalCoordinates should default to false")
+	}
+	WithOriginalCoordinates(true)(cfg)
+	if !cfg.OriginalCoordinates {
+		t.Error("OriginalCoordinates should be enabled")
+	}
+}
+
+func TestWithLineEndings(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.LineEndings != LineEndingsLF {
+		t.Fatalf("LineEndings = %q, want %q", cfg.LineEndings, LineEndingsLF)
+	}
+
+	WithLineEndings(LineEndingsCRLF)(cfg)
+	if cfg.LineEndings != LineEndingsCRLF {
+		t.Errorf("LineEndings = %q, want %q", cfg.LineEndings, LineEndingsCRLF)
+	}
+
+	WithLineEndings("cr")(cfg)
+	if cfg.LineEndings != LineEndingsCRLF {
+		t.Error("unknown value should not override")
+	}
+}
+
+func TestWithTableMergePolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.TableMergePolicy != "" {
+		t.Fatalf("TableMergePolicy = %q, want empty", cfg.TableMergePolicy)
+	}
+
+	WithTableMergePolicy(TableMergeFillDown)(cfg)
+	if cfg.TableMergePolicy != TableMergeFillDown {
+		t.Errorf("TableMergePolicy = %q, want %q", cfg.TableMergePolicy, TableMergeFillDown)
+	}
+
+	WithTableMergePolicy("span")(cfg)
+	if cfg.TableMergePolicy != TableMergeFillDown {
+		t.Error("unknown value should not override")
+	}
+}
+
+func TestWithDeadlinePadding(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.DeadlinePadding != 0 {
+		t.Fatalf("DeadlinePadding = %v, want 0", cfg.DeadlinePadding)
+	}
+
+	WithDeadlinePadding(5 * time.Second)(cfg)
+	if cfg.DeadlinePadding != 5*time.Second {
+		t.Errorf("DeadlinePadding = %v, want 5s", cfg.DeadlinePadding)
+	}
+
+	WithDeadlinePadding(-time.Second)(cfg)
+	if cfg.DeadlinePadding != 5*time.Second {
+		t.Error("negative padding should be ignored")
+	}
+}
+
+func TestWithResponseTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ResponseTimeout != 0 {
+		t.Fatalf("ResponseTimeout = %v, want 0", cfg.ResponseTimeout)
+	}
+
+	WithResponseTimeout(10 * time.Second)(cfg)
+	if cfg.ResponseTimeout != 10*time.Second {
+		t.Errorf("ResponseTimeout = %v, want 10s", cfg.ResponseTimeout)
+	}
+
+	WithResponseTimeout(0)(cfg)
+	WithResponseTimeout(-time.Second)(cfg)
+	if cfg.ResponseTimeout != 10*time.Second {
+		t.Error("non-positive timeouts should be ignored")
+	}
+}
+
+func TestWithRawJSON(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.RawJSON {
+		t.Fatal("RawJSON should be disabled by default")
+	}
+	WithRawJSON(true)(cfg)
+	if !cfg.RawJSON {
+		t.Error("RawJSON should be enabled")
+	}
+}
+
+func TestWithPDFPassword(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.PDFPassword != "" {
+		t.Fatalf("PDFPassword = %q, want empty", cfg.PDFPassword)
+	}
+	WithPDFPassword("s3cret")(cfg)
+	if cfg.PDFPassword != "s3cret" {
+		t.Errorf("PDFPassword = %q, want s3cret", cfg.PDFPassword)
+	}
+}
+
+func TestWithDetectTextDirection(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.DetectTextDirection {
+		t.Fatal("DetectTextDirection should be disabled by default")
+	}
+	WithDetectTextDirection(true)(cfg)
+	if !cfg.DetectTextDirection {
+		t.Error("DetectTextDirection should be enabled")
+	}
+}
+
+func TestWithProgressiveParse(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ProgressiveParse != nil {
+		t.Fatal("ProgressiveParse should be nil by default")
+	}
+	WithProgressiveParse(func(*models.OCRResult) {})(cfg)
+	if cfg.ProgressiveParse == nil {
+		t.Fatal("ProgressiveParse should be set")
+	}
+	WithProgressiveParse(nil)(cfg)
+	if cfg.ProgressiveParse == nil {
+		t.Error("nil should be ignored")
+	}
+}
+
+func TestWithThumbnailHint(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ThumbnailHint {
+		t.Fatal("ThumbnailHint should be disabled by default")
+	}
+	WithThumbnailHint(true)(cfg)
+	if !cfg.ThumbnailHint {
+		t.Error("ThumbnailHint should be enabled")
+	}
+}
+
+func TestWithRetryOnEmptyText(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.RetryOnEmptyText {
+		t.Fatal("RetryOnEmptyText should be disabled by default")
+	}
+	WithRetryOnEmptyText(true)(cfg)
+	if !cfg.RetryOnEmptyText {
+		t.Error("RetryOnEmptyText should be enabled")
+	}
+}
+
+func TestWithRetryBackoff(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.RetryBackoff != 0 || cfg.RetryMaxBackoff != 0 {
+		t.Fatal("retries should not back off by default")
+	}
+	WithRetryBackoff(time.Second)(cfg)
+	WithRetryMaxBackoff(10 * time.Second)(cfg)
+	WithRetryBackoff(-1)(cfg)
+	WithRetryMaxBackoff(0)(cfg)
+	if cfg.RetryBackoff != time.Second || cfg.RetryMaxBackoff != 10*time.Second {
+		t.Errorf("RetryBackoff, RetryMaxBackoff = %v, %v, want 1s, 10s", cfg.RetryBackoff, cfg.RetryMaxBackoff)
+	}
+}
+
+func TestWithFilterWatermarks(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.FilterWatermarks {
+		t.Fatal("FilterWatermarks should be disabled by default")
+	}
+	WithFilterWatermarks(true)(cfg)
+	if !cfg.FilterWatermarks {
+		t.Error("FilterWatermarks should be enabled")
+	}
+}
+
+func TestWithSourceMetadata(t *testing.T) {
+	cfg := DefaultConfig()
+	WithSourceMetadata(map[string]string{"tenant_id": "t-42"})(cfg)
+	if cfg.SourceMetadata["tenant_id"] != "t-42" {
+		t.Fatalf("SourceMetadata = %v", cfg.SourceMetadata)
+	}
+	clone := cfg.Clone()
+	clone.SourceMetadata["tenant_id"] = "other"
+	if cfg.SourceMetadata["tenant_id"] != "t-42" {
+		t.Error("Clone should copy SourceMetadata")
+	}
+	WithSourceMetadata(map[string]string{})(cfg)
+	if cfg.SourceMetadata != nil {
+		t.Errorf("SourceMetadata = %v, want nil for an empty map", cfg.SourceMetadata)
+	}
+}
+
+func TestWithAutoStrip(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.AutoStrip {
+		t.Fatal("AutoStrip should be disabled by default")
+	}
+	WithAutoStrip(true)(cfg)
+	if !cfg.AutoStrip {
+		t.Error("AutoStrip should be enabled")
+	}
+}
+
+func TestWithTableSpans(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.TableSpans {
+		t.Fatal("TableSpans should be disabled by default")
+	}
+	WithTableSpans(true)(cfg)
+	if !cfg.TableSpans {
+		t.Error("TableSpans should be enabled")
+	}
+}
+
+func TestWithCandidates(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Candidates > 1 {
+		t.Fatal("one extraction should be generated by default")
+	}
+	WithCandidates(3)(cfg)
+	WithCandidates(0)(cfg)
+	if cfg.Candidates != 3 {
+		t.Errorf("Candidates = %d, want 3", cfg.Candidates)
+	}
+}
+
+func TestWithSchemaValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.SchemaValidation {
+		t.Fatal("SchemaValidation should be disabled by default")
+	}
+	WithSchemaValidation(true)(cfg)
+	if !cfg.SchemaValidation {
+		t.Error("SchemaValidation should be enabled")
+	}
+}
+
+func TestWithAllowFreeformDocumentType(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.AllowFreeformDocumentType {
+		t.Fatal("AllowFreeformDocumentType should be disabled by default")
+	}
+	WithAllowFreeformDocumentType(true)(cfg)
+	if !cfg.AllowFreeformDocumentType {
+		t.Error("AllowFreeformDocumentType should be enabled")
+	}
+}
+
+func TestWithDetectMultipleDocuments(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.DetectMultipleDocuments {
+		t.Fatal("DetectMultipleDocuments should be disabled by default")
+	}
+	WithDetectMultipleDocuments(true)(cfg)
+	if !cfg.DetectMultipleDocuments {
+		t.Error("DetectMultipleDocuments should be enabled")
+	}
+}
+
+func TestWithMaxLines(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MaxLines != 0 {
+		t.Fatalf("MaxLines = %d, want 0", cfg.MaxLines)
+	}
+
+	WithMaxLines(100)(cfg)
+	if cfg.MaxLines != 100 {
+		t.Errorf("MaxLines = %d, want 100", cfg.MaxLines)
+	}
+
+	WithMaxLines(-1)(cfg)
+	if cfg.MaxLines != 100 {
+		t.Error("negative limit should be ignored")
+	}
+}
+
+func TestWithImageEncoding(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ImageEncoding != ImageEncodingPNG || cfg.JPEGQuality != DefaultJPEGQuality {
+		t.Fatalf("defaults = %q at %d, want png at %d", cfg.ImageEncoding, cfg.JPEGQuality, DefaultJPEGQuality)
+	}
+
+	WithImageEncoding(ImageEncodingJPEG)(cfg)
+	if cfg.ImageEncoding != ImageEncodingJPEG {
+		t.Errorf("ImageEncoding = %q, want jpeg", cfg.ImageEncoding)
+	}
+	WithImageEncoding("webp")(cfg)
+	if cfg.ImageEncoding != ImageEncodingJPEG {
+		t.Error("unknown encoding should not override")
+	}
+}
+
+func TestWithJPEGQuality(t *testing.T) {
+	cfg := DefaultConfig()
+	for _, q := range []int{1, 100, 60} {
+		WithJPEGQuality(q)(cfg)
+		if cfg.JPEGQuality != q {
+			t.Errorf("JPEGQuality = %d, want %d", cfg.JPEGQuality, q)
+		}
+	}
+	for _, q := range []int{0, -5, 101} {
+		WithJPEGQuality(q)(cfg)
+		if cfg.JPEGQuality != 60 {
+			t.Errorf("quality %d should be ignored, got %d", q, cfg.JPEGQuality)
+		}
+	}
+}
+
+func TestWithFlagEmptyStructuredData(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.FlagEmptyStructuredData {
+		t.Fatal("FlagEmptyStructuredData should be disabled by default")
+	}
+	WithFlagEmptyStructuredData(true)(cfg)
+	if !cfg.FlagEmptyStructuredData {
+		t.Error("FlagEmptyStructuredData should be enabled")
+	}
+}
+
+func TestWithMaxConcurrency(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MaxConcurrency != DefaultMaxConcurrency || cfg.MaxConcurrentDownloads != DefaultMaxConcurrentDownloads {
+		t.Fatalf("defaults = %d, %d", cfg.MaxConcurrency, cfg.MaxConcurrentDownloads)
+	}
+
+	WithMaxConcurrency(3)(cfg)
+	WithMaxConcurrentDownloads(8)(cfg)
+	WithMaxConcurrency(0)(cfg)
+	WithMaxConcurrentDownloads(-1)(cfg)
+	if cfg.MaxConcurrency != 3 || cfg.MaxConcurrentDownloads != 8 {
+		t.Errorf("MaxConcurrency = %d, MaxConcurrentDownloads = %d; want 3, 8", cfg.MaxConcurrency, cfg.MaxConcurrentDownloads)
+	}
+}
+
+func TestWithExtractFormFields(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ExtractFormFields {
+		t.Fatal("ExtractFormFields should default to false")
+	}
+	WithExtractFormFields(true)(cfg)
+	if !cfg.ExtractFormFields {
+		t.Error("ExtractFormFields = false, want true")
+	}
+}
+
+func TestWithNumberLocale(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.NumberLocale != "" {
+		t.Fatalf("NumberLocale = %q, want empty (heuristic)", cfg.NumberLocale)
+	}
+
+	WithNumberLocale("de-DE")(cfg)
+	WithNumberLocale("not a locale")(cfg)
+	if cfg.NumberLocale != "de-DE" {
+		t.Errorf("NumberLocale = %q, want de-DE", cfg.NumberLocale)
+	}
+
+	WithNumberLocale("")(cfg)
+	if cfg.NumberLocale != "" {
+		t.Errorf("NumberLocale = %q, want empty", cfg.NumberLocale)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.CircuitBreakerThreshold != 0 {
+		t.Fatalf("CircuitBreakerThreshold = %d, want disabled by default", cfg.CircuitBreakerThreshold)
+	}
+
+	WithCircuitBreaker(3, time.Minute)(cfg)
+	WithCircuitBreaker(0, time.Second)(cfg)
+	WithCircuitBreaker(5, 0)(cfg)
+	if cfg.CircuitBreakerThreshold != 3 || cfg.CircuitBreakerCooldown != time.Minute {
+		t.Errorf("circuit breaker = %d, %s; want 3, 1m0s", cfg.CircuitBreakerThreshold, cfg.CircuitBreakerCooldown)
+	}
+}
+
+func TestWithNormalizeWhitespace(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.NormalizeWhitespace {
+		t.Fatal("NormalizeWhitespace should default to false")
+	}
+	WithNormalizeWhitespace(true)(cfg)
+	if !cfg.NormalizeWhitespace {
+		t.Error("NormalizeWhitespace = false, want true")
+	}
+}
+
+func TestWithMaxTotalTokens(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MaxTotalTokens != 0 {
+		t.Fatalf("MaxTotalTokens = %d, want 0", cfg.MaxTotalTokens)
+	}
+
+	WithMaxTotalTokens(5000)(cfg)
+	if cfg.MaxTotalTokens != 5000 {
+		t.Errorf("MaxTotalTokens = %d, want 5000", cfg.MaxTotalTokens)
+	}
+
+	WithMaxTotalTokens(0)(cfg)
+	WithMaxTotalTokens(-1)(cfg)
+	if cfg.MaxTotalTokens != 5000 {
+		t.Error("values below 1 should be ignored")
+	}
+}
+
+func TestWithMinTableConfidence(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MinTableConfidence != 0 {
+		t.Fatalf("MinTableConfidence = %v, want 0", cfg.MinTableConfidence)
+	}
+
+	WithMinTableConfidence(0.6)(cfg)
+	if cfg.MinTableConfidence != 0.6 {
+		t.Errorf("MinTableConfidence = %v, want 0.6", cfg.MinTableConfidence)
+	}
+
+	for _, min := range []float64{0, -0.5, 1.5} {
+		WithMinTableConfidence(min)(cfg)
+	}
+	if cfg.MinTableConfidence != 0.6 {
+		t.Error("values outside (0, 1] should be ignored")
+	}
+}
+
+func TestWithReflowLines(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ReflowLines != 0 {
+		t.Fatalf("ReflowLines = %d, want 0", cfg.ReflowLines)
+	}
+
+	WithReflowLines(80)(cfg)
+	if cfg.ReflowLines != 80 {
+		t.Errorf("ReflowLines = %d, want 80", cfg.ReflowLines)
+	}
+
+	WithReflowLines(0)(cfg)
+	if cfg.ReflowLines != 80 {
+		t.Error("values below 1 should be ignored")
+	}
+}
+
+func TestWithCompactPrompt(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.CompactPrompt {
+		t.Fatal("CompactPrompt should default to false")
+	}
+	WithCompactPrompt(true)(cfg)
+	if !cfg.CompactPrompt {
+		t.Error("CompactPrompt = false, want true")
+	}
+}
+
+func TestWithFewShotExamples(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.FewShotExamples != nil {
+		t.Fatalf("FewShotExamples = %v, want nil", cfg.FewShotExamples)
+	}
+
+	WithFewShotExamples([]prompt.Example{
+		{Description: "invalid", Output: "not json"},
+		{Description: "1", Output: `{}`},
+		{Description: "2", Output: `{}`},
+		{Description: "3", Output: `{}`},
+		{Description: "4", Output: `{}`},
+	})(cfg)
+	if len(cfg.FewShotExamples) != prompt.MaxExamples || cfg.FewShotExamples[0].Description != "1" {
+		t.Errorf("FewShotExamples = %+v, want the first %d valid examples", cfg.FewShotExamples, prompt.MaxExamples)
+	}
+
+	WithFewShotExamples(nil)(cfg)
+	if cfg.FewShotExamples != nil {
+		t.Errorf("FewShotExamples = %+v, want nil after an empty list", cfg.FewShotExamples)
+	}
+}
+
+func TestWithLogprobConfidence(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.LogprobConfidence {
+		t.Fatal("LogprobConfidence should default to false")
+	}
+	WithLogprobConfidence(true)(cfg)
+	if !cfg.LogprobConfidence {
+		t.Error("LogprobConfidence = false, want true")
+	}
+}
+
+func TestWithSchema(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Schema != SchemaStrict {
+		t.Fatalf("Schema = %q, want %q", cfg.Schema, SchemaStrict)
+	}
+
+	WithSchema(SchemaFlat)(cfg)
+	if cfg.Schema != SchemaFlat {
+		t.Errorf("Schema = %q, want %q", cfg.Schema, SchemaFlat)
+	}
+
+	WithSchema("nested")(cfg)
+	if cfg.Schema != SchemaFlat {
+		t.Error("unknown schema should not override")
+	}
+}
+
+func TestWithKeepEmptyLines(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.KeepEmptyLines {
+		t.Fatal("KeepEmptyLines should default to false")
+	}
+	WithKeepEmptyLines(true)(cfg)
+	if !cfg.KeepEmptyLines {
+		t.Error("KeepEmptyLines = false, want true")
+	}
+}
+
+func TestWithNumPredictAndTopP(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.NumPredict != DefaultNumPredict || cfg.TopP != 0 || cfg.Stop != nil {
+		t.Fatalf("defaults = %d, %v, %q", cfg.NumPredict, cfg.TopP, cfg.Stop)
+	}
+
+	WithNumPredict(1024)(cfg)
+	WithTopP(0.8)(cfg)
+	WithNumPredict(0)(cfg)
+	WithTopP(1.5)(cfg)
+	if cfg.NumPredict != 1024 || cfg.TopP != 0.8 {
+		t.Errorf("NumPredict = %d, TopP = %v; want 1024, 0.8", cfg.NumPredict, cfg.TopP)
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Clock != nil {
+		t.Fatal("the real time should be used by default")
+	}
+	fixed := time.Unix(1700000000, 0)
+	WithClock(func() time.Time { return fixed })(cfg)
+	WithClock(nil)(cfg)
+	if cfg.Clock == nil || !cfg.Clock().Equal(fixed) {
+		t.Error("Clock should be the fixed clock")
+	}
+}
@@ -0,0 +1,99 @@
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// overlayStroke is the width, in pixels, of the rectangles RenderOverlay
+// draws.
+const overlayStroke = 2
+
+// Overlay colors by line confidence.
+var (
+	overlayHigh    = color.RGBA{R: 0x2e, G: 0xa0, B: 0x43, A: 0xff} // confidence >= 0.8
+	overlayMedium  = color.RGBA{R: 0xf0, G: 0x8c, B: 0x00, A: 0xff} // confidence >= 0.5
+	overlayLow     = color.RGBA{R: 0xd7, G: 0x26, B: 0x1e, A: 0xff} // confidence < 0.5
+	overlayUnrated = color.RGBA{R: 0x1f, G: 0x6f, B: 0xeb, A: 0xff} // no confidence
+)
+
+// RenderOverlay decodes original and returns it as a PNG with a rectangle
+// drawn around the bounding box of each line of r, for checking box
+// coordinates by eye. Rectangles are colored by the line's confidence: green
+// from 0.8, orange from 0.5, red below, and blue for lines without one.
+//
+// The boxes must refer to original: extract with WithBoundingBoxes and, if
+// the image was auto-rotated or cropped, WithOriginalCoordinates. Boxes in
+// normalized units are scaled to the decoded image. It fails with
+// ErrNoBoundingBoxes if no line has a usable box.
+func RenderOverlay(original []byte, r *models.OCRResult) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageDecodeFailed, err)
+	}
+
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+
+	units := lineBoxUnits(r)
+	size := models.ImageInfo{Width: bounds.Dx(), Height: bounds.Dy()}
+	drawn := 0
+	for _, line := range r.Text.Lines {
+		x0, y0, x1, y1, ok := pixelBox(line.BoundingBox, units, size)
+		if !ok {
+			continue
+		}
+		strokeRect(img, image.Rect(x0, y0, x1, y1), overlayColor(line.Confidence))
+		drawn++
+	}
+	if drawn == 0 {
+		return nil, ErrNoBoundingBoxes
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode overlay: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// overlayColor returns the rectangle color for a line confidence.
+func overlayColor(confidence float64) color.Color {
+	switch {
+	case confidence <= 0:
+		return overlayUnrated
+	case confidence >= 0.8:
+		return overlayHigh
+	case confidence >= 0.5:
+		return overlayMedium
+	default:
+		return overlayLow
+	}
+}
+
+// strokeRect draws the outline of r, overlayStroke pixels wide and inset
+// into r, clipped to img.
+func strokeRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	r = r.Canon()
+	fill := image.NewUniform(c)
+	w := min(overlayStroke, r.Dx(), r.Dy())
+	if w <= 0 {
+		w = 1
+	}
+	edges := []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w), // top
+		image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y), // bottom
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+w, r.Max.Y), // left
+		image.Rect(r.Max.X-w, r.Min.Y, r.Max.X, r.Max.Y), // right
+	}
+	for _, e := range edges {
+		draw.Draw(img, e.Intersect(img.Bounds()), fill, image.Point{}, draw.Src)
+	}
+}
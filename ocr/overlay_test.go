@@ -0,0 +1,99 @@
+package ocr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestRenderOverlay(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	var in bytes.Buffer
+	if err := jpeg.Encode(&in, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &models.OCRResult{Text: models.TextResult{
+		BoundingBoxUnits: models.BoundingBoxUnitsPixels,
+		Lines: []models.TextLine{
+			{Text: "high", BoundingBox: &models.BoundingBox{X: 2, Y: 2, Width: 10, Height: 6}, Confidence: 0.9},
+			{Text: "low", BoundingBox: &models.BoundingBox{X: 20, Y: 10, Width: 30, Height: 20}, Confidence: 0.3},
+			{Text: "no box"},
+		},
+	}}
+
+	out, err := RenderOverlay(in.Bytes(), r)
+	if err != nil {
+		t.Fatalf("RenderOverlay: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("output is not a valid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 20 {
+		t.Errorf("overlay is %dx%d, want 40x20", b.Dx(), b.Dy())
+	}
+
+	tests := []struct {
+		x, y int
+		want color.Color
+	}{
+		{2, 2, overlayHigh},  // top-left corner of the first box
+		{11, 7, overlayHigh}, // its bottom-right corner
+		{6, 5, nil},          // its inside is left alone
+		{20, 10, overlayLow}, // second box, clipped at the image edge
+		{39, 15, nil},        // its right edge is outside the image
+		{0, 0, nil},          // outside every box
+	}
+	for _, tt := range tests {
+		got := color.RGBAModel.Convert(img.At(tt.x, tt.y)).(color.RGBA)
+		if tt.want == nil {
+			if got.R < 0xf0 || got.G < 0xf0 || got.B < 0xf0 {
+				t.Errorf("pixel (%d,%d) = %v, want the original white", tt.x, tt.y, got)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestRenderOverlay_Normalized(t *testing.T) {
+	r := &models.OCRResult{Text: models.TextResult{
+		BoundingBoxUnits: models.BoundingBoxUnitsNormalized,
+		Lines: []models.TextLine{
+			{BoundingBox: &models.BoundingBox{X: 0.5, Y: 0.5, Width: 0.25, Height: 0.25}},
+		},
+	}}
+	out, err := RenderOverlay(testPNG(t), r)
+	if err != nil {
+		t.Fatalf("RenderOverlay: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("output is not a valid PNG: %v", err)
+	}
+	if got := color.RGBAModel.Convert(img.At(16, 16)); got != overlayUnrated {
+		t.Errorf("pixel (16,16) = %v, want the box drawn at half the 32x32 image", got)
+	}
+}
+
+func TestRenderOverlay_Errors(t *testing.T) {
+	r := &models.OCRResult{Text: models.TextResult{Lines: []models.TextLine{{Text: "no box"}}}}
+	if _, err := RenderOverlay(testPNG(t), r); !errors.Is(err, ErrNoBoundingBoxes) {
+		t.Errorf("err = %v, want ErrNoBoundingBoxes", err)
+	}
+	if _, err := RenderOverlay([]byte("not an image"), r); !errors.Is(err, ErrImageDecodeFailed) {
+		t.Errorf("err = %v, want ErrImageDecodeFailed", err)
+	}
+}
@@ -0,0 +1,74 @@
+package ocr
+
+// Preset names a bundle of model parameters for WithPreset.
+type Preset string
+
+// Supported presets.
+const (
+	// PresetAccurate is deterministic and leaves room for long documents.
+	PresetAccurate Preset = "accurate"
+
+	// PresetFast is deterministic and caps the output short, trading
+	// completeness on dense documents for latency.
+	PresetFast Preset = "fast"
+
+	// PresetCreative samples more freely, e.g. for summaries. It is less
+	// faithful to the document than the other presets.
+	PresetCreative Preset = "creative"
+)
+
+// ModelParameters are the sampling parameters sent with each model request.
+type ModelParameters struct {
+	// Temperature controls randomness, from 0 (deterministic) to 2.
+	Temperature float64
+
+	// NumPredict caps the tokens generated per request.
+	NumPredict int
+
+	// TopP is the nucleus sampling cutoff in (0, 1]. 0 leaves the server
+	// default.
+	TopP float64
+
+	// Stop lists sequences that end generation.
+	Stop []string
+}
+
+// runawayWhitespace stops a model stuck emitting blank lines, which some
+// models do in JSON mode instead of closing the object.
+const runawayWhitespace = "\n\n\n\n"
+
+// presets holds the parameters of every Preset.
+var presets = map[Preset]ModelParameters{
+	PresetAccurate: {Temperature: 0, NumPredict: 8192, Stop: []string{runawayWhitespace}},
+	PresetFast:     {Temperature: 0, NumPredict: 2048, TopP: 0.9, Stop: []string{runawayWhitespace}},
+	PresetCreative: {Temperature: 0.7, NumPredict: DefaultNumPredict, TopP: 0.95},
+}
+
+// WithModelParameters sets every model sampling parameter at once. Parameters
+// with an invalid value (temperature outside [0, 2], NumPredict below 1 or
+// TopP outside [0, 1]) make the whole option ignored.
+func WithModelParameters(p ModelParameters) Option {
+	return func(c *Config) {
+		if p.Temperature < 0 || p.Temperature > 2 || p.NumPredict < 1 || p.TopP < 0 || p.TopP > 1 {
+			return
+		}
+		c.Temperature = p.Temperature
+		c.NumPredict = p.NumPredict
+		c.TopP = p.TopP
+		c.Stop = append([]string(nil), p.Stop...)
+	}
+}
+
+// WithPreset applies the model parameters of a named preset. Options given
+// after it override single parameters, e.g.
+//
+//	ocr.WithPreset(ocr.PresetFast), ocr.WithNumPredict(3000)
+//
+// Unknown presets are ignored.
+func WithPreset(name Preset) Option {
+	p, ok := presets[name]
+	if !ok {
+		return func(*Config) {}
+	}
+	return WithModelParameters(p)
+}
@@ -0,0 +1,79 @@
+package ocr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+)
+
+func TestWithPreset(t *testing.T) {
+	tests := []struct {
+		preset Preset
+		want   ModelParameters
+	}{
+		{PresetAccurate, ModelParameters{Temperature: 0, NumPredict: 8192, Stop: []string{"\n\n\n\n"}}},
+		{PresetFast, ModelParameters{Temperature: 0, NumPredict: 2048, TopP: 0.9, Stop: []string{"\n\n\n\n"}}},
+		{PresetCreative, ModelParameters{Temperature: 0.7, NumPredict: 4096, TopP: 0.95}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.preset), func(t *testing.T) {
+			cfg := DefaultConfig()
+			WithPreset(tt.preset)(cfg)
+			got := ModelParameters{Temperature: cfg.Temperature, NumPredict: cfg.NumPredict, TopP: cfg.TopP, Stop: cfg.Stop}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parameters = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if len(tests) != len(presets) {
+		t.Errorf("%d presets tested, %d defined", len(tests), len(presets))
+	}
+}
+
+func TestWithPreset_Overrides(t *testing.T) {
+	cfg := DefaultConfig()
+	for _, opt := range []Option{WithPreset(PresetFast), WithTemperature(0.2), WithStop()} {
+		opt(cfg)
+	}
+	if cfg.Temperature != 0.2 || cfg.NumPredict != 2048 || cfg.TopP != 0.9 || cfg.Stop != nil {
+		t.Errorf("config = temperature %v, num_predict %d, top_p %v, stop %q; want the preset with overrides",
+			cfg.Temperature, cfg.NumPredict, cfg.TopP, cfg.Stop)
+	}
+
+	// Changing the config must not change the preset
+	cfg = DefaultConfig()
+	WithPreset(PresetAccurate)(cfg)
+	cfg.Stop[0] = "x"
+	if presets[PresetAccurate].Stop[0] != "\n\n\n\n" {
+		t.Error("config shares Stop with the preset")
+	}
+}
+
+func TestWithPreset_Unknown(t *testing.T) {
+	cfg := DefaultConfig()
+	WithPreset("precise")(cfg)
+	WithModelParameters(ModelParameters{Temperature: 3, NumPredict: 100})(cfg)
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Error("unknown preset or invalid parameters should be ignored")
+	}
+}
+
+func TestExtractBytes_ModelParameters(t *testing.T) {
+	srv := ollamatest.NewServer(t, nil)
+
+	_, err := ExtractBytes(context.Background(), testPNG(t), ".png",
+		WithOllamaURL(srv.URL),
+		WithPreset(PresetFast),
+		WithNumPredict(1000),
+	)
+	if err != nil {
+		t.Fatalf("ExtractBytes: %v", err)
+	}
+
+	opts := srv.Requests()[0].Options
+	if opts.Temperature != 0 || opts.NumPredict != 1000 || opts.TopP != 0.9 || !reflect.DeepEqual(opts.Stop, []string{"\n\n\n\n"}) {
+		t.Errorf("options = %+v, want the fast preset with num_predict 1000", opts)
+	}
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// Adapter returns extra instructions for one model family, phrased the way
+// that family follows best, or "" for none. Adapters only add instructions;
+// the JSON schema stays the same for every model.
+type Adapter func(cfg PromptConfig) string
+
+// adapters maps a model name prefix to its registered Adapter.
+var (
+	adaptersMu sync.RWMutex
+	adapters   = map[string]Adapter{
+		"llama3.2-vision": llamaVisionAdapter,
+		"minicpm-v":       minicpmAdapter,
+		"qwen":            qwenAdapter,
+	}
+)
+
+// RegisterAdapter sets the adapter for models whose name starts with prefix,
+// replacing any earlier one; registering a nil adapter removes it. Prefixes
+// are matched case-insensitively against the model name without its
+// namespace, so "minicpm-v" matches "openbmb/minicpm-v:8b". The longest
+// matching prefix wins. Prompts cached before the call are dropped. It is
+// safe for concurrent use.
+func RegisterAdapter(prefix string, a Adapter) {
+	prefix = strings.ToLower(prefix)
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	if a == nil {
+		delete(adapters, prefix)
+	} else {
+		adapters[prefix] = a
+	}
+	promptCache.Clear()
+	promptCacheSize.Store(0)
+}
+
+// lookupAdapter returns the adapter for model and the prefix it was
+// registered under, if any.
+func lookupAdapter(model string) (Adapter, string, bool) {
+	name := strings.ToLower(path.Base(model))
+	if model == "" || name == "" {
+		return nil, "", false
+	}
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	var (
+		best    Adapter
+		bestKey string
+	)
+	for prefix, a := range adapters {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(bestKey) {
+			best, bestKey = a, prefix
+		}
+	}
+	return best, bestKey, best != nil
+}
+
+// jsonOnlyReminders are the closing lines of the built-in prompts. Model
+// notes go right before them so the JSON-only reminder stays last.
+var jsonOnlyReminders = []string{
+	"\n\nRemember: Output ONLY the JSON object. Nothing else.",
+	"\nOutput ONLY the JSON object.",
+}
+
+// adaptForModel adds the instructions of the adapter for cfg.Model to p,
+// before its closing JSON-only reminder if it has one.
+func adaptForModel(p string, cfg PromptConfig) string {
+	a, _, ok := lookupAdapter(cfg.Model)
+	if !ok {
+		return p
+	}
+	notes := a(cfg)
+	if notes == "" {
+		return p
+	}
+	notes = "\n\nMODEL NOTES:\n" + notes
+	for _, reminder := range jsonOnlyReminders {
+		if body, found := strings.CutSuffix(p, reminder); found {
+			return body + notes + reminder
+		}
+	}
+	return p + notes
+}
+
+// llamaVisionAdapter keeps llama3.2-vision from describing the image before
+// answering.
+func llamaVisionAdapter(PromptConfig) string {
+	return `- Do not describe the image or comment on it. Your answer must start with "{" and end with "}".`
+}
+
+// minicpmAdapter keeps MiniCPM-V from translating keys, which it tends to do
+// for documents in Chinese.
+func minicpmAdapter(cfg PromptConfig) string {
+	notes := `- Use the JSON keys exactly as written in the schema, in English. Never use Chinese characters in keys.`
+	if cfg.WithStructuredExtraction {
+		notes += `
+- Keys in "key_value_pairs" are the field labels as printed on the document, in their original language and script.`
+	}
+	return notes
+}
+
+// qwenAdapter asks Qwen-VL models for pixel boxes, as some versions default
+// to a 0-1000 grid, and for bare JSON without code fences.
+func qwenAdapter(cfg PromptConfig) string {
+	notes := "- Output the bare JSON object, without markdown code fences."
+	if cfg.WithBoundingBoxes {
+		notes += `
+- Give bounding boxes in pixels of the image as provided, never on a 0-1000 grid.`
+	}
+	return notes
+}
@@ -0,0 +1,90 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupAdapter(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"llama3.2-vision", "llama3.2-vision"},
+		{"llama3.2-vision:11b", "llama3.2-vision"},
+		{"minicpm-v", "minicpm-v"},
+		{"openbmb/minicpm-v:8b", "minicpm-v"},
+		{"qwen2.5vl:7b", "qwen"},
+		{"Qwen2-VL", "qwen"},
+		{"llava:13b", ""},
+		{"gpt-4o", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		_, got, ok := lookupAdapter(tt.model)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("lookupAdapter(%q) = %q, %v, want %q", tt.model, got, ok, tt.want)
+		}
+	}
+}
+
+func TestRegisterAdapter(t *testing.T) {
+	RegisterAdapter("Qwen2.5", func(PromptConfig) string { return "- Qwen 2.5 note." })
+	t.Cleanup(func() { RegisterAdapter("qwen2.5", nil) })
+
+	// The longest prefix wins
+	if _, got, _ := lookupAdapter("qwen2.5vl:7b"); got != "qwen2.5" {
+		t.Errorf("lookupAdapter(qwen2.5vl:7b) = %q, want qwen2.5", got)
+	}
+	if _, got, _ := lookupAdapter("qwen2-vl"); got != "qwen" {
+		t.Errorf("lookupAdapter(qwen2-vl) = %q, want qwen", got)
+	}
+
+	// Prompts cached before the registration pick it up
+	if p := BuildOCRPrompt(PromptConfig{Model: "qwen2.5vl"}); !strings.Contains(p, "Qwen 2.5 note.") {
+		t.Error("prompt should use the newly registered adapter")
+	}
+	RegisterAdapter("qwen2.5", nil)
+	if p := BuildOCRPrompt(PromptConfig{Model: "qwen2.5vl"}); strings.Contains(p, "Qwen 2.5 note.") {
+		t.Error("prompt should not use a removed adapter")
+	}
+}
+
+func TestBuildOCRPrompt_ModelAdapter(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		cfg := PromptConfig{
+			WithStructuredExtraction: true,
+			WithBoundingBoxes:        true,
+			Compact:                  compact,
+		}
+		generic := BuildOCRPrompt(cfg)
+		for _, model := range []string{"llama3.2-vision", "openbmb/minicpm-v", "qwen2.5vl"} {
+			cfg.Model = model
+			p := BuildOCRPrompt(cfg)
+
+			body, notes, ok := strings.Cut(p, "\n\nMODEL NOTES:\n")
+			if !ok {
+				t.Errorf("%s (compact %v): prompt has no model notes", model, compact)
+				continue
+			}
+			// The notes are inserted whole; the schema and closing reminder
+			// are those of the generic prompt
+			a, _, _ := lookupAdapter(model)
+			want := a(cfg)
+			if !strings.HasPrefix(notes, want) {
+				t.Errorf("%s (compact %v): notes = %q, want %q", model, compact, notes, want)
+				continue
+			}
+			if got := body + strings.TrimPrefix(notes, want); got != generic {
+				t.Errorf("%s (compact %v): prompt without its notes differs from the generic prompt", model, compact)
+			}
+		}
+	}
+
+	if p := BuildOCRPrompt(PromptConfig{Model: "qwen2.5vl"}); strings.Contains(p, "0-1000") {
+		t.Error("bounding box note should only be added with bounding boxes")
+	}
+	if p := BuildOCRPrompt(PromptConfig{Model: "llava"}); p != BuildOCRPrompt(PromptConfig{}) {
+		t.Error("models without an adapter should get the generic prompt")
+	}
+}
@@ -2,7 +2,12 @@
 package prompt
 
 import (
+	"bytes"
+	"encoding/json"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -17,15 +22,211 @@ type PromptConfig struct {
 	WithStructuredExtraction bool
 	WithBoundingBoxes        bool
 	WithConfidenceScores     bool
+
+	// WithKeyValueConfidence asks for a confidence per key-value pair. It
+	// requires WithStructuredExtraction and WithConfidenceScores.
+	WithKeyValueConfidence bool
+
+	// WithTableConfidence asks for a confidence per table that it is a real
+	// table. It requires WithStructuredExtraction and WithConfidenceScores.
+	WithTableConfidence bool
+
+	// WithTableSpans asks which table cells span several columns or rows.
+	// It requires WithStructuredExtraction.
+	WithTableSpans bool
+
+	// WithTextDirection asks for the reading direction of the text.
+	WithTextDirection bool
+
+	// WithDocumentRegions asks for the region of each separate document in
+	// the image, such as several receipts scanned together.
+	WithDocumentRegions bool
+
+	// WithWatermarks asks for watermarks and other background text to be
+	// listed separately and left out of the text.
+	WithWatermarks bool
+
+	// FreeformDocumentType lets the model name any document type instead
+	// of choosing from the fixed list.
+	FreeformDocumentType bool
+
+	// Compact describes the schema tersely, by field types instead of an
+	// annotated example, and cuts the rules to the essentials, to save
+	// context on small models.
+	Compact bool
+
+	// WithThumbnail tells the model that the first image is a downscaled
+	// overview of the second.
+	WithThumbnail bool
+
+	// ExpectedDocumentType, when non-empty, tells the model which document
+	// type to expect. The model still reports the type it sees.
+	ExpectedDocumentType string
+
+	// SummaryLength ("short", "medium" or "long") and SummaryMaxWords guide
+	// the summary length. Zero values leave it to the model.
+	SummaryLength   string
+	SummaryMaxWords int
+
+	// Model is the model the prompt is for. It selects the registered
+	// Adapter, if any; see RegisterAdapter.
+	Model string
+}
+
+// EmptyTextRetryInstruction is appended to the prompt when a response without
+// any text is retried.
+const EmptyTextRetryInstruction = `
+
+IMPORTANT: Your previous answer contained no text, but this image contains text. Look at the image again carefully and extract ALL visible text, including small, faint or handwritten text, into "raw" and "lines".`
+
+// MaxExamples caps the few-shot examples added to a prompt. Each one is sent
+// with every model call, so they quickly eat into the context window.
+const MaxExamples = 3
+
+// Example is a few-shot demonstration: a description of a document and the
+// JSON the model should return for it.
+type Example struct {
+	// Description says what the example document looks like, e.g. "a
+	// pharmacy receipt with a handwritten prescription number".
+	Description string
+
+	// Output is the expected JSON answer for that document. It should
+	// follow the same schema the prompt asks for.
+	Output string
 }
 
-// BuildOCRPrompt constructs the deterministic OCR prompt for Ollama vision models.
-// The prompt strictly enforces JSON-only output with the exact required schema.
+// WithExamples returns p preceded by the first MaxExamples examples as
+// demonstrations. The prompt, with its schema and JSON-only rules, follows
+// the examples, so it stays the last instruction the model reads. Outputs are
+// compacted to save tokens. With no examples p is returned unchanged.
+func WithExamples(p string, examples []Example) string {
+	if len(examples) == 0 {
+		return p
+	}
+	if len(examples) > MaxExamples {
+		examples = examples[:MaxExamples]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`EXAMPLES:
+The following examples show the expected output for similar documents. They are not the image you are given: never copy their values, extract only what is visible in the image.`)
+	for i, ex := range examples {
+		output := []byte(ex.Output)
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, output); err == nil {
+			output = compact.Bytes()
+		}
+		sb.WriteString("\n\nExample " + strconv.Itoa(i+1))
+		if ex.Description != "" {
+			sb.WriteString(": " + ex.Description)
+		}
+		sb.WriteString("\nOutput:\n")
+		sb.Write(output)
+	}
+	sb.WriteString("\n\nTASK:\n")
+	sb.WriteString(p)
+	return sb.String()
+}
+
+// summaryLengthGuidance maps a summary length to its prompt instruction.
+var summaryLengthGuidance = map[string]string{
+	"short":  "in one or two sentences",
+	"medium": "in one paragraph",
+	"long":   "in several paragraphs covering all key details",
+}
+
+// maxCachedPrompts bounds promptCache. Configs vary only in a handful of
+// flags, so in practice the cache stays far below this.
+const maxCachedPrompts = 256
+
+// promptCache maps a PromptConfig to its built prompt.
+var (
+	promptCache     sync.Map
+	promptCacheSize atomic.Int64
+)
+
+// Template builds the prompt for one document type. Its output must still
+// ask for the JSON schema of the generic prompt; a template can build on
+// BuildGenericOCRPrompt to keep it.
+type Template func(cfg PromptConfig) string
+
+// templates maps a document type to its registered Template.
+var (
+	templatesMu sync.RWMutex
+	templates   = map[string]Template{}
+)
+
+// Register sets the template used for prompts whose ExpectedDocumentType is
+// docType, replacing any earlier one. Registering a nil template removes it.
+// It is safe for concurrent use.
+func Register(docType string, t Template) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if t == nil {
+		delete(templates, docType)
+		return
+	}
+	templates[docType] = t
+}
+
+// lookupTemplate returns the template registered for docType, if any.
+func lookupTemplate(docType string) (Template, bool) {
+	if docType == "" {
+		return nil, false
+	}
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	t, ok := templates[docType]
+	return t, ok
+}
+
+// BuildOCRPrompt constructs the OCR prompt for Ollama vision models. If a
+// template is registered for cfg.ExpectedDocumentType it builds the prompt;
+// otherwise the generic prompt from BuildGenericOCRPrompt is used.
 func BuildOCRPrompt(cfg PromptConfig) string {
+	if t, ok := lookupTemplate(cfg.ExpectedDocumentType); ok {
+		return t(cfg)
+	}
+	return BuildGenericOCRPrompt(cfg)
+}
+
+// BuildGenericOCRPrompt constructs the deterministic OCR prompt for Ollama
+// vision models, ignoring registered templates. The prompt strictly enforces
+// JSON-only output with the exact required schema. Prompts are cached per
+// config, so repeated calls do not rebuild them. The adapter for cfg.Model,
+// if any, adds its instructions ahead of the closing JSON-only reminder, so
+// templates built on this prompt get them too.
+func BuildGenericOCRPrompt(cfg PromptConfig) string {
+	if p, ok := promptCache.Load(cfg); ok {
+		return p.(string)
+	}
+	p := adaptForModel(buildOCRPrompt(cfg), cfg)
+	if promptCacheSize.Load() < maxCachedPrompts {
+		if _, loaded := promptCache.LoadOrStore(cfg, p); !loaded {
+			promptCacheSize.Add(1)
+		}
+	}
+	return p
+}
+
+// buildOCRPrompt builds the prompt for cfg without caching.
+func buildOCRPrompt(cfg PromptConfig) string {
+	if cfg.Compact {
+		return buildCompactOCRPrompt(cfg)
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString(`You are a precise OCR engine. Analyze the provided image and extract all text content.
+`)
+
+	if cfg.WithThumbnail {
+		sb.WriteString(`
+You are given two images of the same document. The first is a small overview to help you understand the layout: columns, tables and how fields relate. The second is the full-resolution image; read all text, and measure all bounding boxes, from the second image only.
+`)
+	}
 
+	sb.WriteString(`
 CRITICAL INSTRUCTIONS:
 - Respond ONLY with valid JSON.
 - No markdown. No code fences. No explanations. No comments.
@@ -45,8 +246,13 @@ You must return a JSON object with EXACTLY this structure:
 		sb.WriteString(`null,`)
 	}
 
+	if cfg.WithTextDirection {
+		sb.WriteString(`
+    "direction": "<ltr or rtl, the reading direction of the main text>",`)
+	}
+
 	sb.WriteString(`
-    "document_type": "<one of: invoice, receipt, id_card, contract, unknown>",
+    "document_type": "<` + documentTypeDescription(cfg) + `>",
     "confidence_score": <float between 0.0 and 1.0 representing overall OCR confidence>
   },
   "text": {
@@ -86,11 +292,37 @@ You must return a JSON object with EXACTLY this structure:
   "structured_data": {
     "key_value_pairs": {
       "<key>": "<value>"
-    },
+    },`)
+		if cfg.WithKeyValueConfidence && cfg.WithConfidenceScores {
+			sb.WriteString(`
+    "key_value_confidence": {
+      "<key>": <float between 0.0 and 1.0 representing confidence in this value>
+    },`)
+		}
+		if cfg.WithBoundingBoxes {
+			sb.WriteString(`
+    "key_value_boxes": {
+      "<key>": {"x": <x>, "y": <y>, "width": <width>, "height": <height>}
+    },`)
+		}
+		sb.WriteString(`
     "tables": [
       {
         "headers": ["<column header 1>", "<column header 2>"],
-        "rows": [["<cell 1>", "<cell 2>"]]
+        "rows": [["<cell 1>", "<cell 2>"]]`)
+		if cfg.WithBoundingBoxes {
+			sb.WriteString(`,
+        "bounding_box": {"x": <x>, "y": <y>, "width": <width>, "height": <height>}`)
+		}
+		if cfg.WithTableConfidence && cfg.WithConfidenceScores {
+			sb.WriteString(`,
+        "confidence": <float between 0.0 and 1.0 representing confidence that this is a real table and not, e.g., a form or aligned text>`)
+		}
+		if cfg.WithTableSpans {
+			sb.WriteString(`,
+        "spans": [{"row": <index in rows>, "column": <index in the row>, "colspan": <columns covered>, "rowspan": <rows covered>}]`)
+		}
+		sb.WriteString(`
       }
     ]
   },`)
@@ -102,9 +334,24 @@ You must return a JSON object with EXACTLY this structure:
   },`)
 	}
 
+	if cfg.WithDocumentRegions {
+		sb.WriteString(`
+  "documents": [
+    {
+      "bounding_box": {"x": <x>, "y": <y>, "width": <width>, "height": <height>},
+      "document_type": "<` + documentTypeDescription(cfg) + `>"
+    }
+  ],`)
+	}
+
+	if cfg.WithWatermarks {
+		sb.WriteString(`
+  "watermarks": ["<text of each watermark or background text, e.g. DRAFT>"],`)
+	}
+
 	if cfg.WithSummary {
 		sb.WriteString(`
-  "summary": "<brief natural language summary of the document content>"`)
+  "summary": "<` + summaryDescription(cfg) + `>"`)
 	} else {
 		sb.WriteString(`
   "summary": null`)
@@ -114,8 +361,17 @@ You must return a JSON object with EXACTLY this structure:
 }
 
 RULES:
-1. Extract ALL visible text from the image, missing nothing.
-2. "document_type" MUST be exactly one of: "invoice", "receipt", "id_card", "contract", "unknown".
+1. Extract ALL visible text from the image, missing nothing.`)
+
+	if cfg.FreeformDocumentType {
+		sb.WriteString(`
+2. "document_type" must be a short lowercase name for the kind of document, using underscores between words, e.g. "bank_statement", "letter" or "menu". Use "invoice", "receipt", "id_card" or "contract" when the document is one of those.`)
+	} else {
+		sb.WriteString(`
+2. "document_type" MUST be exactly one of: "invoice", "receipt", "id_card", "contract", "unknown".`)
+	}
+
+	sb.WriteString(`
 3. If no tables are found, return "tables": [].
 4. If no key-value pairs are found, return "key_value_pairs": {}.
 5. "lines" must contain every line of text found, even if only one.`)
@@ -123,6 +379,9 @@ RULES:
 	if cfg.WithBoundingBoxes {
 		sb.WriteString(`
 6. Estimate bounding boxes as best as possible based on text position in the image.`)
+		if cfg.WithStructuredExtraction {
+			sb.WriteString(` Give each table the box around the whole table and each key in "key_value_boxes" the box around its value, in the same units as the line boxes.`)
+		}
 	}
 
 	if cfg.WithLanguageDetection {
@@ -130,9 +389,174 @@ RULES:
 7. Detect the primary language of the document and use ISO 639-1 codes (e.g., "en", "fr", "de").`)
 	}
 
+	if cfg.WithStructuredExtraction && cfg.WithKeyValueConfidence && cfg.WithConfidenceScores {
+		sb.WriteString(`
+8. "key_value_confidence" must have exactly the same keys as "key_value_pairs".`)
+	}
+
+	if cfg.WithTextDirection {
+		sb.WriteString(`
+9. "direction" must be "rtl" for right-to-left scripts such as Arabic or Hebrew, otherwise "ltr". Keep the text of each line in logical reading order.`)
+	}
+
+	if cfg.WithDocumentRegions {
+		sb.WriteString(`
+10. The image may contain several separate documents, e.g. multiple receipts scanned together. List each one in "documents" with a box around the whole document`)
+		if cfg.WithBoundingBoxes {
+			sb.WriteString(`, in the same units as the line boxes`)
+		} else {
+			sb.WriteString(`, in pixels of the image`)
+		}
+		sb.WriteString(`. If there is only one document, list just that one.`)
+	}
+
+	if cfg.WithWatermarks {
+		sb.WriteString(`
+11. Watermarks, such as "DRAFT" or "CONFIDENTIAL" printed across the page, and other background text are not part of the content. List each one once in "watermarks" and leave it out of "raw" and "lines". If there are none, return "watermarks": [].`)
+	}
+
+	if cfg.WithStructuredExtraction && cfg.WithTableSpans {
+		sb.WriteString(`
+12. Keep every table row as wide as the headers. When a cell spans several columns or rows, put its text in its first (top-left) position, leave the positions it covers empty and list it in the table's "spans". Cells spanning nothing are not listed; if there are none, return "spans": [].`)
+	}
+
+	if cfg.ExpectedDocumentType != "" {
+		sb.WriteString(`
+
+DOCUMENT TYPE:
+This document is expected to be of type "` + cfg.ExpectedDocumentType + `"; focus extraction on the fields typical for this document type. Still set "document_type" to the type you actually see, even if it differs.`)
+	}
+
 	sb.WriteString(`
 
 Remember: Output ONLY the JSON object. Nothing else.`)
 
 	return sb.String()
 }
+
+// buildCompactOCRPrompt builds the compact prompt for cfg: the same schema
+// and JSON-only requirement as buildOCRPrompt in a fraction of the tokens.
+func buildCompactOCRPrompt(cfg PromptConfig) string {
+	var sb strings.Builder
+
+	sb.WriteString(`You are an OCR engine. Extract all text from the image.`)
+	if cfg.WithThumbnail {
+		sb.WriteString(` The first image is a small layout overview; read text and measure boxes from the second image only.`)
+	}
+	sb.WriteString(`
+Respond ONLY with valid JSON: one object, no markdown, no code fences, no explanations. Schema:
+{"metadata":{"language":`)
+	if cfg.WithLanguageDetection {
+		sb.WriteString(`<ISO 639-1 code|null>`)
+	} else {
+		sb.WriteString(`null`)
+	}
+	if cfg.WithTextDirection {
+		sb.WriteString(`,"direction":<"ltr"|"rtl">`)
+	}
+	sb.WriteString(`,"document_type":<` + documentTypeDescription(cfg) + `>,"confidence_score":<0-1>},
+"text":{"raw":<all text, lines separated by \n>,"lines":[{"text":<string>,"bounding_box":`)
+	box, conf := `null`, `0`
+	if cfg.WithBoundingBoxes {
+		box = `<box>`
+	}
+	if cfg.WithConfidenceScores {
+		conf = `<0-1>`
+	}
+	sb.WriteString(box + `,"confidence":` + conf + `}]},
+"structured_data":`)
+	if cfg.WithStructuredExtraction {
+		sb.WriteString(`{"key_value_pairs":{<key>:<value>}`)
+		if cfg.WithKeyValueConfidence && cfg.WithConfidenceScores {
+			sb.WriteString(`,"key_value_confidence":{<key>:<0-1>}`)
+		}
+		if cfg.WithBoundingBoxes {
+			sb.WriteString(`,"key_value_boxes":{<key>:<box>}`)
+		}
+		sb.WriteString(`,"tables":[{"headers":[<string>],"rows":[[<string>]]`)
+		if cfg.WithBoundingBoxes {
+			sb.WriteString(`,"bounding_box":<box>`)
+		}
+		if cfg.WithTableConfidence && cfg.WithConfidenceScores {
+			sb.WriteString(`,"confidence":<0-1, that it is a real table>`)
+		}
+		if cfg.WithTableSpans {
+			sb.WriteString(`,"spans":[{"row":<int>,"column":<int>,"colspan":<int>,"rowspan":<int>}]`)
+		}
+		sb.WriteString(`}]},`)
+	} else {
+		sb.WriteString(`{"key_value_pairs":{},"tables":[]},`)
+	}
+	if cfg.WithDocumentRegions {
+		sb.WriteString(`
+"documents":[{"bounding_box":<box>,"document_type":<as above>}],`)
+	}
+	if cfg.WithWatermarks {
+		sb.WriteString(`
+"watermarks":[<watermark or background text>],`)
+	}
+	if cfg.WithSummary {
+		sb.WriteString(`
+"summary":<` + summaryDescription(cfg) + `>}`)
+	} else {
+		sb.WriteString(`
+"summary":null}`)
+	}
+	if cfg.WithBoundingBoxes || cfg.WithDocumentRegions {
+		sb.WriteString(`
+<box> is {"x":<x>,"y":<y>,"width":<width>,"height":<height>}, estimated from the position in the image.`)
+	}
+
+	sb.WriteString(`
+Rules: put every line of text in "lines"; use [] or {} when nothing is found.`)
+	if cfg.WithStructuredExtraction && cfg.WithKeyValueConfidence && cfg.WithConfidenceScores {
+		sb.WriteString(` "key_value_confidence" has the same keys as "key_value_pairs".`)
+	}
+	if cfg.WithStructuredExtraction && cfg.WithBoundingBoxes {
+		sb.WriteString(` Table and value boxes use the units of the line boxes.`)
+	}
+	if cfg.WithStructuredExtraction && cfg.WithTableSpans {
+		sb.WriteString(` A table cell spanning several columns or rows keeps its text in its first position, leaves the covered ones empty and is listed in "spans".`)
+	}
+	if cfg.WithTextDirection {
+		sb.WriteString(` Keep line text in logical reading order.`)
+	}
+	if cfg.WithDocumentRegions {
+		sb.WriteString(` List each separate document in the image, e.g. several receipts, or just the one.`)
+		if !cfg.WithBoundingBoxes {
+			sb.WriteString(` Document boxes are in pixels.`)
+		}
+	}
+	if cfg.WithWatermarks {
+		sb.WriteString(` Put watermarks like "DRAFT" and background text in "watermarks" only, not in "raw" or "lines".`)
+	}
+	if cfg.ExpectedDocumentType != "" {
+		sb.WriteString(` The document is expected to be of type "` + cfg.ExpectedDocumentType + `"; report the actual type in "document_type".`)
+	}
+
+	sb.WriteString(`
+Output ONLY the JSON object.`)
+
+	return sb.String()
+}
+
+// documentTypeDescription describes the document_type placeholder.
+func documentTypeDescription(cfg PromptConfig) string {
+	if cfg.FreeformDocumentType {
+		return "short lowercase name of the document type, e.g. invoice, receipt, bank_statement, letter"
+	}
+	return "one of: invoice, receipt, id_card, contract, unknown"
+}
+
+// summaryDescription describes the summary placeholder, including any
+// length guidance.
+func summaryDescription(cfg PromptConfig) string {
+	desc := "brief natural language summary of the document content"
+	if guidance, ok := summaryLengthGuidance[cfg.SummaryLength]; ok {
+		desc = "natural language summary of the document content " + guidance
+	}
+	if cfg.SummaryMaxWords > 0 {
+		desc += ", at most " + strconv.Itoa(cfg.SummaryMaxWords) + " words"
+	}
+	return desc
+}
@@ -85,8 +85,293 @@ func TestBuildOCRPrompt_ContainsSchema(t *testing.T) {
 	}
 }
 
+func TestBuildOCRPrompt_Compact(t *testing.T) {
+	configs := []PromptConfig{
+		{},
+		{WithSummary: true, WithLanguageDetection: true, WithStructuredExtraction: true, WithBoundingBoxes: true, WithConfidenceScores: true},
+		{
+			WithSummary: true, WithLanguageDetection: true, WithStructuredExtraction: true, WithBoundingBoxes: true,
+			WithConfidenceScores: true, WithKeyValueConfidence: true, WithTableConfidence: true, WithTextDirection: true,
+			WithDocumentRegions: true, WithThumbnail: true, ExpectedDocumentType: "receipt",
+		},
+	}
+	for _, cfg := range configs {
+		full := BuildOCRPrompt(cfg)
+		cfg.Compact = true
+		compact := BuildOCRPrompt(cfg)
+
+		if len(compact) > len(full)/2 {
+			t.Errorf("compact prompt is %d bytes, want at most half of %d for %+v", len(compact), len(full), cfg)
+		}
+		for _, phrase := range []string{"Respond ONLY with valid JSON", "no markdown", "Output ONLY the JSON object"} {
+			if !strings.Contains(compact, phrase) {
+				t.Errorf("compact prompt missing %q", phrase)
+			}
+		}
+
+		// Every key of the full schema must be in the compact one
+		for _, key := range []string{
+			"metadata", "language", "direction", "document_type", "confidence_score", "text", "raw", "lines",
+			"bounding_box", "confidence", "structured_data", "key_value_pairs", "key_value_confidence",
+			"key_value_boxes", "tables", "headers", "rows", "documents", "summary",
+		} {
+			quoted := `"` + key + `"`
+			if got, want := strings.Contains(compact, quoted), strings.Contains(full, quoted); got != want {
+				t.Errorf("key %s in compact prompt = %v, in full prompt = %v, for %+v", quoted, got, want, cfg)
+			}
+		}
+	}
+}
+
 func TestPromptVersion(t *testing.T) {
 	if PromptVersion == "" {
 		t.Fatal("PromptVersion is empty")
 	}
 }
+
+func TestBuildOCRPrompt_ExpectedDocumentType(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{ExpectedDocumentType: "receipt"})
+	if !strings.Contains(prompt, `expected to be of type "receipt"`) {
+		t.Error("prompt should state the expected document type")
+	}
+	if strings.Contains(prompt, `Set "document_type" to "receipt"`) {
+		t.Error("prompt should not force the document type")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{})
+	if strings.Contains(prompt, "DOCUMENT TYPE:") {
+		t.Error("prompt should not include document type guidance when none is expected")
+	}
+}
+
+func TestBuildOCRPrompt_SummaryLength(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{WithSummary: true, SummaryLength: "short", SummaryMaxWords: 25})
+	if !strings.Contains(prompt, "in one or two sentences, at most 25 words") {
+		t.Error("prompt should include summary length guidance")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{WithSummary: true})
+	if !strings.Contains(prompt, "brief natural language summary") {
+		t.Error("prompt should keep the default summary description without guidance")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{SummaryLength: "long"})
+	if strings.Contains(prompt, "several paragraphs") {
+		t.Error("summary guidance should not appear when summaries are disabled")
+	}
+}
+
+func TestBuildOCRPrompt_KeyValueConfidence(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{
+		WithStructuredExtraction: true,
+		WithConfidenceScores:     true,
+		WithKeyValueConfidence:   true,
+	})
+	if !strings.Contains(prompt, `"key_value_confidence"`) {
+		t.Error("prompt should request per-field confidence")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{WithStructuredExtraction: true, WithKeyValueConfidence: true})
+	if strings.Contains(prompt, `"key_value_confidence"`) {
+		t.Error("per-field confidence should not be requested without confidence scores")
+	}
+}
+
+func TestBuildOCRPrompt_TableConfidence(t *testing.T) {
+	const want = "confidence that this is a real table"
+	prompt := BuildOCRPrompt(PromptConfig{
+		WithStructuredExtraction: true,
+		WithConfidenceScores:     true,
+		WithTableConfidence:      true,
+	})
+	if !strings.Contains(prompt, want) {
+		t.Error("prompt should request per-table confidence")
+	}
+
+	for _, cfg := range []PromptConfig{
+		{WithStructuredExtraction: true, WithConfidenceScores: true},
+		{WithStructuredExtraction: true, WithTableConfidence: true},
+	} {
+		if strings.Contains(BuildOCRPrompt(cfg), want) {
+			t.Errorf("per-table confidence should not be requested with %+v", cfg)
+		}
+	}
+}
+
+func TestBuildOCRPrompt_StructuredBoxes(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{WithStructuredExtraction: true, WithBoundingBoxes: true})
+	if !strings.Contains(prompt, `"key_value_boxes"`) || strings.Count(prompt, `"bounding_box"`) != 2 {
+		t.Error("prompt should request boxes for key-value pairs and tables")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{WithStructuredExtraction: true})
+	if strings.Contains(prompt, `"key_value_boxes"`) {
+		t.Error("structured boxes should not be requested without bounding boxes")
+	}
+}
+
+func TestBuildOCRPrompt_TextDirection(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{WithTextDirection: true})
+	if !strings.Contains(prompt, `"direction"`) || !strings.Contains(prompt, "Arabic or Hebrew") {
+		t.Error("prompt should request the text direction")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{})
+	if strings.Contains(prompt, `"direction"`) {
+		t.Error("text direction should not be requested by default")
+	}
+}
+
+func TestBuildOCRPrompt_DocumentRegions(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{WithDocumentRegions: true, WithBoundingBoxes: true})
+	if !strings.Contains(prompt, `"documents"`) || !strings.Contains(prompt, "same units as the line boxes") {
+		t.Error("prompt should request document regions in the units of the line boxes")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{WithDocumentRegions: true})
+	if !strings.Contains(prompt, "in pixels of the image") {
+		t.Error("document regions should be requested in pixels without line boxes")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{})
+	if strings.Contains(prompt, `"documents"`) {
+		t.Error("document regions should not be requested by default")
+	}
+}
+
+func TestBuildOCRPrompt_Watermarks(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		prompt := BuildOCRPrompt(PromptConfig{WithWatermarks: true, Compact: compact})
+		if !strings.Contains(prompt, `"watermarks"`) || !strings.Contains(prompt, "DRAFT") {
+			t.Errorf("compact %v: prompt should ask for watermarks separately", compact)
+		}
+
+		prompt = BuildOCRPrompt(PromptConfig{Compact: compact})
+		if strings.Contains(prompt, `"watermarks"`) {
+			t.Errorf("compact %v: watermarks should not be requested by default", compact)
+		}
+	}
+}
+
+func TestBuildOCRPrompt_TableSpans(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		prompt := BuildOCRPrompt(PromptConfig{WithStructuredExtraction: true, WithTableSpans: true, Compact: compact})
+		if !strings.Contains(prompt, `"spans"`) || !strings.Contains(prompt, `"colspan"`) {
+			t.Errorf("compact %v: prompt should ask for cell spans", compact)
+		}
+
+		for _, cfg := range []PromptConfig{
+			{WithStructuredExtraction: true, Compact: compact},
+			{WithTableSpans: true, Compact: compact},
+		} {
+			if strings.Contains(BuildOCRPrompt(cfg), `"spans"`) {
+				t.Errorf("%+v: spans should only be requested with structured extraction", cfg)
+			}
+		}
+	}
+}
+
+func TestBuildOCRPrompt_FreeformDocumentType(t *testing.T) {
+	prompt := BuildOCRPrompt(PromptConfig{FreeformDocumentType: true})
+	if strings.Contains(prompt, "MUST be exactly one of") || !strings.Contains(prompt, "bank_statement") {
+		t.Error("prompt should let the model name the document type")
+	}
+
+	prompt = BuildOCRPrompt(PromptConfig{})
+	if !strings.Contains(prompt, `"document_type" MUST be exactly one of`) {
+		t.Error("prompt should restrict the document type by default")
+	}
+}
+
+func TestBuildOCRPrompt_Cached(t *testing.T) {
+	cfg := PromptConfig{WithSummary: true, SummaryLength: "short", WithBoundingBoxes: true}
+
+	first := BuildOCRPrompt(cfg)
+	if second := BuildOCRPrompt(cfg); second != first {
+		t.Error("cached prompt differs from the first build")
+	}
+	if uncached := buildOCRPrompt(cfg); uncached != first {
+		t.Error("cached prompt differs from an uncached build")
+	}
+
+	cfg.SummaryMaxWords = 10
+	if BuildOCRPrompt(cfg) == first {
+		t.Error("a different config must not reuse the cached prompt")
+	}
+}
+
+func TestWithExamples(t *testing.T) {
+	base := BuildOCRPrompt(PromptConfig{WithStructuredExtraction: true})
+	if got := WithExamples(base, nil); got != base {
+		t.Error("no examples should leave the prompt unchanged")
+	}
+
+	examples := []Example{
+		{Description: "a pharmacy receipt", Output: `{"structured_data": {"key_value_pairs": {"rx_number": "RX-1"}}}`},
+		{Output: `{"text":{"raw":"second"}}`},
+		{Description: "third", Output: `{}`},
+		{Description: "fourth, over the cap", Output: `{}`},
+	}
+	p := WithExamples(base, examples)
+
+	for _, phrase := range []string{
+		"Example 1: a pharmacy receipt",
+		`{"structured_data":{"key_value_pairs":{"rx_number":"RX-1"}}}`,
+		"Example 2\nOutput:\n" + `{"text":{"raw":"second"}}`,
+		"Example 3: third",
+	} {
+		if !strings.Contains(p, phrase) {
+			t.Errorf("prompt missing %q", phrase)
+		}
+	}
+	if strings.Contains(p, "fourth") {
+		t.Errorf("prompt should hold at most %d examples", MaxExamples)
+	}
+
+	// The schema instructions must follow the examples
+	if !strings.HasSuffix(p, "TASK:\n"+base) {
+		t.Error("prompt should end with the unchanged base prompt after the examples")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	const docType = "test_invoice_specialist"
+	t.Cleanup(func() { Register(docType, nil) })
+
+	Register(docType, func(cfg PromptConfig) string {
+		return BuildGenericOCRPrompt(cfg) + "\nLook for the IBAN."
+	})
+
+	prompt := BuildOCRPrompt(PromptConfig{ExpectedDocumentType: docType})
+	if !strings.HasSuffix(prompt, "Look for the IBAN.") {
+		t.Errorf("prompt = %q, want the registered template", prompt)
+	}
+	if !strings.Contains(prompt, "Respond ONLY with valid JSON") {
+		t.Error("template should be able to build on the generic prompt")
+	}
+
+	Register(docType, func(PromptConfig) string { return "replaced" })
+	if got := BuildOCRPrompt(PromptConfig{ExpectedDocumentType: docType}); got != "replaced" {
+		t.Errorf("prompt = %q, want the replacement template", got)
+	}
+}
+
+func TestRegister_Fallback(t *testing.T) {
+	const docType = "test_receipt_specialist"
+	cfg := PromptConfig{ExpectedDocumentType: docType}
+	generic := BuildGenericOCRPrompt(cfg)
+
+	if got := BuildOCRPrompt(cfg); got != generic {
+		t.Error("unregistered document type should use the generic prompt")
+	}
+
+	Register(docType, func(PromptConfig) string { return "custom" })
+	Register(docType, nil)
+	if got := BuildOCRPrompt(cfg); got != generic {
+		t.Error("removed template should fall back to the generic prompt")
+	}
+
+	if got := BuildOCRPrompt(PromptConfig{}); got != BuildGenericOCRPrompt(PromptConfig{}) {
+		t.Error("prompt without a document type should be generic")
+	}
+}
@@ -0,0 +1,110 @@
+package ocr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// SchemaName names an output shape for Render.
+type SchemaName string
+
+// Built-in schemas.
+const (
+	// SchemaStrict is the full OCRResult, unchanged.
+	SchemaStrict SchemaName = "strict"
+
+	// SchemaMinimal keeps only the text, document type and language, as a
+	// MinimalResult.
+	SchemaMinimal SchemaName = "minimal"
+
+	// SchemaFlat is a map[string]any from the dotted JSON path of every
+	// value in the strict schema to the value, e.g. "metadata.document_type"
+	// or "text.lines.0.text".
+	SchemaFlat SchemaName = "flat"
+)
+
+// renderers holds the projection of every SchemaName.
+var renderers = map[SchemaName]func(*models.OCRResult) (any, error){
+	SchemaStrict:  func(r *models.OCRResult) (any, error) { return r, nil },
+	SchemaMinimal: renderMinimal,
+	SchemaFlat:    renderFlat,
+}
+
+// MinimalResult is the SchemaMinimal projection of a result.
+type MinimalResult struct {
+	Text         string              `json:"text"`
+	DocumentType models.DocumentType `json:"document_type"`
+	Language     *string             `json:"language"`
+}
+
+// Render projects result into the named schema, for consumers that want a
+// different shape than OCRResult. The returned value marshals to JSON in
+// that shape. The error wraps ErrUnknownSchema for an unknown name.
+func Render(result *models.OCRResult, schema SchemaName) (any, error) {
+	render, ok := renderers[schema]
+	if !ok {
+		return nil, WrapError("Render", fmt.Errorf("%w: %q", ErrUnknownSchema, schema))
+	}
+	if result == nil {
+		return nil, WrapError("Render", errors.New("result is nil"))
+	}
+	return render(result)
+}
+
+// Render projects result into the schema configured with WithSchema.
+// Per-call options override the client's base configuration for this call
+// only.
+func (c *Client) Render(result *models.OCRResult, opts ...Option) (any, error) {
+	return Render(result, c.config(opts...).Schema)
+}
+
+func renderMinimal(r *models.OCRResult) (any, error) {
+	return &MinimalResult{
+		Text:         r.Text.Raw,
+		DocumentType: r.Metadata.DocumentType,
+		Language:     r.Metadata.Language,
+	}, nil
+}
+
+// renderFlat flattens the JSON encoding of r, so the keys follow the JSON
+// field names and omitted fields stay omitted. Empty objects and arrays have
+// no values and are left out; numbers are float64 as decoded by
+// encoding/json.
+func renderFlat(r *models.OCRResult) (any, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, WrapError("Render", err)
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, WrapError("Render", err)
+	}
+	flat := make(map[string]any)
+	flatten(flat, "", tree)
+	return flat, nil
+}
+
+func flatten(flat map[string]any, prefix string, v any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flatten(flat, join(key), child)
+		}
+	case []any:
+		for i, child := range v {
+			flatten(flat, join(strconv.Itoa(i)), child)
+		}
+	default:
+		flat[prefix] = v
+	}
+}
@@ -0,0 +1,106 @@
+package ocr
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestRender_Strict(t *testing.T) {
+	r := exportFixture()
+	got, err := Render(r, SchemaStrict)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got != r {
+		t.Errorf("strict render = %v, want the result itself", got)
+	}
+}
+
+func TestRender_Minimal(t *testing.T) {
+	r := exportFixture()
+	r.Text.Raw = "ACME Store\nTOTAL 9.99"
+
+	got, err := Render(r, SchemaMinimal)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"text":"ACME Store\nTOTAL 9.99","document_type":"receipt","language":"en"}`
+	if string(data) != want {
+		t.Errorf("minimal render = %s, want %s", data, want)
+	}
+}
+
+func TestRender_Flat(t *testing.T) {
+	r := exportFixture()
+	r.StructuredData.KeyValuePairs = map[string]string{"total": "9.99"}
+	r.StructuredData.Tables = []models.Table{}
+
+	got, err := Render(r, SchemaFlat)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	flat, ok := got.(map[string]any)
+	if !ok {
+		t.Fatalf("flat render is %T, want map[string]any", got)
+	}
+
+	want := map[string]any{
+		"source.type":                           "file",
+		"image.width":                           600.0,
+		"metadata.document_type":                "receipt",
+		"metadata.language":                     "en",
+		"text.lines.0.text":                     "ACME Store",
+		"text.lines.1.bounding_box.width":       0.6,
+		"text.lines.3.bounding_box":             nil,
+		"structured_data.key_value_pairs.total": "9.99",
+		"summary":                               nil,
+	}
+	for key, value := range want {
+		if got, ok := flat[key]; !ok || !reflect.DeepEqual(got, value) {
+			t.Errorf("flat[%q] = %v (present %v), want %v", key, got, ok, value)
+		}
+	}
+	for _, key := range []string{"text.lines", "structured_data.tables", "warnings"} {
+		if _, ok := flat[key]; ok {
+			t.Errorf("flat has container key %q", key)
+		}
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	if _, err := Render(exportFixture(), "nested"); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("err = %v, want ErrUnknownSchema", err)
+	}
+	if _, err := Render(nil, SchemaStrict); err == nil {
+		t.Error("expected an error for a nil result")
+	}
+}
+
+func TestClient_Render(t *testing.T) {
+	c := NewClient(WithSchema(SchemaMinimal))
+	r := exportFixture()
+
+	got, err := c.Render(r)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if _, ok := got.(*MinimalResult); !ok {
+		t.Errorf("client render is %T, want *MinimalResult", got)
+	}
+
+	got, err = c.Render(r, WithSchema(SchemaStrict))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got != r {
+		t.Errorf("per-call strict render = %T, want the result itself", got)
+	}
+}
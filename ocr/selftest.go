@@ -0,0 +1,206 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/client"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/engine"
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/utils"
+)
+
+// SelfTestReport is the outcome of SelfTest.
+type SelfTestReport struct {
+	// OK is true if every required check passed.
+	OK     bool            `json:"ok"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// SelfTestCheck is the outcome of one dependency check.
+type SelfTestCheck struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+
+	// Required is false for dependencies the pipeline can work without,
+	// possibly with reduced functionality.
+	Required bool `json:"required"`
+
+	// Detail describes what was found and Hint, for failed checks, how to
+	// fix it.
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// SelfTest checks the dependencies of the pipeline configured by opts: that
+// the model server is reachable and serves the configured models, whether
+// pdftoppm and, for the tesseract and auto engines, tesseract are installed,
+// and which image decoders are registered. Unlike the ping done by Extract,
+// it checks everything and reports all problems at once, so it suits
+// deployment checks.
+//
+// The report is always returned. The error wraps ErrSelfTestFailed if a
+// required check failed.
+func SelfTest(ctx context.Context, opts ...Option) (*SelfTestReport, error) {
+	cfg := DefaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	var checks []SelfTestCheck
+	if cfg.Engine != EngineTesseract {
+		checks = append(checks, checkBackend(ctx, cfg)...)
+	}
+	if cfg.Engine == EngineTesseract || cfg.Engine == EngineAuto {
+		checks = append(checks, checkTesseract(cfg))
+	}
+	checks = append(checks, checkPDFRenderer())
+	checks = append(checks, checkDecoders()...)
+
+	report := &SelfTestReport{OK: true, Checks: checks}
+	var failed []string
+	for _, c := range checks {
+		if c.Required && !c.OK {
+			report.OK = false
+			failed = append(failed, c.Name)
+		}
+	}
+	if !report.OK {
+		return report, NewOCRError("SelfTest", "", fmt.Errorf("%w: %s", ErrSelfTestFailed, strings.Join(failed, ", ")))
+	}
+	return report, nil
+}
+
+// checkBackend checks that the model server is reachable and serves the
+// primary model and any fallback models. Only the primary model is
+// required.
+func checkBackend(ctx context.Context, cfg *Config) []SelfTestCheck {
+	backend := newBackend(cfg)
+
+	server := SelfTestCheck{Name: "model server", Required: true}
+	if err := backend.Ping(ctx); err != nil {
+		server.Detail = err.Error()
+		server.Hint = "start the model server (e.g. `ollama serve`) or fix WithOllamaURL/WithBackend"
+		return []SelfTestCheck{server}
+	}
+	server.OK = true
+	server.Detail = "reachable"
+	checks := []SelfTestCheck{server}
+
+	lister, ok := backend.(client.ModelLister)
+	if !ok {
+		return append(checks, SelfTestCheck{
+			Name:     "model " + cfg.Model,
+			OK:       true,
+			Required: true,
+			Detail:   "not checked: the backend cannot list its models",
+		})
+	}
+	available, err := listModels(ctx, lister, cfg.Model)
+
+	for i, model := range append([]string{cfg.Model}, cfg.FallbackModels...) {
+		check := SelfTestCheck{Name: "model " + model, Required: i == 0}
+		switch {
+		case err != nil:
+			check.Detail = "listing models failed: " + err.Error()
+			check.Hint = "check that the server supports listing models"
+		case modelAvailable(available, model):
+			check.OK = true
+			check.Detail = "available"
+		default:
+			check.Detail = "not found on the server"
+			check.Hint = fmt.Sprintf("pull it with `ollama pull %s` or choose another model with WithModel", model)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// A model server that is still starting can answer the model list with an
+// empty or partial body for a moment, so a listing that fails or lacks the
+// primary model is retried this many times in total, this far apart.
+var (
+	modelListAttempts   = 3
+	modelListRetryDelay = 500 * time.Millisecond
+)
+
+// listModels lists the models of lister, retrying while the listing fails or
+// lacks model, until modelListAttempts are used up or ctx is done. It returns
+// the last listing.
+func listModels(ctx context.Context, lister client.ModelLister, model string) ([]string, error) {
+	for attempt := 1; ; attempt++ {
+		available, err := lister.ListModels(ctx)
+		if err == nil && modelAvailable(available, model) || attempt >= modelListAttempts {
+			return available, err
+		}
+		timer := time.NewTimer(modelListRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return available, err
+		case <-timer.C:
+		}
+	}
+}
+
+// modelAvailable reports whether model is in available. Ollama lists models
+// with their tag, so a model without one also matches its ":latest" tag.
+func modelAvailable(available []string, model string) bool {
+	for _, name := range available {
+		if name == model || (!strings.Contains(model, ":") && name == model+":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTesseract checks for the tesseract binary, which is required by
+// EngineTesseract and used as a fallback by EngineAuto.
+func checkTesseract(cfg *Config) SelfTestCheck {
+	check := SelfTestCheck{Name: "tesseract", Required: cfg.Engine == EngineTesseract}
+	if engine.TesseractAvailable() {
+		check.OK = true
+		check.Detail = "found on PATH"
+		return check
+	}
+	check.Detail = "not found on PATH"
+	check.Hint = "install tesseract-ocr (brew install tesseract, apt install tesseract-ocr)"
+	return check
+}
+
+// checkPDFRenderer checks for pdftoppm. Without it PDFs are sent to the
+// model unrendered and encrypted PDFs cannot be opened.
+func checkPDFRenderer() SelfTestCheck {
+	check := SelfTestCheck{Name: "pdftoppm"}
+	if utils.PDFRendererAvailable() {
+		check.OK = true
+		check.Detail = "found on PATH"
+		return check
+	}
+	check.Detail = "not found on PATH; PDFs are sent to the model unrendered"
+	check.Hint = "install poppler-utils (brew install poppler, apt install poppler-utils)"
+	return check
+}
+
+// checkDecoders reports which image decoders are registered. PNG, JPEG and
+// GIF come from the standard library, WebP and TIFF from golang.org/x/image.
+func checkDecoders() []SelfTestCheck {
+	formats := []string{"png", "jpeg", "gif", "webp", "tiff"}
+
+	checks := make([]SelfTestCheck, 0, len(formats))
+	for _, format := range formats {
+		check := SelfTestCheck{Name: format + " decoder", Required: true}
+		if utils.DecoderRegistered(format) {
+			check.OK = true
+			check.Detail = "registered"
+		} else {
+			check.Detail = "not registered"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
@@ -0,0 +1,142 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/ollamatest"
+)
+
+// findCheck returns the check called name, failing the test if it is missing.
+func findCheck(t *testing.T, report *SelfTestReport, name string) SelfTestCheck {
+	t.Helper()
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %q check in %+v", name, report.Checks)
+	return SelfTestCheck{}
+}
+
+func TestSelfTest_MissingPDFRenderer(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // simulate pdftoppm not being installed
+	srv := ollamatest.NewServer(t, nil)
+	srv.SetModels(DefaultModel + ":latest")
+
+	report, err := SelfTest(context.Background(), WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("SelfTest: %v", err)
+	}
+	if !report.OK {
+		t.Errorf("report not OK: %+v", report.Checks)
+	}
+	if c := findCheck(t, report, "model server"); !c.OK {
+		t.Errorf("model server check = %+v, want OK", c)
+	}
+	if c := findCheck(t, report, "model "+DefaultModel); !c.OK {
+		t.Errorf("model check = %+v, want the :latest tag to match", c)
+	}
+	pdf := findCheck(t, report, "pdftoppm")
+	if pdf.OK || pdf.Required || pdf.Hint == "" {
+		t.Errorf("pdftoppm check = %+v, want an optional failure with a hint", pdf)
+	}
+	if c := findCheck(t, report, "png decoder"); !c.OK {
+		t.Errorf("png decoder check = %+v, want OK", c)
+	}
+	for _, c := range report.Checks {
+		if c.Name == "tesseract" {
+			t.Error("tesseract should not be checked for the ollama engine")
+		}
+	}
+}
+
+func TestSelfTest_MissingModel(t *testing.T) {
+	defer func(d time.Duration) { modelListRetryDelay = d }(modelListRetryDelay)
+	modelListRetryDelay = time.Millisecond
+
+	srv := ollamatest.NewServer(t, nil)
+	srv.SetModels("other-model:latest", "backup:latest")
+
+	report, err := SelfTest(context.Background(), WithOllamaURL(srv.URL), WithFallbackModels([]string{"backup", "absent"}))
+	if !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("err = %v, want ErrSelfTestFailed", err)
+	}
+	if report == nil || report.OK {
+		t.Fatalf("report = %+v, want a failed report", report)
+	}
+
+	primary := findCheck(t, report, "model "+DefaultModel)
+	if primary.OK || !primary.Required || primary.Hint == "" {
+		t.Errorf("primary model check = %+v, want a required failure with a hint", primary)
+	}
+	if c := findCheck(t, report, "model backup"); !c.OK {
+		t.Errorf("fallback check = %+v, want OK", c)
+	}
+	if c := findCheck(t, report, "model absent"); c.OK || c.Required {
+		t.Errorf("missing fallback check = %+v, want an optional failure", c)
+	}
+}
+
+func TestSelfTest_ModelListStartupRace(t *testing.T) {
+	defer func(d time.Duration) { modelListRetryDelay = d }(modelListRetryDelay)
+	modelListRetryDelay = time.Millisecond
+
+	// The ping and the first listing see a server that has not loaded its
+	// models yet, the second listing a partial body.
+	bodies := []string{
+		`{"models":[]}`,
+		`{"models":[]}`,
+		`{"models":[{"name":"llama`,
+		`{"models":[{"name":"` + DefaultModel + `:latest"}]}`,
+	}
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		w.Write([]byte(bodies[min(n, len(bodies)-1)]))
+	}))
+	defer srv.Close()
+
+	report, err := SelfTest(context.Background(), WithOllamaURL(srv.URL))
+	if err != nil {
+		t.Fatalf("SelfTest: %v", err)
+	}
+	if c := findCheck(t, report, "model "+DefaultModel); !c.OK {
+		t.Errorf("model check = %+v, want OK once the server lists the model", c)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("tags requests = %d, want 4 (ping + 3 listings)", n)
+	}
+}
+
+func TestSelfTest_ServerUnavailable(t *testing.T) {
+	report, err := SelfTest(context.Background(), WithOllamaURL("http://127.0.0.1:1"))
+	if !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("err = %v, want ErrSelfTestFailed", err)
+	}
+	if c := findCheck(t, report, "model server"); c.OK || c.Hint == "" {
+		t.Errorf("model server check = %+v, want a failure with a hint", c)
+	}
+}
+
+func TestSelfTest_TesseractEngine(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	report, err := SelfTest(context.Background(), WithEngine(EngineTesseract))
+	if !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("err = %v, want ErrSelfTestFailed for missing tesseract", err)
+	}
+	if c := findCheck(t, report, "tesseract"); c.OK || !c.Required {
+		t.Errorf("tesseract check = %+v, want a required failure", c)
+	}
+	for _, c := range report.Checks {
+		if c.Name == "model server" {
+			t.Error("the model server should not be checked for the tesseract engine")
+		}
+	}
+}
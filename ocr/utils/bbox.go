@@ -0,0 +1,44 @@
+package utils
+
+import "github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+
+// DetectBoundingBoxUnits guesses the coordinate system of a set of boxes.
+// Boxes that all fit within the unit square are considered normalized;
+// anything larger is considered pixels. It returns "" when there are no boxes.
+func DetectBoundingBoxUnits(boxes []*models.BoundingBox) models.BoundingBoxUnits {
+	found := false
+	for _, b := range boxes {
+		if b == nil {
+			continue
+		}
+		found = true
+		if b.X+b.Width > 1 || b.Y+b.Height > 1 {
+			return models.BoundingBoxUnitsPixels
+		}
+	}
+	if !found {
+		return ""
+	}
+	return models.BoundingBoxUnitsNormalized
+}
+
+// ConvertBoundingBox converts a box between pixel and normalized coordinates
+// for an image of the given size. It returns false, leaving the box
+// unchanged, if a conversion is needed but the dimensions are unknown.
+func ConvertBoundingBox(b models.BoundingBox, from, to models.BoundingBoxUnits, width, height int) (models.BoundingBox, bool) {
+	if from == to {
+		return b, true
+	}
+	if width <= 0 || height <= 0 {
+		return b, false
+	}
+
+	w, h := float64(width), float64(height)
+	switch {
+	case from == models.BoundingBoxUnitsPixels && to == models.BoundingBoxUnitsNormalized:
+		return models.BoundingBox{X: b.X / w, Y: b.Y / h, Width: b.Width / w, Height: b.Height / h}, true
+	case from == models.BoundingBoxUnitsNormalized && to == models.BoundingBoxUnitsPixels:
+		return models.BoundingBox{X: b.X * w, Y: b.Y * h, Width: b.Width * w, Height: b.Height * h}, true
+	}
+	return b, false
+}
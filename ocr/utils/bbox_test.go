@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestDetectBoundingBoxUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		boxes    []*models.BoundingBox
+		expected models.BoundingBoxUnits
+	}{
+		{"none", nil, ""},
+		{"only nil", []*models.BoundingBox{nil}, ""},
+		{"normalized", []*models.BoundingBox{{X: 0.1, Y: 0.2, Width: 0.5, Height: 0.05}}, models.BoundingBoxUnitsNormalized},
+		{"pixels", []*models.BoundingBox{{X: 0.1, Y: 0.2, Width: 0.5, Height: 0.05}, {X: 120, Y: 40, Width: 300, Height: 18}}, models.BoundingBoxUnitsPixels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectBoundingBoxUnits(tt.boxes); got != tt.expected {
+				t.Errorf("DetectBoundingBoxUnits = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertBoundingBox(t *testing.T) {
+	pixels := models.BoundingBox{X: 100, Y: 50, Width: 400, Height: 25}
+	normalized := models.BoundingBox{X: 0.125, Y: 0.125, Width: 0.5, Height: 0.0625}
+
+	got, ok := ConvertBoundingBox(pixels, models.BoundingBoxUnitsPixels, models.BoundingBoxUnitsNormalized, 800, 400)
+	if !ok || got != normalized {
+		t.Errorf("pixels -> normalized = (%+v, %v), want (%+v, true)", got, ok, normalized)
+	}
+
+	got, ok = ConvertBoundingBox(normalized, models.BoundingBoxUnitsNormalized, models.BoundingBoxUnitsPixels, 800, 400)
+	if !ok || got != pixels {
+		t.Errorf("normalized -> pixels = (%+v, %v), want (%+v, true)", got, ok, pixels)
+	}
+
+	got, ok = ConvertBoundingBox(pixels, models.BoundingBoxUnitsPixels, models.BoundingBoxUnitsPixels, 0, 0)
+	if !ok || got != pixels {
+		t.Errorf("same units should be a no-op, got (%+v, %v)", got, ok)
+	}
+}
+
+func TestConvertBoundingBox_UnknownDimensions(t *testing.T) {
+	pixels := models.BoundingBox{X: 100, Y: 50, Width: 400, Height: 25}
+
+	got, ok := ConvertBoundingBox(pixels, models.BoundingBoxUnitsPixels, models.BoundingBoxUnitsNormalized, 0, 400)
+	if ok {
+		t.Error("conversion should be skipped when dimensions are unknown")
+	}
+	if got != pixels {
+		t.Errorf("box should be unchanged, got %+v", got)
+	}
+}
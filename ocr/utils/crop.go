@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// subImager is implemented by all standard library image types.
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// CropImage crops the image to region, given in pixels, and returns the crop
+// encoded as PNG together with the cropped rectangle in original-image
+// coordinates. The region is clipped to the image bounds.
+func CropImage(data []byte, region models.BoundingBox) ([]byte, image.Rectangle, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("decode image: %w", err)
+	}
+
+	b := img.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(math.Floor(region.X)),
+		b.Min.Y+int(math.Floor(region.Y)),
+		b.Min.X+int(math.Ceil(region.X+region.Width)),
+		b.Min.Y+int(math.Ceil(region.Y+region.Height)),
+	).Intersect(b)
+	if rect.Empty() {
+		return nil, image.Rectangle{}, fmt.Errorf("region %+v is outside the %dx%d image", region, b.Dx(), b.Dy())
+	}
+
+	si, ok := img.(subImager)
+	if !ok {
+		return nil, image.Rectangle{}, fmt.Errorf("image type %T cannot be cropped", img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, si.SubImage(rect)); err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("encode crop: %w", err)
+	}
+	return buf.Bytes(), rect.Sub(b.Min), nil
+}
+
+// StripRegions splits a width x height image into the fewest horizontal
+// strips of equal height, give or take a pixel, that are each at most
+// maxAspect times taller than wide. The strips are ordered top to bottom and
+// together cover every row exactly once. An image that needs no split yields
+// a single strip.
+func StripRegions(width, height int, maxAspect float64) []models.BoundingBox {
+	if width <= 0 || height <= 0 || maxAspect <= 0 {
+		return nil
+	}
+	n := max(1, int(math.Ceil(float64(height)/(maxAspect*float64(width)))))
+	strips := make([]models.BoundingBox, n)
+	for i := range strips {
+		y0, y1 := i*height/n, (i+1)*height/n
+		strips[i] = models.BoundingBox{Y: float64(y0), Width: float64(width), Height: float64(y1 - y0)}
+	}
+	return strips
+}
+
+// OffsetBoundingBox translates a pixel bounding box found in a crop back to
+// the coordinates of the original image.
+func OffsetBoundingBox(b models.BoundingBox, offset image.Point) models.BoundingBox {
+	b.X += float64(offset.X)
+	b.Y += float64(offset.Y)
+	return b
+}
+
+// RotateImage180 rotates the image by 180 degrees and returns it encoded as
+// PNG.
+func RotateImage180(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(src.Bounds())
+	w, h := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(w-1-x, h-1-y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("encode rotated image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
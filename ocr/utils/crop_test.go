@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestCropImage(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 100, 50))
+	src.SetGray(30, 20, color.Gray{Y: 200})
+	data := encodePNG(t, src)
+
+	tests := []struct {
+		name   string
+		region models.BoundingBox
+		want   image.Rectangle
+	}{
+		{"inside", models.BoundingBox{X: 25, Y: 10, Width: 20, Height: 15}, image.Rect(25, 10, 45, 25)},
+		{"fractional edges round outward", models.BoundingBox{X: 25.5, Y: 10.2, Width: 10, Height: 5}, image.Rect(25, 10, 36, 16)},
+		{"clipped to bounds", models.BoundingBox{X: 90, Y: 40, Width: 50, Height: 50}, image.Rect(90, 40, 100, 50)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crop, rect, err := CropImage(data, tt.region)
+			if err != nil {
+				t.Fatalf("CropImage: %v", err)
+			}
+			if rect != tt.want {
+				t.Errorf("rect = %v, want %v", rect, tt.want)
+			}
+
+			img, err := png.Decode(bytes.NewReader(crop))
+			if err != nil {
+				t.Fatalf("decode crop: %v", err)
+			}
+			if img.Bounds().Dx() != tt.want.Dx() || img.Bounds().Dy() != tt.want.Dy() {
+				t.Errorf("crop size = %v, want %dx%d", img.Bounds().Size(), tt.want.Dx(), tt.want.Dy())
+			}
+		})
+	}
+}
+
+func TestCropImage_PixelLandsAtOffset(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 100, 50))
+	src.SetGray(30, 20, color.Gray{Y: 200})
+
+	crop, rect, err := CropImage(encodePNG(t, src), models.BoundingBox{X: 25, Y: 10, Width: 20, Height: 15})
+	if err != nil {
+		t.Fatalf("CropImage: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(crop))
+	if err != nil {
+		t.Fatalf("decode crop: %v", err)
+	}
+
+	// The marked pixel at (30, 20) must be at (5, 10) inside the crop, and
+	// mapping it back with the crop offset must give the original position.
+	b := img.Bounds()
+	if g := color.GrayModel.Convert(img.At(b.Min.X+5, b.Min.Y+10)).(color.Gray); g.Y != 200 {
+		t.Errorf("pixel at crop (5,10) = %d, want 200", g.Y)
+	}
+	back := OffsetBoundingBox(models.BoundingBox{X: 5, Y: 10, Width: 1, Height: 1}, rect.Min)
+	if back.X != 30 || back.Y != 20 {
+		t.Errorf("offset box = (%v,%v), want (30,20)", back.X, back.Y)
+	}
+}
+
+func TestCropImage_OutsideImage(t *testing.T) {
+	data := encodePNG(t, image.NewGray(image.Rect(0, 0, 10, 10)))
+	if _, _, err := CropImage(data, models.BoundingBox{X: 20, Y: 20, Width: 5, Height: 5}); err == nil {
+		t.Error("expected error for a region outside the image")
+	}
+}
+
+func TestOffsetBoundingBox(t *testing.T) {
+	got := OffsetBoundingBox(models.BoundingBox{X: 1.5, Y: 2, Width: 10, Height: 4}, image.Pt(100, 200))
+	want := models.BoundingBox{X: 101.5, Y: 202, Width: 10, Height: 4}
+	if got != want {
+		t.Errorf("OffsetBoundingBox = %+v, want %+v", got, want)
+	}
+}
+
+func TestStripRegions(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		wantHeights   []float64
+	}{
+		{"long receipt", 1000, 8000, []float64{2000, 2000, 2000, 2000}},
+		{"uneven split", 100, 301, []float64{150, 151}},
+		{"just over", 100, 201, []float64{100, 101}},
+		{"fits", 100, 200, []float64{200}},
+		{"wide", 300, 100, []float64{100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strips := StripRegions(tt.width, tt.height, 2)
+			if len(strips) != len(tt.wantHeights) {
+				t.Fatalf("got %d strips, want %d", len(strips), len(tt.wantHeights))
+			}
+			y := 0.0
+			for i, s := range strips {
+				// Top to bottom, edge to edge, without gaps or overlap
+				if s.X != 0 || s.Y != y || s.Width != float64(tt.width) || s.Height != tt.wantHeights[i] {
+					t.Errorf("strip %d = %+v, want y %v, width %d, height %v", i, s, y, tt.width, tt.wantHeights[i])
+				}
+				if s.Height > 2*s.Width+1 {
+					t.Errorf("strip %d is %vx%v, taller than the aspect ratio allows", i, s.Width, s.Height)
+				}
+				y += s.Height
+			}
+			if y != float64(tt.height) {
+				t.Errorf("strips cover %v rows, want %d", y, tt.height)
+			}
+		})
+	}
+
+	if got := StripRegions(0, 100, 2); got != nil {
+		t.Errorf("StripRegions of an empty image = %v, want nil", got)
+	}
+}
+
+func TestRotateImage180(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 100, 50))
+	src.SetGray(30, 20, color.Gray{Y: 200})
+
+	rotated, err := RotateImage180(encodePNG(t, src))
+	if err != nil {
+		t.Fatalf("RotateImage180: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(rotated))
+	if err != nil {
+		t.Fatalf("decode rotated image: %v", err)
+	}
+
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 50 {
+		t.Errorf("size = %v, want 100x50", img.Bounds().Size())
+	}
+	if g := color.GrayModel.Convert(img.At(69, 29)).(color.Gray); g.Y != 200 {
+		t.Errorf("pixel at (69,29) = %d, want the marked pixel from (30,20)", g.Y)
+	}
+	if g := color.GrayModel.Convert(img.At(30, 20)).(color.Gray); g.Y != 0 {
+		t.Errorf("pixel at (30,20) = %d, want 0", g.Y)
+	}
+}
+
+func TestRotateImage180_Undecodable(t *testing.T) {
+	if _, err := RotateImage180([]byte("not an image")); err == nil {
+		t.Error("expected error for undecodable data")
+	}
+}
@@ -2,10 +2,15 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"strings"
 )
 
 // SHA256File computes the SHA-256 checksum of a file.
@@ -23,6 +28,44 @@ func SHA256File(path string) (string, error) {
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
+// ErrSizeLimitExceeded is returned by ReadLimited when the input is larger
+// than the allowed size.
+var ErrSizeLimitExceeded = errors.New("size limit exceeded")
+
+// ReadLimited reads r into memory, failing as soon as more than maxSize
+// bytes have been read, and computes the SHA-256 checksum while reading.
+func ReadLimited(r io.Reader, maxSize int64) ([]byte, string, error) {
+	h := sha256.New()
+	var buf bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(&buf, h), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("read: %w", err)
+	}
+	if n > maxSize {
+		return nil, "", fmt.Errorf("%w: more than %d bytes", ErrSizeLimitExceeded, maxSize)
+	}
+	return buf.Bytes(), fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ErrUnsupportedHash is returned by Checksum for an unknown algorithm.
+var ErrUnsupportedHash = errors.New("unsupported hash algorithm")
+
+// Checksum computes the hex checksum of data with algo, "sha256" or
+// "sha512" (case-insensitive).
+func Checksum(algo string, data []byte) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(algo) {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedHash, algo)
+	}
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
 // SHA256Bytes computes the SHA-256 checksum of a byte slice.
 func SHA256Bytes(data []byte) string {
 	h := sha256.Sum256(data)
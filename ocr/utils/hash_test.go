@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestSHA256File(t *testing.T) {
@@ -34,9 +38,78 @@ func TestSHA256Bytes(t *testing.T) {
 	}
 }
 
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		algo string
+		want string
+	}{
+		{"sha256", "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		{"SHA512", "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"},
+	}
+	for _, tt := range tests {
+		got, err := Checksum(tt.algo, []byte("hello world"))
+		if err != nil {
+			t.Fatalf("Checksum(%q): %v", tt.algo, err)
+		}
+		if got != tt.want {
+			t.Errorf("Checksum(%q) = %q, want %q", tt.algo, got, tt.want)
+		}
+	}
+
+	if _, err := Checksum("md4", nil); !errors.Is(err, ErrUnsupportedHash) {
+		t.Errorf("err = %v, want ErrUnsupportedHash", err)
+	}
+}
+
 func TestSHA256File_NotFound(t *testing.T) {
 	_, err := SHA256File("/nonexistent/path/file.txt")
 	if err == nil {
 		t.Fatal("expected error for nonexistent file")
 	}
 }
+
+func TestReadLimited(t *testing.T) {
+	data, checksum, err := ReadLimited(strings.NewReader("hello world"), 11)
+	if err != nil {
+		t.Fatalf("ReadLimited: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("data = %q, want %q", data, "hello world")
+	}
+	if checksum != SHA256Bytes([]byte("hello world")) {
+		t.Errorf("checksum = %q, want checksum of the data", checksum)
+	}
+}
+
+// countingReader is an endless reader that records how many bytes it served.
+type countingReader struct {
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestReadLimited_TooLarge(t *testing.T) {
+	r := &countingReader{}
+
+	_, _, err := ReadLimited(r, 1024)
+	if !errors.Is(err, ErrSizeLimitExceeded) {
+		t.Fatalf("err = %v, want ErrSizeLimitExceeded", err)
+	}
+	if r.n > 1025 {
+		t.Errorf("read %d bytes, want at most limit+1 (1025)", r.n)
+	}
+}
+
+func TestReadLimited_ReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+
+	if _, _, err := ReadLimited(r, 1024); err == nil || errors.Is(err, ErrSizeLimitExceeded) {
+		t.Fatalf("err = %v, want read error", err)
+	}
+}
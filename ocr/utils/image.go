@@ -1,18 +1,28 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	_ "image/jpeg" // Register JPEG decoder
-	_ "image/png"  // Register PNG decoder
+	"image/draw"
+	_ "image/gif" // Register GIF decoder
+	"image/jpeg"
+	"image/png"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	_ "golang.org/x/image/tiff" // Register TIFF decoder
+	_ "golang.org/x/image/webp" // Register WebP decoder
 
 	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
 )
@@ -22,9 +32,19 @@ var SupportedExtensions = map[string]bool{
 	".png":  true,
 	".jpg":  true,
 	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+	".tif":  true,
+	".tiff": true,
 	".pdf":  true,
 }
 
+// modelFormats are the image formats vision models reliably accept.
+var modelFormats = map[string]bool{
+	"png":  true,
+	"jpeg": true,
+}
+
 // ValidateFilePath checks that a file exists, is within size limits, and has a supported extension.
 func ValidateFilePath(path string, maxSize int64) error {
 	ext := strings.ToLower(filepath.Ext(path))
@@ -101,16 +121,56 @@ func LoadImageFromFile(path string) ([]byte, error) {
 	return data, nil
 }
 
-// DownloadImage fetches an image from a URL and returns its bytes.
+// NewDownloadClient returns an HTTP client for image downloads. A non-nil
+// proxy routes every download through it, regardless of the HTTP_PROXY and
+// HTTPS_PROXY environment variables; nil uses the environment as usual.
+func NewDownloadClient(proxy *url.URL) *http.Client {
+	if proxy == nil {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	return &http.Client{Transport: transport}
+}
+
+// HTTPStatusError is returned by DownloadImageContext when the server
+// answers with a status other than 200 OK.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("download image: HTTP %d", e.StatusCode)
+}
+
+// DownloadImage fetches an image from a URL and returns its bytes. It is
+// DownloadImageContext with a background context and http.DefaultClient.
 func DownloadImage(rawURL string, maxSize int64) ([]byte, error) {
-	resp, err := http.Get(rawURL)
+	return DownloadImageContext(context.Background(), nil, rawURL, maxSize)
+}
+
+// DownloadImageContext fetches an image from a URL with client and returns
+// its bytes. A nil client uses http.DefaultClient. The URL should already
+// have passed ValidateURL; the check applies to the target, not to any
+// proxy. Canceling ctx, or its deadline passing, aborts the download, also
+// while the body is being read. A non-200 response yields an
+// *HTTPStatusError.
+func DownloadImageContext(ctx context.Context, client *http.Client, rawURL string, maxSize int64) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download image: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("download image: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download image: HTTP %d", resp.StatusCode)
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	// Limit reader to prevent downloading excessively large files
@@ -127,13 +187,38 @@ func DownloadImage(rawURL string, maxSize int64) ([]byte, error) {
 	return data, nil
 }
 
-// EncodeBase64 encodes bytes to a base64 string.
+// maxPooledBufferSize caps the buffers kept in encodeBufPool so one huge
+// image does not pin its buffer in memory.
+const maxPooledBufferSize = 16 << 20
+
+// encodeBufPool holds scratch buffers for EncodeBase64.
+var encodeBufPool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// EncodeBase64 encodes bytes to a base64 string. The encoding scratch buffer
+// is pooled, so the returned string is the only allocation in steady state.
 func EncodeBase64(data []byte) string {
-	return base64.StdEncoding.EncodeToString(data)
+	n := base64.StdEncoding.EncodedLen(len(data))
+	if n > maxPooledBufferSize {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+
+	bp := encodeBufPool.Get().(*[]byte)
+	if cap(*bp) < n {
+		*bp = make([]byte, n)
+	}
+	buf := (*bp)[:n]
+	base64.StdEncoding.Encode(buf, data)
+	s := string(buf)
+	encodeBufPool.Put(bp)
+	return s
 }
 
 // GetImageInfo decodes image dimensions and color mode from raw bytes.
 // For PDFs it returns a placeholder since we handle them page-by-page.
+// Only the header is read, through a bytes.Reader, so data is not copied
+// and decoders that need to seek can.
 func GetImageInfo(data []byte, ext string) models.ImageInfo {
 	if strings.ToLower(ext) == ".pdf" {
 		return models.ImageInfo{
@@ -144,7 +229,7 @@ func GetImageInfo(data []byte, ext string) models.ImageInfo {
 		}
 	}
 
-	cfg, _, err := image.DecodeConfig(strings.NewReader(string(data)))
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
 		return models.ImageInfo{
 			Width:     0,
@@ -181,6 +266,195 @@ func GetImageInfo(data []byte, ext string) models.ImageInfo {
 	}
 }
 
+// NormalizeImageFormat makes sure the image sent to the model is PNG or JPEG.
+// Images in any other decodable format (GIF, WebP, TIFF, ...) are transcoded
+// to PNG; PNG and JPEG data is returned unchanged. Data that no registered
+// decoder recognizes is returned unchanged if ext claims PNG or JPEG, so the
+// model still gets a chance at it, and is an error otherwise.
+func NormalizeImageFormat(data []byte, ext string) ([]byte, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		switch strings.ToLower(ext) {
+		case ".png", ".jpg", ".jpeg":
+			return data, nil
+		}
+		return nil, fmt.Errorf("no decoder for %s image: %w", ext, err)
+	}
+	if modelFormats[format] {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s image: %w", format, err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode %s image as png: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJPEG re-encodes an image as JPEG at the given quality (1-100), which
+// is usually much smaller than PNG for photos. JPEG has no alpha channel, so
+// transparent areas are flattened onto white to keep dark text readable.
+func EncodeJPEG(data []byte, quality int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	b := img.Bounds()
+	flat := image.NewRGBA(b)
+	draw.Draw(flat, b, image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, b, img, b.Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Thumbnail returns a JPEG copy of the image scaled down so that neither side
+// exceeds maxSide pixels, keeping its aspect ratio. Each thumbnail pixel
+// averages a grid of samples from the area it covers. It returns nil if the
+// image already fits within maxSide.
+func Thumbnail(data []byte, maxSide int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= maxSide && sh <= maxSide {
+		return nil, nil
+	}
+	scale := float64(maxSide) / float64(max(sw, sh))
+	w, h := max(1, int(float64(sw)*scale)), max(1, int(float64(sh)*scale))
+
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0, y1 := b.Min.Y+y*sh/h, b.Min.Y+max((y+1)*sh/h, y*sh/h+1)
+		ystep := max(1, (y1-y0)/thumbnailSamples)
+		for x := range w {
+			x0, x1 := b.Min.X+x*sw/w, b.Min.X+max((x+1)*sw/w, x*sw/w+1)
+			xstep := max(1, (x1-x0)/thumbnailSamples)
+
+			var r, g, bl, n uint32
+			for sy := y0; sy < y1; sy += ystep {
+				for sx := x0; sx < x1; sx += xstep {
+					// Flatten transparency onto white, as EncodeJPEG does
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					white := 0xffff - ca
+					r += (cr + white) >> 8
+					g += (cg + white) >> 8
+					bl += (cb + white) >> 8
+					n++
+				}
+			}
+			thumb.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 0xff})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnailSamples is the number of samples per side averaged into one
+// thumbnail pixel, and thumbnailQuality the JPEG quality of thumbnails.
+const (
+	thumbnailSamples = 4
+	thumbnailQuality = 80
+)
+
+// formatSignatures holds the start of a file in each format, enough for
+// image.DecodeConfig to pick the registered decoder.
+var formatSignatures = map[string][]byte{
+	"png":  []byte("\x89PNG\r\n\x1a\n"),
+	"jpeg": []byte("\xff\xd8\xff"),
+	"gif":  []byte("GIF89a"),
+	"webp": []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+	"tiff": []byte("II*\x00"),
+}
+
+// DecoderRegistered reports whether an image decoder is registered for
+// format ("png", "jpeg", "gif", "webp" or "tiff"). Unknown formats report
+// false.
+func DecoderRegistered(format string) bool {
+	sig, ok := formatSignatures[format]
+	if !ok {
+		return false
+	}
+	_, _, err := image.DecodeConfig(bytes.NewReader(sig))
+	return !errors.Is(err, image.ErrFormat)
+}
+
+// VerifyImage fully decodes data to make sure its pixels are readable. Unlike
+// image.DecodeConfig, which only reads the header, this catches truncated or
+// corrupt image data.
+func VerifyImage(data []byte) error {
+	if _, format, err := image.Decode(bytes.NewReader(data)); err != nil {
+		if format != "" {
+			return fmt.Errorf("decode %s image: %w", format, err)
+		}
+		return fmt.Errorf("decode image: %w", err)
+	}
+	return nil
+}
+
+// DetectContentType returns the MIME type of the given image or PDF bytes.
+func DetectContentType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// BlankStdDevThreshold is the luminance standard deviation (0-255 scale)
+// below which an image is considered blank.
+const BlankStdDevThreshold = 4.0
+
+// blankSampleTarget caps how many pixels IsLikelyBlank inspects.
+const blankSampleTarget = 250_000
+
+// IsLikelyBlank reports whether the image has so little luminance variance
+// that it almost certainly contains no text. Large images are sampled on a
+// grid. Data that cannot be decoded is never considered blank.
+func IsLikelyBlank(data []byte) bool {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+
+	b := img.Bounds()
+	if b.Empty() {
+		return true
+	}
+
+	step := 1
+	for (b.Dx()/step)*(b.Dy()/step) > blankSampleTarget {
+		step++
+	}
+
+	var n, sum, sumSq float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			g := color.GrayModel.Convert(img.At(x, y)).(color.Gray)
+			v := float64(g.Y)
+			n++
+			sum += v
+			sumSq += v * v
+		}
+	}
+
+	mean := sum / n
+	variance := sumSq/n - mean*mean
+	return math.Sqrt(math.Max(variance, 0)) < BlankStdDevThreshold
+}
+
 // FileExtension returns the lowercase extension for a source path or URL.
 func FileExtension(source string) string {
 	if IsURL(source) {
@@ -1,11 +1,49 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/image/tiff"
 )
 
+// readWebP returns a 150x103 lossy WebP photo.
+func readWebP(t testing.TB) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "video-001.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// encodeTIFF returns img as an uncompressed TIFF.
+func encodeTIFF(t testing.TB, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tiff.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
 func TestValidateFilePath_Success(t *testing.T) {
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "test.png")
@@ -183,6 +221,21 @@ func TestEncodeBase64(t *testing.T) {
 	}
 }
 
+func TestEncodeBase64_PooledBufferReuse(t *testing.T) {
+	// Shrinking inputs reuse a larger pooled buffer; earlier results must
+	// not be affected by later encodes.
+	inputs := [][]byte{bytes.Repeat([]byte("a"), 300), []byte("hello"), {}, bytes.Repeat([]byte{0xff}, 40)}
+	var got []string
+	for _, in := range inputs {
+		got = append(got, EncodeBase64(in))
+	}
+	for i, in := range inputs {
+		if want := base64.StdEncoding.EncodeToString(in); got[i] != want {
+			t.Errorf("EncodeBase64(input %d) = %q, want %q", i, got[i], want)
+		}
+	}
+}
+
 func TestGetImageInfo_UnknownFormat(t *testing.T) {
 	info := GetImageInfo([]byte("not a real image"), ".png")
 	if info.ColorMode != "Unknown" {
@@ -190,6 +243,40 @@ func TestGetImageInfo_UnknownFormat(t *testing.T) {
 	}
 }
 
+// GetImageInfo must report what a plain, unseekable reader over the same
+// bytes decodes.
+func TestGetImageInfo_Formats(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 7, 5))
+	var jpg, gf bytes.Buffer
+	if err := jpeg.Encode(&jpg, rgba, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&gf, rgba, nil); err != nil {
+		t.Fatal(err)
+	}
+	images := map[string][]byte{
+		"png rgba": encodePNG(t, rgba),
+		"png gray": encodePNG(t, image.NewGray(image.Rect(0, 0, 3, 9))),
+		"jpeg":     jpg.Bytes(),
+		"gif":      gf.Bytes(),
+		"webp":     readWebP(t),
+		"tiff":     encodeTIFF(t, rgba),
+	}
+
+	for name, data := range images {
+		t.Run(name, func(t *testing.T) {
+			cfg, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := GetImageInfo(data, ".png")
+			if info.Width != cfg.Width || info.Height != cfg.Height {
+				t.Errorf("size = %dx%d, want %dx%d", info.Width, info.Height, cfg.Width, cfg.Height)
+			}
+		})
+	}
+}
+
 func TestGetImageInfo_PDF(t *testing.T) {
 	info := GetImageInfo([]byte("pdf data"), ".pdf")
 	if info.Width != 0 || info.Height != 0 {
@@ -199,3 +286,362 @@ func TestGetImageInfo_PDF(t *testing.T) {
 		t.Errorf("expected Unknown color mode for PDF, got %q", info.ColorMode)
 	}
 }
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n0000"), "image/png"},
+		{"jpeg", []byte("\xff\xd8\xff\xe0"), "image/jpeg"},
+		{"pdf", []byte("%PDF-1.4\n"), "application/pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectContentType(tt.data); got != tt.expected {
+				t.Errorf("DetectContentType = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsLikelyBlank_WhiteImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	if !IsLikelyBlank(encodePNG(t, img)) {
+		t.Error("expected white image to be detected as blank")
+	}
+}
+
+func TestIsLikelyBlank_ImageWithText(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	// Draw a few dark "text" strokes
+	for y := 20; y < 80; y += 15 {
+		for x := 20; x < 180; x++ {
+			img.SetGray(x, y, color.Gray{Y: 0})
+			img.SetGray(x, y+1, color.Gray{Y: 0})
+		}
+	}
+
+	if IsLikelyBlank(encodePNG(t, img)) {
+		t.Error("expected image with text strokes not to be blank")
+	}
+}
+
+func TestIsLikelyBlank_Undecodable(t *testing.T) {
+	if IsLikelyBlank([]byte("not an image")) {
+		t.Error("undecodable data should not be considered blank")
+	}
+}
+
+func encodePNG(t testing.TB, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalizeImageFormat_PNGAndJPEGUnchanged(t *testing.T) {
+	data := encodePNG(t, image.NewGray(image.Rect(0, 0, 2, 2)))
+
+	got, err := NormalizeImageFormat(data, ".png")
+	if err != nil {
+		t.Fatalf("NormalizeImageFormat: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("PNG data should be returned unchanged")
+	}
+}
+
+func TestNormalizeImageFormat_TranscodesToPNG(t *testing.T) {
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewGray(image.Rect(0, 0, 3, 3)), nil); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		ext  string
+		w, h int
+	}{
+		{"gif", gifData.Bytes(), ".gif", 3, 3},
+		{"webp", readWebP(t), ".webp", 150, 103},
+		{"tiff", encodeTIFF(t, image.NewGray(image.Rect(0, 0, 4, 2))), ".tiff", 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeImageFormat(tt.data, tt.ext)
+			if err != nil {
+				t.Fatalf("NormalizeImageFormat: %v", err)
+			}
+			if ct := DetectContentType(got); ct != "image/png" {
+				t.Fatalf("content type = %q, want image/png", ct)
+			}
+			cfg, err := png.DecodeConfig(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("decode result: %v", err)
+			}
+			if cfg.Width != tt.w || cfg.Height != tt.h {
+				t.Errorf("size = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestDecoderRegistered(t *testing.T) {
+	tests := map[string]bool{
+		"png":  true,
+		"jpeg": true,
+		"gif":  true,
+		"webp": true,
+		"tiff": true,
+		"bmp":  false,
+	}
+	for format, want := range tests {
+		if got := DecoderRegistered(format); got != want {
+			t.Errorf("DecoderRegistered(%q) = %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	for y := range 50 {
+		for x := range 100 {
+			if x < 50 {
+				img.SetNRGBA(x, y, color.NRGBA{A: 255}) // left half black
+			} // right half transparent, i.e. white
+		}
+	}
+
+	got, err := Thumbnail(encodePNG(t, img), 20)
+	if err != nil {
+		t.Fatalf("Thumbnail: %v", err)
+	}
+	thumb, format, err := image.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if format != "jpeg" || thumb.Bounds().Dx() != 20 || thumb.Bounds().Dy() != 10 {
+		t.Fatalf("thumbnail = %s %v, want a 20x10 jpeg", format, thumb.Bounds())
+	}
+	if l := color.GrayModel.Convert(thumb.At(2, 5)).(color.Gray).Y; l > 40 {
+		t.Errorf("left luminance = %d, want dark", l)
+	}
+	if l := color.GrayModel.Convert(thumb.At(17, 5)).(color.Gray).Y; l < 215 {
+		t.Errorf("right luminance = %d, want light", l)
+	}
+
+	// Images that already fit get no thumbnail
+	if got, err := Thumbnail(encodePNG(t, img), 100); got != nil || err != nil {
+		t.Errorf("Thumbnail of a fitting image = %d bytes, %v; want nil", len(got), err)
+	}
+	if _, err := Thumbnail([]byte("not an image"), 20); err == nil {
+		t.Error("expected error for undecodable data")
+	}
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	img.SetNRGBA(8, 8, color.NRGBA{A: 255}) // one black pixel on a transparent background
+
+	got, err := EncodeJPEG(encodePNG(t, img), 90)
+	if err != nil {
+		t.Fatalf("EncodeJPEG: %v", err)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("result is not a JPEG: %v", err)
+	}
+	if r, _, _, _ := decoded.At(0, 0).RGBA(); r>>8 < 240 {
+		t.Errorf("transparent pixel red = %d, want flattened onto white", r>>8)
+	}
+
+	if _, err := EncodeJPEG([]byte("%PDF-1.4"), 90); err == nil {
+		t.Error("expected error for undecodable data")
+	}
+}
+
+func TestNormalizeImageFormat_Undecodable(t *testing.T) {
+	if _, err := NormalizeImageFormat([]byte("II*\x00not really a tiff"), ".tiff"); err == nil {
+		t.Error("expected error for a format without a registered decoder")
+	}
+
+	// Data claiming to be PNG is passed through for the model to try
+	got, err := NormalizeImageFormat([]byte("garbage"), ".png")
+	if err != nil || string(got) != "garbage" {
+		t.Errorf("NormalizeImageFormat(.png garbage) = %q, %v; want data unchanged", got, err)
+	}
+}
+
+// newStubProxy starts an HTTP proxy that answers every request with body and
+// records the absolute URLs it was asked for.
+func newStubProxy(t *testing.T, body []byte) (*url.URL, *[]string) {
+	t.Helper()
+
+	var seen []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.URL.String())
+		w.Write(body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf("parse proxy URL: %v", err)
+	}
+	return u, &seen
+}
+
+func TestVerifyImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	data := buf.Bytes()
+
+	if err := VerifyImage(data); err != nil {
+		t.Fatalf("VerifyImage(valid jpeg): %v", err)
+	}
+
+	truncated := data[:len(data)/2]
+	if _, _, err := image.DecodeConfig(bytes.NewReader(truncated)); err != nil {
+		t.Fatalf("header of truncated jpeg should still decode: %v", err)
+	}
+	if err := VerifyImage(truncated); err == nil {
+		t.Error("VerifyImage should fail for a truncated jpeg")
+	}
+}
+
+func TestDownloadImage_ViaProxy(t *testing.T) {
+	proxyURL, seen := newStubProxy(t, []byte("image bytes"))
+	t.Setenv("HTTP_PROXY", "http://env-proxy.invalid:1")
+
+	client := NewDownloadClient(proxyURL)
+	data, err := DownloadImageContext(context.Background(), client, "http://images.example.com/receipt.png", 1024)
+	if err != nil {
+		t.Fatalf("DownloadImage: %v", err)
+	}
+	if string(data) != "image bytes" {
+		t.Errorf("data = %q, want %q", data, "image bytes")
+	}
+	if len(*seen) != 1 || (*seen)[0] != "http://images.example.com/receipt.png" {
+		t.Errorf("proxy saw %v, want the target URL", *seen)
+	}
+}
+
+func TestDownloadImage_TooLarge(t *testing.T) {
+	proxyURL, _ := newStubProxy(t, make([]byte, 2048))
+
+	_, err := DownloadImageContext(context.Background(), NewDownloadClient(proxyURL), "http://images.example.com/big.png", 1024)
+	if err == nil {
+		t.Fatal("expected error for a download over the size limit")
+	}
+}
+
+func TestDownloadImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image bytes"))
+	}))
+	defer server.Close()
+
+	data, err := DownloadImage(server.URL+"/receipt.png", 1024)
+	if err != nil {
+		t.Fatalf("DownloadImage: %v", err)
+	}
+	if string(data) != "image bytes" {
+		t.Errorf("data = %q, want %q", data, "image bytes")
+	}
+	if _, err := DownloadImage(server.URL+"/receipt.png", 4); err == nil {
+		t.Error("expected error for a download over the size limit")
+	}
+}
+
+func TestDownloadImage_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := DownloadImageContext(context.Background(), nil, server.URL+"/expired.png", 1024)
+	var status *HTTPStatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusForbidden {
+		t.Fatalf("err = %v, want an HTTPStatusError with 403", err)
+	}
+	if err.Error() != "download image: HTTP 403" {
+		t.Errorf("err = %q, want %q", err, "download image: HTTP 403")
+	}
+}
+
+func TestDownloadImage_CanceledDuringBody(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2048")
+		w.Write(make([]byte, 512))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := DownloadImageContext(ctx, nil, server.URL+"/slow.png", 4096)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("download took %v, want it aborted at the deadline", elapsed)
+	}
+}
+
+func TestNewDownloadClient_NoProxy(t *testing.T) {
+	if NewDownloadClient(nil) != http.DefaultClient {
+		t.Error("nil proxy should use the default client")
+	}
+}
+
+// The string reader GetImageInfo used to decode through copied the whole
+// image; the header is all it needs.
+func BenchmarkGetImageInfo(b *testing.B) {
+	// Noise does not compress, so the PNG is about 4 MB
+	img := image.NewGray(image.Rect(0, 0, 2048, 2048))
+	rand.NewChaCha8([32]byte{}).Read(img.Pix)
+	data := encodePNG(b, img)
+
+	b.Run("bytes.Reader", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			GetImageInfo(data, ".png")
+		}
+	})
+	b.Run("string copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			image.DecodeConfig(strings.NewReader(string(data)))
+		}
+	})
+}
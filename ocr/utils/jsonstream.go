@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONField is a top-level field of a JSON object with its raw value.
+type JSONField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// jsonScanState is where a JSONFieldScanner is within the object.
+type jsonScanState int
+
+const (
+	scanBeforeObject jsonScanState = iota
+	scanBeforeKey
+	scanKey
+	scanBeforeColon
+	scanBeforeValue
+	scanValue
+	scanAfterValue
+	scanDone
+)
+
+// JSONFieldScanner reads a JSON object that arrives in pieces, such as a
+// streamed model answer, and reports each top-level field as soon as its
+// value is complete. It is tolerant: text before the opening brace (e.g. a
+// markdown code fence) and after the closing brace is skipped, and values
+// are not validated, so a malformed value is reported as is. The zero value
+// is ready to use.
+type JSONFieldScanner struct {
+	buf   []byte
+	pos   int
+	state jsonScanState
+
+	start   int    // offset of the current key or value in buf
+	key     string // key of the current value
+	depth   int    // nesting of objects and arrays within the current value
+	inStr   bool   // inside a string within the current value
+	escaped bool   // the previous byte was a backslash within a string
+}
+
+// Feed appends chunk and returns the fields completed by it, in order.
+func (s *JSONFieldScanner) Feed(chunk string) []JSONField {
+	s.buf = append(s.buf, chunk...)
+
+	var fields []JSONField
+	emit := func(end int) {
+		value := bytes.TrimSpace(s.buf[s.start:end])
+		if len(value) > 0 {
+			fields = append(fields, JSONField{Key: s.key, Value: append(json.RawMessage(nil), value...)})
+		}
+	}
+
+	for ; s.pos < len(s.buf) && s.state != scanDone; s.pos++ {
+		c := s.buf[s.pos]
+		switch s.state {
+		case scanBeforeObject:
+			if c == '{' {
+				s.state = scanBeforeKey
+			}
+
+		case scanBeforeKey:
+			switch c {
+			case '"':
+				s.start = s.pos
+				s.escaped = false
+				s.state = scanKey
+			case '}':
+				s.state = scanDone
+			}
+
+		case scanKey:
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				if json.Unmarshal(s.buf[s.start:s.pos+1], &s.key) != nil {
+					s.key = ""
+				}
+				s.state = scanBeforeColon
+			}
+
+		case scanBeforeColon:
+			if c == ':' {
+				s.state = scanBeforeValue
+			}
+
+		case scanBeforeValue:
+			if isJSONSpace(c) {
+				continue
+			}
+			s.start = s.pos
+			s.depth = 0
+			s.inStr = false
+			s.escaped = false
+			s.state = scanValue
+			s.pos-- // scan c as part of the value
+
+		case scanValue:
+			if s.inStr {
+				switch {
+				case s.escaped:
+					s.escaped = false
+				case c == '\\':
+					s.escaped = true
+				case c == '"':
+					s.inStr = false
+					if s.depth == 0 {
+						emit(s.pos + 1)
+						s.state = scanAfterValue
+					}
+				}
+				continue
+			}
+			switch c {
+			case '"':
+				s.inStr = true
+			case '{', '[':
+				s.depth++
+			case '}', ']':
+				if s.depth == 0 {
+					// A number, literal or malformed value closing the object
+					emit(s.pos)
+					s.state = scanDone
+					break
+				}
+				s.depth--
+				if s.depth == 0 {
+					emit(s.pos + 1)
+					s.state = scanAfterValue
+				}
+			case ',':
+				if s.depth == 0 {
+					emit(s.pos)
+					s.state = scanBeforeKey
+				}
+			}
+
+		case scanAfterValue:
+			switch c {
+			case ',':
+				s.state = scanBeforeKey
+			case '}':
+				s.state = scanDone
+			}
+		}
+	}
+	return fields
+}
+
+// Done reports whether the object has been closed.
+func (s *JSONFieldScanner) Done() bool {
+	return s.state == scanDone
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONFieldScanner_Chunked(t *testing.T) {
+	const doc = "```json\n" + `{
+  "metadata": {"document_type": "receipt", "note": "a } in \"quotes\""},
+  "count": 3,
+  "text": {"raw": "a, b\n[c]", "lines": [{"text": "a"}, {"text": "b"}]},
+  "flag" : true,
+  "summary": "done"
+}` + "\n```"
+
+	want := []JSONField{
+		{Key: "metadata", Value: []byte(`{"document_type": "receipt", "note": "a } in \"quotes\""}`)},
+		{Key: "count", Value: []byte(`3`)},
+		{Key: "text", Value: []byte(`{"raw": "a, b\n[c]", "lines": [{"text": "a"}, {"text": "b"}]}`)},
+		{Key: "flag", Value: []byte(`true`)},
+		{Key: "summary", Value: []byte(`"done"`)},
+	}
+
+	for _, size := range []int{1, 2, 7, 64, len(doc)} {
+		var s JSONFieldScanner
+		var got []JSONField
+		for i := 0; i < len(doc); i += size {
+			got = append(got, s.Feed(doc[i:min(i+size, len(doc))])...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("chunk size %d: fields = %q, want %q", size, got, want)
+		}
+		if !s.Done() {
+			t.Errorf("chunk size %d: Done = false after the closing brace", size)
+		}
+	}
+}
+
+func TestJSONFieldScanner_FieldsAsTheyClose(t *testing.T) {
+	var s JSONFieldScanner
+	steps := []struct {
+		chunk string
+		keys  []string
+	}{
+		{`{"metadata": {"language": "en"`, nil},
+		{`}, "text": {"raw": "ACME`, []string{"metadata"}},
+		{` Store"}`, []string{"text"}},
+		{`, "summary": nu`, nil},
+		{`ll}`, []string{"summary"}},
+		{` trailing text {"ignored": 1}`, nil},
+	}
+	for i, step := range steps {
+		var keys []string
+		for _, f := range s.Feed(step.chunk) {
+			keys = append(keys, f.Key)
+		}
+		if !reflect.DeepEqual(keys, step.keys) {
+			t.Errorf("step %d: keys = %q, want %q", i, keys, step.keys)
+		}
+	}
+}
+
+func TestJSONFieldScanner_Incomplete(t *testing.T) {
+	var s JSONFieldScanner
+	fields := s.Feed(`{"metadata": {}, "text": {"raw": "cut off`)
+	if len(fields) != 1 || fields[0].Key != "metadata" {
+		t.Errorf("fields = %q, want only metadata", fields)
+	}
+	if s.Done() {
+		t.Error("Done = true for an unterminated object")
+	}
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// maxMergeGapRatio is the largest horizontal gap between two fragments,
+// relative to line height, for them to be treated as one visual line.
+const maxMergeGapRatio = 2.0
+
+// MergeAdjacentLines merges consecutive lines that a model split into
+// fragments of one visual line. Two lines are merged when their boxes overlap
+// vertically by at least half the smaller height and the second starts just
+// after the first ends. Merged text is joined with a space, the box becomes
+// the union of both boxes and confidence is averaged weighted by text length.
+// Lines without bounding boxes or from different crop regions are never
+// merged.
+func MergeAdjacentLines(lines []models.TextLine) []models.TextLine {
+	if len(lines) < 2 {
+		return lines
+	}
+
+	merged := make([]models.TextLine, 0, len(lines))
+	for _, line := range lines {
+		if n := len(merged); n > 0 && merged[n-1].PageNumber == line.PageNumber &&
+			sameRegion(merged[n-1].Region, line.Region) &&
+			sameVisualLine(merged[n-1].BoundingBox, line.BoundingBox) {
+			merged[n-1] = mergeLines(merged[n-1], line)
+			continue
+		}
+		merged = append(merged, line)
+	}
+	return merged
+}
+
+// sameRegion reports whether two lines come from the same crop region.
+func sameRegion(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameVisualLine reports whether b directly continues a on the same line.
+func sameVisualLine(a, b *models.BoundingBox) bool {
+	if a == nil || b == nil || a.Height <= 0 || b.Height <= 0 {
+		return false
+	}
+
+	overlap := math.Min(a.Y+a.Height, b.Y+b.Height) - math.Max(a.Y, b.Y)
+	if overlap < math.Min(a.Height, b.Height)/2 {
+		return false
+	}
+
+	height := math.Max(a.Height, b.Height)
+	gap := b.X - (a.X + a.Width)
+	return gap >= -height/2 && gap <= height*maxMergeGapRatio
+}
+
+func mergeLines(a, b models.TextLine) models.TextLine {
+	wa := float64(utf8.RuneCountInString(a.Text))
+	wb := float64(utf8.RuneCountInString(b.Text))
+	conf := (a.Confidence + b.Confidence) / 2
+	if wa+wb > 0 {
+		conf = (a.Confidence*wa + b.Confidence*wb) / (wa + wb)
+	}
+
+	x := math.Min(a.BoundingBox.X, b.BoundingBox.X)
+	y := math.Min(a.BoundingBox.Y, b.BoundingBox.Y)
+	right := math.Max(a.BoundingBox.X+a.BoundingBox.Width, b.BoundingBox.X+b.BoundingBox.Width)
+	bottom := math.Max(a.BoundingBox.Y+a.BoundingBox.Height, b.BoundingBox.Y+b.BoundingBox.Height)
+
+	return models.TextLine{
+		Text:        a.Text + " " + b.Text,
+		BoundingBox: &models.BoundingBox{X: x, Y: y, Width: right - x, Height: bottom - y},
+		Confidence:  conf,
+		Region:      a.Region,
+		LineNumber:  a.LineNumber,
+		PageNumber:  a.PageNumber,
+	}
+}
+
+// ReflowLines splits every line longer than maxLen runes into several lines
+// with ReflowText. The pieces keep the bounding box, confidence, region, line
+// number and page of the line they came from. Lines within maxLen are
+// returned unchanged.
+func ReflowLines(lines []models.TextLine, maxLen int) []models.TextLine {
+	out := make([]models.TextLine, 0, len(lines))
+	for _, line := range lines {
+		if utf8.RuneCountInString(line.Text) <= maxLen {
+			out = append(out, line)
+			continue
+		}
+		for _, piece := range ReflowText(line.Text, maxLen) {
+			l := line
+			l.Text = piece
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// ReflowText splits s into pieces of at most maxLen runes, such as a whole
+// document a model returned as one line. A piece ends at the last sentence
+// end (".", "!" or "?" followed by whitespace) in its second half, or else
+// at the last whitespace, so words are never split; a single word longer
+// than maxLen becomes a piece of its own. Whitespace between pieces is
+// dropped. maxLen below 1 returns s unchanged.
+func ReflowText(s string, maxLen int) []string {
+	if maxLen < 1 {
+		return []string{s}
+	}
+	var pieces []string
+	rest := []rune(strings.TrimSpace(s))
+	for len(rest) > maxLen {
+		end := reflowBreak(rest, maxLen)
+		pieces = append(pieces, strings.TrimSpace(string(rest[:end])))
+		rest = []rune(strings.TrimLeftFunc(string(rest[end:]), unicode.IsSpace))
+	}
+	if len(rest) > 0 || len(pieces) == 0 {
+		pieces = append(pieces, string(rest))
+	}
+	return pieces
+}
+
+// reflowBreak returns where to end the first piece of rs, which is longer
+// than maxLen: at rs[end], a whitespace rune.
+func reflowBreak(rs []rune, maxLen int) int {
+	space, sentence := -1, -1
+	for i := 1; i <= maxLen; i++ {
+		if !unicode.IsSpace(rs[i]) || unicode.IsSpace(rs[i-1]) {
+			continue
+		}
+		space = i
+		if strings.ContainsRune(".!?", rs[i-1]) {
+			sentence = i
+		}
+	}
+	switch {
+	case sentence >= maxLen/2:
+		return sentence
+	case space > 0:
+		return space
+	}
+	// No whitespace within maxLen: keep the long word whole
+	for i := maxLen + 1; i < len(rs); i++ {
+		if unicode.IsSpace(rs[i]) {
+			return i
+		}
+	}
+	return len(rs)
+}
+
+// DejoinHyphens rejoins words that justified text split across a line break
+// with a hyphen, as in "inter-\nnational". A line ending in a letter and a
+// hyphen is joined with the next line when that starts with a lowercase
+// letter; the completed word moves up to the first line and the rest of the
+// next line stays on its own line. A soft hyphen (U+00AD) at a line end is
+// always a break, so it is joined before any letter. Hyphens within a line,
+// dashes after a space and breaks before a capital, as in "Coca-\nCola", are
+// kept.
+func DejoinHyphens(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i := 0; i < len(lines)-1; {
+		body, eol := splitLineEnding(lines[i])
+		hyphen, size := utf8.DecodeLastRuneInString(body)
+		if hyphen != '-' && hyphen != '\u00ad' {
+			i++
+			continue
+		}
+		prefix := body[:len(body)-size]
+		if last, _ := utf8.DecodeLastRuneInString(prefix); !unicode.IsLetter(last) {
+			i++
+			continue
+		}
+
+		next, nextEOL := splitLineEnding(lines[i+1])
+		next = strings.TrimLeft(next, " \t")
+		first, _ := utf8.DecodeRuneInString(next)
+		if !unicode.IsLower(first) && (hyphen == '-' || !unicode.IsLetter(first)) {
+			i++
+			continue
+		}
+
+		end := strings.IndexFunc(next, unicode.IsSpace)
+		if end < 0 {
+			end = len(next)
+		}
+		word, rest := next[:end], strings.TrimLeft(next[end:], " \t")
+		if rest == "" {
+			// The whole next line completed the word; check the joined line
+			// again in case it ends in another break
+			lines[i] = prefix + word + nextEOL
+			lines = append(lines[:i+1], lines[i+2:]...)
+			continue
+		}
+		lines[i] = prefix + word + eol
+		lines[i+1] = rest + nextEOL
+		i++
+	}
+	return strings.Join(lines, "")
+}
+
+// splitLineEnding splits a line from strings.SplitAfter into its text and
+// its "\n" or "\r\n" ending, which is empty for the last line.
+func splitLineEnding(line string) (string, string) {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return line[:len(line)-2], "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func box(x, y, w, h float64) *models.BoundingBox {
+	return &models.BoundingBox{X: x, Y: y, Width: w, Height: h}
+}
+
+func TestMergeAdjacentLines(t *testing.T) {
+	// "ACME Store" split into three fragments, followed by a line below it
+	// and a fragment far to the right on that lower line.
+	lines := []models.TextLine{
+		{Text: "AC", BoundingBox: box(10, 10, 20, 20), Confidence: 0.9},
+		{Text: "ME", BoundingBox: box(32, 11, 20, 20), Confidence: 0.7},
+		{Text: "Store", BoundingBox: box(60, 10, 50, 20), Confidence: 0.8},
+		{Text: "Total", BoundingBox: box(10, 40, 50, 20), Confidence: 0.9},
+		{Text: "$5", BoundingBox: box(400, 40, 20, 20), Confidence: 0.9},
+	}
+
+	got := MergeAdjacentLines(lines)
+
+	if len(got) != 3 {
+		t.Fatalf("len = %d, want 3: %+v", len(got), got)
+	}
+	if got[0].Text != "AC ME Store" {
+		t.Errorf("Text = %q, want %q", got[0].Text, "AC ME Store")
+	}
+	wantBox := models.BoundingBox{X: 10, Y: 10, Width: 100, Height: 21}
+	if *got[0].BoundingBox != wantBox {
+		t.Errorf("BoundingBox = %+v, want %+v", *got[0].BoundingBox, wantBox)
+	}
+	wantConf := (0.9*2 + 0.7*2 + 0.8*5) / 9
+	if math.Abs(got[0].Confidence-wantConf) > 1e-9 {
+		t.Errorf("Confidence = %v, want %v", got[0].Confidence, wantConf)
+	}
+	if got[1].Text != "Total" || got[2].Text != "$5" {
+		t.Errorf("lines on separate rows or far apart should not merge: %q, %q", got[1].Text, got[2].Text)
+	}
+
+	// The input must not be modified
+	if lines[0].Text != "AC" || lines[0].BoundingBox.Width != 20 {
+		t.Error("MergeAdjacentLines modified its input")
+	}
+}
+
+func TestMergeAdjacentLines_Normalized(t *testing.T) {
+	lines := []models.TextLine{
+		{Text: "Invoice", BoundingBox: box(0.10, 0.05, 0.15, 0.02)},
+		{Text: "#42", BoundingBox: box(0.26, 0.05, 0.05, 0.02)},
+	}
+
+	got := MergeAdjacentLines(lines)
+	if len(got) != 1 || got[0].Text != "Invoice #42" {
+		t.Errorf("got %+v, want one merged line", got)
+	}
+}
+
+func TestMergeAdjacentLines_WithoutBoxes(t *testing.T) {
+	lines := []models.TextLine{{Text: "a"}, {Text: "b"}}
+
+	got := MergeAdjacentLines(lines)
+	if len(got) != 2 {
+		t.Errorf("len = %d, want 2 (lines without boxes are kept)", len(got))
+	}
+}
+
+func TestMergeAdjacentLines_DifferentRegions(t *testing.T) {
+	r0, r1 := 0, 1
+	lines := []models.TextLine{
+		{Text: "Name", BoundingBox: box(10, 10, 40, 20), Region: &r0},
+		{Text: "Alice", BoundingBox: box(55, 10, 40, 20), Region: &r1},
+	}
+
+	if got := MergeAdjacentLines(lines); len(got) != 2 {
+		t.Errorf("len = %d, want 2 (lines from different regions are kept)", len(got))
+	}
+}
+
+func TestReflowText(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		maxLen int
+		want   []string
+	}{
+		{"short", "ACME Store", 20, []string{"ACME Store"}},
+		{"sentence end", "Thank you. Please come again soon.", 20, []string{"Thank you.", "Please come again", "soon."}},
+		{"word boundary", "the quick brown fox jumps over", 12, []string{"the quick", "brown fox", "jumps over"}},
+		{"early sentence end ignored", "Hi. the quick brown fox", 16, []string{"Hi. the quick", "brown fox"}},
+		{"long word kept whole", "see https://example.com/a/very/long/path now", 10, []string{"see", "https://example.com/a/very/long/path", "now"}},
+		{"runs of spaces", "one   two\tthree  four", 9, []string{"one   two", "three", "four"}},
+		{"multibyte", "größe größe größe", 11, []string{"größe größe", "größe"}},
+		{"no limit", "a b c", 0, []string{"a b c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReflowText(tt.s, tt.maxLen)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReflowText(%q, %d) = %q, want %q", tt.s, tt.maxLen, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReflowText_NeverSplitsWords(t *testing.T) {
+	const s = "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Sed do eiusmod tempor incididunt ut labore et dolore magna aliqua."
+	words := strings.Fields(s)
+	for maxLen := 1; maxLen <= len(s); maxLen++ {
+		var got []string
+		for _, piece := range ReflowText(s, maxLen) {
+			if n := utf8.RuneCountInString(piece); n > maxLen && strings.Contains(piece, " ") {
+				t.Fatalf("maxLen %d: piece %q is too long", maxLen, piece)
+			}
+			got = append(got, strings.Fields(piece)...)
+		}
+		if !reflect.DeepEqual(got, words) {
+			t.Fatalf("maxLen %d: words = %q, want %q", maxLen, got, words)
+		}
+	}
+}
+
+func TestReflowLines(t *testing.T) {
+	region := 1
+	lines := []models.TextLine{
+		{Text: "Invoice 42", BoundingBox: box(0, 0, 100, 10), Confidence: 0.9, LineNumber: 1},
+		{Text: "Pay within 30 days. Thank you!", BoundingBox: box(0, 20, 300, 10), Confidence: 0.8, Region: &region, LineNumber: 2, PageNumber: 3},
+	}
+
+	got := ReflowLines(lines, 20)
+
+	want := []models.TextLine{
+		lines[0],
+		{Text: "Pay within 30 days.", BoundingBox: lines[1].BoundingBox, Confidence: 0.8, Region: &region, LineNumber: 2, PageNumber: 3},
+		{Text: "Thank you!", BoundingBox: lines[1].BoundingBox, Confidence: 0.8, Region: &region, LineNumber: 2, PageNumber: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReflowLines = %+v, want %+v", got, want)
+	}
+	if lines[1].Text != "Pay within 30 days. Thank you!" {
+		t.Error("input lines were modified")
+	}
+}
+
+func TestDejoinHyphens(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"split word", "inter-\nnational trade", "international\ntrade"},
+		{"whole next line", "the inter-\nnational\nmarket", "the international\nmarket"},
+		{"chained breaks", "super-\ncali-\nfragilistic", "supercalifragilistic"},
+		{"crlf", "inter-\r\nnational trade\r\nends", "international\r\ntrade\r\nends"},
+		{"indented continuation", "inter-\n   national trade", "international\ntrade"},
+		{"umlaut", "Grö-\nße Männer", "Größe\nMänner"},
+		{"soft hyphen before capital", "Coca\u00ad\nCola drink", "CocaCola\ndrink"},
+		{"compound within line", "a well-known co-op\nstore", "a well-known co-op\nstore"},
+		{"capital after break", "Coca-\nCola drink", "Coca-\nCola drink"},
+		{"dash after space", "prices -\nsee below", "prices -\nsee below"},
+		{"number before hyphen", "2024-\nmarch", "2024-\nmarch"},
+		{"digit after break", "page-\n2 of 3", "page-\n2 of 3"},
+		{"hyphen at end of text", "inter-", "inter-"},
+		{"list item", "items:\n- milk\n- eggs", "items:\n- milk\n- eggs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DejoinHyphens(tt.in); got != tt.want {
+				t.Errorf("DejoinHyphens(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,6 +11,10 @@ import (
 	"strings"
 )
 
+// ErrPDFEncrypted is returned by PDFToImages for an encrypted PDF that could
+// not be rendered, e.g. because it needs a password.
+var ErrPDFEncrypted = errors.New("PDF is encrypted/password-protected")
+
 // PDFToImages converts a PDF to a slice of PNG image byte slices, one per page.
 // This implementation uses a system call to a tool that can render PDFs.
 // For production use, consider using a Go-native PDF rendering library.
@@ -17,24 +23,76 @@ import (
 // 1. Use 'pdftoppm' (poppler-utils) if available
 // 2. Use 'sips' (macOS built-in) for single-page conversion
 // 3. Return the raw PDF bytes as a single "page" for Ollama to process directly
+//
+// Encrypted PDFs are never sent raw, since the model cannot read them; if
+// pdftoppm cannot render one, ErrPDFEncrypted is returned.
 func PDFToImages(pdfPath string) ([][]byte, error) {
+	return PDFToImagesWithPassword(pdfPath, "")
+}
+
+// PDFToImagesWithPassword is like PDFToImages but opens encrypted PDFs with
+// password when rendering them with pdftoppm.
+func PDFToImagesWithPassword(pdfPath, password string) ([][]byte, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("read pdf: %w", err)
+	}
+	encrypted := IsPDFEncrypted(data)
+
 	// Try pdftoppm first (most reliable for multi-page PDFs)
-	if pages, err := pdfToPPM(pdfPath); err == nil && len(pages) > 0 {
+	pages, err := pdfToPPM(pdfPath, password)
+	if err == nil && len(pages) > 0 {
 		return pages, nil
 	}
+	if encrypted {
+		if err == nil {
+			err = errors.New("pdftoppm produced no pages")
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPDFEncrypted, err)
+	}
 
 	// Fallback: return the raw PDF data as a single entry.
 	// Many vision models can process PDF data directly when sent as base64.
-	data, err := os.ReadFile(pdfPath)
-	if err != nil {
-		return nil, fmt.Errorf("pdf fallback read: %w", err)
+	return [][]byte{data}, nil
+}
+
+// PDFRendererAvailable reports whether pdftoppm is on PATH. Without it,
+// PDFs are sent to the model unrendered.
+func PDFRendererAvailable() bool {
+	_, err := exec.LookPath("pdftoppm")
+	return err == nil
+}
+
+// IsPDFEncrypted reports whether data looks like an encrypted PDF, i.e. its
+// trailer or cross-reference stream refers to an /Encrypt dictionary. The
+// check is a byte scan, not a full parse.
+func IsPDFEncrypted(data []byte) bool {
+	const name = "/Encrypt"
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte(name))
+		if j < 0 {
+			return false
+		}
+		end := i + j + len(name)
+		// Skip longer names such as /EncryptMetadata
+		if end == len(data) || isPDFDelimiter(data[end]) {
+			return true
+		}
+		i = end
 	}
+}
 
-	return [][]byte{data}, nil
+// isPDFDelimiter reports whether c ends a PDF name token.
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', 0, '/', '<', '>', '[', ']', '(', ')', '{', '}', '%':
+		return true
+	}
+	return false
 }
 
 // pdfToPPM uses pdftoppm from poppler-utils to convert PDF pages to PNG images.
-func pdfToPPM(pdfPath string) ([][]byte, error) {
+func pdfToPPM(pdfPath, password string) ([][]byte, error) {
 	pdftoppm, err := exec.LookPath("pdftoppm")
 	if err != nil {
 		return nil, fmt.Errorf("pdftoppm not found: %w", err)
@@ -48,7 +106,11 @@ func pdfToPPM(pdfPath string) ([][]byte, error) {
 
 	outputPrefix := filepath.Join(tmpDir, "page")
 
-	cmd := exec.Command(pdftoppm, "-png", "-r", "300", pdfPath, outputPrefix)
+	args := []string{"-png", "-r", "300"}
+	if password != "" {
+		args = append(args, "-upw", password)
+	}
+	cmd := exec.Command(pdftoppm, append(args, pdfPath, outputPrefix)...)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return nil, fmt.Errorf("pdftoppm failed: %s: %w", string(output), err)
 	}
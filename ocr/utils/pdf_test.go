@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsPDFEncrypted(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"trailer", "%PDF-1.4\ntrailer\n<< /Size 5 /Root 1 0 R /Encrypt 4 0 R >>\n%%EOF", true},
+		{"xref stream", "%PDF-1.5\n9 0 obj\n<</Type/XRef/Encrypt 8 0 R/Size 10>>stream", true},
+		{"inline dictionary", "trailer << /Encrypt<< /Filter /Standard >> >>", true},
+		{"plain", "%PDF-1.4\ntrailer\n<< /Size 5 /Root 1 0 R >>\n%%EOF", false},
+		{"longer name only", "<< /EncryptMetadata false >>", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPDFEncrypted([]byte(tt.data)); got != tt.want {
+				t.Errorf("IsPDFEncrypted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFToImages_EncryptedNotSentRaw(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locked.pdf")
+	data := "%PDF-1.4\ntrailer\n<< /Size 1 /Encrypt 4 0 R >>\n%%EOF\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := PDFToImages(path)
+	if !errors.Is(err, ErrPDFEncrypted) {
+		t.Fatalf("err = %v, want ErrPDFEncrypted", err)
+	}
+	_, err = PDFToImagesWithPassword(path, "wrong")
+	if !errors.Is(err, ErrPDFEncrypted) {
+		t.Fatalf("with password: err = %v, want ErrPDFEncrypted", err)
+	}
+}
+
+func TestPDFToImages_RawFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	data := []byte("%PDF-1.4\ntrailer\n<< /Size 1 >>\n%%EOF\n")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := PDFToImages(path)
+	if err != nil {
+		t.Fatalf("PDFToImages: %v", err)
+	}
+	if len(pages) != 1 || string(pages[0]) != string(data) {
+		t.Errorf("pages = %d, want the raw PDF as a single page", len(pages))
+	}
+}
+
+func TestPDFRendererAvailable_MissingFromPath(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if PDFRendererAvailable() {
+		t.Error("pdftoppm should not be found on an empty PATH")
+	}
+}
@@ -0,0 +1,595 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The parser reads untrusted input, so these limits bound the work and
+// memory a malformed or hostile file can cost.
+const (
+	// maxObjectStreamSize caps the decompressed size of one PDF object
+	// stream and maxObjectStreamTotal of all of them together.
+	maxObjectStreamSize  = 64 << 20
+	maxObjectStreamTotal = 256 << 20
+
+	// maxPDFObjects caps the number of objects read from one file.
+	maxPDFObjects = 1 << 20
+
+	// maxNestingDepth bounds how deeply arrays and dictionaries may nest.
+	maxNestingDepth = 64
+
+	// maxFieldDepth bounds the depth of the form field tree.
+	maxFieldDepth = 32
+)
+
+// PDFFormFields returns the values of the filled-in AcroForm fields of the
+// PDF in data, keyed by fully qualified field name ("parent.child"). Fields
+// without a value are left out; checkboxes and radio buttons report their
+// export value (e.g. "Yes" or "Off") and multi-select lists their choices
+// joined with ", ". A PDF without a form yields no fields and no error.
+//
+// The parser is minimal: it reads plain and Flate-compressed object streams
+// but ignores the cross-reference table, so objects are taken in file order
+// with later definitions winning. Encrypted PDFs return ErrPDFEncrypted since
+// their field values are encrypted too.
+func PDFFormFields(data []byte) (map[string]string, error) {
+	if !bytes.Contains(data, []byte("/AcroForm")) {
+		return nil, nil
+	}
+	if IsPDFEncrypted(data) {
+		return nil, ErrPDFEncrypted
+	}
+
+	doc, err := parsePDFObjects(data)
+	if err != nil {
+		return nil, err
+	}
+
+	form, ok := doc.resolve(doc.catalog["AcroForm"]).(pdfDict)
+	if !ok {
+		return nil, nil
+	}
+	fields, _ := doc.resolve(form["Fields"]).(pdfArray)
+
+	values := make(map[string]string)
+	visited := make(map[pdfRef]bool)
+	for _, f := range fields {
+		doc.collectField(values, visited, f, "", 0)
+	}
+	return values, nil
+}
+
+// collectField adds the value of field, and of any child fields, to values.
+// visited holds the fields already collected, so a field reachable twice,
+// e.g. through a reference cycle, is only read once.
+func (d *pdfDocument) collectField(values map[string]string, visited map[pdfRef]bool, ref pdfValue, parent string, depth int) {
+	if r, ok := ref.(pdfRef); ok {
+		if visited[r] {
+			return
+		}
+		visited[r] = true
+	}
+	field, ok := d.resolve(ref).(pdfDict)
+	if !ok || depth > maxFieldDepth {
+		return
+	}
+
+	name := parent
+	if t, ok := d.resolve(field["T"]).(pdfString); ok {
+		if name != "" {
+			name += "."
+		}
+		name += t.text()
+	}
+
+	// Kids without a name of their own are the field's widgets, not fields
+	kids, _ := d.resolve(field["Kids"]).(pdfArray)
+	hasChildFields := false
+	for _, kid := range kids {
+		if k, ok := d.resolve(kid).(pdfDict); ok && k["T"] != nil {
+			hasChildFields = true
+			d.collectField(values, visited, kid, name, depth+1)
+		}
+	}
+	if hasChildFields || name == "" {
+		return
+	}
+
+	if v := fieldValue(d.resolve(field["V"]), d); v != "" {
+		values[name] = v
+	}
+}
+
+// fieldValue returns the text of a field's /V entry. The choices of an
+// array are read one level deep.
+func fieldValue(v pdfValue, d *pdfDocument) string {
+	arr, ok := v.(pdfArray)
+	if !ok {
+		return scalarFieldValue(v)
+	}
+	var parts []string
+	for _, item := range arr {
+		if s := scalarFieldValue(d.resolve(item)); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// scalarFieldValue returns the text of a string or name value.
+func scalarFieldValue(v pdfValue) string {
+	switch v := v.(type) {
+	case pdfString:
+		return strings.TrimSpace(v.text())
+	case pdfName:
+		return string(v)
+	}
+	return ""
+}
+
+// pdfValue is a parsed PDF object: pdfDict, pdfArray, pdfName, pdfString,
+// pdfRef or pdfKeyword (numbers, booleans and null).
+type pdfValue any
+
+type (
+	pdfDict    map[string]pdfValue
+	pdfArray   []pdfValue
+	pdfName    string
+	pdfString  []byte
+	pdfRef     int
+	pdfKeyword string
+)
+
+// text decodes a PDF text string: UTF-16BE or UTF-8 with a byte order mark,
+// otherwise PDFDocEncoding, which is read as Latin-1.
+func (s pdfString) text() string {
+	switch {
+	case bytes.HasPrefix(s, []byte{0xFE, 0xFF}):
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(s, []byte{0xEF, 0xBB, 0xBF}):
+		return string(s[3:])
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// pdfDocument holds the objects of a PDF by object number.
+type pdfDocument struct {
+	objects map[int]pdfValue
+	catalog pdfDict
+
+	// added counts the objects read and inflated the bytes of object
+	// streams decompressed, against maxPDFObjects and maxObjectStreamTotal.
+	added    int
+	inflated int
+
+	// info is the /Info entry of the last trailer or cross-reference
+	// stream.
+	info pdfValue
+}
+
+// resolve follows indirect references.
+func (d *pdfDocument) resolve(v pdfValue) pdfValue {
+	for range maxFieldDepth {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[int(ref)]
+	}
+	return nil
+}
+
+var (
+	objHeader      = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	trailerKeyword = regexp.MustCompile(`\btrailer\b`)
+)
+
+// parsePDFObjects scans data for indirect objects, including those packed
+// into object streams.
+func parsePDFObjects(data []byte) (*pdfDocument, error) {
+	doc := &pdfDocument{objects: make(map[int]pdfValue)}
+
+	for _, m := range objHeader.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		lex := &pdfLexer{data: data, pos: m[1]}
+		v, ok := lex.value()
+		if !ok {
+			continue
+		}
+		if err := doc.add(num, v); err != nil {
+			return nil, err
+		}
+
+		dict, ok := v.(pdfDict)
+		if !ok || dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		stream, err := lex.stream(dict)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", num, err)
+		}
+		if err := doc.addObjectStream(dict, stream); err != nil {
+			return nil, fmt.Errorf("object %d: %w", num, err)
+		}
+	}
+
+	for _, i := range trailerKeyword.FindAllIndex(data, -1) {
+		lex := &pdfLexer{data: data, pos: i[1]}
+		if v, ok := lex.value(); ok {
+			if dict, ok := v.(pdfDict); ok && dict["Info"] != nil {
+				doc.info = dict["Info"]
+			}
+		}
+	}
+	return doc, nil
+}
+
+// add records object num, remembering the document catalog and the /Info
+// entry of cross-reference streams, which replace the trailer in PDF 1.5+.
+func (d *pdfDocument) add(num int, v pdfValue) error {
+	if d.added++; d.added > maxPDFObjects {
+		return fmt.Errorf("more than %d objects", maxPDFObjects)
+	}
+	d.objects[num] = v
+	dict, ok := v.(pdfDict)
+	if !ok {
+		return nil
+	}
+	switch dict["Type"] {
+	case pdfName("Catalog"):
+		d.catalog = dict
+	case pdfName("XRef"):
+		if dict["Info"] != nil {
+			d.info = dict["Info"]
+		}
+	}
+	return nil
+}
+
+// addObjectStream adds the objects packed into an object stream.
+func (d *pdfDocument) addObjectStream(dict pdfDict, stream []byte) error {
+	if filter := dict["Filter"]; filter != nil {
+		if a, ok := filter.(pdfArray); ok && len(a) == 1 {
+			filter = a[0]
+		}
+		if filter != pdfName("FlateDecode") {
+			return fmt.Errorf("unsupported object stream filter %v", filter)
+		}
+		r, err := zlib.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			return fmt.Errorf("inflate object stream: %w", err)
+		}
+		limit := min(maxObjectStreamSize, maxObjectStreamTotal-d.inflated)
+		stream, err = io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		if err != nil {
+			return fmt.Errorf("inflate object stream: %w", err)
+		}
+		if len(stream) > limit {
+			return fmt.Errorf("object streams inflate to more than %d bytes", limit)
+		}
+		d.inflated += len(stream)
+	}
+
+	n, _ := strconv.Atoi(string(pdfKeywordOf(dict["N"])))
+	first, _ := strconv.Atoi(string(pdfKeywordOf(dict["First"])))
+	if first < 0 || first > len(stream) {
+		return fmt.Errorf("object stream offset %d out of range", first)
+	}
+
+	header := strings.Fields(string(stream[:first]))
+	for i := 0; i+1 < len(header) && i/2 < n; i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		offset, err2 := strconv.Atoi(header[i+1])
+		// Compare without adding, which could overflow
+		if err1 != nil || err2 != nil || offset < 0 || offset > len(stream)-first {
+			continue
+		}
+		lex := &pdfLexer{data: stream, pos: first + offset}
+		if v, ok := lex.value(); ok {
+			if err := d.add(num, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func pdfKeywordOf(v pdfValue) pdfKeyword {
+	k, _ := v.(pdfKeyword)
+	return k
+}
+
+// pdfLexer reads PDF objects from data. Positions outside [0, len(data)]
+// read as the end of data.
+type pdfLexer struct {
+	data []byte
+	pos  int
+
+	// depth is the number of arrays and dictionaries being read.
+	depth int
+}
+
+// value reads one object. Integers followed by "<gen> R" become a pdfRef.
+func (l *pdfLexer) value() (pdfValue, bool) {
+	if !l.inRange() {
+		return nil, false
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		return l.name(), true
+	case c == '(':
+		return l.literalString(), true
+	case c == '<' && l.peek(1) == '<':
+		return l.dict()
+	case c == '<':
+		return l.hexString(), true
+	case c == '[':
+		return l.array()
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		return nil, false
+	}
+
+	kw := l.keyword()
+	if kw == "" {
+		return nil, false
+	}
+	if num, err := strconv.Atoi(string(kw)); err == nil && num >= 0 {
+		save := l.pos
+		l.skipSpace()
+		if gen := l.keyword(); gen != "" {
+			if _, err := strconv.Atoi(string(gen)); err == nil {
+				l.skipSpace()
+				if l.keyword() == "R" {
+					return pdfRef(num), true
+				}
+			}
+		}
+		l.pos = save
+	}
+	return kw, true
+}
+
+// stream returns the data of the stream following dict.
+func (l *pdfLexer) stream(dict pdfDict) ([]byte, error) {
+	if !l.inRange() {
+		return nil, fmt.Errorf("missing stream")
+	}
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		return nil, fmt.Errorf("missing stream")
+	}
+	l.pos += len("stream")
+	if l.peek(0) == '\r' {
+		l.pos++
+	}
+	if l.peek(0) == '\n' {
+		l.pos++
+	}
+
+	if n, err := strconv.Atoi(string(pdfKeywordOf(dict["Length"]))); err == nil && n >= 0 && n <= len(l.data)-l.pos {
+		return l.data[l.pos : l.pos+n], nil
+	}
+	// Indirect or wrong length: read up to the end marker
+	end := bytes.Index(l.data[l.pos:], []byte("endstream"))
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated stream")
+	}
+	return bytes.TrimRight(l.data[l.pos:l.pos+end], "\r\n"), nil
+}
+
+// inRange reports whether l.pos lies within [0, len(l.data)].
+func (l *pdfLexer) inRange() bool {
+	return l.pos >= 0 && l.pos <= len(l.data)
+}
+
+func (l *pdfLexer) peek(ahead int) byte {
+	if l.pos+ahead >= len(l.data) {
+		return 0
+	}
+	return l.data[l.pos+ahead]
+}
+
+// skipSpace skips white space and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0:
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads a run of regular characters.
+func (l *pdfLexer) keyword() pdfKeyword {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return pdfKeyword(l.data[start:l.pos])
+}
+
+// name reads a name, decoding #xx escapes.
+func (l *pdfLexer) name() pdfName {
+	l.pos++ // '/'
+	raw := string(l.keyword())
+	if !strings.Contains(raw, "#") {
+		return pdfName(raw)
+	}
+	var sb strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if b, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(raw[i])
+	}
+	return pdfName(sb.String())
+}
+
+// literalString reads a (string), handling escapes and balanced parentheses.
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // '('
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.peek(0) == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.peek(0) >= '0' && l.peek(0) <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hexString reads a <hex string>; an odd final digit is padded with 0.
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++ // '<'
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		b, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(b)
+	}
+	return out
+}
+
+func (l *pdfLexer) array() (pdfValue, bool) {
+	if l.depth >= maxNestingDepth {
+		return nil, false
+	}
+	l.depth++
+	defer func() { l.depth-- }()
+
+	l.pos++ // '['
+	var arr pdfArray
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return nil, false
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			return arr, true
+		}
+		v, ok := l.value()
+		if !ok {
+			return nil, false
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (l *pdfLexer) dict() (pdfValue, bool) {
+	if l.depth >= maxNestingDepth {
+		return nil, false
+	}
+	l.depth++
+	defer func() { l.depth-- }()
+
+	l.pos += 2 // '<<'
+	dict := make(pdfDict)
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return nil, false
+		}
+		if l.data[l.pos] == '>' && l.peek(1) == '>' {
+			l.pos += 2
+			return dict, true
+		}
+		if l.data[l.pos] != '/' {
+			return nil, false
+		}
+		key := l.name()
+		v, ok := l.value()
+		if !ok {
+			return nil, false
+		}
+		dict[string(key)] = v
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const formPDF = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 5 0 R 6 0 R 7 0 R 8 0 R] >> >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /Annots [11 0 R] >>
+endobj
+4 0 obj
+<< /FT /Tx /T (name) /V (Jane \(J.\) Doe\\) /DA (/Helv 0 Tf 0 g) >>
+endobj
+5 0 obj
+<< /FT /Tx /T <FEFF0063006900740079> /V <FEFF004D00FC006E006300680065006E> >>
+endobj
+6 0 obj
+<< /FT /Btn /T (subscribe) /V /Yes /Kids [12 0 R] >>
+endobj
+7 0 obj
+<< /T (address) /Kids [9 0 R 10 0 R] >>
+endobj
+8 0 obj
+<< /FT /Tx /T (notes) >>
+endobj
+9 0 obj
+<< /FT /Tx /Parent 7 0 R /T (street) /V 13 0 R >>
+endobj
+10 0 obj
+<< /FT /Ch /Parent 7 0 R /T (tags) /V [(a) (b)] >>
+endobj
+11 0 obj
+<< /Type /Annot /Subtype /Text /T (Reviewer) /V (not a field) >>
+endobj
+12 0 obj
+<< /Type /Annot /Subtype /Widget /Parent 6 0 R /AS /Yes >>
+endobj
+13 0 obj
+(1 Main St)
+endobj
+trailer
+<< /Root 1 0 R >>
+%%EOF
+`
+
+func TestPDFFormFields(t *testing.T) {
+	got, err := PDFFormFields([]byte(formPDF))
+	if err != nil {
+		t.Fatalf("PDFFormFields: %v", err)
+	}
+
+	want := map[string]string{
+		"name":           `Jane (J.) Doe\`,
+		"city":           "München",
+		"subscribe":      "Yes",
+		"address.street": "1 Main St",
+		"address.tags":   "a, b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %q, want %q", got, want)
+	}
+}
+
+func TestPDFFormFields_ObjectStream(t *testing.T) {
+	objs := []string{
+		"<< /Type /Catalog /AcroForm 3 0 R >>",
+		"<< /T (total) /V (9.99) >>",
+		"<< /Fields [2 0 R] >>",
+	}
+	var header, body bytes.Buffer
+	for i, o := range objs {
+		fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+		body.WriteString(o + "\n")
+	}
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write(append(header.Bytes(), body.Bytes()...))
+	zw.Close()
+
+	var pdf bytes.Buffer
+	fmt.Fprintf(&pdf, "%%PDF-1.5\n4 0 obj\n<< /Type /ObjStm /N 3 /First %d /Filter /FlateDecode /Length %d >>\nstream\n",
+		header.Len(), stream.Len())
+	pdf.Write(stream.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+	got, err := PDFFormFields(pdf.Bytes())
+	if err != nil {
+		t.Fatalf("PDFFormFields: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]string{"total": "9.99"}) {
+		t.Errorf("fields = %q, want total", got)
+	}
+}
+
+func TestPDFFormFields_NoForm(t *testing.T) {
+	got, err := PDFFormFields([]byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n%%EOF\n"))
+	if err != nil || got != nil {
+		t.Errorf("PDFFormFields = %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestPDFFormFields_Encrypted(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "encrypted.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, "\n% /AcroForm\n"...)
+
+	if _, err := PDFFormFields(data); !errors.Is(err, ErrPDFEncrypted) {
+		t.Errorf("err = %v, want ErrPDFEncrypted", err)
+	}
+}
+
+// overflowObjStmPDF has an object stream whose header gives an offset that
+// overflows when added to /First.
+const overflowObjStmPDF = "%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /N 1 /First 22 /Length 27 >>\nstream\n" +
+	"5 9223372036854775807 << >>\nendstream\nendobj\n/AcroForm /Info"
+
+func TestPDFFormFields_Limits(t *testing.T) {
+	tests := map[string]string{
+		"object stream offset overflow": overflowObjStmPDF,
+		"stream length overflow": "%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length 9223372036854775807 >>\n" +
+			"stream\n1 0 << >>\nendstream\nendobj\n",
+		"deep nesting": "%PDF-1.7\n1 0 obj\n<< /Type /Catalog /AcroForm << /Fields [" +
+			strings.Repeat("[", 100000) + "] >> >>\nendobj\n",
+		"field cycle": "%PDF-1.7\n1 0 obj\n<< /Type /Catalog /AcroForm << /Fields [2 0 R] >> >>\nendobj\n" +
+			"2 0 obj\n<< /T (a) /Kids [2 0 R 2 0 R 3 0 R] >>\nendobj\n3 0 obj\n<< /T (b) /V (x) /Kids [2 0 R 2 0 R] >>\nendobj\n",
+		"value cycle": "%PDF-1.7\n1 0 obj\n<< /Type /Catalog /AcroForm << /Fields [2 0 R] >> >>\nendobj\n" +
+			"2 0 obj\n<< /T (a) /V 3 0 R >>\nendobj\n3 0 obj\n[3 0 R (x)]\nendobj\n",
+	}
+	for name, pdf := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Must return without exhausting the stack or looping
+			if _, err := PDFFormFields([]byte(pdf)); err != nil {
+				t.Logf("PDFFormFields: %v", err)
+			}
+		})
+	}
+}
+
+func TestPDFLexer_OutOfRange(t *testing.T) {
+	data := []byte("<< /A 1 >>")
+	for _, pos := range []int{-1, -9223372036854775787, len(data) + 1} {
+		lex := &pdfLexer{data: data, pos: pos}
+		if v, ok := lex.value(); ok {
+			t.Errorf("value() at %d = %v, want none", pos, v)
+		}
+		if _, err := lex.stream(pdfDict{}); err == nil {
+			t.Errorf("stream() at %d succeeded, want an error", pos)
+		}
+	}
+}
+
+func FuzzParsePDFForm(f *testing.F) {
+	f.Add([]byte(formPDF))
+	for _, name := range []string{"form.pdf", "info.pdf"} {
+		data, err := os.ReadFile(filepath.Join("..", "testdata", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte("%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length 8 >>\nstream\n1 0 << >>\nendstream\nendobj\n/AcroForm /Info"))
+	f.Add([]byte(overflowObjStmPDF))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if fields, err := PDFFormFields(data); err == nil {
+			for name, value := range fields {
+				if name == "" || value == "" {
+					t.Errorf("empty field %q = %q", name, value)
+				}
+			}
+		}
+		PDFMetadata(data)
+	})
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// PDFMetadata returns the document information dictionary of the PDF in
+// data: title, author, producer, dates and so on. A PDF without one yields
+// nil and no error. It uses the same minimal parser as PDFFormFields, and
+// encrypted PDFs return ErrPDFEncrypted since their strings are encrypted
+// too.
+func PDFMetadata(data []byte) (*models.PDFInfo, error) {
+	if !bytes.Contains(data, []byte("/Info")) {
+		return nil, nil
+	}
+	if IsPDFEncrypted(data) {
+		return nil, ErrPDFEncrypted
+	}
+
+	doc, err := parsePDFObjects(data)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := doc.resolve(doc.info).(pdfDict)
+	if !ok {
+		return nil, nil
+	}
+
+	text := func(key string) string {
+		s, _ := doc.resolve(dict[key]).(pdfString)
+		return strings.TrimSpace(s.text())
+	}
+	date := func(key string) *time.Time {
+		t, ok := parsePDFDate(text(key))
+		if !ok {
+			return nil
+		}
+		return &t
+	}
+
+	info := &models.PDFInfo{
+		Title:        text("Title"),
+		Author:       text("Author"),
+		Subject:      text("Subject"),
+		Keywords:     text("Keywords"),
+		Creator:      text("Creator"),
+		Producer:     text("Producer"),
+		CreationDate: date("CreationDate"),
+		ModDate:      date("ModDate"),
+	}
+	if *info == (models.PDFInfo{}) {
+		return nil, nil
+	}
+	return info, nil
+}
+
+var pdfDate = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:([Zz+-])(?:(\d{2})'?(?:(\d{2})'?)?)?)?$`)
+
+// parsePDFDate parses a PDF date string such as "D:20240102150405+01'00'".
+// Everything after the year is optional; missing fields default to the
+// start of the period and a missing offset means UTC.
+func parsePDFDate(s string) (time.Time, bool) {
+	m := pdfDate.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return time.Time{}, false
+	}
+	num := func(i, def int) int {
+		if m[i] == "" {
+			return def
+		}
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+
+	month, day := num(2, 1), num(3, 1)
+	hour, minute, sec := num(4, 0), num(5, 0), num(6, 0)
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || sec > 59 {
+		return time.Time{}, false
+	}
+
+	loc := time.UTC
+	if m[7] == "+" || m[7] == "-" {
+		offset := num(8, 0)*3600 + num(9, 0)*60
+		if m[7] == "-" {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+	return time.Date(num(1, 0), time.Month(month), day, hour, minute, sec, 0, loc), true
+}
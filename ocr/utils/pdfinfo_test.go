@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestPDFMetadata(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "info.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PDFMetadata(data)
+	if err != nil {
+		t.Fatalf("PDFMetadata: %v", err)
+	}
+	if got == nil {
+		t.Fatal("PDFMetadata = nil, want the info dictionary")
+	}
+	if got.Title != "Invoice 2024-017" || got.Author != "Jürgen" || got.Producer != "LibreOffice 7.6" {
+		t.Errorf("info = %+v", got)
+	}
+	want := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("", 3600))
+	if got.CreationDate == nil || !got.CreationDate.Equal(want) {
+		t.Errorf("CreationDate = %v, want %v", got.CreationDate, want)
+	}
+	if got.ModDate != nil {
+		t.Errorf("ModDate = %v, want nil for an unparseable date", got.ModDate)
+	}
+}
+
+func TestPDFMetadata_XRefStream(t *testing.T) {
+	pdf := "%PDF-1.5\n" +
+		"1 0 obj\n<< /Type /Catalog >>\nendobj\n" +
+		"2 0 obj\n<< /Subject (Receipts) /Keywords (q1, travel) >>\nendobj\n" +
+		"3 0 obj\n<< /Type /XRef /Root 1 0 R /Info 2 0 R /Length 0 >>\nstream\n\nendstream\nendobj\n%%EOF\n"
+
+	got, err := PDFMetadata([]byte(pdf))
+	if err != nil {
+		t.Fatalf("PDFMetadata: %v", err)
+	}
+	want := models.PDFInfo{Subject: "Receipts", Keywords: "q1, travel"}
+	if got == nil || *got != want {
+		t.Errorf("info = %+v, want %+v", got, want)
+	}
+}
+
+func TestPDFMetadata_None(t *testing.T) {
+	for name, pdf := range map[string]string{
+		"no info":    "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n",
+		"empty info": "%PDF-1.4\n1 0 obj\n<< >>\nendobj\ntrailer\n<< /Info 1 0 R >>\n%%EOF\n",
+	} {
+		got, err := PDFMetadata([]byte(pdf))
+		if err != nil || got != nil {
+			t.Errorf("%s: PDFMetadata = %+v, %v; want nil, nil", name, got, err)
+		}
+	}
+}
+
+func TestPDFMetadata_Encrypted(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<< /Title <8f2a01> >>\nendobj\n" +
+		"2 0 obj\n<< /Filter /Standard /V 2 >>\nendobj\n" +
+		"trailer\n<< /Info 1 0 R /Encrypt 2 0 R >>\n%%EOF\n"
+	if _, err := PDFMetadata([]byte(pdf)); !errors.Is(err, ErrPDFEncrypted) {
+		t.Errorf("err = %v, want ErrPDFEncrypted", err)
+	}
+}
+
+func TestParsePDFDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"D:20240102150405Z", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), true},
+		{"D:20240102150405-05'30'", time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("", -(5*3600+30*60))), true},
+		{"D:20240102150405+01", time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("", 3600)), true},
+		{"D:2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"20240102", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		{"D:20241302", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePDFDate(tt.in)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parsePDFDate(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// LowConfidenceThreshold is the line confidence below which a line counts as
+// low confidence in a quality report.
+const LowConfidenceThreshold = 0.5
+
+// BuildQualityReport computes line count and confidence statistics over
+// lines. Lines with only whitespace are counted as empty and excluded from
+// the confidence statistics.
+func BuildQualityReport(lines []models.TextLine) *models.QualityReport {
+	report := &models.QualityReport{
+		LineCount:              len(lines),
+		LowConfidenceThreshold: LowConfidenceThreshold,
+	}
+
+	confs := make([]float64, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line.Text) == "" {
+			report.EmptyLineCount++
+			continue
+		}
+		confs = append(confs, line.Confidence)
+	}
+	if len(confs) == 0 {
+		return report
+	}
+
+	sort.Float64s(confs)
+
+	var sum float64
+	low := 0
+	for _, c := range confs {
+		sum += c
+		if c < LowConfidenceThreshold {
+			low++
+		}
+	}
+
+	n := len(confs)
+	report.MeanConfidence = sum / float64(n)
+	report.MinConfidence = confs[0]
+	report.LowConfidenceFraction = float64(low) / float64(n)
+	if n%2 == 1 {
+		report.MedianConfidence = confs[n/2]
+	} else {
+		report.MedianConfidence = (confs[n/2-1] + confs[n/2]) / 2
+	}
+
+	return report
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func TestBuildQualityReport(t *testing.T) {
+	lines := []models.TextLine{
+		{Text: "INVOICE", Confidence: 0.9},
+		{Text: "  ", Confidence: 0},
+		{Text: "Total", Confidence: 0.4},
+		{Text: "$12.50", Confidence: 0.8},
+		{Text: "Thanks", Confidence: 0.3},
+	}
+
+	r := BuildQualityReport(lines)
+
+	if r.LineCount != 5 {
+		t.Errorf("LineCount = %d, want 5", r.LineCount)
+	}
+	if r.EmptyLineCount != 1 {
+		t.Errorf("EmptyLineCount = %d, want 1", r.EmptyLineCount)
+	}
+
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"MeanConfidence", r.MeanConfidence, 0.6},
+		{"MedianConfidence", r.MedianConfidence, 0.6},
+		{"MinConfidence", r.MinConfidence, 0.3},
+		{"LowConfidenceFraction", r.LowConfidenceFraction, 0.5},
+		{"LowConfidenceThreshold", r.LowConfidenceThreshold, LowConfidenceThreshold},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestBuildQualityReport_OddMedian(t *testing.T) {
+	r := BuildQualityReport([]models.TextLine{
+		{Text: "a", Confidence: 0.2},
+		{Text: "b", Confidence: 0.9},
+		{Text: "c", Confidence: 0.7},
+	})
+	if r.MedianConfidence != 0.7 {
+		t.Errorf("MedianConfidence = %v, want 0.7", r.MedianConfidence)
+	}
+}
+
+func TestBuildQualityReport_NoLines(t *testing.T) {
+	r := BuildQualityReport(nil)
+	if r.LineCount != 0 || r.MeanConfidence != 0 || r.LowConfidenceFraction != 0 {
+		t.Errorf("report = %+v, want zero statistics", r)
+	}
+}
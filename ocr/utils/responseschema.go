@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// JSONSchema is the subset of JSON Schema needed to describe the model
+// response. It marshals to a standard JSON Schema document.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 []string               `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"` // false or *JSONSchema
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+}
+
+// ResponseSchema is the JSON Schema of the answer the OCR prompt asks the
+// model for, generated from the same structure. Every key of the prompt is
+// required, except the optional sections; unknown keys are rejected.
+var ResponseSchema = responseSchema()
+
+// responseSchema builds ResponseSchema.
+func responseSchema() *JSONSchema {
+	zero, one := 0.0, 1.0
+	str := &JSONSchema{Type: []string{"string"}}
+	confidence := &JSONSchema{Type: []string{"number"}, Minimum: &zero, Maximum: &one}
+	nullable := func(s *JSONSchema) *JSONSchema {
+		c := *s
+		c.Type = append(slices.Clone(s.Type), "null")
+		return &c
+	}
+	object := func(props map[string]*JSONSchema, required ...string) *JSONSchema {
+		return &JSONSchema{Type: []string{"object"}, Properties: props, Required: required, AdditionalProperties: false}
+	}
+	mapOf := func(s *JSONSchema) *JSONSchema {
+		return &JSONSchema{Type: []string{"object"}, AdditionalProperties: s}
+	}
+	arrayOf := func(s *JSONSchema) *JSONSchema {
+		return &JSONSchema{Type: []string{"array"}, Items: s}
+	}
+
+	number := &JSONSchema{Type: []string{"number"}}
+	integer := &JSONSchema{Type: []string{"integer"}}
+	box := object(map[string]*JSONSchema{
+		"x": number, "y": number, "width": number, "height": number,
+	}, "x", "y", "width", "height")
+
+	s := object(map[string]*JSONSchema{
+		"metadata": object(map[string]*JSONSchema{
+			"language":         nullable(str),
+			"direction":        {Type: []string{"string"}, Enum: []string{"ltr", "rtl"}},
+			"document_type":    str,
+			"confidence_score": confidence,
+		}, "language", "document_type", "confidence_score"),
+		"text": object(map[string]*JSONSchema{
+			"raw": str,
+			"lines": arrayOf(object(map[string]*JSONSchema{
+				"text":         str,
+				"bounding_box": nullable(box),
+				"confidence":   confidence,
+			}, "text", "bounding_box", "confidence")),
+		}, "raw", "lines"),
+		"structured_data": object(map[string]*JSONSchema{
+			"key_value_pairs":      mapOf(str),
+			"key_value_confidence": mapOf(confidence),
+			"key_value_boxes":      mapOf(nullable(box)),
+			"tables": arrayOf(object(map[string]*JSONSchema{
+				"headers":      arrayOf(str),
+				"rows":         arrayOf(arrayOf(str)),
+				"bounding_box": nullable(box),
+				"confidence":   confidence,
+				"spans": arrayOf(object(map[string]*JSONSchema{
+					"row": integer, "column": integer, "colspan": integer, "rowspan": integer,
+				}, "row", "column", "colspan", "rowspan")),
+			}, "headers", "rows")),
+		}, "key_value_pairs", "tables"),
+		"documents": arrayOf(object(map[string]*JSONSchema{
+			"bounding_box":  box,
+			"document_type": str,
+		}, "bounding_box", "document_type")),
+		"image": object(map[string]*JSONSchema{
+			"width":      integer,
+			"height":     integer,
+			"dpi":        nullable(integer),
+			"color_mode": str,
+		}),
+		"watermarks": arrayOf(str),
+		"summary":    nullable(str),
+	}, "metadata", "text", "structured_data", "summary")
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	return s
+}
+
+// ValidateResponseSchema checks the model answer raw, after stripping any
+// code fences, against ResponseSchema. Unlike ParseAndValidateJSON, which
+// tolerates wrong types and unknown keys, it fails on the first value that
+// does not conform, naming its path, e.g. "text.lines[2].confidence: got
+// string, want number".
+func ValidateResponseSchema(raw string) error {
+	dec := json.NewDecoder(strings.NewReader(CleanJSONResponse(raw)))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("json decode: %w", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after the JSON object")
+	}
+	return validateSchema(doc, ResponseSchema, "")
+}
+
+// validateSchema checks v, decoded with UseNumber, against s. path locates
+// v for error messages.
+func validateSchema(v any, s *JSONSchema, path string) error {
+	where := path
+	if where == "" {
+		where = "response"
+	}
+	if got := schemaType(v, s.Type); !slices.Contains(s.Type, got) {
+		return fmt.Errorf("%s: got %s, want %s", where, got, strings.Join(s.Type, " or "))
+	}
+
+	switch v := v.(type) {
+	case string:
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+			return fmt.Errorf("%s: %q is not one of %q", where, v, s.Enum)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: %s is below the minimum %g", where, v, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: %s is above the maximum %g", where, v, *s.Maximum)
+		}
+	case []any:
+		for i, item := range v {
+			if err := validateSchema(item, s.Items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				return fmt.Errorf("%s: missing required key %q", where, key)
+			}
+		}
+		// Sorted, so the first error is the same on every run
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if ps, ok := s.Properties[key]; ok {
+				if err := validateSchema(v[key], ps, child); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case *JSONSchema:
+				if err := validateSchema(v[key], extra, child); err != nil {
+					return err
+				}
+			case bool:
+				if !extra {
+					return fmt.Errorf("%s: unexpected key %q", where, key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaType returns the JSON Schema type name of v. Whole numbers are
+// "integer" if the schema accepts integers, otherwise "number".
+func schemaType(v any, accepted []string) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if slices.Contains(accepted, "integer") {
+			if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+				return "integer"
+			}
+		}
+		return "number"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readSchemaFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "schema", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestValidateResponseSchema_Valid(t *testing.T) {
+	raw := readSchemaFixture(t, "valid.json")
+	if err := ValidateResponseSchema(raw); err != nil {
+		t.Errorf("ValidateResponseSchema: %v", err)
+	}
+	if err := ValidateResponseSchema("```json\n" + raw + "\n```"); err != nil {
+		t.Errorf("fenced: ValidateResponseSchema: %v", err)
+	}
+}
+
+// The fixtures parse into an OllamaVisionResponse but do not match the
+// schema the prompt asks for.
+func TestValidateResponseSchema_Invalid(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"wrong_type.json", "text.lines[0].confidence: got string, want number"},
+		{"extra_key.json", `metadata: unexpected key "notes"`},
+		{"missing_key.json", `response: missing required key "structured_data"`},
+		{"numeric_value.json", "structured_data.key_value_pairs.total: got number, want string"},
+		{"out_of_range.json", "metadata.confidence_score: 93 is above the maximum 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			raw := readSchemaFixture(t, tt.fixture)
+			if _, err := ParseAndValidateJSON(raw); err != nil {
+				t.Fatalf("fixture should pass struct parsing: %v", err)
+			}
+			err := ValidateResponseSchema(raw)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ValidateResponseSchema = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResponseSchema_Malformed(t *testing.T) {
+	for _, raw := range []string{"", "not json", `{"metadata":{}} trailing`, `[]`} {
+		if err := ValidateResponseSchema(raw); err == nil {
+			t.Errorf("ValidateResponseSchema(%q) = nil, want an error", raw)
+		}
+	}
+}
+
+func TestResponseSchema_Marshal(t *testing.T) {
+	data, err := json.Marshal(ResponseSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc["$schema"].(string), "json-schema.org") {
+		t.Errorf("$schema = %v", doc["$schema"])
+	}
+	if doc["additionalProperties"] != false {
+		t.Errorf("additionalProperties = %v, want false", doc["additionalProperties"])
+	}
+	props := doc["properties"].(map[string]any)
+	for _, key := range []string{"metadata", "text", "structured_data", "summary", "documents"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("schema has no property %q", key)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SanitizeString removes invalid UTF-8 sequences and control characters
+// from s. Newlines, carriage returns and tabs are kept because they carry
+// layout in raw OCR text.
+func SanitizeString(s string) string {
+	if isClean(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if r == utf8.RuneError && size <= 1 {
+			continue
+		}
+		if !allowedRune(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NormalizeLineEndings converts every line ending in s (\r\n, \r or \n) to
+// eol.
+func NormalizeLineEndings(s, eol string) string {
+	if !strings.ContainsRune(s, '\r') && eol == "\n" {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	if eol != "\n" {
+		s = strings.ReplaceAll(s, "\n", eol)
+	}
+	return s
+}
+
+// NormalizeWhitespace tidies the spacing of s line by line: runs of spaces,
+// tabs and other horizontal whitespace become a single space, leading and
+// trailing whitespace is trimmed, and blank lines at the start and end are
+// removed. Newlines between lines, including blank lines, are kept, and a
+// line ending in \r\n keeps it.
+func NormalizeWhitespace(s string) string {
+	lines := strings.Split(s, "\n")
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, line := range lines {
+		cr := strings.HasSuffix(line, "\r")
+		line = strings.TrimFunc(strings.TrimSuffix(line, "\r"), isHorizontalSpace)
+
+		var prevSpace bool
+		for _, r := range line {
+			space := isHorizontalSpace(r)
+			if space && prevSpace {
+				continue
+			}
+			prevSpace = space
+			if space {
+				r = ' '
+			}
+			b.WriteRune(r)
+		}
+		if cr {
+			b.WriteByte('\r')
+		}
+		if i < len(lines)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return strings.TrimFunc(b.String(), func(r rune) bool { return r == '\n' || r == '\r' })
+}
+
+// isHorizontalSpace reports whether r is whitespace other than a line break.
+func isHorizontalSpace(r rune) bool {
+	return r != '\n' && r != '\r' && unicode.IsSpace(r)
+}
+
+// TruncateWords returns s cut after its first n whitespace-separated words.
+// Whitespace between the kept words is preserved.
+func TruncateWords(s string, n int) string {
+	words := 0
+	inWord := false
+	for i, r := range s {
+		if unicode.IsSpace(r) {
+			if inWord && words == n {
+				return s[:i]
+			}
+			inWord = false
+			continue
+		}
+		if !inWord {
+			inWord = true
+			words++
+		}
+	}
+	return s
+}
+
+// SanitizeStrings sanitizes every element of ss in place.
+func SanitizeStrings(ss []string) {
+	for i, s := range ss {
+		ss[i] = SanitizeString(s)
+	}
+}
+
+// isClean reports whether s needs no sanitization, avoiding an allocation
+// for the common case.
+func isClean(s string) bool {
+	for _, r := range s {
+		if r == utf8.RuneError || !allowedRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func allowedRune(r rune) bool {
+	switch r {
+	case '\n', '\r', '\t':
+		return true
+	}
+	return !unicode.IsControl(r)
+}
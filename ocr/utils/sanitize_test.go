@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"clean", "Total: $12.50", "Total: $12.50"},
+		{"keeps layout whitespace", "a\tb\r\nc", "a\tb\r\nc"},
+		{"keeps non-ASCII", "Größe 東京 €", "Größe 東京 €"},
+		{"invalid bytes", "ab\xff\xfecd", "abcd"},
+		{"truncated multibyte", "caf\xc3", "caf"},
+		{"control characters", "a\x00b\x07c\x1bd\x7fe", "abcde"},
+		{"C1 control", "a\u0085b", "ab"},
+		{"mixed", "\x00INV\xffOICE\x08\n#42", "INVOICE\n#42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeString(tt.in)
+			if got != tt.want {
+				t.Errorf("SanitizeString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("result %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestSanitizeStrings(t *testing.T) {
+	ss := []string{"ok", "b\x00ad", "\xff"}
+	SanitizeStrings(ss)
+
+	want := []string{"ok", "bad", ""}
+	for i := range want {
+		if ss[i] != want[i] {
+			t.Errorf("ss[%d] = %q, want %q", i, ss[i], want[i])
+		}
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"one two three four", 2, "one two"},
+		{"one  two\nthree", 2, "one  two"},
+		{"one two", 5, "one two"},
+		{"  lead trail  ", 1, "  lead"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := TruncateWords(tt.in, tt.n); got != tt.want {
+			t.Errorf("TruncateWords(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	tests := []struct {
+		in   string
+		eol  string
+		want string
+	}{
+		{"a\r\nb\nc\rd", "\n", "a\nb\nc\nd"},
+		{"a\r\nb\nc\rd", "\r\n", "a\r\nb\r\nc\r\nd"},
+		{"a\r\n\r\nb", "\n", "a\n\nb"},
+		{"a\r\nb", "\r\n", "a\r\nb"},
+		{"plain", "\r\n", "plain"},
+		{"", "\n", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLineEndings(tt.in, tt.eol); got != tt.want {
+			t.Errorf("NormalizeLineEndings(%q, %q) = %q, want %q", tt.in, tt.eol, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"ACME  Store", "ACME Store"},
+		{"  Total:\t\t9.99   ", "Total: 9.99"},
+		{"\n\n  ACME Store  \nTOTAL    9.99\n\n", "ACME Store\nTOTAL 9.99"},
+		{"Header\n\n\nBody", "Header\n\n\nBody"},
+		{"a \n  \nb", "a\n\nb"},
+		{"Name:\u00a0\tJane", "Name: Jane"},
+		{"\r\n a  b \r\nc \r\n\r\n", "a b\r\nc"},
+		{" \n\t\n", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeWhitespace(tt.in); got != tt.want {
+			t.Errorf("NormalizeWhitespace(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import "github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+
+// Models represent a cell merged with the cell above it (e.g. a category
+// spanning several rows) inconsistently: as an empty string, as a repeat of
+// the value above, or by leaving the row short. The functions below make
+// such tables consistent. Each returns a copy in which short rows are padded
+// with empty cells to the header width and continuation cells are rewritten;
+// the first row is never changed. Only vertical merges are handled.
+
+// FillDownMergedCells replaces every empty cell with the value above it.
+func FillDownMergedCells(t models.Table) models.Table {
+	return rewriteMergedCells(t, func(cell, above string) string {
+		if cell == "" {
+			return above
+		}
+		return cell
+	})
+}
+
+// EmptyMergedCells clears every cell that repeats the value above it.
+func EmptyMergedCells(t models.Table) models.Table {
+	return rewriteMergedCells(t, func(cell, above string) string {
+		if cell == above {
+			return ""
+		}
+		return cell
+	})
+}
+
+// MarkMergedCells replaces every cell that is empty or repeats the value
+// above it with marker.
+func MarkMergedCells(t models.Table, marker string) models.Table {
+	return rewriteMergedCells(t, func(cell, above string) string {
+		if cell == "" || cell == above {
+			return marker
+		}
+		return cell
+	})
+}
+
+// rewriteMergedCells pads t's rows and applies rewrite to every cell that
+// has a non-empty cell above it. above is the nearest non-empty value in the
+// column, so a run of merged cells is rewritten against the cell that starts
+// it.
+func rewriteMergedCells(t models.Table, rewrite func(cell, above string) string) models.Table {
+	width := len(t.Headers)
+	for _, row := range t.Rows {
+		width = max(width, len(row))
+	}
+
+	out := t
+	if t.Rows == nil {
+		return out
+	}
+	out.Rows = make([][]string, len(t.Rows))
+	if t.Spans != nil {
+		out.Spans = padSpans(t.Spans, width)
+	}
+
+	// last holds the nearest non-empty original value in each column.
+	last := make([]string, width)
+	for i, row := range t.Rows {
+		padded := make([]string, width)
+		copy(padded, row)
+		for col, cell := range padded {
+			if i > 0 && last[col] != "" {
+				padded[col] = rewrite(cell, last[col])
+			}
+			if cell != "" {
+				last[col] = cell
+			}
+		}
+		out.Rows[i] = padded
+	}
+	return out
+}
+
+// padSpans returns a copy of spans with every row padded to width with cells
+// that span nothing, to match the padded rows.
+func padSpans(spans [][]models.CellSpan, width int) [][]models.CellSpan {
+	out := make([][]models.CellSpan, len(spans))
+	for i, row := range spans {
+		padded := make([]models.CellSpan, max(width, len(row)))
+		for j := range padded {
+			padded[j] = models.CellSpan{ColSpan: 1, RowSpan: 1}
+		}
+		copy(padded, row)
+		out[i] = padded
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// mergedTable has a "Fruit" category spanning rows as an empty cell, a
+// repeated value and a short row.
+func mergedTable() models.Table {
+	return models.Table{
+		Headers: []string{"Category", "Item", "Qty"},
+		Rows: [][]string{
+			{"Fruit", "Apple", "1"},
+			{"", "Pear", "2"},
+			{"Fruit", "Plum", "2"},
+			{"Veg", "Kale"},
+		},
+	}
+}
+
+func TestFillDownMergedCells(t *testing.T) {
+	got := FillDownMergedCells(mergedTable())
+	want := [][]string{
+		{"Fruit", "Apple", "1"},
+		{"Fruit", "Pear", "2"},
+		{"Fruit", "Plum", "2"},
+		{"Veg", "Kale", "2"},
+	}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("Rows = %q, want %q", got.Rows, want)
+	}
+}
+
+func TestMergedCells_KeepsTableFields(t *testing.T) {
+	table := mergedTable()
+	table.Confidence = 0.8
+	table.BoundingBox = &models.BoundingBox{X: 1, Y: 2, Width: 3, Height: 4}
+	for name, got := range map[string]models.Table{
+		"fill down": FillDownMergedCells(table),
+		"empty":     EmptyMergedCells(table),
+		"mark":      MarkMergedCells(table, "^"),
+	} {
+		if got.Confidence != 0.8 || !reflect.DeepEqual(got.BoundingBox, table.BoundingBox) {
+			t.Errorf("%s: Confidence = %v, BoundingBox = %+v, want %v, %+v",
+				name, got.Confidence, got.BoundingBox, table.Confidence, table.BoundingBox)
+		}
+	}
+}
+
+func TestEmptyMergedCells(t *testing.T) {
+	got := EmptyMergedCells(mergedTable())
+	want := [][]string{
+		{"Fruit", "Apple", "1"},
+		{"", "Pear", "2"},
+		{"", "Plum", ""},
+		{"Veg", "Kale", ""},
+	}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("Rows = %q, want %q", got.Rows, want)
+	}
+}
+
+func TestMarkMergedCells(t *testing.T) {
+	got := MarkMergedCells(mergedTable(), "^")
+	want := [][]string{
+		{"Fruit", "Apple", "1"},
+		{"^", "Pear", "2"},
+		{"^", "Plum", "^"},
+		{"Veg", "Kale", "^"},
+	}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("Rows = %q, want %q", got.Rows, want)
+	}
+}
+
+func TestMergedCells_LeadingEmptyCellsKept(t *testing.T) {
+	table := models.Table{Rows: [][]string{{"", "a"}, {"", "a"}}}
+	got := FillDownMergedCells(table)
+	if got.Rows[1][0] != "" {
+		t.Errorf("Rows[1][0] = %q, want empty with nothing above", got.Rows[1][0])
+	}
+	got = MarkMergedCells(table, "^")
+	want := [][]string{{"", "a"}, {"", "^"}}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("Rows = %q, want %q", got.Rows, want)
+	}
+}
+
+func TestMergedCells_DoesNotModifyInput(t *testing.T) {
+	table := mergedTable()
+	FillDownMergedCells(table)
+	if !reflect.DeepEqual(table, mergedTable()) {
+		t.Errorf("input modified: %q", table.Rows)
+	}
+}
+
+func TestMergedCells_PadsToWidestRow(t *testing.T) {
+	table := models.Table{Rows: [][]string{{"a", "b", "c"}, {"d"}}}
+	got := EmptyMergedCells(table)
+	want := [][]string{{"a", "b", "c"}, {"d", "", ""}}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("Rows = %q, want %q", got.Rows, want)
+	}
+}
+
+func TestMergedCells_PadsSpans(t *testing.T) {
+	table := mergedTable()
+	single := models.CellSpan{ColSpan: 1, RowSpan: 1}
+	table.Spans = [][]models.CellSpan{
+		{{ColSpan: 1, RowSpan: 2}, single, single},
+		{{}, single, single},
+		{single, single, single},
+		{single, single},
+	}
+
+	got := FillDownMergedCells(table)
+	if len(got.Spans) != len(got.Rows) || len(got.Spans[3]) != 3 || got.Spans[3][2] != single {
+		t.Errorf("Spans = %v, want them padded like the rows", got.Spans)
+	}
+	if got.Spans[0][0] != (models.CellSpan{ColSpan: 1, RowSpan: 2}) || got.Spans[1][0] != (models.CellSpan{}) {
+		t.Errorf("Spans = %v, want the reported spans kept", got.Spans)
+	}
+	if len(table.Spans[3]) != 2 {
+		t.Error("input spans were modified")
+	}
+}
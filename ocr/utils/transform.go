@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"image"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+// CropTransform records a crop to rect, given in the coordinates of the
+// image it was taken from.
+func CropTransform(rect image.Rectangle) models.ImageTransform {
+	return models.ImageTransform{
+		Type:   models.TransformCrop,
+		Width:  rect.Dx(),
+		Height: rect.Dy(),
+		X:      float64(rect.Min.X),
+		Y:      float64(rect.Min.Y),
+	}
+}
+
+// scaleTransform records resizing a width x height image to
+// newWidth x newHeight. No preprocessing step resizes the image sent to the
+// model yet; MapToOriginal handles scale transforms for when one does.
+func scaleTransform(width, height, newWidth, newHeight int) models.ImageTransform {
+	t := models.ImageTransform{Type: models.TransformScale, Width: newWidth, Height: newHeight}
+	if width > 0 && height > 0 {
+		t.ScaleX = float64(newWidth) / float64(width)
+		t.ScaleY = float64(newHeight) / float64(height)
+	}
+	return t
+}
+
+// RotateTransform records a clockwise rotation by degrees, a multiple of 90,
+// of a width x height image.
+func RotateTransform(degrees, width, height int) models.ImageTransform {
+	degrees = ((degrees % 360) + 360) % 360
+	t := models.ImageTransform{Type: models.TransformRotate, Width: width, Height: height, Degrees: degrees}
+	if degrees == 90 || degrees == 270 {
+		t.Width, t.Height = height, width
+	}
+	return t
+}
+
+// TransformedSize returns the size of the image after all transforms, or
+// width x height if there are none.
+func TransformedSize(transforms []models.ImageTransform, width, height int) (int, int) {
+	if len(transforms) == 0 {
+		return width, height
+	}
+	last := transforms[len(transforms)-1]
+	return last.Width, last.Height
+}
+
+// MapToOriginal maps a pixel bounding box found in the transformed image back
+// through transforms, applied in order, to the coordinates of the original
+// image.
+func MapToOriginal(b models.BoundingBox, transforms []models.ImageTransform) models.BoundingBox {
+	for i := len(transforms) - 1; i >= 0; i-- {
+		b = invertTransform(b, transforms[i])
+	}
+	return b
+}
+
+// invertTransform maps a pixel box from the output of t to its input.
+func invertTransform(b models.BoundingBox, t models.ImageTransform) models.BoundingBox {
+	switch t.Type {
+	case models.TransformCrop:
+		b.X += t.X
+		b.Y += t.Y
+	case models.TransformScale:
+		if t.ScaleX > 0 && t.ScaleY > 0 {
+			b = models.BoundingBox{X: b.X / t.ScaleX, Y: b.Y / t.ScaleY, Width: b.Width / t.ScaleX, Height: b.Height / t.ScaleY}
+		}
+	case models.TransformRotate:
+		w, h := float64(t.Width), float64(t.Height)
+		switch t.Degrees {
+		case 90:
+			b = models.BoundingBox{X: b.Y, Y: w - b.X - b.Width, Width: b.Height, Height: b.Width}
+		case 180:
+			b = models.BoundingBox{X: w - b.X - b.Width, Y: h - b.Y - b.Height, Width: b.Width, Height: b.Height}
+		case 270:
+			b = models.BoundingBox{X: h - b.Y - b.Height, Y: b.X, Width: b.Height, Height: b.Width}
+		}
+	}
+	return b
+}
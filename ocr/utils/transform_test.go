@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/sudhanshushekhar/ocr-go-prototype/ocr/models"
+)
+
+func boxesEqual(a, b models.BoundingBox) bool {
+	const eps = 1e-9
+	return math.Abs(a.X-b.X) < eps && math.Abs(a.Y-b.Y) < eps &&
+		math.Abs(a.Width-b.Width) < eps && math.Abs(a.Height-b.Height) < eps
+}
+
+func TestMapToOriginal(t *testing.T) {
+	tests := []struct {
+		name       string
+		transforms []models.ImageTransform
+		box        models.BoundingBox
+		want       models.BoundingBox
+	}{
+		{
+			"no transforms",
+			nil,
+			models.BoundingBox{X: 1, Y: 2, Width: 3, Height: 4},
+			models.BoundingBox{X: 1, Y: 2, Width: 3, Height: 4},
+		},
+		{
+			"crop",
+			[]models.ImageTransform{CropTransform(image.Rect(100, 200, 300, 400))},
+			models.BoundingBox{X: 10, Y: 20, Width: 30, Height: 40},
+			models.BoundingBox{X: 110, Y: 220, Width: 30, Height: 40},
+		},
+		{
+			"downscale",
+			[]models.ImageTransform{scaleTransform(2000, 1000, 1000, 500)},
+			models.BoundingBox{X: 10, Y: 20, Width: 30, Height: 40},
+			models.BoundingBox{X: 20, Y: 40, Width: 60, Height: 80},
+		},
+		{
+			"rotate 180",
+			[]models.ImageTransform{RotateTransform(180, 100, 50)},
+			models.BoundingBox{X: 60, Y: 20, Width: 10, Height: 5},
+			models.BoundingBox{X: 30, Y: 25, Width: 10, Height: 5},
+		},
+		{
+			"rotate 90",
+			[]models.ImageTransform{RotateTransform(90, 100, 50)},
+			models.BoundingBox{X: 10, Y: 30, Width: 5, Height: 20},
+			models.BoundingBox{X: 30, Y: 35, Width: 20, Height: 5},
+		},
+		{
+			"rotate 270",
+			[]models.ImageTransform{RotateTransform(-90, 100, 50)},
+			models.BoundingBox{X: 35, Y: 30, Width: 5, Height: 20},
+			models.BoundingBox{X: 50, Y: 35, Width: 20, Height: 5},
+		},
+		{
+			// A 2000x1000 scan is downscaled to 1000x500, then the region
+			// (100,50)-(300,250) of the small image is cropped.
+			"downscale then crop",
+			[]models.ImageTransform{
+				scaleTransform(2000, 1000, 1000, 500),
+				CropTransform(image.Rect(100, 50, 300, 250)),
+			},
+			models.BoundingBox{X: 10, Y: 20, Width: 30, Height: 40},
+			models.BoundingBox{X: 220, Y: 140, Width: 60, Height: 80},
+		},
+		{
+			// The region (200,100)-(600,500) of a 2000x1000 scan is
+			// cropped, then the 400x400 crop is downscaled to 200x200.
+			"crop then downscale",
+			[]models.ImageTransform{
+				CropTransform(image.Rect(200, 100, 600, 500)),
+				scaleTransform(400, 400, 200, 200),
+			},
+			models.BoundingBox{X: 10, Y: 20, Width: 30, Height: 40},
+			models.BoundingBox{X: 220, Y: 140, Width: 60, Height: 80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MapToOriginal(tt.box, tt.transforms); !boxesEqual(got, tt.want) {
+				t.Errorf("MapToOriginal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateTransform_Size(t *testing.T) {
+	tests := []struct {
+		degrees      int
+		wantW, wantH int
+		wantDegrees  int
+	}{
+		{90, 50, 100, 90},
+		{180, 100, 50, 180},
+		{270, 50, 100, 270},
+		{-90, 50, 100, 270},
+		{360, 100, 50, 0},
+	}
+	for _, tt := range tests {
+		got := RotateTransform(tt.degrees, 100, 50)
+		if got.Width != tt.wantW || got.Height != tt.wantH || got.Degrees != tt.wantDegrees {
+			t.Errorf("RotateTransform(%d) = %dx%d at %d°, want %dx%d at %d°",
+				tt.degrees, got.Width, got.Height, got.Degrees, tt.wantW, tt.wantH, tt.wantDegrees)
+		}
+	}
+}
+
+func TestTransformedSize(t *testing.T) {
+	if w, h := TransformedSize(nil, 100, 50); w != 100 || h != 50 {
+		t.Errorf("TransformedSize(nil) = %dx%d, want 100x50", w, h)
+	}
+	transforms := []models.ImageTransform{
+		scaleTransform(100, 50, 50, 25),
+		CropTransform(image.Rect(5, 5, 25, 15)),
+	}
+	if w, h := TransformedSize(transforms, 100, 50); w != 20 || h != 10 {
+		t.Errorf("TransformedSize = %dx%d, want 20x10", w, h)
+	}
+}
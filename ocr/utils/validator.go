@@ -25,25 +25,54 @@ var ValidColorModes = map[models.ColorMode]bool{
 	models.ColorModeUnknown:   true,
 }
 
+// ValidationOption relaxes a check of ValidateOCRResult.
+type ValidationOption func(*validation)
+
+// validation holds the checks relaxed by ValidationOptions.
+type validation struct {
+	freeformDocumentType bool
+}
+
+// AllowFreeformDocumentType accepts any non-empty document type instead of
+// only the ValidDocumentTypes.
+func AllowFreeformDocumentType() ValidationOption {
+	return func(v *validation) {
+		v.freeformDocumentType = true
+	}
+}
+
 // ValidateOCRResult validates that an OCRResult conforms to the strict schema.
-func ValidateOCRResult(result *models.OCRResult) error {
+func ValidateOCRResult(result *models.OCRResult, opts ...ValidationOption) error {
+	var v validation
+	for _, opt := range opts {
+		opt(&v)
+	}
+
 	if result == nil {
 		return fmt.Errorf("result is nil")
 	}
 
 	// Validate source
-	if result.Source.Type != models.SourceTypeFile && result.Source.Type != models.SourceTypeURL {
+	switch result.Source.Type {
+	case models.SourceTypeFile, models.SourceTypeURL:
+		if result.Source.Path == "" {
+			return fmt.Errorf("source path is empty")
+		}
+	case models.SourceTypeBytes:
+		// In-memory sources have no path
+	default:
 		return fmt.Errorf("invalid source type: %q", result.Source.Type)
 	}
-	if result.Source.Path == "" {
-		return fmt.Errorf("source path is empty")
-	}
 	if result.Source.Checksum == "" {
 		return fmt.Errorf("source checksum is empty")
 	}
 
 	// Validate metadata
-	if !ValidDocumentTypes[result.Metadata.DocumentType] {
+	if v.freeformDocumentType {
+		if strings.TrimSpace(string(result.Metadata.DocumentType)) == "" {
+			return fmt.Errorf("document type is empty")
+		}
+	} else if !ValidDocumentTypes[result.Metadata.DocumentType] {
 		return fmt.Errorf("invalid document type: %q", result.Metadata.DocumentType)
 	}
 	if result.Metadata.ConfidenceScore < 0 || result.Metadata.ConfidenceScore > 1 {
@@ -89,6 +118,27 @@ func ParseAndValidateJSON(raw string) (*models.OllamaVisionResponse, error) {
 	return &resp, nil
 }
 
+// ParseRawJSON unmarshals raw into a generic map after CleanJSONResponse, so
+// fields outside the schema are kept. It also returns a best-effort
+// OllamaVisionResponse in which fields that do not match the schema are left
+// unset instead of failing the parse.
+func ParseRawJSON(raw string) (map[string]any, *models.OllamaVisionResponse, error) {
+	cleaned := []byte(CleanJSONResponse(raw))
+
+	var data map[string]any
+	if err := json.Unmarshal(cleaned, &data); err != nil {
+		return nil, nil, fmt.Errorf("json unmarshal: %w", err)
+	}
+	if data == nil {
+		return nil, nil, fmt.Errorf("json unmarshal: top-level value is null")
+	}
+
+	var resp models.OllamaVisionResponse
+	_ = json.Unmarshal(cleaned, &resp)
+
+	return data, &resp, nil
+}
+
 // CleanJSONResponse strips markdown code fences and extraneous text from model output,
 // extracting only the JSON object.
 func CleanJSONResponse(raw string) string {
@@ -69,6 +69,16 @@ func TestValidateOCRResult_EmptyPath(t *testing.T) {
 	}
 }
 
+func TestValidateOCRResult_BytesSourceWithoutPath(t *testing.T) {
+	result := validResult()
+	result.Source.Type = models.SourceTypeBytes
+	result.Source.Path = ""
+
+	if err := ValidateOCRResult(result); err != nil {
+		t.Fatalf("in-memory source without path should be valid: %v", err)
+	}
+}
+
 func TestValidateOCRResult_EmptyChecksum(t *testing.T) {
 	result := validResult()
 	result.Source.Checksum = ""
@@ -87,6 +97,33 @@ func TestValidateOCRResult_InvalidDocumentType(t *testing.T) {
 	}
 }
 
+func TestValidateOCRResult_FreeformDocumentType(t *testing.T) {
+	tests := []struct {
+		docType models.DocumentType
+		wantErr bool
+	}{
+		{"bank_statement", false},
+		{models.DocumentTypeInvoice, false},
+		{models.DocumentTypeUnknown, false},
+		{"", true},
+		{"  ", true},
+	}
+	for _, tt := range tests {
+		result := validResult()
+		result.Metadata.DocumentType = tt.docType
+		if err := ValidateOCRResult(result, AllowFreeformDocumentType()); (err != nil) != tt.wantErr {
+			t.Errorf("document type %q: err = %v, wantErr %v", tt.docType, err, tt.wantErr)
+		}
+	}
+
+	// The strict enum remains the default
+	result := validResult()
+	result.Metadata.DocumentType = "bank_statement"
+	if err := ValidateOCRResult(result); err == nil {
+		t.Error("expected error for a freeform document type in strict mode")
+	}
+}
+
 func TestValidateOCRResult_ConfidenceOutOfRange(t *testing.T) {
 	result := validResult()
 	result.Metadata.ConfidenceScore = 1.5
@@ -287,3 +324,26 @@ func validResult() *models.OCRResult {
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestParseRawJSON(t *testing.T) {
+	input := "```json\n{\"metadata\":{\"document_type\":\"invoice\",\"confidence_score\":0.7},\"text\":{\"raw\":42},\"extra\":[1,2]}\n```"
+
+	data, resp, err := ParseRawJSON(input)
+	if err != nil {
+		t.Fatalf("ParseRawJSON: %v", err)
+	}
+	if extra, ok := data["extra"].([]any); !ok || len(extra) != 2 {
+		t.Errorf("data[extra] = %v, want the unknown field kept", data["extra"])
+	}
+	if resp.Metadata == nil || resp.Metadata.DocumentType != "invoice" {
+		t.Errorf("Metadata = %+v, want the fields that fit the schema", resp.Metadata)
+	}
+}
+
+func TestParseRawJSON_Invalid(t *testing.T) {
+	for _, input := range []string{"not json", "null", "[1,2]"} {
+		if _, _, err := ParseRawJSON(input); err == nil {
+			t.Errorf("ParseRawJSON(%q) should fail", input)
+		}
+	}
+}